@@ -0,0 +1,184 @@
+// authzctl checks Casbin policies before they are deployed.
+//
+//	authzctl test -suite casbin/policy_suite.yaml
+//	authzctl lint -policy policy.csv -skip empty-domain
+//
+// Both commands exit with a non-zero status when a case fails or an issue is found.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+const usage = `Usage: authzctl <command> [flags]
+
+Commands:
+  test   Run a YAML suite of expected allow/deny cases against a policy file
+  lint   Check a policy file for duplicate rules, unknown roles, domains without
+         members and unreachable rules
+
+Run "authzctl <command> -h" for the flags of a command.
+`
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var ok bool
+	switch os.Args[1] {
+	case "test":
+		ok = runTest(os.Args[2:])
+	case "lint":
+		ok = runLint(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// runTest runs a test suite and reports whether every case passed
+func runTest(args []string) bool {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	modelFile := flags.String("model", "casbin/model.conf", "Path to the Casbin model")
+	suiteFile := flags.String("suite", "casbin/policy_suite.yaml", "Path to the YAML test suite")
+	policyFile := flags.String("policy", "", "Path to the policy file, overriding the one of the suite")
+	verbose := flags.Bool("v", false, "Print passing cases too")
+	flags.Parse(args)
+
+	suite, err := LoadSuite(*suiteFile)
+	if err != nil {
+		log.Fatalf("Failed to load test suite: %v", err)
+	}
+	if *policyFile != "" {
+		suite.Policy = *policyFile
+	}
+	if suite.Policy == "" {
+		log.Fatalf("No policy file given by the suite or the -policy flag")
+	}
+
+	casbinService, err := loadPolicy(*modelFile, suite.Policy)
+	if err != nil {
+		log.Fatalf("Failed to load policy: %v", err)
+	}
+
+	fixtures, err := suite.FixtureRules()
+	if err != nil {
+		log.Fatalf("Failed to parse suite rules: %v", err)
+	}
+	diff := auth.PolicyDiff{Added: casbinService.NormalizePolicies(fixtures)}
+	if err := casbinService.ApplyPolicyDiff(context.Background(), entity.PolicyActionImport, diff); err != nil {
+		log.Fatalf("Failed to add suite rules: %v", err)
+	}
+
+	failed := 0
+	for _, result := range suite.Run(casbinService) {
+		if !result.Passed() {
+			failed++
+		}
+		if !result.Passed() || *verbose {
+			fmt.Println(result)
+		}
+	}
+
+	fmt.Printf("%d cases, %d passed, %d failed\n", len(suite.Cases), len(suite.Cases)-failed, failed)
+	return failed == 0
+}
+
+// runLint lints a policy file and reports whether it is free of issues
+func runLint(args []string) bool {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	modelFile := flags.String("model", "casbin/model.conf", "Path to the Casbin model")
+	policyFile := flags.String("policy", "casbin/policy.csv", "Path to the policy file")
+	skip := flags.String("skip", "", "Comma separated checks to skip: "+strings.Join(auth.LintChecks, ", "))
+	flags.Parse(args)
+
+	skipped := make(map[string]bool)
+	for _, check := range strings.Split(*skip, ",") {
+		if check = strings.TrimSpace(check); check == "" {
+			continue
+		}
+		if !isLintCheck(check) {
+			log.Fatalf("Unknown lint check %q", check)
+		}
+		skipped[check] = true
+	}
+
+	casbinService, err := loadPolicy(*modelFile, *policyFile)
+	if err != nil {
+		log.Fatalf("Failed to load policy: %v", err)
+	}
+
+	// Lint the rules as written, since loading them drops duplicates
+	rules, err := readPolicyFile(*policyFile)
+	if err != nil {
+		log.Fatalf("Failed to read policy: %v", err)
+	}
+
+	issues, err := casbinService.LintPolicies(rules)
+	if err != nil {
+		log.Fatalf("Failed to lint policy: %v", err)
+	}
+
+	reported := 0
+	for _, issue := range issues {
+		if skipped[issue.Check] {
+			continue
+		}
+		fmt.Println(issue)
+		reported++
+	}
+
+	fmt.Printf("%d rules, %d issues\n", len(rules), reported)
+	return reported == 0
+}
+
+// loadPolicy creates an in-memory Casbin service holding the rules of a policy file
+func loadPolicy(modelFile, policyFile string) (*auth.CasbinService, error) {
+	cfg := &config.Config{Casbin: config.CasbinConfig{ModelPath: modelFile}}
+	return auth.NewCasbinServiceFromPolicyFile(cfg, policyFile)
+}
+
+// readPolicyFile parses the rules of a policy file
+func readPolicyFile(path string) ([]entity.PolicyRule, error) {
+	format, err := auth.PolicyFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.ParsePolicies(data, format)
+}
+
+// isLintCheck reports whether a name is one of the lint checks
+func isLintCheck(name string) bool {
+	for _, check := range auth.LintChecks {
+		if check == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// Expected decisions of a test case
+const (
+	expectAllow = "allow"
+	expectDeny  = "deny"
+)
+
+// Suite is a set of authorization cases run against a policy, e.g.
+//
+//	policy: policy.csv
+//	rules:
+//	  - g, alice, user, default
+//	cases:
+//	  - name: users can update their own profile
+//	    subject: alice
+//	    domain: default
+//	    object: /v1/users/1
+//	    action: PUT
+//	    attributes: {subject_id: "1", owner_id: "1"}
+//	    expect: allow
+type Suite struct {
+	// Policy is the policy file the cases run against, relative to the suite file
+	Policy string `yaml:"policy"`
+	// Rules are added to the policy before running the cases, typically role assignments
+	Rules []string `yaml:"rules"`
+	Cases []Case   `yaml:"cases"`
+}
+
+// Case is an authorization request and its expected decision
+type Case struct {
+	Name       string     `yaml:"name"`
+	Subject    string     `yaml:"subject"`
+	Domain     string     `yaml:"domain"`
+	Object     string     `yaml:"object"`
+	Action     string     `yaml:"action"`
+	Attributes Attributes `yaml:"attributes"`
+	// At is the time of the request, now when empty
+	At     string `yaml:"at"`
+	Expect string `yaml:"expect"`
+}
+
+// Attributes are the request attributes conditional rules are evaluated against
+type Attributes struct {
+	SubjectID string `yaml:"subject_id"`
+	OwnerID   string `yaml:"owner_id"`
+	OwnerRole string `yaml:"owner_role"`
+	IP        string `yaml:"ip"`
+}
+
+// CaseResult is the outcome of a test case
+type CaseResult struct {
+	Case    Case
+	Allowed bool
+	Err     error
+}
+
+// Passed reports whether the case got its expected decision
+func (r CaseResult) Passed() bool {
+	return r.Err == nil && r.Allowed == (r.Case.Expect == expectAllow)
+}
+
+// String returns the result as a single line
+func (r CaseResult) String() string {
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+
+	line := fmt.Sprintf("%s %s (%s, %s, %s, %s)", status, r.Case.Name, r.Case.Subject, r.Case.Domain, r.Case.Object, r.Case.Action)
+	switch {
+	case r.Err != nil:
+		line += ": " + r.Err.Error()
+	case !r.Passed():
+		line += fmt.Sprintf(": expected %s, got %s", r.Case.Expect, decision(r.Allowed))
+	}
+	return line
+}
+
+// LoadSuite reads a suite file, resolving its policy file relative to it
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if suite.Policy != "" && !filepath.IsAbs(suite.Policy) {
+		suite.Policy = filepath.Join(filepath.Dir(path), suite.Policy)
+	}
+
+	for i, c := range suite.Cases {
+		if c.Name == "" {
+			return nil, fmt.Errorf("case %d: name is required", i+1)
+		}
+		if c.Expect != expectAllow && c.Expect != expectDeny {
+			return nil, fmt.Errorf("case %q: expect must be %s or %s", c.Name, expectAllow, expectDeny)
+		}
+		if c.At != "" {
+			if _, err := time.Parse(time.RFC3339, c.At); err != nil {
+				return nil, fmt.Errorf("case %q: invalid time: %w", c.Name, err)
+			}
+		}
+	}
+
+	return &suite, nil
+}
+
+// FixtureRules parses the rules the suite adds to the policy
+func (s *Suite) FixtureRules() ([]entity.PolicyRule, error) {
+	return auth.ParsePolicies([]byte(strings.Join(s.Rules, "\n")), auth.PolicyFormatCSV)
+}
+
+// Run evaluates every case of the suite
+func (s *Suite) Run(casbinService *auth.CasbinService) []CaseResult {
+	results := make([]CaseResult, len(s.Cases))
+	for i, c := range s.Cases {
+		at := time.Now()
+		if c.At != "" {
+			at, _ = time.Parse(time.RFC3339, c.At)
+		}
+
+		attrs := auth.NewRequestAttributes(c.Attributes.SubjectID, c.Attributes.OwnerID, c.Attributes.OwnerRole, c.Attributes.IP, at)
+		allowed, err := casbinService.Enforce(c.Subject, c.Domain, c.Object, c.Action, attrs)
+		results[i] = CaseResult{Case: c, Allowed: allowed, Err: err}
+	}
+	return results
+}
+
+// decision returns the name of a decision
+func decision(allowed bool) string {
+	if allowed {
+		return expectAllow
+	}
+	return expectDeny
+}
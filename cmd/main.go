@@ -5,20 +5,28 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
+	_ "time/tzdata" // Embed time zones, for images without them
 
 	_ "github.com/hinha/echo-casbin-ddd-app/docs" // Import Swagger docs
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/usecase"
 	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/mail"
 	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/storage"
 	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/handler"
 	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/jobs"
 	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/websocket"
 	"github.com/labstack/echo/v4"
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
@@ -35,6 +43,10 @@ import (
 func main() {
 	// Define command line flags
 	migrateFlag := flag.Bool("migrate", false, "Run database migrations")
+	exportPolicyFlag := flag.String("export-policy", "", "Export all Casbin policies to a .csv or .yaml file and exit")
+	importPolicyFlag := flag.String("import-policy", "", "Import Casbin policies from a .csv or .yaml file and exit")
+	replacePolicyFlag := flag.Bool("replace-policy", false, "With --import-policy, remove rules missing from the file")
+	dryRunFlag := flag.Bool("dry-run", false, "With --import-policy, only show what would be added and removed")
 	flag.Parse()
 
 	// Load configuration
@@ -58,54 +70,167 @@ func main() {
 	// Initialize repositories
 	userRepo := persistence.NewUserRepository(db.DB)
 	apiClientRepo := persistence.NewAPIClientRepository(db.DB)
-
-	// Initialize and run seeder
-	if *migrateFlag {
-		seeder := persistence.NewSeeder(cfg, userRepo)
-		if err := seeder.Seed(context.Background()); err != nil {
-			log.Fatalf("Failed to seed database: %v", err)
-		}
-		log.Println("Database seeding users completed successfully")
-	}
+	policyVersionRepo := persistence.NewPolicyVersionRepository(db.DB)
+	roleGrantRepo := persistence.NewRoleGrantRepository(db.DB)
+	userImportRepo := persistence.NewUserImportRepository(db.DB)
+	invitationRepo := persistence.NewInvitationRepository(db.DB)
+	userAttributeRepo := persistence.NewUserAttributeRepository(db.DB)
+	groupRepo := persistence.NewGroupRepository(db.DB)
+	erasureRequestRepo := persistence.NewErasureRequestRepository(db.DB)
+	personalDataRepo := persistence.NewPersonalDataRepository(db.DB)
 
 	// Initialize auth services
-	jwtService := auth.NewJWTService(cfg)
+	jwtService := auth.NewJWTService(cfg, userRepo)
 	apiKeyService := auth.NewAPIKeyService(cfg, apiClientRepo)
-	casbinService, err := auth.NewCasbinService(db.DB, cfg)
+	casbinService, err := auth.NewCasbinService(db.DB, cfg, policyVersionRepo)
 	if err != nil {
 		log.Fatalf("Failed to initialize Casbin service: %v", err)
 	}
 
+	// Initialize the mailer
+	mailer, err := mail.NewMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize the blob store holding avatars
+	blobStore, err := storage.NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+
+	// Decide who may register
+	registrationPolicy, err := entity.NewRegistrationPolicy(cfg.Users.RegistrationMode, cfg.Users.RegistrationAllowedDomains, cfg.Users.DefaultRole)
+	if err != nil {
+		log.Fatalf("Invalid registration configuration: %v", err)
+	}
+
+	// Migrate the stored policies, then initialize and run seeder
+	if *migrateFlag {
+		if err := casbinService.MigratePolicies(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate policies: %v", err)
+		}
+
+		seeder := persistence.NewSeeder(cfg, userRepo, casbinService)
+		if err := seeder.Seed(context.Background()); err != nil {
+			log.Fatalf("Failed to seed database: %v", err)
+		}
+		log.Println("Database seeding completed successfully")
+	}
+
 	// Initialize use cases
-	userUseCase := usecase.NewUserUseCase(userRepo, jwtService, casbinService)
-	apiClientUseCase := usecase.NewAPIClientUseCase(apiClientRepo, casbinService)
+	userUseCase := usecase.NewUserUseCase(userRepo, userAttributeRepo, roleGrantRepo, jwtService, casbinService, mailer, registrationPolicy, blobStore, cfg.Users.AvatarSize, cfg.Users.EmailChangeExpiration, cfg.Users.EmailChangeURL)
+	apiClientUseCase := usecase.NewAPIClientUseCase(apiClientRepo, casbinService, cfg.APIClients.TrashRetention)
+	authzUseCase := usecase.NewAuthzUseCase(casbinService, cfg.Authz.BatchMaxSize, cfg.Authz.BatchTimeout)
+	policyUseCase := usecase.NewPolicyUseCase(casbinService, policyVersionRepo)
+	roleGrantUseCase := usecase.NewRoleGrantUseCase(roleGrantRepo, userRepo, casbinService, cfg.Roles.ApprovalRequired)
+	userImportUseCase := usecase.NewUserImportUseCase(userImportRepo, userRepo, casbinService)
+	invitationUseCase := usecase.NewInvitationUseCase(invitationRepo, userRepo, jwtService, casbinService, mailer, registrationPolicy, cfg.Users.InvitationExpiration, cfg.Users.InvitationURL)
+	userAttributeUseCase := usecase.NewUserAttributeUseCase(userAttributeRepo, userRepo)
+	groupUseCase := usecase.NewGroupUseCase(groupRepo, userRepo, casbinService)
+	privacyUseCase := usecase.NewPrivacyUseCase(userRepo, erasureRequestRepo, personalDataRepo, roleGrantRepo, casbinService, blobStore, cfg.Users.ErasureDelay)
+
+	// Run policy import/export commands and exit
+	if *exportPolicyFlag != "" {
+		exportPolicies(policyUseCase, *exportPolicyFlag)
+		casbinService.Close()
+		return
+	}
+	if *importPolicyFlag != "" {
+		importPolicies(policyUseCase, *importPolicyFlag, *replacePolicyFlag, *dryRunFlag)
+		casbinService.Close()
+		return
+	}
 
 	// Initialize Echo
 	e := echo.New()
+	e.Validator = handler.NewRequestValidator()
 
 	// Middleware
 	e.Use(echoMiddleware.Logger())
 	e.Use(echoMiddleware.Recover())
 	e.Use(echoMiddleware.CORS())
 
-	// Initialize handlers
-	userHandler := handler.NewUserHandler(userUseCase)
+	// Initialize handlers, recording their routes in the catalog
+	routeCatalog := middleware.NewRouteCatalog()
+	userHandler := handler.NewUserHandler(userUseCase, cfg.Users.AvatarMaxSize)
 	apiClientHandler := handler.NewAPIClientHandler(apiClientUseCase)
+	authzHandler := handler.NewAuthzHandler(authzUseCase, routeCatalog)
+	policyHandler := handler.NewPolicyHandler(policyUseCase)
+	roleGrantHandler := handler.NewRoleGrantHandler(roleGrantUseCase)
+	userImportHandler := handler.NewUserImportHandler(userImportUseCase, userUseCase)
+	invitationHandler := handler.NewInvitationHandler(invitationUseCase)
+	userAttributeHandler := handler.NewUserAttributeHandler(userAttributeUseCase)
+	groupHandler := handler.NewGroupHandler(groupUseCase)
+	privacyHandler := handler.NewPrivacyHandler(privacyUseCase)
 
 	// Initialize WebSocket handler
 	userWSHandler := websocket.NewUserWSHandler(userUseCase)
 	userWSHandler.Start()
 
+	// Activate and expire role grants in the background
+	roleGrantReaper := jobs.NewRoleGrantReaper(roleGrantUseCase, cfg.Roles.GrantReaperInterval)
+	roleGrantReaper.Start()
+
+	// Purge deleted API clients past their retention in the background
+	apiClientPurger := jobs.NewAPIClientPurger(apiClientUseCase, cfg.APIClients.PurgeInterval)
+	apiClientPurger.Start()
+
+	// Process queued user imports in the background
+	userImporter := jobs.NewUserImporter(userImportUseCase, cfg.Users.ImportInterval)
+	userImporter.Start()
+
+	// Erase the users whose erasure requests are due in the background
+	userEraser := jobs.NewUserEraser(privacyUseCase, cfg.Users.ErasureInterval)
+	userEraser.Start()
+
 	// Register routes
-	jwtMiddleware := middleware.JWTMiddleware(cfg)
+	jwtMiddleware := middleware.JWTMiddleware(jwtService)
 	apiKeyMiddleware := middleware.APIKeyMiddleware(cfg, apiKeyService)
-	casbinMiddleware := middleware.CasbinMiddleware(casbinService)
+	resourceLoaders := middleware.ResourceLoaders{}
+	userHandler.RegisterResourceLoaders(resourceLoaders)
+	privacyHandler.RegisterResourceLoaders(resourceLoaders)
+	casbinMiddleware := middleware.CasbinMiddleware(casbinService, resourceLoaders)
+	authenticateMiddleware := middleware.AuthenticateMiddleware(cfg, jwtService, apiKeyService)
 
-	// User routes with JWT authentication
-	userHandler.RegisterRoutes(e, jwtMiddleware)
+	// User routes with JWT authentication and Casbin authorization
+	userHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
 
 	// API client routes with API key authentication and admin authorization
-	apiClientHandler.RegisterRoutes(e, apiKeyMiddleware, casbinMiddleware)
+	apiClientHandler.RegisterRoutes(e, routeCatalog, apiKeyMiddleware, casbinMiddleware)
+
+	// Permission introspection routes for either users or API clients
+	authzHandler.RegisterRoutes(e, routeCatalog, authenticateMiddleware)
+
+	// Authorization decision routes for API clients
+	authzHandler.RegisterCheckRoutes(e, routeCatalog, apiKeyMiddleware, casbinMiddleware)
+
+	// Route catalog routes with JWT authentication and Casbin authorization
+	authzHandler.RegisterCatalogRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Policy management routes with JWT authentication and Casbin authorization
+	policyHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Role grant routes with JWT authentication and Casbin authorization
+	roleGrantHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// User import and export routes with JWT authentication and Casbin authorization
+	userImportHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Invitation routes with JWT authentication and Casbin authorization, except accepting one
+	invitationHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Custom user attribute routes with JWT authentication and Casbin authorization
+	userAttributeHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Group routes with JWT authentication and Casbin authorization
+	groupHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Data export and erasure routes with JWT authentication and Casbin authorization
+	privacyHandler.RegisterRoutes(e, routeCatalog, jwtMiddleware, casbinMiddleware)
+
+	// Warn about routes no policy allows anyone to use
+	warnUngrantedRoutes(routeCatalog, casbinService)
 
 	// Serve static files
 	e.Static("/", "web")
@@ -136,6 +261,21 @@ func main() {
 	// Stop WebSocket handler
 	userWSHandler.Stop()
 
+	// Stop the role grant reaper
+	roleGrantReaper.Stop()
+
+	// Stop the API client purger
+	apiClientPurger.Stop()
+
+	// Stop the user importer, queuing the import in progress again
+	userImporter.Stop()
+
+	// Stop the user eraser
+	userEraser.Stop()
+
+	// Stop policy synchronization
+	casbinService.Close()
+
 	if err := e.Shutdown(ctx); err != nil {
 		log.Fatalf("Failed to gracefully shut down server: %v", err)
 	}
@@ -151,3 +291,73 @@ func main() {
 
 	log.Println("Server stopped")
 }
+
+// warnUngrantedRoutes logs the routes authorized by Casbin that no policy rule grants
+func warnUngrantedRoutes(routeCatalog *middleware.RouteCatalog, casbinService *auth.CasbinService) {
+	routes, err := routeCatalog.Ungranted(casbinService)
+	if err != nil {
+		log.Printf("Warning: Failed to check route permissions: %v", err)
+		return
+	}
+
+	for _, route := range routes {
+		log.Printf("Warning: No policy grants %s %s (%s) in domain %q", route.Method, route.Path, route.Permission, route.Domain)
+	}
+}
+
+// exportPolicies writes all policies to a file, inferring the format from its extension
+func exportPolicies(policyUseCase interfaces.PolicyUseCase, path string) {
+	format, err := auth.PolicyFormatFromPath(path)
+	if err != nil {
+		log.Fatalf("Failed to export policies: %v", err)
+	}
+
+	output, err := policyUseCase.Export(context.Background(), dto.ExportPoliciesInput{Format: format})
+	if err != nil {
+		log.Fatalf("Failed to export policies: %v", err)
+	}
+
+	if err := os.WriteFile(path, output.Data, 0o644); err != nil {
+		log.Fatalf("Failed to write policy file: %v", err)
+	}
+
+	log.Printf("Exported %d policy rules to %s", output.Count, path)
+}
+
+// importPolicies imports policies from a file and prints the changes
+func importPolicies(policyUseCase interfaces.PolicyUseCase, path string, replace, dryRun bool) {
+	format, err := auth.PolicyFormatFromPath(path)
+	if err != nil {
+		log.Fatalf("Failed to import policies: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read policy file: %v", err)
+	}
+
+	input := dto.ImportPoliciesInput{
+		Data:    data,
+		Format:  format,
+		Replace: replace,
+		DryRun:  dryRun,
+	}
+
+	output, err := policyUseCase.Import(context.Background(), input)
+	if err != nil {
+		log.Fatalf("Failed to import policies: %v", err)
+	}
+
+	for _, rule := range output.Added {
+		fmt.Printf("+ %s\n", rule)
+	}
+	for _, rule := range output.Removed {
+		fmt.Printf("- %s\n", rule)
+	}
+
+	if dryRun {
+		log.Printf("Dry run: %d rules would be added, %d removed", len(output.Added), len(output.Removed))
+		return
+	}
+	log.Printf("Imported policies: %d rules added, %d removed", len(output.Added), len(output.Removed))
+}
@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 )
 
@@ -32,6 +34,24 @@ type UpdateAPIClientInput struct {
 	ID          uint
 	Name        string
 	Description string
+	// Version is the version of the API client the update is based on, 0 for whichever is current
+	Version uint
+}
+
+// PatchAPIClientInput represents the input for patching an API client with a JSON merge patch (RFC 7396)
+type PatchAPIClientInput struct {
+	ID uint
+	// Patch replaces the fields of the API client it has members for, null removing optional fields
+	Patch map[string]any
+	// Version is the version of the API client the patch is based on, 0 for whichever is current
+	Version uint
+}
+
+// PatchAPIClientOutput represents the output for patching an API client
+type PatchAPIClientOutput struct {
+	APIClient *entity.APIClient
+	// Changes are the fields the patch changed, none when it left the API client as is
+	Changes []entity.FieldChange
 }
 
 // RegenerateAPIKeyInput represents the input for regenerating an API key
@@ -48,16 +68,51 @@ type SetAPIClientActiveInput struct {
 // DeleteAPIClientInput represents the input for deleting an API client
 type DeleteAPIClientInput struct {
 	ID uint
+	// Version is the version of the API client the deletion is based on, 0 for whichever is current
+	Version uint
 }
 
-// ListAPIClientsInput represents the input for listing API clients
+// ListAPIClientsInput represents the input for listing API clients. Zero filters are ignored.
 type ListAPIClientsInput struct {
-	Page  int
+	// After and Before are cursors returned by a previous listing, to list the API clients following or
+	// preceding it. Neither gives the first page.
+	After  string
+	Before string
+	// Limit is capped at repository.MaxPageLimit
 	Limit int
+	// SkipCount leaves the total count out
+	SkipCount bool
+
+	Active      *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Search matches API clients whose name contains it, ignoring case
+	Search string
+	// Sort is a comma separated list of columns, each prefixed with - for descending order
+	Sort string
+}
+
+// RestoreAPIClientInput represents the input for restoring a deleted API client
+type RestoreAPIClientInput struct {
+	ID uint
+}
+
+// PurgeAPIClientInput represents the input for permanently deleting a deleted API client
+type PurgeAPIClientInput struct {
+	ID uint
 }
 
 // ListAPIClientsOutput represents the output for listing API clients
 type ListAPIClientsOutput struct {
 	APIClients []*entity.APIClient
-	TotalCount int64
+	// Next and Prev are the cursors of the following and preceding pages, empty when there is none
+	Next string
+	Prev string
+	// TotalCount is nil when the count is skipped
+	TotalCount *int64
+}
+
+// PurgeExpiredAPIClientsOutput represents the output for purging the deleted API clients past their retention
+type PurgeExpiredAPIClientsOutput struct {
+	Purged int
 }
@@ -0,0 +1,71 @@
+package dto
+
+import "time"
+
+// Authorization DTOs
+
+// GetPermissionsInput represents the input for resolving a principal's effective permissions
+type GetPermissionsInput struct {
+	Subject      string
+	Domain       string
+	ObjectPrefix string
+}
+
+// Permission represents a single effective permission
+type Permission struct {
+	Subject string
+	Domain  string
+	Object  string
+	Action  string
+	// Condition is the expression the request must satisfy, empty when unconditional
+	Condition string
+	// Effect is either allow or deny
+	Effect string
+	// Priority decides between conflicting rules, the highest wins
+	Priority int
+}
+
+// GetPermissionsOutput represents the output for resolving a principal's effective permissions
+type GetPermissionsOutput struct {
+	Subject string
+	Domain  string
+	Roles   []string
+	// Groups are the names of the groups the subject is a member of
+	Groups      []string
+	Permissions []Permission
+}
+
+// CheckAttributes represents the request attributes conditional rules are evaluated against
+type CheckAttributes struct {
+	SubjectID string
+	OwnerID   string
+	OwnerRole string
+	IP        string
+}
+
+// Check represents a single authorization question
+type Check struct {
+	Subject    string
+	Domain     string
+	Object     string
+	Action     string
+	Attributes CheckAttributes
+}
+
+// Decision represents the answer to an authorization question
+type Decision struct {
+	Allowed bool
+	// Error explains why the check could not be evaluated, in which case it is denied
+	Error string
+}
+
+// BatchCheckInput represents the input for evaluating authorization questions in one call
+type BatchCheckInput struct {
+	Checks []Check
+}
+
+// BatchCheckOutput represents the decisions of a batch, in the order of its checks
+type BatchCheckOutput struct {
+	Decisions []Decision
+	Elapsed   time.Duration
+}
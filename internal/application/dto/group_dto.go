@@ -0,0 +1,74 @@
+package dto
+
+import "github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+
+// Group DTOs
+
+// CreateGroupInput represents the input for creating a group
+type CreateGroupInput struct {
+	Name        string
+	Description string
+	Domain      string
+}
+
+// UpdateGroupInput represents the input for updating a group
+type UpdateGroupInput struct {
+	ID          uint
+	Description string
+}
+
+// ListGroupsInput represents the input for listing groups
+type ListGroupsInput struct {
+	Domain string
+	Page   int
+	Limit  int
+}
+
+// GroupOutput represents a group along with the roles it holds
+type GroupOutput struct {
+	Group *entity.Group
+	// Roles are the roles assigned to the group, sorted
+	Roles []string
+}
+
+// ListGroupsOutput represents the output for listing groups
+type ListGroupsOutput struct {
+	Groups     []*GroupOutput
+	TotalCount int64
+}
+
+// GroupMember represents a member of a group
+type GroupMember struct {
+	Username string
+	// User is nil when no account goes by the username, e.g. once soft deleted
+	User *entity.User
+}
+
+// GroupMemberInput represents the input for adding a user to a group or removing them
+type GroupMemberInput struct {
+	GroupID uint
+	UserID  uint
+}
+
+// GroupRoleInput represents the input for assigning a role to a group or unassigning it
+type GroupRoleInput struct {
+	GroupID uint
+	Role    string
+}
+
+// GroupRoles represents the roles a user inherits from a group
+type GroupRoles struct {
+	Group string
+	Roles []string
+}
+
+// UserRolesOutput represents the roles of a user in a domain
+type UserRolesOutput struct {
+	Domain string
+	// Direct are the roles assigned to the user
+	Direct []string
+	// Groups are the groups of the user, with the roles each of them holds
+	Groups []GroupRoles
+	// Effective are the roles the user holds, directly, through groups or by inheritance
+	Effective []string
+}
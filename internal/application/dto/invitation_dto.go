@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// Invitation DTOs
+
+// CreateInvitationInput represents the input for inviting someone to create an account
+type CreateInvitationInput struct {
+	Email string
+	Role  string
+	// Domain is the domain the role is assigned in, default by default
+	Domain string
+}
+
+// ListInvitationsInput represents the input for listing invitations
+type ListInvitationsInput struct {
+	Status string
+	Email  string
+	Page   int
+	Limit  int
+}
+
+// ListInvitationsOutput represents the output for listing invitations
+type ListInvitationsOutput struct {
+	Invitations []*entity.Invitation
+	TotalCount  int64
+}
+
+// AcceptInvitationInput represents the input for accepting an invitation
+type AcceptInvitationInput struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// AcceptInvitationOutput represents the output for accepting an invitation
+type AcceptInvitationOutput struct {
+	User  *entity.User
+	Token string
+}
@@ -0,0 +1,108 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// Policy DTOs
+
+// ExportPoliciesInput represents the input for exporting policies
+type ExportPoliciesInput struct {
+	Format string
+}
+
+// ExportPoliciesOutput represents the output for exporting policies
+type ExportPoliciesOutput struct {
+	Data  []byte
+	Count int
+}
+
+// Policy represents a policy rule allowing or denying an action on an object
+type Policy struct {
+	Subject string
+	Domain  string
+	Object  string
+	Action  string
+	// Condition is the expression the request must satisfy, empty when unconditional
+	Condition string
+	// Effect is either allow or deny, allow by default
+	Effect string
+	// Priority decides between conflicting rules, the highest wins
+	Priority int
+}
+
+// ListPoliciesInput represents the input for listing policy rules
+type ListPoliciesInput struct {
+	// Subject only lists the rules of a subject or role when set
+	Subject string
+	// Domain only lists the rules of a domain when set
+	Domain string
+}
+
+// ListPoliciesOutput represents the output for listing policy rules
+type ListPoliciesOutput struct {
+	Policies []Policy
+}
+
+// ImportPoliciesInput represents the input for importing policies
+type ImportPoliciesInput struct {
+	Data   []byte
+	Format string
+	// Replace removes existing rules missing from the imported data
+	Replace bool
+	// DryRun computes the changes without applying them
+	DryRun bool
+}
+
+// ImportPoliciesOutput represents the output for importing policies
+type ImportPoliciesOutput struct {
+	Added   []entity.PolicyRule
+	Removed []entity.PolicyRule
+	Applied bool
+}
+
+// ListPolicyVersionsInput represents the input for listing policy versions
+type ListPolicyVersionsInput struct {
+	Page  int
+	Limit int
+}
+
+// ListPolicyVersionsOutput represents the output for listing policy versions
+type ListPolicyVersionsOutput struct {
+	Versions   []*entity.PolicyVersion
+	TotalCount int64
+}
+
+// DiffPolicyVersionsInput represents the input for comparing two policy versions
+type DiffPolicyVersionsInput struct {
+	From uint
+	To   uint
+}
+
+// DiffPolicyVersionsOutput represents the rules added and removed between two policy versions
+type DiffPolicyVersionsOutput struct {
+	From    uint
+	To      uint
+	Added   []entity.PolicyRule
+	Removed []entity.PolicyRule
+}
+
+// RollbackPolicyInput represents the input for rolling back the policy to a version
+type RollbackPolicyInput struct {
+	Version uint
+}
+
+// DecisionCacheStatsOutput represents the activity of the authorization decision cache
+type DecisionCacheStatsOutput struct {
+	Enabled       bool
+	TTL           time.Duration
+	Size          int
+	Capacity      int
+	Hits          uint64
+	Misses        uint64
+	HitRatio      float64
+	Evictions     uint64
+	Invalidations uint64
+}
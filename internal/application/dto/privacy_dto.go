@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserDataExport holds everything kept about a user, for data subject access requests
+type UserDataExport struct {
+	ExportedAt time.Time
+	User       *entity.User
+	// Roles are the roles of the user in the default domain
+	Roles *UserRolesOutput
+	// RoleGrants, Invitations, PolicyVersions, UserImports and Groups are the records
+	// referring to the user
+	RoleGrants      []*entity.RoleGrant
+	Invitations     []*entity.Invitation
+	PolicyVersions  []*entity.PolicyVersion
+	UserImports     []*entity.UserImport
+	Groups          []*entity.Group
+	ErasureRequests []*entity.ErasureRequest
+	// Avatar is nil when the user has no avatar
+	Avatar *AvatarOutput
+}
+
+// RequestErasureInput represents the input for requesting the erasure of a user
+type RequestErasureInput struct {
+	UserID uint
+	Reason string
+}
+
+// ProcessErasuresOutput represents the outcome of processing the due erasure requests
+type ProcessErasuresOutput struct {
+	Erased int
+}
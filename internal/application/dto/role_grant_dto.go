@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// Role Grant DTOs
+
+// CreateRoleGrantInput represents the input for granting or requesting a role temporarily
+type CreateRoleGrantInput struct {
+	Subject   string
+	Role      string
+	Domain    string
+	Reason    string
+	StartsAt  time.Time
+	ExpiresAt time.Time
+}
+
+// ListRoleGrantsInput represents the input for listing role grants
+type ListRoleGrantsInput struct {
+	Status  string
+	Subject string
+	Page    int
+	Limit   int
+}
+
+// ListRoleGrantsOutput represents the output for listing role grants
+type ListRoleGrantsOutput struct {
+	Grants     []*entity.RoleGrant
+	TotalCount int64
+}
+
+// ProcessRoleGrantsOutput represents the grants activated and expired by a reaper run
+type ProcessRoleGrantsOutput struct {
+	Activated int
+	Expired   int
+}
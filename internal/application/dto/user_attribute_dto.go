@@ -0,0 +1,22 @@
+package dto
+
+import "github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+
+// User Attribute DTOs
+
+// PutUserAttributeInput represents the input for defining a custom user attribute, or changing its definition
+type PutUserAttributeInput struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	// Options are the values allowed for enum attributes
+	Options []string
+}
+
+// PutUserAttributeOutput represents the output for defining a custom user attribute
+type PutUserAttributeOutput struct {
+	Attribute *entity.UserAttribute
+	// Created is false when an existing definition was changed
+	Created bool
+}
@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 )
 
@@ -11,7 +13,6 @@ type RegisterInput struct {
 	Username string
 	Email    string
 	Password string
-	Role     string
 }
 
 // RegisterOutput represents the output for user registration
@@ -20,6 +21,15 @@ type RegisterOutput struct {
 	Token string
 }
 
+// CreateUserInput represents the input for creating a user on behalf of an actor
+type CreateUserInput struct {
+	Username string
+	Email    string
+	Password string
+	// Role defaults to the role of self-registered users
+	Role string
+}
+
 // LoginInput represents the input for user login
 type LoginInput struct {
 	Username string
@@ -32,11 +42,57 @@ type LoginOutput struct {
 	Token string
 }
 
-// UpdateUserInput represents the input for updating a user
+// UpdateUserInput represents the input for updating a user. Empty profile fields are cleared.
 type UpdateUserInput struct {
 	ID       uint
 	Username string
-	Email    string
+	// Email only replaces the email of the user once confirmed, see RequestEmailChangeInput
+	Email       string
+	DisplayName string
+	Locale      string
+	Timezone    string
+	// Attributes replaces the custom attributes of the user
+	Attributes map[string]any
+	// Version is the version of the user the update is based on, 0 for whichever is current
+	Version uint
+}
+
+// PatchUserInput represents the input for patching a user with a JSON merge patch (RFC 7396)
+type PatchUserInput struct {
+	ID uint
+	// Patch replaces the fields of the user it has members for, null removing optional fields
+	Patch map[string]any
+	// Version is the version of the user the patch is based on, 0 for whichever is current
+	Version uint
+}
+
+// PatchUserOutput represents the output for patching a user
+type PatchUserOutput struct {
+	User *entity.User
+	// Changes are the fields the patch changed, none when it left the user as is
+	Changes []entity.FieldChange
+}
+
+// RequestEmailChangeInput represents the input for changing the email of a user, which is
+// replaced once the new one is confirmed
+type RequestEmailChangeInput struct {
+	ID    uint
+	Email string
+}
+
+// UploadAvatarInput represents the input for uploading the avatar of a user
+type UploadAvatarInput struct {
+	ID uint
+	// Data is a PNG, JPEG or GIF image
+	Data []byte
+}
+
+// AvatarOutput represents the output for getting the avatar of a user
+type AvatarOutput struct {
+	Data        []byte
+	ContentType string
+	// Version changes whenever a new avatar is uploaded
+	Version string
 }
 
 // ChangePasswordInput represents the input for changing a user's password
@@ -46,14 +102,55 @@ type ChangePasswordInput struct {
 	NewPassword string
 }
 
-// ListUsersInput represents the input for listing users
+// ListUsersInput represents the input for listing users. Zero filters are ignored.
 type ListUsersInput struct {
-	Page  int
+	// After and Before are cursors returned by a previous listing, to list the users following or
+	// preceding it. Neither gives the first page.
+	After  string
+	Before string
+	// Limit is capped at repository.MaxPageLimit
 	Limit int
+	// SkipCount leaves the total count out
+	SkipCount bool
+
+	Role        string
+	Active      *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Search matches users whose username or email contains it, ignoring case
+	Search string
+	// Sort is a comma separated list of columns, each prefixed with - for descending order
+	Sort string
 }
 
 // ListUsersOutput represents the output for listing users
 type ListUsersOutput struct {
-	Users      []*entity.User
-	TotalCount int64
+	Users []*entity.User
+	// Next and Prev are the cursors of the following and preceding pages, empty when there is none
+	Next string
+	Prev string
+	// TotalCount is nil when the count is skipped
+	TotalCount *int64
+}
+
+// SetUserActiveInput represents the input for activating or deactivating a user
+type SetUserActiveInput struct {
+	ID     uint
+	Active bool
+}
+
+// ImpersonateInput represents the input for impersonating a user
+type ImpersonateInput struct {
+	// UserID is the ID of the user to impersonate
+	UserID uint
+	// ActorID is the ID of the user impersonating them
+	ActorID uint
+}
+
+// ImpersonateOutput represents the output for impersonating a user
+type ImpersonateOutput struct {
+	User      *entity.User
+	Actor     *entity.User
+	Token     string
+	ExpiresAt time.Time
 }
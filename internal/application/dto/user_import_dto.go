@@ -0,0 +1,12 @@
+package dto
+
+// User Import DTOs
+
+// ImportUsersInput represents the input for importing users from a file
+type ImportUsersInput struct {
+	// Data is the content of a CSV or JSONL file
+	Data   []byte
+	Format string
+	// DryRun only validates the rows, without creating any user
+	DryRun bool
+}
@@ -18,18 +18,35 @@ type APIClientUseCase interface {
 	// GetByAPIKey gets an API client by API key
 	GetByAPIKey(ctx context.Context, input dto.GetAPIClientByAPIKeyInput) (*entity.APIClient, error)
 
-	// Update updates an API client
+	// Update updates an API client, unless it was updated since the version the update is based on
 	Update(ctx context.Context, input dto.UpdateAPIClientInput) (*entity.APIClient, error)
 
+	// Patch applies a JSON merge patch to an API client, unless it was updated since the version the
+	// patch is based on, and returns the changes made
+	Patch(ctx context.Context, input dto.PatchAPIClientInput) (*dto.PatchAPIClientOutput, error)
+
 	// RegenerateAPIKey regenerates an API key for an API client
 	RegenerateAPIKey(ctx context.Context, input dto.RegenerateAPIKeyInput) (*entity.APIClient, error)
 
 	// SetActive sets an API client's active status
 	SetActive(ctx context.Context, input dto.SetAPIClientActiveInput) (*entity.APIClient, error)
 
-	// Delete deletes an API client
+	// Delete soft deletes an API client, snapshotting its Casbin rules, unless it was updated since
+	// the version the deletion is based on
 	Delete(ctx context.Context, input dto.DeleteAPIClientInput) error
 
 	// List lists API clients with pagination
 	List(ctx context.Context, input dto.ListAPIClientsInput) (*dto.ListAPIClientsOutput, error)
+
+	// ListDeleted lists deleted API clients with pagination
+	ListDeleted(ctx context.Context, input dto.ListAPIClientsInput) (*dto.ListAPIClientsOutput, error)
+
+	// Restore restores a deleted API client along with its Casbin rules
+	Restore(ctx context.Context, input dto.RestoreAPIClientInput) (*entity.APIClient, error)
+
+	// Purge permanently deletes a deleted API client
+	Purge(ctx context.Context, input dto.PurgeAPIClientInput) error
+
+	// PurgeExpired permanently deletes the deleted API clients past their retention
+	PurgeExpired(ctx context.Context) (*dto.PurgeExpiredAPIClientsOutput, error)
 }
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+)
+
+// AuthzUseCase defines the interface for authorization-related business logic
+type AuthzUseCase interface {
+	// GetPermissions resolves the effective roles and permissions of a subject in a domain
+	GetPermissions(ctx context.Context, input dto.GetPermissionsInput) (*dto.GetPermissionsOutput, error)
+
+	// BatchCheck evaluates many authorization questions within the configured latency budget
+	BatchCheck(ctx context.Context, input dto.BatchCheckInput) (*dto.BatchCheckOutput, error)
+}
@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+)
+
+// GroupUseCase defines the interface for group business logic
+type GroupUseCase interface {
+	// Create creates a group
+	Create(ctx context.Context, input dto.CreateGroupInput) (*dto.GroupOutput, error)
+
+	// GetByID gets a group by ID
+	GetByID(ctx context.Context, id uint) (*dto.GroupOutput, error)
+
+	// List lists groups with pagination, sorted by name
+	List(ctx context.Context, input dto.ListGroupsInput) (*dto.ListGroupsOutput, error)
+
+	// Update updates the description of a group
+	Update(ctx context.Context, input dto.UpdateGroupInput) (*dto.GroupOutput, error)
+
+	// Delete deletes a group, removing its members and roles
+	Delete(ctx context.Context, id uint) error
+
+	// ListMembers lists the members of a group, sorted by username
+	ListMembers(ctx context.Context, id uint) ([]*dto.GroupMember, error)
+
+	// AddMember adds a user to a group, giving them the roles of the group
+	AddMember(ctx context.Context, input dto.GroupMemberInput) error
+
+	// RemoveMember removes a user from a group
+	RemoveMember(ctx context.Context, input dto.GroupMemberInput) error
+
+	// AddRole assigns a role to a group
+	AddRole(ctx context.Context, input dto.GroupRoleInput) (*dto.GroupOutput, error)
+
+	// RemoveRole unassigns a role from a group
+	RemoveRole(ctx context.Context, input dto.GroupRoleInput) (*dto.GroupOutput, error)
+}
@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// InvitationUseCase defines the interface for invitation-related business logic
+type InvitationUseCase interface {
+	// Create invites someone to create an account holding a role, sending them the invitation by email
+	Create(ctx context.Context, input dto.CreateInvitationInput) (*entity.Invitation, error)
+
+	// GetByID gets an invitation by ID
+	GetByID(ctx context.Context, id uint) (*entity.Invitation, error)
+
+	// List lists invitations with pagination, newest first
+	List(ctx context.Context, input dto.ListInvitationsInput) (*dto.ListInvitationsOutput, error)
+
+	// Revoke withdraws a pending invitation
+	Revoke(ctx context.Context, id uint) (*entity.Invitation, error)
+
+	// Accept creates the account of an invitee, with the role they were invited to, claiming the invitation first
+	Accept(ctx context.Context, input dto.AcceptInvitationInput) (*dto.AcceptInvitationOutput, error)
+}
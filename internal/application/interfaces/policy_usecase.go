@@ -0,0 +1,41 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// PolicyUseCase defines the interface for policy management business logic
+type PolicyUseCase interface {
+	// ListPolicies lists the policy rules
+	ListPolicies(ctx context.Context, input dto.ListPoliciesInput) (*dto.ListPoliciesOutput, error)
+
+	// AddPolicy adds a policy rule
+	AddPolicy(ctx context.Context, input dto.Policy) error
+
+	// RemovePolicy removes a policy rule
+	RemovePolicy(ctx context.Context, input dto.Policy) error
+
+	// Export exports the full policy set
+	Export(ctx context.Context, input dto.ExportPoliciesInput) (*dto.ExportPoliciesOutput, error)
+
+	// Import imports a policy set, or only reports the changes it would make in dry-run mode
+	Import(ctx context.Context, input dto.ImportPoliciesInput) (*dto.ImportPoliciesOutput, error)
+
+	// ListVersions lists policy versions with pagination, newest first
+	ListVersions(ctx context.Context, input dto.ListPolicyVersionsInput) (*dto.ListPolicyVersionsOutput, error)
+
+	// GetVersion gets a policy version
+	GetVersion(ctx context.Context, version uint) (*entity.PolicyVersion, error)
+
+	// DiffVersions compares the policy at two versions
+	DiffVersions(ctx context.Context, input dto.DiffPolicyVersionsInput) (*dto.DiffPolicyVersionsOutput, error)
+
+	// Rollback restores the policy as it was at a version, recorded as a new version
+	Rollback(ctx context.Context, input dto.RollbackPolicyInput) (*entity.PolicyVersion, error)
+
+	// DecisionCacheStats reports the hits and misses of the authorization decision cache
+	DecisionCacheStats(ctx context.Context) (*dto.DecisionCacheStatsOutput, error)
+}
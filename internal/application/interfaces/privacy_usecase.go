@@ -0,0 +1,34 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// PrivacyUseCase defines the interface for data subject requests: exporting the data
+// kept about a user and erasing it
+type PrivacyUseCase interface {
+	// GetDataSubject gets a user whose data may be exported or erased, soft deleted or not
+	GetDataSubject(ctx context.Context, id uint) (*entity.User, error)
+
+	// GetOwnerRole gets the highest of the effective roles of a data subject in the default domain,
+	// falling back to their role when they hold none
+	GetOwnerRole(ctx context.Context, user *entity.User) (string, error)
+
+	// Export gathers everything kept about a user, soft deleted or not
+	Export(ctx context.Context, id uint) (*dto.UserDataExport, error)
+
+	// RequestErasure schedules the erasure of a user, which can be cancelled until it is carried out
+	RequestErasure(ctx context.Context, input dto.RequestErasureInput) (*entity.ErasureRequest, error)
+
+	// GetErasure gets the latest erasure request of a user
+	GetErasure(ctx context.Context, userID uint) (*entity.ErasureRequest, error)
+
+	// CancelErasure cancels the pending erasure request of a user
+	CancelErasure(ctx context.Context, userID uint) (*entity.ErasureRequest, error)
+
+	// ProcessDue erases the users whose erasure requests are due
+	ProcessDue(ctx context.Context) (*dto.ProcessErasuresOutput, error)
+}
@@ -0,0 +1,35 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// RoleGrantUseCase defines the interface for time-bound role assignment business logic
+type RoleGrantUseCase interface {
+	// Create grants a role to a subject for a period of time, pending approval for privileged roles
+	Create(ctx context.Context, input dto.CreateRoleGrantInput) (*entity.RoleGrant, error)
+
+	// RequestElevation requests a role for the caller, always pending approval
+	RequestElevation(ctx context.Context, input dto.CreateRoleGrantInput) (*entity.RoleGrant, error)
+
+	// GetByID gets a role grant by ID
+	GetByID(ctx context.Context, id uint) (*entity.RoleGrant, error)
+
+	// List lists role grants with pagination, newest first
+	List(ctx context.Context, input dto.ListRoleGrantsInput) (*dto.ListRoleGrantsOutput, error)
+
+	// Approve approves a pending role grant
+	Approve(ctx context.Context, id uint) (*entity.RoleGrant, error)
+
+	// Reject rejects a pending role grant
+	Reject(ctx context.Context, id uint) (*entity.RoleGrant, error)
+
+	// Revoke withdraws a role grant, unassigning the role if it is active
+	Revoke(ctx context.Context, id uint) (*entity.RoleGrant, error)
+
+	// ProcessDue activates the grants whose start time has come and expires the grants past their expiry
+	ProcessDue(ctx context.Context) (*dto.ProcessRoleGrantsOutput, error)
+}
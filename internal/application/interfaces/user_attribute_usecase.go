@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserAttributeUseCase defines the interface for the business logic of custom user attribute definitions
+type UserAttributeUseCase interface {
+	// List lists the attribute definitions, sorted by name
+	List(ctx context.Context) ([]*entity.UserAttribute, error)
+
+	// Put defines an attribute, or changes its definition
+	Put(ctx context.Context, input dto.PutUserAttributeInput) (*dto.PutUserAttributeOutput, error)
+
+	// Delete deletes an attribute definition and removes the attribute from every user
+	Delete(ctx context.Context, name string) error
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserImportUseCase defines the interface for bulk user import business logic
+type UserImportUseCase interface {
+	// Import checks that a file can be read and queues the import of its rows
+	Import(ctx context.Context, input dto.ImportUsersInput) (*entity.UserImport, error)
+
+	// GetByID gets a user import by ID, along with its progress and row errors
+	GetByID(ctx context.Context, id uint) (*entity.UserImport, error)
+
+	// ProcessNext processes the next queued import and returns it, or nil when there is none
+	ProcessNext(ctx context.Context) (*entity.UserImport, error)
+}
@@ -12,18 +12,72 @@ type UserUseCase interface {
 	// Register registers a new user
 	Register(ctx context.Context, input dto.RegisterInput) (*dto.RegisterOutput, error)
 
+	// CreateUser creates a user holding a role the actor is allowed to assign
+	CreateUser(ctx context.Context, input dto.CreateUserInput) (*entity.User, error)
+
 	// Login authenticates a user
 	Login(ctx context.Context, input dto.LoginInput) (*dto.LoginOutput, error)
 
 	// GetUserByID gets a user by ID
 	GetUserByID(ctx context.Context, id uint) (*entity.User, error)
 
-	// UpdateUser updates a user
+	// UpdateUser updates a user, unless they were updated since the version the update is based on
 	UpdateUser(ctx context.Context, input dto.UpdateUserInput) (*entity.User, error)
 
+	// RequestEmailChange sends a confirmation token to the new email of a user and notifies the
+	// former one, the email being replaced once confirmed
+	RequestEmailChange(ctx context.Context, input dto.RequestEmailChangeInput) (*entity.User, error)
+
+	// ConfirmEmailChange replaces the email of the user whose email change has a token
+	ConfirmEmailChange(ctx context.Context, token string) (*entity.User, error)
+
+	// CancelEmailChange withdraws the email change of a user
+	CancelEmailChange(ctx context.Context, id uint) (*entity.User, error)
+
+	// UploadAvatar resizes and stores the avatar of a user, replacing the previous one
+	UploadAvatar(ctx context.Context, input dto.UploadAvatarInput) (*entity.User, error)
+
+	// GetAvatar gets the avatar of a user
+	GetAvatar(ctx context.Context, id uint) (*dto.AvatarOutput, error)
+
+	// GetRoles resolves the roles of a user in a domain, including those inherited through groups
+	GetRoles(ctx context.Context, id uint, domain string) (*dto.UserRolesOutput, error)
+
 	// ChangePassword changes a user's password
 	ChangePassword(ctx context.Context, input dto.ChangePasswordInput) error
 
 	// ListUsers lists users with pagination
 	ListUsers(ctx context.Context, input dto.ListUsersInput) (*dto.ListUsersOutput, error)
+
+	// SetUserActive activates or deactivates a user
+	SetUserActive(ctx context.Context, input dto.SetUserActiveInput) (*entity.User, error)
+
+	// GetOwnerRole gets the highest of the effective roles of a user in the default domain, those
+	// held through groups and temporary grants included, falling back to their role when they hold none
+	GetOwnerRole(ctx context.Context, user *entity.User) (string, error)
+
+	// GetDeletedUserByID gets a soft deleted user by ID
+	GetDeletedUserByID(ctx context.Context, id uint) (*entity.User, error)
+
+	// PatchUser applies a JSON merge patch to a user, unless they were updated since the version the
+	// patch is based on, and returns the changes made
+	PatchUser(ctx context.Context, input dto.PatchUserInput) (*dto.PatchUserOutput, error)
+
+	// DeleteUser soft deletes a user, unless they were updated since the given version, 0 for any
+	DeleteUser(ctx context.Context, id uint, version uint) error
+
+	// ListDeletedUsers lists soft deleted users with pagination
+	ListDeletedUsers(ctx context.Context, input dto.ListUsersInput) (*dto.ListUsersOutput, error)
+
+	// ExportUsers passes every user matching the filters of a listing to write, a page at a time
+	ExportUsers(ctx context.Context, input dto.ListUsersInput, write func(users []*entity.User) error) error
+
+	// RestoreUser restores a soft deleted user
+	RestoreUser(ctx context.Context, id uint) (*entity.User, error)
+
+	// PurgeUser permanently deletes a soft deleted user along with their Casbin rules
+	PurgeUser(ctx context.Context, id uint) error
+
+	// Impersonate issues a token to act as a user on behalf of another one
+	Impersonate(ctx context.Context, input dto.ImpersonateInput) (*dto.ImpersonateOutput, error)
 }
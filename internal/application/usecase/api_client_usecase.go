@@ -2,7 +2,9 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"log"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
@@ -16,21 +18,29 @@ import (
 type APIClientUseCaseImpl struct {
 	apiClientRepository repository.APIClientRepository
 	casbinService       *auth.CasbinService
+	// trashRetention is how long deleted clients are kept before being purged, 0 forever
+	trashRetention time.Duration
 }
 
 // NewAPIClientUseCase creates a new APIClientUseCaseImpl
 func NewAPIClientUseCase(
 	apiClientRepository repository.APIClientRepository,
 	casbinService *auth.CasbinService,
+	trashRetention time.Duration,
 ) interfaces.APIClientUseCase {
 	return &APIClientUseCaseImpl{
 		apiClientRepository: apiClientRepository,
 		casbinService:       casbinService,
+		trashRetention:      trashRetention,
 	}
 }
 
 // Create creates a new API client
 func (uc *APIClientUseCaseImpl) Create(ctx context.Context, input dto.CreateAPIClientInput) (*dto.CreateAPIClientOutput, error) {
+	if err := uc.checkNameAvailable(input.Name); err != nil {
+		return nil, err
+	}
+
 	// Create new API client
 	client, err := entity.NewAPIClient(input.Name, input.Description)
 	if err != nil {
@@ -42,8 +52,9 @@ func (uc *APIClientUseCaseImpl) Create(ctx context.Context, input dto.CreateAPIC
 		return nil, err
 	}
 
-	// Add policy for API client in Casbin
-	if _, err := uc.casbinService.AddPolicy(client.Name, "api", "/api/*", "GET"); err != nil {
+	// Add policies for API client in Casbin
+	diff := auth.PolicyDiff{Added: auth.APIClientPolicies(client.Name)}
+	if err := uc.casbinService.ApplyPolicyDiff(ctx, entity.PolicyActionAdd, diff); err != nil {
 		return nil, err
 	}
 
@@ -52,6 +63,20 @@ func (uc *APIClientUseCaseImpl) Create(ctx context.Context, input dto.CreateAPIC
 	}, nil
 }
 
+// checkNameAvailable checks that an API client may take a name that is neither a role nor the
+// subject of a policy rule, such as another client. Clients are Casbin subjects named after their
+// name, so one named after a role would hold its permissions.
+func (uc *APIClientUseCaseImpl) checkNameAvailable(name string) error {
+	reserved, err := uc.casbinService.IsReservedSubject(name)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return fmt.Errorf("%w: name %q is the name of a role or policy subject", entity.ErrInvalidAPIClient, name)
+	}
+	return nil
+}
+
 // GetByID gets an API client by ID
 func (uc *APIClientUseCaseImpl) GetByID(ctx context.Context, input dto.GetAPIClientByIDInput) (*entity.APIClient, error) {
 	return uc.apiClientRepository.GetByID(ctx, input.ID)
@@ -62,7 +87,7 @@ func (uc *APIClientUseCaseImpl) GetByAPIKey(ctx context.Context, input dto.GetAP
 	return uc.apiClientRepository.GetByAPIKey(ctx, input.APIKey)
 }
 
-// Update updates an API client
+// Update updates an API client, unless it was updated since the version the update is based on
 func (uc *APIClientUseCaseImpl) Update(ctx context.Context, input dto.UpdateAPIClientInput) (*entity.APIClient, error) {
 	// Get API client by ID
 	client, err := uc.apiClientRepository.GetByID(ctx, input.ID)
@@ -70,7 +95,16 @@ func (uc *APIClientUseCaseImpl) Update(ctx context.Context, input dto.UpdateAPIC
 		return nil, err
 	}
 	if client == nil {
-		return nil, errors.New("API client not found")
+		return nil, entity.ErrAPIClientNotFound
+	}
+	if err := checkVersion(client.Version, input.Version); err != nil {
+		return nil, err
+	}
+
+	if input.Name != client.Name {
+		if err := uc.checkNameAvailable(input.Name); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update API client
@@ -86,6 +120,63 @@ func (uc *APIClientUseCaseImpl) Update(ctx context.Context, input dto.UpdateAPIC
 	return client, nil
 }
 
+// apiClientFields are the fields of an API client a merge patch may change
+type apiClientFields struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+}
+
+// Patch applies a JSON merge patch to an API client, unless it was updated since the version the patch
+// is based on. Whether the client is active is a restricted field, see auth.CasbinService.CanUpdateField.
+// Nothing is saved when the patch changes nothing.
+func (uc *APIClientUseCaseImpl) Patch(ctx context.Context, input dto.PatchAPIClientInput) (*dto.PatchAPIClientOutput, error) {
+	client, err := uc.apiClientRepository.GetByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, entity.ErrAPIClientNotFound
+	}
+	if err := checkVersion(client.Version, input.Version); err != nil {
+		return nil, err
+	}
+
+	current := apiClientFields{
+		Name:        client.Name,
+		Description: client.Description,
+		Active:      client.Active,
+	}
+	fields, changes, err := applyMergePatch(current, input.Patch, "name", "active")
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return &dto.PatchAPIClientOutput{APIClient: client}, nil
+	}
+
+	if err := authorizeFieldChanges(ctx, uc.casbinService, "api_clients", auth.DomainAPI, changes, "active"); err != nil {
+		return nil, err
+	}
+	if fields.Name != client.Name {
+		if err := uc.checkNameAvailable(fields.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := client.UpdateInfo(fields.Name, fields.Description); err != nil {
+		return nil, err
+	}
+	client.SetActive(fields.Active)
+
+	if err := uc.apiClientRepository.Update(ctx, client); err != nil {
+		return nil, err
+	}
+
+	log.Printf("API client %d patched by %s: %s", client.ID, auth.ActorFromContext(ctx), changedFields(changes))
+	return &dto.PatchAPIClientOutput{APIClient: client, Changes: changes}, nil
+}
+
 // RegenerateAPIKey regenerates an API key for an API client
 func (uc *APIClientUseCaseImpl) RegenerateAPIKey(ctx context.Context, input dto.RegenerateAPIKeyInput) (*entity.APIClient, error) {
 	// Get API client by ID
@@ -94,7 +185,7 @@ func (uc *APIClientUseCaseImpl) RegenerateAPIKey(ctx context.Context, input dto.
 		return nil, err
 	}
 	if client == nil {
-		return nil, errors.New("API client not found")
+		return nil, entity.ErrAPIClientNotFound
 	}
 
 	// Regenerate API key
@@ -118,7 +209,7 @@ func (uc *APIClientUseCaseImpl) SetActive(ctx context.Context, input dto.SetAPIC
 		return nil, err
 	}
 	if client == nil {
-		return nil, errors.New("API client not found")
+		return nil, entity.ErrAPIClientNotFound
 	}
 
 	// Set active status
@@ -132,7 +223,9 @@ func (uc *APIClientUseCaseImpl) SetActive(ctx context.Context, input dto.SetAPIC
 	return client, nil
 }
 
-// Delete deletes an API client
+// Delete soft deletes an API client, unless it was updated since the version the deletion is based on.
+// Its Casbin rules are snapshotted along with it before being removed, so that restoring the client
+// gives it back the same permissions.
 func (uc *APIClientUseCaseImpl) Delete(ctx context.Context, input dto.DeleteAPIClientInput) error {
 	// Get API client by ID
 	client, err := uc.apiClientRepository.GetByID(ctx, input.ID)
@@ -140,31 +233,150 @@ func (uc *APIClientUseCaseImpl) Delete(ctx context.Context, input dto.DeleteAPIC
 		return err
 	}
 	if client == nil {
-		return errors.New("API client not found")
+		return entity.ErrAPIClientNotFound
+	}
+	if err := checkVersion(client.Version, input.Version); err != nil {
+		return err
 	}
 
-	// Remove policies for API client in Casbin
-	if _, err := uc.casbinService.RemovePolicy(client.Name, "api", "/api/*", "GET"); err != nil {
+	// Snapshot the policies of the API client
+	rules, err := uc.casbinService.GetSubjectPolicies(client.Name, auth.DomainAPI)
+	if err != nil {
+		return err
+	}
+	client.SnapshotPolicies(rules)
+	if err := uc.apiClientRepository.Update(ctx, client); err != nil {
 		return err
 	}
 
 	// Delete API client from database
-	return uc.apiClientRepository.Delete(ctx, input.ID)
+	if err := uc.apiClientRepository.Delete(ctx, input.ID, client.Version); err != nil {
+		return err
+	}
+
+	// Remove policies for API client in Casbin
+	_, err = uc.casbinService.DeleteSubject(ctx, client.Name, auth.DomainAPI)
+	return err
 }
 
-// List lists API clients with pagination
+// List lists API clients with filters, sorting and pagination
 func (uc *APIClientUseCaseImpl) List(ctx context.Context, input dto.ListAPIClientsInput) (*dto.ListAPIClientsOutput, error) {
-	// Calculate offset
-	offset := (input.Page - 1) * input.Limit
+	return uc.list(ctx, input, false)
+}
+
+// ListDeleted lists deleted API clients with filters, sorting and pagination
+func (uc *APIClientUseCaseImpl) ListDeleted(ctx context.Context, input dto.ListAPIClientsInput) (*dto.ListAPIClientsOutput, error) {
+	return uc.list(ctx, input, true)
+}
+
+// list lists either deleted API clients or the others
+func (uc *APIClientUseCaseImpl) list(ctx context.Context, input dto.ListAPIClientsInput, deleted bool) (*dto.ListAPIClientsOutput, error) {
+	sort, err := repository.ParseSort(input.Sort, repository.APIClientSortColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	query := repository.APIClientQuery{
+		Active:      input.Active,
+		CreatedFrom: input.CreatedFrom,
+		CreatedTo:   input.CreatedTo,
+		Deleted:     deleted,
+		Search:      input.Search,
+		PageQuery: repository.PageQuery{
+			Sort:      sort,
+			After:     input.After,
+			Before:    input.Before,
+			Limit:     pageLimit(input.Limit),
+			SkipCount: input.SkipCount,
+		},
+	}
 
 	// Get API clients from database
-	clients, count, err := uc.apiClientRepository.List(ctx, offset, input.Limit)
+	clients, page, err := uc.apiClientRepository.List(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
 	return &dto.ListAPIClientsOutput{
 		APIClients: clients,
-		TotalCount: count,
+		Next:       page.Next,
+		Prev:       page.Prev,
+		TotalCount: page.TotalCount,
 	}, nil
 }
+
+// Restore restores a deleted API client and re-creates the Casbin rules it had when deleted
+func (uc *APIClientUseCaseImpl) Restore(ctx context.Context, input dto.RestoreAPIClientInput) (*entity.APIClient, error) {
+	client, err := uc.apiClientRepository.GetDeletedByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, entity.ErrAPIClientNotFound
+	}
+
+	rules := client.PolicySnapshot
+	if rules == nil {
+		// Clients deleted before snapshots were taken only had the policies given on creation
+		rules = auth.APIClientPolicies(client.Name)
+	}
+	rules = uc.casbinService.NormalizePolicies(rules)
+	// Clients deleted before their rules were scoped get the denies they lacked
+	rules = append(rules, auth.MissingAPIClientDenies(rules)...)
+
+	if err := uc.apiClientRepository.Restore(ctx, input.ID); err != nil {
+		return nil, err
+	}
+
+	// Re-create policies for API client in Casbin
+	diff := auth.PolicyDiff{Added: rules}
+	if err := uc.casbinService.ApplyPolicyDiff(ctx, entity.PolicyActionAdd, diff); err != nil {
+		return nil, err
+	}
+
+	client, err = uc.apiClientRepository.GetByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	client.ClearPolicySnapshot()
+	if err := uc.apiClientRepository.Update(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Purge permanently deletes a deleted API client along with its policy snapshot
+func (uc *APIClientUseCaseImpl) Purge(ctx context.Context, input dto.PurgeAPIClientInput) error {
+	client, err := uc.apiClientRepository.GetDeletedByID(ctx, input.ID)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return entity.ErrAPIClientNotFound
+	}
+
+	return uc.apiClientRepository.PermanentDelete(ctx, input.ID)
+}
+
+// PurgeExpired permanently deletes the API clients deleted longer ago than the trash retention
+func (uc *APIClientUseCaseImpl) PurgeExpired(ctx context.Context) (*dto.PurgeExpiredAPIClientsOutput, error) {
+	output := &dto.PurgeExpiredAPIClientsOutput{}
+	if uc.trashRetention <= 0 {
+		return output, nil
+	}
+
+	clients, err := uc.apiClientRepository.ListDeletedBefore(ctx, time.Now().Add(-uc.trashRetention))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range clients {
+		if err := uc.apiClientRepository.PermanentDelete(ctx, client.ID); err != nil {
+			return output, err
+		}
+		output.Purged++
+	}
+
+	return output, nil
+}
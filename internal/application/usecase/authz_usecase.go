@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// AuthzUseCaseImpl handles authorization-related business logic
+// It implements the interfaces.AuthzUseCase interface
+type AuthzUseCaseImpl struct {
+	casbinService *auth.CasbinService
+	batchMaxSize  int
+	batchTimeout  time.Duration
+}
+
+// NewAuthzUseCase creates a new AuthzUseCaseImpl.
+// Batches hold at most batchMaxSize checks, and checks not evaluated within batchTimeout are denied.
+func NewAuthzUseCase(casbinService *auth.CasbinService, batchMaxSize int, batchTimeout time.Duration) interfaces.AuthzUseCase {
+	return &AuthzUseCaseImpl{
+		casbinService: casbinService,
+		batchMaxSize:  batchMaxSize,
+		batchTimeout:  batchTimeout,
+	}
+}
+
+// GetPermissions resolves the effective roles and permissions of a subject in a domain
+func (uc *AuthzUseCaseImpl) GetPermissions(ctx context.Context, input dto.GetPermissionsInput) (*dto.GetPermissionsOutput, error) {
+	if input.Subject == "" {
+		return nil, errors.New("subject cannot be empty")
+	}
+
+	// Resolve roles through role inheritance, including those of the groups of the subject
+	implicitRoles, err := uc.casbinService.GetImplicitRolesForUser(input.Subject, input.Domain)
+	if err != nil {
+		return nil, err
+	}
+	split := splitGroups(implicitRoles)
+	groups := make([]string, len(split.groups))
+	for i, group := range split.groups {
+		groups[i] = entity.GroupNameFromSubject(group)
+	}
+
+	// Resolve permissions granted to the subject and all of its roles
+	rules, err := uc.casbinService.GetImplicitPermissionsForUser(input.Subject, input.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]dto.Permission, 0, len(rules))
+	seen := make(map[dto.Permission]bool, len(rules))
+	for _, rule := range rules {
+		if len(rule) < 4 {
+			continue
+		}
+
+		permission := dto.Permission{
+			Subject: rule[0],
+			Domain:  rule[1],
+			Object:  rule[2],
+			Action:  rule[3],
+		}
+		if len(rule) > 4 && rule[4] != auth.ConditionAlways {
+			permission.Condition = rule[4]
+		}
+		permission.Effect = auth.EffectAllow
+		if len(rule) > 5 {
+			if permission.Effect, permission.Priority, err = auth.ParseEffect(rule[5]); err != nil {
+				return nil, err
+			}
+		}
+		if !strings.HasPrefix(permission.Object, input.ObjectPrefix) || seen[permission] {
+			continue
+		}
+
+		seen[permission] = true
+		permissions = append(permissions, permission)
+	}
+
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Object != permissions[j].Object {
+			return permissions[i].Object < permissions[j].Object
+		}
+		if permissions[i].Action != permissions[j].Action {
+			return permissions[i].Action < permissions[j].Action
+		}
+		if permissions[i].Priority != permissions[j].Priority {
+			return permissions[i].Priority > permissions[j].Priority
+		}
+		return permissions[i].Condition < permissions[j].Condition
+	})
+
+	return &dto.GetPermissionsOutput{
+		Subject:     input.Subject,
+		Domain:      input.Domain,
+		Roles:       split.roles,
+		Groups:      groups,
+		Permissions: permissions,
+	}, nil
+}
+
+// BatchCheck evaluates many authorization questions within the configured latency budget.
+// Checks are evaluated concurrently; those that are invalid, fail or are not reached before the
+// budget runs out are denied with an error, without failing the rest of the batch.
+func (uc *AuthzUseCaseImpl) BatchCheck(ctx context.Context, input dto.BatchCheckInput) (*dto.BatchCheckOutput, error) {
+	if len(input.Checks) == 0 {
+		return nil, errors.New("at least one check is required")
+	}
+	if uc.batchMaxSize > 0 && len(input.Checks) > uc.batchMaxSize {
+		return nil, fmt.Errorf("a batch cannot hold more than %d checks", uc.batchMaxSize)
+	}
+
+	start := time.Now()
+	if uc.batchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, uc.batchTimeout)
+		defer cancel()
+	}
+
+	indexes := make(chan int, len(input.Checks))
+	for i := range input.Checks {
+		indexes <- i
+	}
+	close(indexes)
+
+	decisions := make([]dto.Decision, len(input.Checks))
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(input.Checks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				decisions[i] = uc.check(ctx, input.Checks[i], start)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return &dto.BatchCheckOutput{
+		Decisions: decisions,
+		Elapsed:   time.Since(start),
+	}, nil
+}
+
+// check evaluates a single authorization question, time conditions being evaluated at the given time
+func (uc *AuthzUseCaseImpl) check(ctx context.Context, check dto.Check, at time.Time) dto.Decision {
+	if ctx.Err() != nil {
+		return dto.Decision{Error: "not evaluated within the latency budget"}
+	}
+	if check.Subject == "" || check.Domain == "" || check.Object == "" || check.Action == "" {
+		return dto.Decision{Error: "subject, domain, object and action are required"}
+	}
+
+	attrs := auth.NewRequestAttributes(
+		check.Attributes.SubjectID,
+		check.Attributes.OwnerID,
+		check.Attributes.OwnerRole,
+		check.Attributes.IP,
+		at,
+	)
+	allowed, err := uc.casbinService.Enforce(check.Subject, check.Domain, check.Object, check.Action, attrs)
+	if err != nil {
+		return dto.Decision{Error: err.Error()}
+	}
+
+	return dto.Decision{Allowed: allowed}
+}
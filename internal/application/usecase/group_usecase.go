@@ -0,0 +1,367 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// GroupUseCaseImpl handles group business logic
+// It implements the interfaces.GroupUseCase interface
+type GroupUseCaseImpl struct {
+	groupRepository repository.GroupRepository
+	userRepository  repository.UserRepository
+	casbinService   *auth.CasbinService
+}
+
+// NewGroupUseCase creates a new GroupUseCaseImpl
+func NewGroupUseCase(groupRepository repository.GroupRepository, userRepository repository.UserRepository, casbinService *auth.CasbinService) interfaces.GroupUseCase {
+	return &GroupUseCaseImpl{
+		groupRepository: groupRepository,
+		userRepository:  userRepository,
+		casbinService:   casbinService,
+	}
+}
+
+// Create creates a group, holding no role until one is assigned to it
+func (uc *GroupUseCaseImpl) Create(ctx context.Context, input dto.CreateGroupInput) (*dto.GroupOutput, error) {
+	domain := input.Domain
+	if domain == "" {
+		domain = auth.DomainDefault
+	}
+
+	group, err := entity.NewGroup(input.Name, input.Description, domain, auth.ActorFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	existingGroup, err := uc.groupRepository.GetByName(ctx, group.Name, group.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if existingGroup != nil {
+		return nil, entity.ErrGroupExists
+	}
+
+	if err := uc.groupRepository.Create(ctx, group); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Group %s created by %s in %s", group.Name, group.CreatedBy, group.Domain)
+	return &dto.GroupOutput{Group: group, Roles: []string{}}, nil
+}
+
+// GetByID gets a group by ID
+func (uc *GroupUseCaseImpl) GetByID(ctx context.Context, id uint) (*dto.GroupOutput, error) {
+	group, err := uc.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return uc.toOutput(group)
+}
+
+// List lists groups with pagination, sorted by name
+func (uc *GroupUseCaseImpl) List(ctx context.Context, input dto.ListGroupsInput) (*dto.ListGroupsOutput, error) {
+	// Calculate offset
+	offset := (input.Page - 1) * input.Limit
+
+	groups, count, err := uc.groupRepository.List(ctx, input.Domain, offset, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]*dto.GroupOutput, len(groups))
+	for i, group := range groups {
+		if outputs[i], err = uc.toOutput(group); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.ListGroupsOutput{
+		Groups:     outputs,
+		TotalCount: count,
+	}, nil
+}
+
+// Update updates the description of a group
+func (uc *GroupUseCaseImpl) Update(ctx context.Context, input dto.UpdateGroupInput) (*dto.GroupOutput, error) {
+	group, err := uc.getGroup(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	group.UpdateDescription(input.Description)
+	if err := uc.groupRepository.Update(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return uc.toOutput(group)
+}
+
+// Delete deletes a group, removing the grouping rules of its members and roles.
+// Members lose the roles of the group, so the actor must be allowed to assign them.
+func (uc *GroupUseCaseImpl) Delete(ctx context.Context, id uint) error {
+	group, err := uc.getGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.checkAssignable(ctx, group); err != nil {
+		return err
+	}
+
+	// The rules and roles of the group, and its memberships
+	members := 0
+	_, err = uc.casbinService.UpdatePolicies(ctx, entity.PolicyActionRemove, func(current []entity.PolicyRule) (auth.PolicyDiff, error) {
+		var removed []entity.PolicyRule
+		for _, rule := range current {
+			switch {
+			case rule.PType == "p" && len(rule.Values) > 1 && rule.Values[0] == group.Subject() && rule.Values[1] == group.Domain:
+				removed = append(removed, rule)
+			case rule.PType == "g" && len(rule.Values) > 2 && rule.Values[2] == group.Domain && rule.Values[0] == group.Subject():
+				removed = append(removed, rule)
+			case rule.PType == "g" && len(rule.Values) > 2 && rule.Values[2] == group.Domain && rule.Values[1] == group.Subject():
+				removed = append(removed, rule)
+				members++
+			}
+		}
+		return auth.PolicyDiff{Removed: removed}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := uc.groupRepository.Delete(ctx, group.ID); err != nil {
+		return err
+	}
+
+	log.Printf("Group %s deleted by %s in %s, removing %d members", group.Name, auth.ActorFromContext(ctx), group.Domain, members)
+	return nil
+}
+
+// ListMembers lists the members of a group, sorted by username
+func (uc *GroupUseCaseImpl) ListMembers(ctx context.Context, id uint) ([]*dto.GroupMember, error) {
+	group, err := uc.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames, err := uc.casbinService.GetUsersForRole(group.Subject(), group.Domain)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(usernames)
+
+	members := make([]*dto.GroupMember, len(usernames))
+	for i, username := range usernames {
+		user, err := uc.userRepository.GetByUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		members[i] = &dto.GroupMember{Username: username, User: user}
+	}
+	return members, nil
+}
+
+// AddMember adds a user to a group. Membership gives the user the roles of the group,
+// so the actor must be allowed to assign them. Adding a member twice has no effect.
+func (uc *GroupUseCaseImpl) AddMember(ctx context.Context, input dto.GroupMemberInput) error {
+	group, user, err := uc.getMembership(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.checkAssignable(ctx, group); err != nil {
+		return err
+	}
+
+	added, err := uc.casbinService.AddRoleForUser(ctx, user.Username, group.Subject(), group.Domain)
+	if err != nil {
+		return err
+	}
+	if added {
+		log.Printf("%s added to group %s by %s in %s", user.Username, group.Name, auth.ActorFromContext(ctx), group.Domain)
+	}
+	return nil
+}
+
+// RemoveMember removes a user from a group, taking the roles of the group away from them
+func (uc *GroupUseCaseImpl) RemoveMember(ctx context.Context, input dto.GroupMemberInput) error {
+	group, user, err := uc.getMembership(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.checkAssignable(ctx, group); err != nil {
+		return err
+	}
+
+	removed, err := uc.casbinService.DeleteRoleForUser(ctx, user.Username, group.Subject(), group.Domain)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return entity.ErrNotGroupMember
+	}
+
+	log.Printf("%s removed from group %s by %s in %s", user.Username, group.Name, auth.ActorFromContext(ctx), group.Domain)
+	return nil
+}
+
+// AddRole assigns a role of the domain of a group to the group, and thereby to its members
+func (uc *GroupUseCaseImpl) AddRole(ctx context.Context, input dto.GroupRoleInput) (*dto.GroupOutput, error) {
+	group, err := uc.getGroup(ctx, input.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := uc.casbinService.GetRoles(group.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(roles, input.Role) {
+		return nil, fmt.Errorf("unknown role %q in domain %s", input.Role, group.Domain)
+	}
+
+	if err := uc.checkRoleAssignable(ctx, input.Role, group.Domain); err != nil {
+		return nil, err
+	}
+
+	added, err := uc.casbinService.AddRoleForUser(ctx, group.Subject(), input.Role, group.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if added {
+		log.Printf("Group %s granted %s by %s in %s", group.Name, input.Role, auth.ActorFromContext(ctx), group.Domain)
+	}
+
+	return uc.toOutput(group)
+}
+
+// RemoveRole unassigns a role from a group
+func (uc *GroupUseCaseImpl) RemoveRole(ctx context.Context, input dto.GroupRoleInput) (*dto.GroupOutput, error) {
+	group, err := uc.getGroup(ctx, input.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.checkRoleAssignable(ctx, input.Role, group.Domain); err != nil {
+		return nil, err
+	}
+
+	removed, err := uc.casbinService.DeleteRoleForUser(ctx, group.Subject(), input.Role, group.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, entity.ErrGroupRoleNotHeld
+	}
+
+	log.Printf("Group %s lost %s by %s in %s", group.Name, input.Role, auth.ActorFromContext(ctx), group.Domain)
+	return uc.toOutput(group)
+}
+
+// getGroup gets a group by ID, failing when it does not exist
+func (uc *GroupUseCaseImpl) getGroup(ctx context.Context, id uint) (*entity.Group, error) {
+	group, err := uc.groupRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, entity.ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// getMembership gets the group and the user of a membership
+func (uc *GroupUseCaseImpl) getMembership(ctx context.Context, input dto.GroupMemberInput) (*entity.Group, *entity.User, error) {
+	group, err := uc.getGroup(ctx, input.GroupID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := uc.userRepository.GetByID(ctx, input.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, entity.ErrUserNotFound
+	}
+	return group, user, nil
+}
+
+// checkAssignable checks that the actor may assign every role of a group, as changing who
+// is a member of it gives or takes these roles
+func (uc *GroupUseCaseImpl) checkAssignable(ctx context.Context, group *entity.Group) error {
+	roles, err := uc.groupRoles(group)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if err := uc.checkRoleAssignable(ctx, role, group.Domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRoleAssignable checks that the actor may assign a role in a domain
+func (uc *GroupUseCaseImpl) checkRoleAssignable(ctx context.Context, role, domain string) error {
+	allowed, err := uc.casbinService.CanAssignRole(ctx, role, domain)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entity.ErrRoleNotAssignable
+	}
+	return nil
+}
+
+// groupRoles returns the roles assigned to a group, sorted
+func (uc *GroupUseCaseImpl) groupRoles(group *entity.Group) ([]string, error) {
+	roles, err := uc.casbinService.GetRolesForUser(group.Subject(), group.Domain)
+	if err != nil {
+		return nil, err
+	}
+	return splitGroups(roles).roles, nil
+}
+
+// toOutput converts a group to a group output, along with its roles
+func (uc *GroupUseCaseImpl) toOutput(group *entity.Group) (*dto.GroupOutput, error) {
+	roles, err := uc.groupRoles(group)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.GroupOutput{Group: group, Roles: roles}, nil
+}
+
+// subjectRoles holds the roles and groups among the roles of a Casbin subject
+type subjectRoles struct {
+	roles []string
+	// groups are the Casbin subjects of the groups
+	groups []string
+}
+
+// splitGroups sets the groups apart from the other roles of a subject, both sorted.
+// The slices are never nil.
+func splitGroups(roles []string) subjectRoles {
+	split := subjectRoles{roles: []string{}, groups: []string{}}
+	for _, role := range roles {
+		if entity.IsGroupSubject(role) {
+			split.groups = append(split.groups, role)
+		} else {
+			split.roles = append(split.roles, role)
+		}
+	}
+	sort.Strings(split.roles)
+	sort.Strings(split.groups)
+	return split
+}
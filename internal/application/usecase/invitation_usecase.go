@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	mailer "github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/mail"
+)
+
+// InvitationUseCaseImpl handles invitation-related business logic
+// It implements the interfaces.InvitationUseCase interface
+type InvitationUseCaseImpl struct {
+	invitationRepository repository.InvitationRepository
+	userRepository       repository.UserRepository
+	jwtService           *auth.JWTService
+	casbinService        *auth.CasbinService
+	mailer               mailer.Mailer
+	registration         *entity.RegistrationPolicy
+	validity             time.Duration
+	invitationURL        string
+}
+
+// NewInvitationUseCase creates a new InvitationUseCaseImpl.
+// Invitations can be accepted for validity, through invitationURL in which {token} is replaced by their token,
+// unless the registration policy is disabled.
+func NewInvitationUseCase(
+	invitationRepository repository.InvitationRepository,
+	userRepository repository.UserRepository,
+	jwtService *auth.JWTService,
+	casbinService *auth.CasbinService,
+	mailer mailer.Mailer,
+	registration *entity.RegistrationPolicy,
+	validity time.Duration,
+	invitationURL string,
+) interfaces.InvitationUseCase {
+	return &InvitationUseCaseImpl{
+		invitationRepository: invitationRepository,
+		userRepository:       userRepository,
+		jwtService:           jwtService,
+		casbinService:        casbinService,
+		mailer:               mailer,
+		registration:         registration,
+		validity:             validity,
+		invitationURL:        invitationURL,
+	}
+}
+
+// Create invites someone to create an account holding a role, sending them the invitation by email.
+// An invitation that cannot be sent is revoked, as nobody else knows its token.
+func (uc *InvitationUseCaseImpl) Create(ctx context.Context, input dto.CreateInvitationInput) (*entity.Invitation, error) {
+	if err := uc.registration.AllowsInvitations(); err != nil {
+		return nil, err
+	}
+
+	if address, err := mail.ParseAddress(input.Email); err != nil || address.Address != input.Email {
+		return nil, fmt.Errorf("invalid email %q", input.Email)
+	}
+
+	domain := input.Domain
+	if domain == "" {
+		domain = auth.DomainDefault
+	}
+	roles, err := uc.casbinService.GetRoles(domain)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(roles, input.Role) {
+		return nil, fmt.Errorf("unknown role %q in domain %s", input.Role, domain)
+	}
+
+	// Inviting someone assigns them the role, which the inviter must be allowed to do
+	allowed, err := uc.casbinService.CanAssignRole(ctx, input.Role, domain)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, entity.ErrRoleNotAssignable
+	}
+
+	taken, err := uc.userRepository.EmailExists(ctx, input.Email)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, entity.ErrEmailTaken
+	}
+
+	filter := repository.InvitationFilter{Status: entity.InvitationStatusPending, Email: input.Email, Now: time.Now()}
+	_, pending, err := uc.invitationRepository.List(ctx, filter, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, errors.New("a pending invitation was already sent to this email")
+	}
+
+	invitation, token, err := entity.NewInvitation(input.Email, input.Role, domain, auth.ActorFromContext(ctx), uc.validity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.invitationRepository.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	if err := uc.mailer.Send(ctx, uc.invitationMessage(invitation, token)); err != nil {
+		log.Printf("Failed to send invitation %d to %s: %v", invitation.ID, invitation.Email, err)
+		if revokeErr := invitation.Revoke(); revokeErr == nil {
+			if updateErr := uc.invitationRepository.Update(ctx, invitation); updateErr != nil {
+				log.Printf("Failed to revoke unsent invitation %d: %v", invitation.ID, updateErr)
+			}
+		}
+		return nil, entity.ErrInvitationNotSent
+	}
+
+	log.Printf("Invitation %d sent by %s to %s as %s in %s", invitation.ID, invitation.InvitedBy, invitation.Email, invitation.Role, invitation.Domain)
+	return invitation, nil
+}
+
+// invitationMessage returns the email sending an invitation, with the link to accept it
+func (uc *InvitationUseCaseImpl) invitationMessage(invitation *entity.Invitation, token string) mailer.Message {
+	link := strings.ReplaceAll(uc.invitationURL, "{token}", url.QueryEscape(token))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "You have been invited to create an account with the %s role.\n\n", invitation.Role)
+	fmt.Fprintf(&body, "Choose your username and password at:\n%s\n\n", link)
+	fmt.Fprintf(&body, "The invitation expires on %s.\n", invitation.ExpiresAt.UTC().Format(time.RFC1123))
+
+	return mailer.Message{
+		To:      invitation.Email,
+		Subject: "You are invited to create an account",
+		Body:    body.String(),
+	}
+}
+
+// GetByID gets an invitation by ID
+func (uc *InvitationUseCaseImpl) GetByID(ctx context.Context, id uint) (*entity.Invitation, error) {
+	invitation, err := uc.invitationRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, entity.ErrInvitationNotFound
+	}
+	return invitation, nil
+}
+
+// List lists invitations with pagination, newest first
+func (uc *InvitationUseCaseImpl) List(ctx context.Context, input dto.ListInvitationsInput) (*dto.ListInvitationsOutput, error) {
+	filter := repository.InvitationFilter{
+		Status: input.Status,
+		Email:  input.Email,
+		Now:    time.Now(),
+	}
+	offset := (input.Page - 1) * input.Limit
+
+	invitations, count, err := uc.invitationRepository.List(ctx, filter, offset, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ListInvitationsOutput{
+		Invitations: invitations,
+		TotalCount:  count,
+	}, nil
+}
+
+// Revoke withdraws a pending invitation
+func (uc *InvitationUseCaseImpl) Revoke(ctx context.Context, id uint) (*entity.Invitation, error) {
+	invitation, err := uc.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := invitation.Revoke(); err != nil {
+		return nil, err
+	}
+	if err := uc.invitationRepository.Update(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Invitation %d to %s revoked by %s", invitation.ID, invitation.Email, auth.ActorFromContext(ctx))
+	return invitation, nil
+}
+
+// Accept creates the account of an invitee with the username and password they chose,
+// and assigns them the role they were invited to. The invitation is claimed first, so that
+// of concurrent accepts only one creates an account.
+func (uc *InvitationUseCaseImpl) Accept(ctx context.Context, input dto.AcceptInvitationInput) (*dto.AcceptInvitationOutput, error) {
+	invitation, err := uc.invitationRepository.GetByTokenHash(ctx, entity.HashInvitationToken(input.Token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, entity.ErrInvitationNotFound
+	}
+	if status := invitation.CurrentStatus(time.Now()); status != entity.InvitationStatusPending {
+		return nil, fmt.Errorf("%w: it is %s", entity.ErrInvitationUnusable, status)
+	}
+	if err := uc.registration.AllowsInvitations(); err != nil {
+		return nil, err
+	}
+
+	if len(input.Password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+
+	if err := checkUsernameAvailable(ctx, uc.userRepository, uc.casbinService, input.Username); err != nil {
+		return nil, err
+	}
+
+	// Someone may have registered with the email since the invitation was sent
+	taken, err := uc.userRepository.EmailExists(ctx, invitation.Email)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, entity.ErrEmailTaken
+	}
+
+	user, err := entity.NewUser(input.Username, invitation.Email, input.Password, invitation.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	// The invitation is claimed before the account is created, so that it is used only once
+	// however many accepts run concurrently
+	if err := invitation.Accept(user.Username); err != nil {
+		return nil, err
+	}
+	if err := uc.invitationRepository.Claim(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	if err := uc.userRepository.Create(ctx, user); err != nil {
+		uc.releaseInvitation(ctx, invitation)
+		return nil, err
+	}
+
+	// The role is granted on behalf of the inviter
+	if _, err := uc.casbinService.AddRoleForUser(auth.WithActor(ctx, invitation.InvitedBy), user.Username, invitation.Role, invitation.Domain); err != nil {
+		// A user without their role would be left behind otherwise
+		if deleteErr := uc.userRepository.PermanentDelete(ctx, user.ID); deleteErr != nil {
+			log.Printf("Failed to remove user %s left without role: %v", user.Username, deleteErr)
+			return nil, err
+		}
+		uc.releaseInvitation(ctx, invitation)
+		return nil, err
+	}
+
+	token, err := uc.jwtService.GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Invitation %d accepted: %s joined as %s in %s", invitation.ID, user.Username, invitation.Role, invitation.Domain)
+	return &dto.AcceptInvitationOutput{
+		User:  user,
+		Token: token,
+	}, nil
+}
+
+// releaseInvitation puts back to pending an invitation claimed for an account that could not be created,
+// so that the invitee can try again. Should that fail, the invitation stays used up rather than reusable.
+func (uc *InvitationUseCaseImpl) releaseInvitation(ctx context.Context, invitation *entity.Invitation) {
+	if err := uc.invitationRepository.Release(ctx, invitation); err != nil {
+		log.Printf("Failed to release invitation %d: %v", invitation.ID, err)
+	}
+}
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/pkg/mergepatch"
+)
+
+// applyMergePatch applies a JSON merge patch to the fields of a resource a patch may change, given
+// as a struct with JSON tags, and returns the patched fields along with the changes made. Required
+// fields cannot be removed; unknown fields and values of the wrong type are rejected.
+func applyMergePatch[T any](fields T, patch map[string]any, required ...string) (T, []entity.FieldChange, error) {
+	var patched T
+
+	before, err := toDocument(fields)
+	if err != nil {
+		return patched, nil, err
+	}
+
+	after := mergepatch.Apply(before, patch)
+	for _, name := range required {
+		if _, ok := after[name]; !ok {
+			return patched, nil, fmt.Errorf("%w: %s cannot be removed", entity.ErrInvalidPatch, name)
+		}
+	}
+
+	data, err := json.Marshal(after)
+	if err != nil {
+		return patched, nil, fmt.Errorf("%w: %v", entity.ErrInvalidPatch, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return patched, nil, fmt.Errorf("%w: %v", entity.ErrInvalidPatch, err)
+	}
+
+	return patched, fieldChanges("", before, after), nil
+}
+
+// toDocument converts fields to the JSON object they are encoded as
+func toDocument(fields any) (map[string]any, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// fieldChanges lists the members that differ between two JSON objects, sorted by name.
+// Nested objects are compared member by member, their members being named by their path.
+func fieldChanges(prefix string, before, after map[string]any) []entity.FieldChange {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []entity.FieldChange
+	for _, name := range names {
+		from, to := before[name], after[name]
+		fromObject, fromIsObject := from.(map[string]any)
+		toObject, toIsObject := to.(map[string]any)
+		if (fromIsObject || toIsObject) && (fromIsObject || from == nil) && (toIsObject || to == nil) {
+			changes = append(changes, fieldChanges(prefix+name+".", fromObject, toObject)...)
+			continue
+		}
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, entity.FieldChange{Field: prefix + name, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// authorizeFieldChanges checks that the actor of ctx may change the restricted fields a patch changes,
+// see auth.CasbinService.CanUpdateField
+func authorizeFieldChanges(ctx context.Context, casbinService *auth.CasbinService, resource, dom string, changes []entity.FieldChange, restricted ...string) error {
+	checked := map[string]bool{}
+	for _, change := range changes {
+		field, _, _ := strings.Cut(change.Field, ".")
+		if !slices.Contains(restricted, field) || checked[field] {
+			continue
+		}
+		checked[field] = true
+
+		allowed, err := casbinService.CanUpdateField(ctx, resource, field, dom)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s", entity.ErrFieldChangeNotAllowed, field)
+		}
+	}
+	return nil
+}
+
+// changedFields returns the names of the fields changed by a patch, for logging
+func changedFields(changes []entity.FieldChange) string {
+	fields := make([]string, len(changes))
+	for i, change := range changes {
+		fields[i] = change.Field
+	}
+	return strings.Join(fields, ", ")
+}
+
+// hasFieldChange reports whether a patch changed a field or any member of it
+func hasFieldChange(changes []entity.FieldChange, field string) bool {
+	for _, change := range changes {
+		if change.Field == field || strings.HasPrefix(change.Field, field+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		patch       string
+		wantChanges []entity.FieldChange
+		wantErr     error
+	}{
+		{patch: `{"username":"jdoe","active":true}`},
+		{
+			patch: `{"role":"admin","locale":null}`,
+			wantChanges: []entity.FieldChange{
+				{Field: "locale", From: "en"},
+				{Field: "role", From: "user", To: "admin"},
+			},
+		},
+		{
+			patch: `{"attributes":{"floor":4,"department":null}}`,
+			wantChanges: []entity.FieldChange{
+				{Field: "attributes.department", From: "sales"},
+				{Field: "attributes.floor", From: float64(3), To: float64(4)},
+			},
+		},
+		{patch: `{"email":null}`, wantErr: entity.ErrInvalidPatch},
+		{patch: `{"password":"secret"}`, wantErr: entity.ErrInvalidPatch},
+		{patch: `{"active":"yes"}`, wantErr: entity.ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		current := userFields{
+			Username:   "jdoe",
+			Email:      "jdoe@example.com",
+			Locale:     "en",
+			Attributes: map[string]any{"department": "sales", "floor": float64(3)},
+			Active:     true,
+			Role:       "user",
+		}
+		var patch map[string]any
+		if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+			t.Fatalf("invalid patch %s: %v", tt.patch, err)
+		}
+
+		_, changes, err := applyMergePatch(current, patch, "username", "email", "active", "role")
+		if !errors.Is(err, tt.wantErr) || !reflect.DeepEqual(changes, tt.wantChanges) {
+			t.Errorf("applyMergePatch(%s) = %+v, %v, want %+v, %v", tt.patch, changes, err, tt.wantChanges, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// PolicyUseCaseImpl handles policy management business logic
+// It implements the interfaces.PolicyUseCase interface
+type PolicyUseCaseImpl struct {
+	casbinService           *auth.CasbinService
+	policyVersionRepository repository.PolicyVersionRepository
+}
+
+// NewPolicyUseCase creates a new PolicyUseCaseImpl
+func NewPolicyUseCase(
+	casbinService *auth.CasbinService,
+	policyVersionRepository repository.PolicyVersionRepository,
+) interfaces.PolicyUseCase {
+	return &PolicyUseCaseImpl{
+		casbinService:           casbinService,
+		policyVersionRepository: policyVersionRepository,
+	}
+}
+
+// toPolicyRule converts a policy to the rule stored by Casbin
+func toPolicyRule(policy dto.Policy) (entity.PolicyRule, error) {
+	if policy.Subject == "" || policy.Domain == "" || policy.Object == "" || policy.Action == "" {
+		return entity.PolicyRule{}, errors.New("subject, domain, object and action are required")
+	}
+
+	condition := policy.Condition
+	if condition == "" {
+		condition = auth.ConditionAlways
+	}
+
+	effect := policy.Effect
+	if effect == "" {
+		effect = auth.EffectAllow
+	}
+	if effect != auth.EffectAllow && effect != auth.EffectDeny {
+		return entity.PolicyRule{}, fmt.Errorf("invalid effect %q, expected %s or %s", effect, auth.EffectAllow, auth.EffectDeny)
+	}
+
+	return entity.NewPolicyRule("p", policy.Subject, policy.Domain, policy.Object, policy.Action, condition, auth.FormatEffect(effect, policy.Priority))
+}
+
+// toPolicy converts a rule stored by Casbin to a policy
+func toPolicy(rule entity.PolicyRule) (dto.Policy, error) {
+	if len(rule.Values) < 6 {
+		return dto.Policy{}, fmt.Errorf("%s: expected 6 fields", rule)
+	}
+
+	effect, priority, err := auth.ParseEffect(rule.Values[5])
+	if err != nil {
+		return dto.Policy{}, err
+	}
+
+	policy := dto.Policy{
+		Subject:  rule.Values[0],
+		Domain:   rule.Values[1],
+		Object:   rule.Values[2],
+		Action:   rule.Values[3],
+		Effect:   effect,
+		Priority: priority,
+	}
+	if rule.Values[4] != auth.ConditionAlways {
+		policy.Condition = rule.Values[4]
+	}
+
+	return policy, nil
+}
+
+// ListPolicies lists the policy rules
+func (uc *PolicyUseCaseImpl) ListPolicies(ctx context.Context, input dto.ListPoliciesInput) (*dto.ListPoliciesOutput, error) {
+	rules, err := uc.casbinService.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]dto.Policy, 0, len(rules))
+	for _, rule := range rules {
+		if rule.PType != "p" {
+			continue
+		}
+
+		policy, err := toPolicy(rule)
+		if err != nil {
+			return nil, err
+		}
+		if (input.Subject != "" && policy.Subject != input.Subject) || (input.Domain != "" && policy.Domain != input.Domain) {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+
+	return &dto.ListPoliciesOutput{
+		Policies: policies,
+	}, nil
+}
+
+// AddPolicy adds a policy rule
+func (uc *PolicyUseCaseImpl) AddPolicy(ctx context.Context, input dto.Policy) error {
+	rule, err := toPolicyRule(input)
+	if err != nil {
+		return err
+	}
+
+	added, err := uc.casbinService.AddPolicyRule(ctx, rule)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return auth.ErrPolicyExists
+	}
+
+	return nil
+}
+
+// RemovePolicy removes a policy rule
+func (uc *PolicyUseCaseImpl) RemovePolicy(ctx context.Context, input dto.Policy) error {
+	rule, err := toPolicyRule(input)
+	if err != nil {
+		return err
+	}
+
+	removed, err := uc.casbinService.RemovePolicyRule(ctx, rule)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return auth.ErrPolicyNotFound
+	}
+
+	return nil
+}
+
+// Export exports the full policy set
+func (uc *PolicyUseCaseImpl) Export(ctx context.Context, input dto.ExportPoliciesInput) (*dto.ExportPoliciesOutput, error) {
+	rules, err := uc.casbinService.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave out fields holding their default value, as they would be written by hand
+	data, err := auth.FormatPolicies(uc.casbinService.CompactPolicies(rules), input.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ExportPoliciesOutput{
+		Data:  data,
+		Count: len(rules),
+	}, nil
+}
+
+// Import imports a policy set, or only reports the changes it would make in dry-run mode
+func (uc *PolicyUseCaseImpl) Import(ctx context.Context, input dto.ImportPoliciesInput) (*dto.ImportPoliciesOutput, error) {
+	// Parse the imported rules
+	desired, err := auth.ParsePolicies(input.Data, input.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	desired = uc.casbinService.NormalizePolicies(desired)
+	if err := uc.casbinService.ValidatePolicies(desired); err != nil {
+		return nil, err
+	}
+
+	if input.DryRun {
+		current, err := uc.casbinService.GetAllPolicies()
+		if err != nil {
+			return nil, err
+		}
+		diff := auth.DiffPolicies(current, desired, input.Replace)
+		return &dto.ImportPoliciesOutput{Added: diff.Added, Removed: diff.Removed}, nil
+	}
+
+	// Compare against the current policy and apply the changes, with no other change in between
+	diff, err := uc.casbinService.UpdatePolicies(ctx, entity.PolicyActionImport, func(current []entity.PolicyRule) (auth.PolicyDiff, error) {
+		return auth.DiffPolicies(current, desired, input.Replace), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ImportPoliciesOutput{
+		Added:   diff.Added,
+		Removed: diff.Removed,
+		Applied: !diff.IsEmpty(),
+	}, nil
+}
+
+// ListVersions lists policy versions with pagination, newest first
+func (uc *PolicyUseCaseImpl) ListVersions(ctx context.Context, input dto.ListPolicyVersionsInput) (*dto.ListPolicyVersionsOutput, error) {
+	// Calculate offset
+	offset := (input.Page - 1) * input.Limit
+
+	versions, count, err := uc.policyVersionRepository.List(ctx, offset, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ListPolicyVersionsOutput{
+		Versions:   versions,
+		TotalCount: count,
+	}, nil
+}
+
+// GetVersion gets a policy version
+func (uc *PolicyUseCaseImpl) GetVersion(ctx context.Context, version uint) (*entity.PolicyVersion, error) {
+	return uc.policyVersionRepository.GetByVersion(ctx, version)
+}
+
+// DiffVersions compares the policy at two versions
+func (uc *PolicyUseCaseImpl) DiffVersions(ctx context.Context, input dto.DiffPolicyVersionsInput) (*dto.DiffPolicyVersionsOutput, error) {
+	from, err := uc.casbinService.GetPoliciesAtVersion(ctx, input.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := uc.casbinService.GetPoliciesAtVersion(ctx, input.To)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := auth.DiffPolicies(from, to, true)
+
+	return &dto.DiffPolicyVersionsOutput{
+		From:    input.From,
+		To:      input.To,
+		Added:   diff.Added,
+		Removed: diff.Removed,
+	}, nil
+}
+
+// Rollback restores the policy as it was at a version, recorded as a new version
+func (uc *PolicyUseCaseImpl) Rollback(ctx context.Context, input dto.RollbackPolicyInput) (*entity.PolicyVersion, error) {
+	return uc.casbinService.Rollback(ctx, input.Version)
+}
+
+// DecisionCacheStats reports the hits and misses of the authorization decision cache
+func (uc *PolicyUseCaseImpl) DecisionCacheStats(ctx context.Context) (*dto.DecisionCacheStatsOutput, error) {
+	stats := uc.casbinService.DecisionCacheStats()
+
+	return &dto.DecisionCacheStatsOutput{
+		Enabled:       stats.Enabled,
+		TTL:           stats.TTL,
+		Size:          stats.Size,
+		Capacity:      stats.Capacity,
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		HitRatio:      stats.HitRatio(),
+		Evictions:     stats.Evictions,
+		Invalidations: stats.Invalidations,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/storage"
+)
+
+// PrivacyUseCaseImpl handles data subject request business logic
+// It implements the interfaces.PrivacyUseCase interface
+type PrivacyUseCaseImpl struct {
+	userRepository           repository.UserRepository
+	erasureRequestRepository repository.ErasureRequestRepository
+	personalDataRepository   repository.PersonalDataRepository
+	roleGrantRepository      repository.RoleGrantRepository
+	casbinService            *auth.CasbinService
+	blobStore                storage.BlobStore
+	erasureDelay             time.Duration
+}
+
+// NewPrivacyUseCase creates a new PrivacyUseCaseImpl.
+// Users are erased erasureDelay after the erasure is requested, until when it can be cancelled.
+func NewPrivacyUseCase(
+	userRepository repository.UserRepository,
+	erasureRequestRepository repository.ErasureRequestRepository,
+	personalDataRepository repository.PersonalDataRepository,
+	roleGrantRepository repository.RoleGrantRepository,
+	casbinService *auth.CasbinService,
+	blobStore storage.BlobStore,
+	erasureDelay time.Duration,
+) interfaces.PrivacyUseCase {
+	return &PrivacyUseCaseImpl{
+		userRepository:           userRepository,
+		erasureRequestRepository: erasureRequestRepository,
+		personalDataRepository:   personalDataRepository,
+		roleGrantRepository:      roleGrantRepository,
+		casbinService:            casbinService,
+		blobStore:                blobStore,
+		erasureDelay:             erasureDelay,
+	}
+}
+
+// GetDataSubject gets a user whose data may be exported or erased, soft deleted or not
+func (uc *PrivacyUseCaseImpl) GetDataSubject(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := uc.userRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = uc.userRepository.GetDeletedByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// GetOwnerRole gets the highest of the effective roles of a data subject in the default domain
+func (uc *PrivacyUseCaseImpl) GetOwnerRole(ctx context.Context, user *entity.User) (string, error) {
+	return ownerRole(uc.casbinService, user)
+}
+
+// Export gathers everything kept about a user, soft deleted or not: their account, their roles,
+// the records referring to them and their avatar
+func (uc *PrivacyUseCaseImpl) Export(ctx context.Context, id uint) (*dto.UserDataExport, error) {
+	user, err := uc.GetDataSubject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := resolveRoles(uc.casbinService, user.Username, auth.DomainDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := uc.personalDataRepository.Find(ctx, user.ID, dataSubject(user))
+	if err != nil {
+		return nil, err
+	}
+
+	avatar, err := loadAvatar(ctx, uc.blobStore, user)
+	if err != nil && !errors.Is(err, entity.ErrAvatarNotFound) {
+		return nil, err
+	}
+
+	return &dto.UserDataExport{
+		ExportedAt:      time.Now(),
+		User:            user,
+		Roles:           roles,
+		RoleGrants:      records.RoleGrants,
+		Invitations:     records.Invitations,
+		PolicyVersions:  records.PolicyVersions,
+		UserImports:     records.UserImports,
+		Groups:          records.Groups,
+		ErasureRequests: records.ErasureRequests,
+		Avatar:          avatar,
+	}, nil
+}
+
+// RequestErasure schedules the erasure of a user, which can be cancelled until it is carried out
+func (uc *PrivacyUseCaseImpl) RequestErasure(ctx context.Context, input dto.RequestErasureInput) (*entity.ErasureRequest, error) {
+	user, err := uc.GetDataSubject(ctx, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsErased() {
+		return nil, entity.ErrUserErased
+	}
+
+	latest, err := uc.erasureRequestRepository.GetLatestByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Status == entity.ErasureStatusPending {
+		return nil, entity.ErrErasurePending
+	}
+
+	request, err := entity.NewErasureRequest(user.ID, input.Reason, auth.ActorFromContext(ctx), uc.erasureDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.erasureRequestRepository.Create(ctx, request); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Erasure of user %d requested by %s for %s", user.ID, request.RequestedBy, request.ScheduledAt.Format("2006-01-02T15:04:05Z07:00"))
+	return request, nil
+}
+
+// GetErasure gets the latest erasure request of a user
+func (uc *PrivacyUseCaseImpl) GetErasure(ctx context.Context, userID uint) (*entity.ErasureRequest, error) {
+	request, err := uc.erasureRequestRepository.GetLatestByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, entity.ErrErasureRequestNotFound
+	}
+	return request, nil
+}
+
+// CancelErasure cancels the pending erasure request of a user
+func (uc *PrivacyUseCaseImpl) CancelErasure(ctx context.Context, userID uint) (*entity.ErasureRequest, error) {
+	request, err := uc.GetErasure(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := request.Cancel(auth.ActorFromContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	if err := uc.erasureRequestRepository.Update(ctx, request); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Erasure of user %d cancelled by %s", userID, request.CancelledBy)
+	return request, nil
+}
+
+// ProcessDue erases the users whose erasure requests are due
+func (uc *PrivacyUseCaseImpl) ProcessDue(ctx context.Context) (*dto.ProcessErasuresOutput, error) {
+	requests, err := uc.erasureRequestRepository.ListDue(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dto.ProcessErasuresOutput{}
+	for _, request := range requests {
+		// The changes are attributed to whoever requested the erasure
+		if err := uc.erase(auth.WithActor(ctx, request.RequestedBy), request); err != nil {
+			return output, err
+		}
+		output.Erased++
+	}
+
+	return output, nil
+}
+
+// erase carries out an erasure request. The user's Casbin rules and open role grants are removed
+// first, then the user and the references to them are anonymized at once, so that a failure leaves
+// the request pending to be carried out again.
+func (uc *PrivacyUseCaseImpl) erase(ctx context.Context, request *entity.ErasureRequest) error {
+	now := time.Now()
+
+	user, err := uc.GetDataSubject(ctx, request.UserID)
+	if errors.Is(err, entity.ErrUserNotFound) {
+		// Purged since the request, leaving nothing to erase
+		if err := request.Complete(now); err != nil {
+			return err
+		}
+		return uc.erasureRequestRepository.Update(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+
+	former := dataSubject(user)
+	actor := auth.ActorFromContext(ctx)
+
+	records, err := uc.personalDataRepository.Find(ctx, user.ID, former)
+	if err != nil {
+		return err
+	}
+	for _, grant := range records.RoleGrants {
+		if grant.Subject != former.Username || grant.Revoke(actor) != nil {
+			continue
+		}
+		if err := uc.roleGrantRepository.Update(ctx, grant); err != nil {
+			return err
+		}
+	}
+
+	removed, err := uc.casbinService.DeleteSubjectInAllDomains(ctx, former.Username)
+	if err != nil {
+		return err
+	}
+
+	avatarKey := user.AvatarKey
+	user.Erase(now)
+	if err := request.Complete(now); err != nil {
+		return err
+	}
+
+	if err := uc.personalDataRepository.Erase(ctx, user, request, former, dataSubject(user)); err != nil {
+		return err
+	}
+
+	if avatarKey != "" {
+		if err := uc.blobStore.Delete(ctx, avatarKey); err != nil {
+			log.Printf("Failed to delete avatar %s: %v", avatarKey, err)
+		}
+	}
+
+	log.Printf("User %d erased as %s, %d Casbin rules removed", user.ID, user.Username, removed)
+	return nil
+}
+
+// dataSubject returns how a user is referred to in other records
+func dataSubject(user *entity.User) entity.DataSubject {
+	return entity.DataSubject{
+		Username: user.Username,
+		Email:    user.Email,
+		Actor:    auth.UserActor(user.Username),
+	}
+}
@@ -0,0 +1,285 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// RoleGrantUseCaseImpl handles time-bound role assignment business logic
+// It implements the interfaces.RoleGrantUseCase interface
+type RoleGrantUseCaseImpl struct {
+	roleGrantRepository repository.RoleGrantRepository
+	userRepository      repository.UserRepository
+	casbinService       *auth.CasbinService
+	approvalRequired    []string
+}
+
+// NewRoleGrantUseCase creates a new RoleGrantUseCaseImpl.
+// Grants of the roles in approvalRequired must be approved before they take effect.
+func NewRoleGrantUseCase(
+	roleGrantRepository repository.RoleGrantRepository,
+	userRepository repository.UserRepository,
+	casbinService *auth.CasbinService,
+	approvalRequired []string,
+) interfaces.RoleGrantUseCase {
+	return &RoleGrantUseCaseImpl{
+		roleGrantRepository: roleGrantRepository,
+		userRepository:      userRepository,
+		casbinService:       casbinService,
+		approvalRequired:    approvalRequired,
+	}
+}
+
+// Create grants a role to a subject for a period of time, pending approval for privileged roles.
+// Granting a role assigns it, which the granter must be allowed to do.
+func (uc *RoleGrantUseCaseImpl) Create(ctx context.Context, input dto.CreateRoleGrantInput) (*entity.RoleGrant, error) {
+	domain := input.Domain
+	if domain == "" {
+		domain = auth.DomainDefault
+	}
+	allowed, err := uc.casbinService.CanAssignRole(ctx, input.Role, domain)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, entity.ErrRoleNotAssignable
+	}
+
+	return uc.create(ctx, input, slices.Contains(uc.approvalRequired, input.Role))
+}
+
+// RequestElevation requests a role for the caller, always pending approval
+func (uc *RoleGrantUseCaseImpl) RequestElevation(ctx context.Context, input dto.CreateRoleGrantInput) (*entity.RoleGrant, error) {
+	return uc.create(ctx, input, true)
+}
+
+// create records a new grant and activates it right away when it is approved and due
+func (uc *RoleGrantUseCaseImpl) create(ctx context.Context, input dto.CreateRoleGrantInput, requiresApproval bool) (*entity.RoleGrant, error) {
+	if input.Domain == "" {
+		input.Domain = auth.DomainDefault
+	}
+
+	// Roles are granted to users only
+	if input.Domain == auth.DomainDefault {
+		user, err := uc.userRepository.GetByUsername(ctx, input.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("user not found")
+		}
+	}
+
+	// A role assigned permanently would be removed when the grant expires
+	roles, err := uc.casbinService.GetRolesForUser(input.Subject, input.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(roles, input.Role) {
+		return nil, fmt.Errorf("%s already holds the %s role", input.Subject, input.Role)
+	}
+
+	grant, err := entity.NewRoleGrant(
+		input.Subject,
+		input.Role,
+		input.Domain,
+		input.Reason,
+		auth.ActorFromContext(ctx),
+		input.StartsAt,
+		input.ExpiresAt,
+		requiresApproval,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.roleGrantRepository.Create(ctx, grant); err != nil {
+		return nil, err
+	}
+
+	if err := uc.activateIfDue(ctx, grant, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// GetByID gets a role grant by ID
+func (uc *RoleGrantUseCaseImpl) GetByID(ctx context.Context, id uint) (*entity.RoleGrant, error) {
+	return uc.roleGrantRepository.GetByID(ctx, id)
+}
+
+// List lists role grants with pagination, newest first
+func (uc *RoleGrantUseCaseImpl) List(ctx context.Context, input dto.ListRoleGrantsInput) (*dto.ListRoleGrantsOutput, error) {
+	// Calculate offset
+	offset := (input.Page - 1) * input.Limit
+
+	filter := repository.RoleGrantFilter{
+		Status:  input.Status,
+		Subject: input.Subject,
+	}
+
+	grants, count, err := uc.roleGrantRepository.List(ctx, filter, offset, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ListRoleGrantsOutput{
+		Grants:     grants,
+		TotalCount: count,
+	}, nil
+}
+
+// Approve approves a pending role grant
+func (uc *RoleGrantUseCaseImpl) Approve(ctx context.Context, id uint) (*entity.RoleGrant, error) {
+	grant, err := uc.getGrant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := grant.Approve(auth.ActorFromContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	if err := uc.roleGrantRepository.Update(ctx, grant); err != nil {
+		return nil, err
+	}
+
+	if err := uc.activateIfDue(ctx, grant, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// Reject rejects a pending role grant
+func (uc *RoleGrantUseCaseImpl) Reject(ctx context.Context, id uint) (*entity.RoleGrant, error) {
+	grant, err := uc.getGrant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := grant.Reject(auth.ActorFromContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	if err := uc.roleGrantRepository.Update(ctx, grant); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// Revoke withdraws a role grant, unassigning the role if it is active
+func (uc *RoleGrantUseCaseImpl) Revoke(ctx context.Context, id uint) (*entity.RoleGrant, error) {
+	grant, err := uc.getGrant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wasActive := grant.Status == entity.RoleGrantStatusActive
+	if err := grant.Revoke(auth.ActorFromContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	if wasActive {
+		if err := uc.unassignRole(ctx, grant); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := uc.roleGrantRepository.Update(ctx, grant); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// ProcessDue activates the grants whose start time has come and expires the grants past their expiry
+func (uc *RoleGrantUseCaseImpl) ProcessDue(ctx context.Context) (*dto.ProcessRoleGrantsOutput, error) {
+	now := time.Now()
+
+	grants, err := uc.roleGrantRepository.ListDue(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dto.ProcessRoleGrantsOutput{}
+	for _, grant := range grants {
+		if grant.IsExpired(now) {
+			if grant.Status == entity.RoleGrantStatusActive {
+				if err := uc.unassignRole(ctx, grant); err != nil {
+					return output, err
+				}
+			}
+
+			grant.Expire()
+			if err := uc.roleGrantRepository.Update(ctx, grant); err != nil {
+				return output, err
+			}
+
+			log.Printf("Role grant %d expired: %s no longer holds %s in %s", grant.ID, grant.Subject, grant.Role, grant.Domain)
+			output.Expired++
+			continue
+		}
+
+		if grant.IsDue(now) {
+			if err := uc.activateIfDue(ctx, grant, now); err != nil {
+				return output, err
+			}
+			output.Activated++
+		}
+	}
+
+	return output, nil
+}
+
+// getGrant gets a role grant that must exist
+func (uc *RoleGrantUseCaseImpl) getGrant(ctx context.Context, id uint) (*entity.RoleGrant, error) {
+	grant, err := uc.roleGrantRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if grant == nil {
+		return nil, entity.ErrRoleGrantNotFound
+	}
+	return grant, nil
+}
+
+// activateIfDue assigns the role of an approved grant whose start time has come
+func (uc *RoleGrantUseCaseImpl) activateIfDue(ctx context.Context, grant *entity.RoleGrant, now time.Time) error {
+	if !grant.IsDue(now) {
+		return nil
+	}
+
+	if _, err := uc.casbinService.AddRoleForUser(ctx, grant.Subject, grant.Role, grant.Domain); err != nil {
+		return err
+	}
+
+	grant.Activate()
+	return uc.roleGrantRepository.Update(ctx, grant)
+}
+
+// unassignRole removes the role of an active grant, unless another active grant still holds it
+func (uc *RoleGrantUseCaseImpl) unassignRole(ctx context.Context, grant *entity.RoleGrant) error {
+	count, err := uc.roleGrantRepository.CountActive(ctx, grant.Subject, grant.Role, grant.Domain, grant.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = uc.casbinService.DeleteRoleForUser(ctx, grant.Subject, grant.Role, grant.Domain)
+	return err
+}
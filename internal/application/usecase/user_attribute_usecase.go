@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"log"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// UserAttributeUseCaseImpl handles the business logic of custom user attribute definitions
+// It implements the interfaces.UserAttributeUseCase interface
+type UserAttributeUseCaseImpl struct {
+	userAttributeRepository repository.UserAttributeRepository
+	userRepository          repository.UserRepository
+}
+
+// NewUserAttributeUseCase creates a new UserAttributeUseCaseImpl
+func NewUserAttributeUseCase(
+	userAttributeRepository repository.UserAttributeRepository,
+	userRepository repository.UserRepository,
+) interfaces.UserAttributeUseCase {
+	return &UserAttributeUseCaseImpl{
+		userAttributeRepository: userAttributeRepository,
+		userRepository:          userRepository,
+	}
+}
+
+// List lists the attribute definitions, sorted by name
+func (uc *UserAttributeUseCaseImpl) List(ctx context.Context) ([]*entity.UserAttribute, error) {
+	return uc.userAttributeRepository.List(ctx)
+}
+
+// Put defines an attribute, or changes its definition. Values users already hold are not
+// checked against the new definition, only values written from then on.
+func (uc *UserAttributeUseCaseImpl) Put(ctx context.Context, input dto.PutUserAttributeInput) (*dto.PutUserAttributeOutput, error) {
+	attribute, err := uc.userAttributeRepository.GetByName(ctx, input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if attribute != nil {
+		if err := attribute.Update(input.Type, input.Description, input.Required, input.Options); err != nil {
+			return nil, err
+		}
+		if err := uc.userAttributeRepository.Update(ctx, attribute); err != nil {
+			return nil, err
+		}
+
+		log.Printf("User attribute %s changed by %s", attribute.Name, auth.ActorFromContext(ctx))
+		return &dto.PutUserAttributeOutput{Attribute: attribute}, nil
+	}
+
+	attribute, err = entity.NewUserAttribute(input.Name, input.Type, input.Description, input.Required, input.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.userAttributeRepository.Create(ctx, attribute); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User attribute %s defined by %s", attribute.Name, auth.ActorFromContext(ctx))
+	return &dto.PutUserAttributeOutput{Attribute: attribute, Created: true}, nil
+}
+
+// Delete deletes an attribute definition and removes the attribute from every user, so
+// that users are not left with values they could not write back
+func (uc *UserAttributeUseCaseImpl) Delete(ctx context.Context, name string) error {
+	attribute, err := uc.userAttributeRepository.GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if attribute == nil {
+		return entity.ErrUserAttributeNotFound
+	}
+
+	if err := uc.userRepository.RemoveAttribute(ctx, attribute.Name); err != nil {
+		return err
+	}
+	if err := uc.userAttributeRepository.Delete(ctx, attribute.ID); err != nil {
+		return err
+	}
+
+	log.Printf("User attribute %s deleted by %s", attribute.Name, auth.ActorFromContext(ctx))
+	return nil
+}
@@ -0,0 +1,399 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+const (
+	// minPasswordLength is the minimum length of an imported password, as for registration
+	minPasswordLength = 6
+	// userImportSaveInterval is the number of rows processed between two saves of the progress of an import
+	userImportSaveInterval = 50
+	// userImportStaleAfter is how long a running import may go without saving its progress
+	// before it is considered abandoned, e.g. by a crashed instance, and processed again
+	userImportStaleAfter = 5 * time.Minute
+)
+
+// userFileColumns are the columns of a user file, active being optional
+var userFileColumns = []string{"username", "email", "password", "role", "active"}
+
+// userRecord is a row of a user file
+type userRecord struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	// Active defaults to true
+	Active *bool `json:"active"`
+
+	// err is the reason the row could not be read
+	err error
+}
+
+// UserImportUseCaseImpl handles bulk user import business logic
+// It implements the interfaces.UserImportUseCase interface
+type UserImportUseCaseImpl struct {
+	userImportRepository repository.UserImportRepository
+	userRepository       repository.UserRepository
+	casbinService        *auth.CasbinService
+}
+
+// NewUserImportUseCase creates a new UserImportUseCaseImpl
+func NewUserImportUseCase(
+	userImportRepository repository.UserImportRepository,
+	userRepository repository.UserRepository,
+	casbinService *auth.CasbinService,
+) interfaces.UserImportUseCase {
+	return &UserImportUseCaseImpl{
+		userImportRepository: userImportRepository,
+		userRepository:       userRepository,
+		casbinService:        casbinService,
+	}
+}
+
+// Import checks that a file can be read and queues the import of its rows.
+// Rows that cannot be read are reported as row errors once the import is processed.
+func (uc *UserImportUseCaseImpl) Import(ctx context.Context, input dto.ImportUsersInput) (*entity.UserImport, error) {
+	records, err := parseUserFile(input.Format, input.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the file has no rows")
+	}
+
+	userImport, err := entity.NewUserImport(input.Format, input.Data, len(records), input.DryRun, auth.ActorFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.userImportRepository.Create(ctx, userImport); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User import %d queued by %s: %d rows (dry run: %t)", userImport.ID, userImport.RequestedBy, userImport.TotalRows, userImport.DryRun)
+	return userImport, nil
+}
+
+// GetByID gets a user import by ID, along with its progress and row errors
+func (uc *UserImportUseCaseImpl) GetByID(ctx context.Context, id uint) (*entity.UserImport, error) {
+	userImport, err := uc.userImportRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if userImport == nil {
+		return nil, entity.ErrUserImportNotFound
+	}
+	return userImport, nil
+}
+
+// ProcessNext processes the next queued import and returns it, or nil when there is none.
+// When ctx is canceled, the import is put back in the queue to be carried on later.
+func (uc *UserImportUseCaseImpl) ProcessNext(ctx context.Context) (*entity.UserImport, error) {
+	userImport, err := uc.userImportRepository.ClaimNext(ctx, time.Now().Add(-userImportStaleAfter))
+	if err != nil || userImport == nil {
+		return nil, err
+	}
+
+	// Changes are attributed to the requester of the import
+	ctx = auth.WithActor(ctx, userImport.RequestedBy)
+
+	if err := uc.process(ctx, userImport); err != nil {
+		if ctx.Err() != nil {
+			userImport.Interrupt()
+			log.Printf("User import %d interrupted after %d of %d rows", userImport.ID, userImport.ProcessedRows, userImport.TotalRows)
+		} else {
+			userImport.Fail(err)
+			log.Printf("User import %d failed: %v", userImport.ID, err)
+		}
+	} else {
+		userImport.Complete()
+		log.Printf("User import %d completed: %d of %d rows succeeded (dry run: %t)", userImport.ID, userImport.SucceededRows, userImport.TotalRows, userImport.DryRun)
+	}
+
+	// The import is saved even when ctx is canceled, so that it can be carried on
+	if err := uc.userImportRepository.Update(context.WithoutCancel(ctx), userImport); err != nil {
+		return nil, err
+	}
+	return userImport, nil
+}
+
+// process imports the rows of an import not processed yet, saving its progress along the way
+func (uc *UserImportUseCaseImpl) process(ctx context.Context, userImport *entity.UserImport) error {
+	records, err := parseUserFile(userImport.Format, userImport.Data)
+	if err != nil {
+		return err
+	}
+
+	roles, err := uc.casbinService.GetRoles(auth.DomainDefault)
+	if err != nil {
+		return err
+	}
+
+	// Usernames and emails of the rows processed, to report duplicates within the file
+	seen := make(map[string]bool)
+	for _, record := range records[:userImport.ProcessedRows] {
+		seen["username:"+record.Username] = true
+		seen["email:"+strings.ToLower(record.Email)] = true
+	}
+
+	for _, record := range records[userImport.ProcessedRows:] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := uc.importRecord(ctx, record, roles, seen, userImport.DryRun); err != nil {
+			// Failing to reach the database or Casbin aborts the import, rather than failing every row
+			var rowErr *userRowError
+			if !errors.As(err, &rowErr) {
+				return err
+			}
+			userImport.RecordError(record.Username, err)
+		} else {
+			userImport.RecordSuccess()
+		}
+
+		if userImport.ProcessedRows%userImportSaveInterval == 0 {
+			userImport.UpdatedAt = time.Now()
+			if err := uc.userImportRepository.Update(ctx, userImport); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// userRowError is the reason a row of a user file is not imported
+type userRowError struct {
+	reason string
+}
+
+// Error returns the reason the row is not imported
+func (e *userRowError) Error() string {
+	return e.reason
+}
+
+// rowError returns a row error with a formatted reason
+func rowError(format string, args ...any) error {
+	return &userRowError{reason: fmt.Sprintf(format, args...)}
+}
+
+// importRecord validates a row of a user file and creates its user with its role,
+// unless in dry-run mode. Invalid rows are reported with a *userRowError.
+func (uc *UserImportUseCaseImpl) importRecord(ctx context.Context, record userRecord, roles []string, seen map[string]bool, dryRun bool) error {
+	if record.err != nil {
+		return rowError("%v", record.err)
+	}
+
+	if err := validateUserRecord(record, roles); err != nil {
+		return err
+	}
+
+	// The requester must be allowed to assign the role themselves
+	allowed, err := uc.casbinService.CanAssignRole(ctx, record.Role, auth.DomainDefault)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return rowError("not allowed to assign role %q", record.Role)
+	}
+
+	usernameKey, emailKey := "username:"+record.Username, "email:"+strings.ToLower(record.Email)
+	if seen[usernameKey] {
+		return rowError("username %q appears earlier in the file", record.Username)
+	}
+	if seen[emailKey] {
+		return rowError("email %q appears earlier in the file", record.Email)
+	}
+	seen[usernameKey], seen[emailKey] = true, true
+
+	if err := checkUsernameAvailable(ctx, uc.userRepository, uc.casbinService, record.Username); err != nil {
+		if errors.Is(err, entity.ErrUsernameTaken) || errors.Is(err, entity.ErrInvalidProfile) {
+			return rowError("%v", err)
+		}
+		return err
+	}
+
+	existingUser, err := uc.userRepository.GetByEmail(ctx, record.Email)
+	if err != nil {
+		return err
+	}
+	if existingUser != nil {
+		return rowError("email already exists")
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	user, err := entity.NewUser(record.Username, record.Email, record.Password, record.Role)
+	if err != nil {
+		return rowError("%v", err)
+	}
+	if record.Active != nil {
+		user.Active = *record.Active
+	}
+
+	// Soft deleted users still hold their username and email
+	if err := uc.userRepository.Create(ctx, user); err != nil {
+		return rowError("failed to create user: %v", err)
+	}
+
+	if _, err := uc.casbinService.AddRoleForUser(ctx, user.Username, user.Role, auth.DomainDefault); err != nil {
+		// A user without their role would be left behind otherwise
+		if deleteErr := uc.userRepository.PermanentDelete(ctx, user.ID); deleteErr != nil {
+			log.Printf("Failed to remove imported user %s left without role: %v", user.Username, deleteErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// validateUserRecord checks the fields of a row of a user file
+func validateUserRecord(record userRecord, roles []string) error {
+	if record.Username == "" {
+		return rowError("username cannot be empty")
+	}
+	if len(record.Username) > 255 {
+		return rowError("username cannot be longer than 255 characters")
+	}
+
+	if record.Email == "" {
+		return rowError("email cannot be empty")
+	}
+	if address, err := mail.ParseAddress(record.Email); err != nil || address.Address != record.Email {
+		return rowError("invalid email %q", record.Email)
+	}
+
+	if len(record.Password) < minPasswordLength {
+		return rowError("password must be at least %d characters long", minPasswordLength)
+	}
+
+	if record.Role == "" {
+		return rowError("role cannot be empty")
+	}
+	if !slices.Contains(roles, record.Role) {
+		return rowError("unknown role %q", record.Role)
+	}
+
+	return nil
+}
+
+// parseUserFile reads the rows of a CSV or JSONL user file. Rows that cannot be read are
+// returned with the reason, so that they are reported without failing the whole file.
+func parseUserFile(format string, data []byte) ([]userRecord, error) {
+	switch format {
+	case entity.UserFileFormatCSV:
+		return parseUserCSV(data)
+	case entity.UserFileFormatJSONL:
+		return parseUserJSONL(data), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q, expected %s or %s", format, entity.UserFileFormatCSV, entity.UserFileFormatJSONL)
+	}
+}
+
+// parseUserCSV reads the rows of a CSV user file, whose header names the columns
+func parseUserCSV(data []byte) ([]userRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the file is empty")
+		}
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(userFileColumns, name) {
+			return nil, fmt.Errorf("invalid header: unknown column %q, expected %s", name, strings.Join(userFileColumns, ", "))
+		}
+		columns[name] = i
+	}
+	for _, name := range userFileColumns[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("invalid header: missing column %q", name)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []userRecord
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			// The rest of the file cannot be trusted once quoting goes wrong
+			return nil, err
+		}
+
+		record := userRecord{
+			Username: field(row, "username"),
+			Email:    field(row, "email"),
+			Password: field(row, "password"),
+			Role:     field(row, "role"),
+			err:      err,
+		}
+		if active := field(row, "active"); active != "" && record.err == nil {
+			b, parseErr := strconv.ParseBool(active)
+			if parseErr != nil {
+				record.err = fmt.Errorf("invalid active %q: expected true or false", active)
+			}
+			record.Active = &b
+		}
+
+		records = append(records, record)
+	}
+}
+
+// parseUserJSONL reads the rows of a JSONL user file, skipping blank lines
+func parseUserJSONL(data []byte) []userRecord {
+	var records []userRecord
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var record userRecord
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			record = userRecord{err: fmt.Errorf("invalid JSON: %v", err)}
+		}
+
+		record.Username = strings.TrimSpace(record.Username)
+		record.Email = strings.TrimSpace(record.Email)
+		records = append(records, record)
+	}
+	return records
+}
@@ -1,83 +1,179 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Decodes GIF avatars
+	_ "image/jpeg" // Decodes JPEG avatars
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
 	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	mailer "github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/mail"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/storage"
+	"github.com/hinha/echo-casbin-ddd-app/pkg/imaging"
 )
 
+// maxAvatarPixels is the largest number of pixels of an uploaded avatar, so that decoding a small
+// but highly compressed file cannot exhaust memory
+const maxAvatarPixels = 40_000_000
+
 // UserUseCaseImpl handles user-related business logic
 // It implements the interfaces.UserUseCase interface
 type UserUseCaseImpl struct {
-	userRepository repository.UserRepository
-	jwtService     *auth.JWTService
-	casbinService  *auth.CasbinService
+	userRepository          repository.UserRepository
+	userAttributeRepository repository.UserAttributeRepository
+	roleGrantRepository     repository.RoleGrantRepository
+	jwtService              *auth.JWTService
+	casbinService           *auth.CasbinService
+	mailer                  mailer.Mailer
+	registration            *entity.RegistrationPolicy
+	blobStore               storage.BlobStore
+	avatarSize              int
+	emailChangeValidity     time.Duration
+	emailChangeURL          string
 }
 
-// NewUserUseCase creates a new UserUseCaseImpl
+// NewUserUseCase creates a new UserUseCaseImpl.
+// The registration policy decides who may register themselves and with which role.
+// Avatars are resized to avatarSize pixels square and kept in the blob store.
+// New emails can be confirmed for emailChangeValidity, through emailChangeURL in which {token} is replaced by
+// the token of the change.
 func NewUserUseCase(
 	userRepository repository.UserRepository,
+	userAttributeRepository repository.UserAttributeRepository,
+	roleGrantRepository repository.RoleGrantRepository,
 	jwtService *auth.JWTService,
 	casbinService *auth.CasbinService,
+	mailer mailer.Mailer,
+	registration *entity.RegistrationPolicy,
+	blobStore storage.BlobStore,
+	avatarSize int,
+	emailChangeValidity time.Duration,
+	emailChangeURL string,
 ) interfaces.UserUseCase {
 	return &UserUseCaseImpl{
-		userRepository: userRepository,
-		jwtService:     jwtService,
-		casbinService:  casbinService,
+		userRepository:          userRepository,
+		userAttributeRepository: userAttributeRepository,
+		roleGrantRepository:     roleGrantRepository,
+		jwtService:              jwtService,
+		casbinService:           casbinService,
+		mailer:                  mailer,
+		registration:            registration,
+		blobStore:               blobStore,
+		avatarSize:              avatarSize,
+		emailChangeValidity:     emailChangeValidity,
+		emailChangeURL:          emailChangeURL,
 	}
 }
 
-// Register registers a new user
+// Register registers a new user with the default role, if the registration policy lets them
 func (uc *UserUseCaseImpl) Register(ctx context.Context, input dto.RegisterInput) (*dto.RegisterOutput, error) {
-	// Check if user with the same username already exists
-	existingUser, err := uc.userRepository.GetByUsername(ctx, input.Username)
+	if err := uc.registration.AllowsSelfRegistration(input.Email); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.createUser(ctx, input.Username, input.Email, input.Password, uc.registration.DefaultRole)
 	if err != nil {
 		return nil, err
 	}
-	if existingUser != nil {
-		return nil, errors.New("username already exists")
+
+	// Generate JWT token
+	token, err := uc.jwtService.GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RegisterOutput{
+		User:  user,
+		Token: token,
+	}, nil
+}
+
+// CreateUser creates a user on behalf of the actor, with the default role unless another is given.
+// The actor must be allowed to assign the role, whatever the registration policy.
+func (uc *UserUseCaseImpl) CreateUser(ctx context.Context, input dto.CreateUserInput) (*entity.User, error) {
+	role := input.Role
+	if role == "" {
+		role = uc.registration.DefaultRole
 	}
 
-	// Check if user with the same email already exists
-	existingUser, err = uc.userRepository.GetByEmail(ctx, input.Email)
+	roles, err := uc.casbinService.GetRoles(auth.DomainDefault)
 	if err != nil {
 		return nil, err
 	}
-	if existingUser != nil {
-		return nil, errors.New("email already exists")
+	if !slices.Contains(roles, role) {
+		return nil, fmt.Errorf("unknown role %q", role)
 	}
 
-	// Create new user
-	user, err := entity.NewUser(input.Username, input.Email, input.Password, input.Role)
+	allowed, err := uc.casbinService.CanAssignRole(ctx, role, auth.DomainDefault)
 	if err != nil {
 		return nil, err
 	}
+	if !allowed {
+		return nil, entity.ErrRoleNotAssignable
+	}
 
-	// Save user to database
-	if err := uc.userRepository.Create(ctx, user); err != nil {
+	user, err := uc.createUser(ctx, input.Username, input.Email, input.Password, role)
+	if err != nil {
 		return nil, err
 	}
 
-	// Add role for user in Casbin
-	if _, err := uc.casbinService.AddRoleForUser(user.Username, user.Role, "default"); err != nil {
+	log.Printf("User %s created by %s as %s", user.Username, auth.ActorFromContext(ctx), role)
+	return user, nil
+}
+
+// createUser saves a new user and assigns them their role in Casbin
+func (uc *UserUseCaseImpl) createUser(ctx context.Context, username, email, password, role string) (*entity.User, error) {
+	if err := checkUsernameAvailable(ctx, uc.userRepository, uc.casbinService, username); err != nil {
 		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := uc.jwtService.GenerateToken(user)
+	// Check if a user, even soft deleted, already has the email
+	taken, err := uc.userRepository.EmailExists(ctx, email)
 	if err != nil {
 		return nil, err
 	}
+	if taken {
+		return nil, entity.ErrEmailTaken
+	}
 
-	return &dto.RegisterOutput{
-		User:  user,
-		Token: token,
-	}, nil
+	// Create new user
+	user, err := entity.NewUser(username, email, password, role)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save user to database
+	if err := uc.userRepository.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Add role for user in Casbin
+	if _, err := uc.casbinService.AddRoleForUser(ctx, user.Username, user.Role, auth.DomainDefault); err != nil {
+		// A user without their role would be left behind otherwise
+		if deleteErr := uc.userRepository.PermanentDelete(ctx, user.ID); deleteErr != nil {
+			log.Printf("Failed to remove user %s left without role: %v", user.Username, deleteErr)
+		}
+		return nil, err
+	}
+
+	return user, nil
 }
 
 // Login authenticates a user
@@ -118,7 +214,9 @@ func (uc *UserUseCaseImpl) GetUserByID(ctx context.Context, id uint) (*entity.Us
 	return uc.userRepository.GetByID(ctx, id)
 }
 
-// UpdateUser updates a user
+// UpdateUser updates a user, unless they were updated since the version the update is based on.
+// A new email is only requested, see RequestEmailChange. A new username must be available, see
+// renameUser.
 func (uc *UserUseCaseImpl) UpdateUser(ctx context.Context, input dto.UpdateUserInput) (*entity.User, error) {
 	// Get user by ID
 	user, err := uc.userRepository.GetByID(ctx, input.ID)
@@ -126,22 +224,540 @@ func (uc *UserUseCaseImpl) UpdateUser(ctx context.Context, input dto.UpdateUserI
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, entity.ErrUserNotFound
+	}
+	if err := checkVersion(user.Version, input.Version); err != nil {
+		return nil, err
+	}
+
+	definitions, err := uc.userAttributeRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	formerUsername := user.Username
+	renamed := input.Username != formerUsername
+	if renamed {
+		if err := checkUsernameAvailable(ctx, uc.userRepository, uc.casbinService, input.Username); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update user
-	if err := user.UpdateProfile(input.Username, input.Email); err != nil {
+	if err := user.UpdateProfile(input.Username); err != nil {
+		return nil, err
+	}
+	if err := user.UpdateDetails(input.DisplayName, input.Locale, input.Timezone); err != nil {
 		return nil, err
 	}
+	if err := user.SetAttributes(input.Attributes, definitions); err != nil {
+		return nil, err
+	}
+
+	formerEmail := user.Email
+	changeEmail := needsEmailChange(user, input.Email)
+	if changeEmail {
+		if err := uc.startEmailChange(ctx, user, input.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tokens carry the username as the Casbin subject of the user
+	if renamed {
+		user.RevokeTokens(time.Now())
+	}
 
 	// Save user to database
 	if err := uc.userRepository.Update(ctx, user); err != nil {
 		return nil, err
 	}
 
+	if renamed {
+		if err := uc.renameUser(ctx, user, formerUsername); err != nil {
+			return nil, err
+		}
+	}
+	if changeEmail {
+		uc.notifyEmailChange(ctx, formerEmail, user)
+	}
+	return user, nil
+}
+
+// renameUser moves the Casbin rules and role grants of a user saved under a new username, which
+// would otherwise be left to whoever takes the former one. The tokens of the user are expected to be
+// revoked along with the rename, as they name the user by their former username.
+func (uc *UserUseCaseImpl) renameUser(ctx context.Context, user *entity.User, formerUsername string) error {
+	moved, err := uc.casbinService.RenameSubject(ctx, formerUsername, user.Username)
+	if err != nil {
+		return err
+	}
+	if err := uc.roleGrantRepository.RenameSubject(ctx, formerUsername, user.Username); err != nil {
+		return err
+	}
+
+	log.Printf("User %d renamed from %s to %s by %s, %d Casbin rules moved", user.ID, formerUsername, user.Username, auth.ActorFromContext(ctx), moved)
+	return nil
+}
+
+// userFields are the fields of a user a merge patch may change
+type userFields struct {
+	Username    string         `json:"username"`
+	Email       string         `json:"email"`
+	DisplayName string         `json:"display_name"`
+	Locale      string         `json:"locale"`
+	Timezone    string         `json:"timezone"`
+	Attributes  map[string]any `json:"attributes"`
+	Active      bool           `json:"active"`
+	Role        string         `json:"role"`
+}
+
+// PatchUser applies a JSON merge patch to a user, unless they were updated since the version the patch
+// is based on. Whether the user is active and their role are restricted fields, see
+// auth.CasbinService.CanUpdateField; a role can moreover only be replaced by someone allowed to assign
+// both the former and the new one. A new username must be available, see renameUser. A new email is
+// only requested, see RequestEmailChange, and reported as a change of pending_email. Nothing is saved
+// when the patch changes nothing.
+func (uc *UserUseCaseImpl) PatchUser(ctx context.Context, input dto.PatchUserInput) (*dto.PatchUserOutput, error) {
+	user, err := uc.userRepository.GetByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+	if err := checkVersion(user.Version, input.Version); err != nil {
+		return nil, err
+	}
+
+	current := userFields{
+		Username:    user.Username,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Attributes:  user.Attributes,
+		Active:      user.Active,
+		Role:        user.Role,
+	}
+	fields, changes, err := applyMergePatch(current, input.Patch, "username", "email", "active", "role")
+	if err != nil {
+		return nil, err
+	}
+
+	changes = slices.DeleteFunc(changes, func(change entity.FieldChange) bool {
+		return change.Field == "email"
+	})
+	formerEmail := user.Email
+	changeEmail := needsEmailChange(user, fields.Email)
+	if changeEmail {
+		changes = append(changes, entity.FieldChange{Field: "pending_email", From: user.PendingEmail, To: fields.Email})
+		slices.SortFunc(changes, func(a, b entity.FieldChange) int {
+			return strings.Compare(a.Field, b.Field)
+		})
+	}
+	if len(changes) == 0 {
+		return &dto.PatchUserOutput{User: user}, nil
+	}
+
+	if err := authorizeFieldChanges(ctx, uc.casbinService, "users", auth.DomainDefault, changes, "active", "role"); err != nil {
+		return nil, err
+	}
+	if fields.Role != current.Role {
+		if err := uc.checkRoleChange(ctx, current.Role, fields.Role); err != nil {
+			return nil, err
+		}
+	}
+	renamed := fields.Username != current.Username
+	if renamed {
+		if err := checkUsernameAvailable(ctx, uc.userRepository, uc.casbinService, fields.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := user.UpdateProfile(fields.Username); err != nil {
+		return nil, err
+	}
+	if err := user.UpdateDetails(fields.DisplayName, fields.Locale, fields.Timezone); err != nil {
+		return nil, err
+	}
+	// Attributes are only checked when changed, so that a newly required one does not block other changes
+	if hasFieldChange(changes, "attributes") {
+		definitions, err := uc.userAttributeRepository.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := user.SetAttributes(fields.Attributes, definitions); err != nil {
+			return nil, err
+		}
+	}
+	user.SetActive(fields.Active)
+	user.SetRole(fields.Role)
+
+	if changeEmail {
+		if err := uc.startEmailChange(ctx, user, fields.Email); err != nil {
+			return nil, err
+		}
+	}
+	// Tokens carry the username as the Casbin subject of the user
+	if renamed {
+		user.RevokeTokens(time.Now())
+	}
+
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// The role is replaced once the rules of the user are moved to their new username
+	if renamed {
+		if err := uc.renameUser(ctx, user, current.Username); err != nil {
+			return nil, err
+		}
+	}
+	if fields.Role != current.Role {
+		if _, err := uc.casbinService.DeleteRoleForUser(ctx, user.Username, current.Role, auth.DomainDefault); err != nil {
+			return nil, err
+		}
+		if _, err := uc.casbinService.AddRoleForUser(ctx, user.Username, fields.Role, auth.DomainDefault); err != nil {
+			return nil, err
+		}
+	}
+	if changeEmail {
+		uc.notifyEmailChange(ctx, formerEmail, user)
+	}
+
+	log.Printf("User %d patched by %s: %s", user.ID, auth.ActorFromContext(ctx), changedFields(changes))
+	return &dto.PatchUserOutput{User: user, Changes: changes}, nil
+}
+
+// checkRoleChange checks that the actor of ctx may replace a role with another one of the default domain
+func (uc *UserUseCaseImpl) checkRoleChange(ctx context.Context, from, to string) error {
+	roles, err := uc.casbinService.GetRoles(auth.DomainDefault)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(roles, to) {
+		return fmt.Errorf("%w: unknown role %q", entity.ErrInvalidPatch, to)
+	}
+
+	for _, role := range []string{from, to} {
+		if role == "" {
+			continue
+		}
+		allowed, err := uc.casbinService.CanAssignRole(ctx, role, auth.DomainDefault)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return entity.ErrRoleNotAssignable
+		}
+	}
+	return nil
+}
+
+// needsEmailChange reports whether updating a user with an email requests to change it: the
+// email differs from the current one and is not already waiting to be confirmed, so that
+// repeating an update does not send the confirmation again
+func needsEmailChange(user *entity.User, email string) bool {
+	if email == user.Email {
+		return false
+	}
+	return email != user.PendingEmail || !user.HasPendingEmailChange(time.Now())
+}
+
+// RequestEmailChange sends a confirmation token to the new email of a user, replacing any change
+// pending, and notifies the former email. The email is replaced once the change is confirmed.
+func (uc *UserUseCaseImpl) RequestEmailChange(ctx context.Context, input dto.RequestEmailChangeInput) (*entity.User, error) {
+	user, err := uc.userRepository.GetByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+
+	if err := uc.startEmailChange(ctx, user, input.Email); err != nil {
+		return nil, err
+	}
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	uc.notifyEmailChange(ctx, user.Email, user)
+	return user, nil
+}
+
+// startEmailChange records a change of the email of a user and sends its token to the new email.
+// The token is sent before the user is saved, so that a change nobody can confirm is never kept.
+// Whoever impersonates a user cannot change their email, whichever the request, as it would let them
+// take over the account.
+func (uc *UserUseCaseImpl) startEmailChange(ctx context.Context, user *entity.User, email string) error {
+	if auth.IsImpersonating(ctx) {
+		return fmt.Errorf("%w: the email cannot be changed while impersonating a user", entity.ErrImpersonationNotAllowed)
+	}
+
+	token, err := user.RequestEmailChange(email, uc.emailChangeValidity)
+	if err != nil {
+		return err
+	}
+
+	taken, err := uc.userRepository.EmailExists(ctx, email)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return entity.ErrEmailTaken
+	}
+
+	if err := uc.mailer.Send(ctx, uc.emailChangeMessage(user, token)); err != nil {
+		log.Printf("Failed to send the email change confirmation of user %d to %s: %v", user.ID, email, err)
+		return entity.ErrEmailChangeNotSent
+	}
+
+	log.Printf("Email change of user %d to %s requested by %s", user.ID, email, auth.ActorFromContext(ctx))
+	return nil
+}
+
+// emailChangeMessage returns the email sent to the new email of a user, with the link confirming it
+func (uc *UserUseCaseImpl) emailChangeMessage(user *entity.User, token string) mailer.Message {
+	link := strings.ReplaceAll(uc.emailChangeURL, "{token}", url.QueryEscape(token))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "The email of the account %s is being changed to this address.\n\n", user.Username)
+	fmt.Fprintf(&body, "Confirm it at:\n%s\n\n", link)
+	fmt.Fprintf(&body, "The link expires on %s. Until then, the account keeps its current email.\n", user.PendingEmailExpiresAt.UTC().Format(time.RFC1123))
+
+	return mailer.Message{
+		To:      user.PendingEmail,
+		Subject: "Confirm your new email",
+		Body:    body.String(),
+	}
+}
+
+// notifyEmailChange tells the former email of a user that a change was requested, so that they
+// notice a change they did not ask for. A notification that cannot be sent is only logged.
+func (uc *UserUseCaseImpl) notifyEmailChange(ctx context.Context, email string, user *entity.User) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "A change of the email of the account %s to %s was requested.\n\n", user.Username, user.PendingEmail)
+	body.WriteString("It takes effect once confirmed from the new address. If you did not ask for it, cancel it from your account or contact an administrator.\n")
+
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Your email is being changed",
+		Body:    body.String(),
+	}
+	if err := uc.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Failed to notify %s of the email change of user %d: %v", email, user.ID, err)
+	}
+}
+
+// ConfirmEmailChange replaces the email of the user whose email change has a token, unless the new
+// email was taken since the change was requested
+func (uc *UserUseCaseImpl) ConfirmEmailChange(ctx context.Context, token string) (*entity.User, error) {
+	user, err := uc.userRepository.GetByEmailChangeTokenHash(ctx, entity.HashEmailChangeToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrEmailChangeNotFound
+	}
+
+	taken, err := uc.userRepository.EmailExists(ctx, user.PendingEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	formerEmail := user.Email
+	if err := user.ConfirmEmailChange(time.Now()); err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, entity.ErrEmailTaken
+	}
+
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Email of user %d changed from %s to %s", user.ID, formerEmail, user.Email)
 	return user, nil
 }
 
+// CancelEmailChange withdraws the email change of a user, pending or expired
+func (uc *UserUseCaseImpl) CancelEmailChange(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := uc.userRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+
+	if err := user.CancelEmailChange(); err != nil {
+		return nil, err
+	}
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Email change of user %d cancelled by %s", user.ID, auth.ActorFromContext(ctx))
+	return user, nil
+}
+
+// UploadAvatar checks that an avatar is a PNG, JPEG or GIF image, whatever its declared
+// content type, crops it to a square and resizes it to a PNG image of avatarSize pixels.
+// Each upload is stored under a new key, the previous avatar being removed once replaced.
+func (uc *UserUseCaseImpl) UploadAvatar(ctx context.Context, input dto.UploadAvatarInput) (*entity.User, error) {
+	user, err := uc.userRepository.GetByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+
+	data, err := encodeAvatar(input.Data, uc.avatarSize)
+	if err != nil {
+		return nil, err
+	}
+
+	version := make([]byte, 8)
+	if _, err := rand.Read(version); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("avatars/%d/%s.png", user.ID, hex.EncodeToString(version))
+	if err := uc.blobStore.Put(ctx, key, storage.Blob{Data: data, ContentType: "image/png"}); err != nil {
+		return nil, err
+	}
+
+	previousKey := user.AvatarKey
+	user.SetAvatar(key)
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		uc.deleteAvatar(ctx, key)
+		return nil, err
+	}
+
+	if previousKey != "" {
+		uc.deleteAvatar(ctx, previousKey)
+	}
+	return user, nil
+}
+
+// GetAvatar gets the avatar of a user
+func (uc *UserUseCaseImpl) GetAvatar(ctx context.Context, id uint) (*dto.AvatarOutput, error) {
+	user, err := uc.userRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+	return loadAvatar(ctx, uc.blobStore, user)
+}
+
+// loadAvatar loads the avatar of a user from the blob store
+func loadAvatar(ctx context.Context, blobStore storage.BlobStore, user *entity.User) (*dto.AvatarOutput, error) {
+	if user.AvatarKey == "" {
+		return nil, entity.ErrAvatarNotFound
+	}
+
+	blob, err := blobStore.Get(ctx, user.AvatarKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, entity.ErrAvatarNotFound
+		}
+		return nil, err
+	}
+
+	return &dto.AvatarOutput{
+		Data:        blob.Data,
+		ContentType: blob.ContentType,
+		Version:     strings.TrimSuffix(path.Base(user.AvatarKey), path.Ext(user.AvatarKey)),
+	}, nil
+}
+
+// deleteAvatar removes an avatar from the blob store. A failure only leaves an unused file behind.
+func (uc *UserUseCaseImpl) deleteAvatar(ctx context.Context, key string) {
+	if err := uc.blobStore.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete avatar %s: %v", key, err)
+	}
+}
+
+// encodeAvatar decodes an image, sniffing its format from its content, and encodes its
+// thumbnail as PNG. Images larger than maxAvatarPixels are rejected before being decoded.
+func encodeAvatar(data []byte, size int) ([]byte, error) {
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return nil, entity.ErrUnsupportedAvatarType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", entity.ErrInvalidAvatar, err)
+	}
+	if config.Width*config.Height > maxAvatarPixels {
+		return nil, fmt.Errorf("%w: the image cannot have more than %d pixels", entity.ErrInvalidAvatar, maxAvatarPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", entity.ErrInvalidAvatar, err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imaging.Thumbnail(img, size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetRoles resolves the roles of a user in a domain: those assigned to them, those of their
+// groups and the effective roles these add up to through role inheritance
+func (uc *UserUseCaseImpl) GetRoles(ctx context.Context, id uint, domain string) (*dto.UserRolesOutput, error) {
+	user, err := uc.userRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+	if domain == "" {
+		domain = auth.DomainDefault
+	}
+
+	return resolveRoles(uc.casbinService, user.Username, domain)
+}
+
+// resolveRoles resolves the roles of a user in a domain from their Casbin rules
+func resolveRoles(casbinService *auth.CasbinService, username, domain string) (*dto.UserRolesOutput, error) {
+	assigned, err := casbinService.GetRolesForUser(username, domain)
+	if err != nil {
+		return nil, err
+	}
+	direct := splitGroups(assigned)
+
+	groups := make([]dto.GroupRoles, len(direct.groups))
+	for i, group := range direct.groups {
+		roles, err := casbinService.GetRolesForUser(group, domain)
+		if err != nil {
+			return nil, err
+		}
+		groups[i] = dto.GroupRoles{Group: entity.GroupNameFromSubject(group), Roles: splitGroups(roles).roles}
+	}
+
+	implicit, err := casbinService.GetImplicitRolesForUser(username, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UserRolesOutput{
+		Domain:    domain,
+		Direct:    direct.roles,
+		Groups:    groups,
+		Effective: splitGroups(implicit).roles,
+	}, nil
+}
+
 // ChangePassword changes a user's password
 func (uc *UserUseCaseImpl) ChangePassword(ctx context.Context, input dto.ChangePasswordInput) error {
 	// Get user by ID
@@ -167,19 +783,287 @@ func (uc *UserUseCaseImpl) ChangePassword(ctx context.Context, input dto.ChangeP
 	return uc.userRepository.Update(ctx, user)
 }
 
-// ListUsers lists users with pagination
+// ListUsers lists users with filters, sorting and pagination
 func (uc *UserUseCaseImpl) ListUsers(ctx context.Context, input dto.ListUsersInput) (*dto.ListUsersOutput, error) {
-	// Calculate offset
-	offset := (input.Page - 1) * input.Limit
+	return uc.listUsers(ctx, input, false)
+}
+
+// SetUserActive activates or deactivates a user. Inactive users cannot log in, and deactivating
+// a user revokes the tokens they were issued.
+func (uc *UserUseCaseImpl) SetUserActive(ctx context.Context, input dto.SetUserActiveInput) (*entity.User, error) {
+	user, err := uc.userRepository.GetByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+
+	user.SetActive(input.Active)
+
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetOwnerRole gets the highest of the effective roles of a user in the default domain
+func (uc *UserUseCaseImpl) GetOwnerRole(ctx context.Context, user *entity.User) (string, error) {
+	return ownerRole(uc.casbinService, user)
+}
+
+// ownerRole gets the role a user is authorized as when owning a resource: the highest of their
+// effective roles in the default domain, rather than their role column, so that the roles held
+// through groups and temporary grants count. It falls back to the role column when they hold none.
+func ownerRole(casbinService *auth.CasbinService, user *entity.User) (string, error) {
+	role, err := casbinService.GetHighestRoleForUser(user.Username, auth.DomainDefault)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return user.Role, nil
+	}
+	return role, nil
+}
+
+// GetDeletedUserByID gets a soft deleted user by ID
+func (uc *UserUseCaseImpl) GetDeletedUserByID(ctx context.Context, id uint) (*entity.User, error) {
+	return uc.userRepository.GetDeletedByID(ctx, id)
+}
+
+// DeleteUser soft deletes a user, unless they were updated since the given version, 0 for any.
+// Their tokens are revoked, while their Casbin rules are kept so that they get their roles back if restored.
+func (uc *UserUseCaseImpl) DeleteUser(ctx context.Context, id uint, version uint) error {
+	user, err := uc.userRepository.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return entity.ErrUserNotFound
+	}
+	if err := checkVersion(user.Version, version); err != nil {
+		return err
+	}
+
+	// Tokens are revoked first, so that they stay rejected should the user be restored
+	user.RevokeTokens(time.Now())
+	if err := uc.userRepository.Update(ctx, user); err != nil {
+		return err
+	}
+
+	return uc.userRepository.Delete(ctx, id, user.Version)
+}
+
+// ListDeletedUsers lists soft deleted users with filters, sorting and pagination
+func (uc *UserUseCaseImpl) ListDeletedUsers(ctx context.Context, input dto.ListUsersInput) (*dto.ListUsersOutput, error) {
+	return uc.listUsers(ctx, input, true)
+}
+
+// listUsers lists either soft deleted users or the others
+func (uc *UserUseCaseImpl) listUsers(ctx context.Context, input dto.ListUsersInput, deleted bool) (*dto.ListUsersOutput, error) {
+	query, err := userQuery(input, deleted)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get users from database
-	users, count, err := uc.userRepository.List(ctx, offset, input.Limit)
+	users, page, err := uc.userRepository.List(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
 	return &dto.ListUsersOutput{
 		Users:      users,
-		TotalCount: count,
+		Next:       page.Next,
+		Prev:       page.Prev,
+		TotalCount: page.TotalCount,
+	}, nil
+}
+
+// ExportUsers passes every user matching the filters of a listing to write, a page at a time.
+// The pagination of the input is ignored.
+func (uc *UserUseCaseImpl) ExportUsers(ctx context.Context, input dto.ListUsersInput, write func(users []*entity.User) error) error {
+	query, err := userQuery(input, false)
+	if err != nil {
+		return err
+	}
+	query.After, query.Before = "", ""
+	query.Limit = repository.MaxPageLimit
+	query.SkipCount = true
+
+	for {
+		users, page, err := uc.userRepository.List(ctx, query)
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			if err := write(users); err != nil {
+				return err
+			}
+		}
+		if page.Next == "" {
+			return nil
+		}
+		query.After = page.Next
+	}
+}
+
+// userQuery builds the repository query of a user listing
+func userQuery(input dto.ListUsersInput, deleted bool) (repository.UserQuery, error) {
+	sort, err := repository.ParseSort(input.Sort, repository.UserSortColumns)
+	if err != nil {
+		return repository.UserQuery{}, err
+	}
+
+	return repository.UserQuery{
+		Role:        input.Role,
+		Active:      input.Active,
+		CreatedFrom: input.CreatedFrom,
+		CreatedTo:   input.CreatedTo,
+		Deleted:     deleted,
+		Search:      input.Search,
+		PageQuery: repository.PageQuery{
+			Sort:      sort,
+			After:     input.After,
+			Before:    input.Before,
+			Limit:     pageLimit(input.Limit),
+			SkipCount: input.SkipCount,
+		},
+	}, nil
+}
+
+// pageLimit bounds the number of items a listing returns
+func pageLimit(limit int) int {
+	if limit < 1 {
+		return repository.DefaultPageLimit
+	}
+	if limit > repository.MaxPageLimit {
+		return repository.MaxPageLimit
+	}
+	return limit
+}
+
+// checkVersion fails with repository.ErrVersionConflict when a record is no longer at the version
+// a change is based on. Version 0 matches any version.
+func checkVersion(current, expected uint) error {
+	if expected != 0 && expected != current {
+		return repository.ErrVersionConflict
+	}
+	return nil
+}
+
+// RestoreUser restores a soft deleted user. Erased users cannot be restored.
+func (uc *UserUseCaseImpl) RestoreUser(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := uc.userRepository.GetDeletedByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+	if user.IsErased() {
+		return nil, entity.ErrUserErased
+	}
+
+	if err := uc.userRepository.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return uc.userRepository.GetByID(ctx, id)
+}
+
+// PurgeUser permanently deletes a soft deleted user. Their role grants are revoked and their
+// Casbin rules removed from every domain first, so that a user later registered under the same
+// username inherits neither their roles, their group memberships nor their pending grants.
+func (uc *UserUseCaseImpl) PurgeUser(ctx context.Context, id uint) error {
+	user, err := uc.userRepository.GetDeletedByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return entity.ErrUserNotFound
+	}
+
+	if err := uc.revokeRoleGrants(ctx, user.Username); err != nil {
+		return err
+	}
+
+	removed, err := uc.casbinService.DeleteSubjectInAllDomains(ctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.userRepository.PermanentDelete(ctx, id); err != nil {
+		return err
+	}
+
+	if user.AvatarKey != "" {
+		uc.deleteAvatar(ctx, user.AvatarKey)
+	}
+
+	log.Printf("User %s purged, %d Casbin rules removed", user.Username, removed)
+	return nil
+}
+
+// revokeRoleGrants revokes the pending, approved and active role grants of a subject
+func (uc *UserUseCaseImpl) revokeRoleGrants(ctx context.Context, subject string) error {
+	const pageSize = 100
+	actor := auth.ActorFromContext(ctx)
+
+	for offset := 0; ; offset += pageSize {
+		grants, _, err := uc.roleGrantRepository.List(ctx, repository.RoleGrantFilter{Subject: subject}, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, grant := range grants {
+			if grant.Revoke(actor) != nil {
+				continue
+			}
+			if err := uc.roleGrantRepository.Update(ctx, grant); err != nil {
+				return err
+			}
+		}
+		if len(grants) < pageSize {
+			return nil
+		}
+	}
+}
+
+// Impersonate issues a short-lived token to act as a user on behalf of another one.
+// The token carries the actor, so that everything done with it is attributed to them.
+func (uc *UserUseCaseImpl) Impersonate(ctx context.Context, input dto.ImpersonateInput) (*dto.ImpersonateOutput, error) {
+	user, err := uc.userRepository.GetByID(ctx, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, entity.ErrUserNotFound
+	}
+
+	actor, err := uc.userRepository.GetByID(ctx, input.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return nil, entity.ErrUserNotFound
+	}
+
+	if err := user.CanBeImpersonatedBy(actor); err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := uc.jwtService.GenerateImpersonationToken(user, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Impersonation started: %s acting as %s until %s", actor.Username, user.Username, expiresAt.Format("2006-01-02T15:04:05Z07:00"))
+
+	return &dto.ImpersonateOutput{
+		User:      user,
+		Actor:     actor,
+		Token:     token,
+		ExpiresAt: expiresAt,
 	}, nil
 }
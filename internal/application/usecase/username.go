@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// checkUsernameAvailable checks that a user may take a username: no other user, even soft deleted,
+// holds it, and it is neither a role nor the subject of a policy rule. Users are Casbin subjects
+// named after their username, so one named after a role would hold its permissions.
+func checkUsernameAvailable(ctx context.Context, userRepository repository.UserRepository, casbinService *auth.CasbinService, username string) error {
+	reserved, err := casbinService.IsReservedSubject(username)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return fmt.Errorf("%w: username %q is the name of a role or policy subject", entity.ErrInvalidProfile, username)
+	}
+
+	taken, err := userRepository.UsernameExists(ctx, username)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return entity.ErrUsernameTaken
+	}
+	return nil
+}
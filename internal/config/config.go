@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -11,10 +12,17 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	APIKey   APIKeyConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	JWT        JWTConfig
+	APIKey     APIKeyConfig
+	APIClients APIClientsConfig
+	Users      UsersConfig
+	Mail       MailConfig
+	Storage    StorageConfig
+	Casbin     CasbinConfig
+	Roles      RolesConfig
+	Authz      AuthzConfig
 }
 
 // ServerConfig holds all server related configuration
@@ -40,6 +48,8 @@ type DatabaseConfig struct {
 type JWTConfig struct {
 	Secret     string
 	Expiration time.Duration
+	// ImpersonationExpiration is how long a token issued to impersonate a user is valid
+	ImpersonationExpiration time.Duration
 }
 
 // APIKeyConfig holds all API Key related configuration
@@ -47,6 +57,91 @@ type APIKeyConfig struct {
 	HeaderName string
 }
 
+// APIClientsConfig holds all API client management related configuration
+type APIClientsConfig struct {
+	// TrashRetention is how long deleted API clients can be restored before they are purged, 0 keeps them forever
+	TrashRetention time.Duration
+	// PurgeInterval is how often deleted API clients past their retention are purged
+	PurgeInterval time.Duration
+}
+
+// UsersConfig holds all user management related configuration
+type UsersConfig struct {
+	// ImportInterval is how often queued user imports are checked for
+	ImportInterval time.Duration
+	// InvitationExpiration is how long an invitation can be accepted
+	InvitationExpiration time.Duration
+	// InvitationURL is the link sent to invitees, in which {token} is replaced by the invitation token
+	InvitationURL string
+	// EmailChangeExpiration is how long an email change can be confirmed
+	EmailChangeExpiration time.Duration
+	// EmailChangeURL is the link sent to confirm a new email, in which {token} is replaced by the email change token
+	EmailChangeURL string
+	// RegistrationMode decides who may register: "open", "allow_list", "invite_only" or "disabled"
+	RegistrationMode string
+	// RegistrationAllowedDomains are the email domains allowed to register in allow_list mode
+	RegistrationAllowedDomains []string
+	// DefaultRole is the role of self-registered users
+	DefaultRole string
+	// AvatarMaxSize is the largest avatar upload accepted, in bytes
+	AvatarMaxSize int
+	// AvatarSize is the width and height in pixels avatars are resized to
+	AvatarSize int
+	// ErasureDelay is how long after being requested users are erased, until when the erasure can be cancelled
+	ErasureDelay time.Duration
+	// ErasureInterval is how often due erasure requests are carried out
+	ErasureInterval time.Duration
+}
+
+// MailConfig holds all email related configuration
+type MailConfig struct {
+	// Driver selects how emails are sent: "smtp", or "log" to only log them
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// StorageConfig holds all file storage related configuration
+type StorageConfig struct {
+	// Driver selects where files such as avatars are stored: "local"
+	Driver string
+	// LocalPath is the directory of the local driver
+	LocalPath string
+}
+
+// CasbinConfig holds all Casbin related configuration
+type CasbinConfig struct {
+	ModelPath string
+	// BootstrapPolicyPath is the policy file applied when running migrations
+	BootstrapPolicyPath string
+	// Watcher selects how policy changes are synchronized between instances: "postgres" or "none"
+	Watcher        string
+	WatcherChannel string
+	// CacheTTL is how long authorization decisions are cached, 0 disables the cache
+	CacheTTL time.Duration
+	// CacheSize is the maximum number of cached authorization decisions
+	CacheSize int
+}
+
+// RolesConfig holds all role assignment related configuration
+type RolesConfig struct {
+	// ApprovalRequired lists the roles whose grants must be approved before they take effect
+	ApprovalRequired []string
+	// GrantReaperInterval is how often role grants are activated and expired
+	GrantReaperInterval time.Duration
+}
+
+// AuthzConfig holds all authorization decision API related configuration
+type AuthzConfig struct {
+	// BatchMaxSize is the maximum number of checks in a batch
+	BatchMaxSize int
+	// BatchTimeout is the latency budget of a batch, checks not evaluated in time are denied
+	BatchTimeout time.Duration
+}
+
 // loadEnvFiles loads environment variables from .env* files
 func loadEnvFiles() error {
 	// Find all .env* files in the current directory
@@ -92,12 +187,59 @@ func NewConfig() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "your-secret-key"),
-			Expiration: getEnvAsDuration("JWT_EXPIRATION", 24*time.Hour),
+			Secret:                  getEnv("JWT_SECRET", "your-secret-key"),
+			Expiration:              getEnvAsDuration("JWT_EXPIRATION", 24*time.Hour),
+			ImpersonationExpiration: getEnvAsDuration("JWT_IMPERSONATION_EXPIRATION", 15*time.Minute),
 		},
 		APIKey: APIKeyConfig{
 			HeaderName: getEnv("API_KEY_HEADER", "X-API-Key"),
 		},
+		APIClients: APIClientsConfig{
+			TrashRetention: getEnvAsDuration("API_CLIENT_TRASH_RETENTION", 30*24*time.Hour),
+			PurgeInterval:  getEnvAsDuration("API_CLIENT_PURGE_INTERVAL", time.Hour),
+		},
+		Users: UsersConfig{
+			ImportInterval:             getEnvAsDuration("USER_IMPORT_INTERVAL", 5*time.Second),
+			InvitationExpiration:       getEnvAsDuration("INVITATION_EXPIRATION", 72*time.Hour),
+			InvitationURL:              getEnv("INVITATION_URL", "http://localhost:8080/invitations/accept?token={token}"),
+			EmailChangeExpiration:      getEnvAsDuration("EMAIL_CHANGE_EXPIRATION", 24*time.Hour),
+			EmailChangeURL:             getEnv("EMAIL_CHANGE_URL", "http://localhost:8080/email-change/confirm?token={token}"),
+			RegistrationMode:           getEnv("REGISTRATION_MODE", "open"),
+			RegistrationAllowedDomains: getEnvAsSlice("REGISTRATION_ALLOWED_DOMAINS", nil),
+			DefaultRole:                getEnv("REGISTRATION_DEFAULT_ROLE", "user"),
+			AvatarMaxSize:              getEnvAsInt("AVATAR_MAX_SIZE", 5<<20),
+			AvatarSize:                 getEnvAsInt("AVATAR_SIZE", 256),
+			ErasureDelay:               getEnvAsDuration("USER_ERASURE_DELAY", 72*time.Hour),
+			ErasureInterval:            getEnvAsDuration("USER_ERASURE_INTERVAL", time.Minute),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "no-reply@example.com"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		Storage: StorageConfig{
+			Driver:    getEnv("BLOB_STORE_DRIVER", "local"),
+			LocalPath: getEnv("BLOB_STORE_LOCAL_PATH", "data/blobs"),
+		},
+		Casbin: CasbinConfig{
+			ModelPath:           getEnv("CASBIN_MODEL_PATH", "casbin/model.conf"),
+			BootstrapPolicyPath: getEnv("CASBIN_BOOTSTRAP_POLICY", "casbin/policy.csv"),
+			Watcher:             getEnv("CASBIN_WATCHER", "postgres"),
+			WatcherChannel:      getEnv("CASBIN_WATCHER_CHANNEL", "casbin_policy_updates"),
+			CacheTTL:            getEnvAsDuration("CASBIN_CACHE_TTL", 30*time.Second),
+			CacheSize:           getEnvAsInt("CASBIN_CACHE_SIZE", 10000),
+		},
+		Roles: RolesConfig{
+			ApprovalRequired:    getEnvAsSlice("ROLE_APPROVAL_REQUIRED", []string{"superadmin", "admin"}),
+			GrantReaperInterval: getEnvAsDuration("ROLE_GRANT_REAPER_INTERVAL", time.Minute),
+		},
+		Authz: AuthzConfig{
+			BatchMaxSize: getEnvAsInt("AUTHZ_BATCH_MAX_SIZE", 100),
+			BatchTimeout: getEnvAsDuration("AUTHZ_BATCH_TIMEOUT", 50*time.Millisecond),
+		},
 	}
 }
 
@@ -119,6 +261,20 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// Helper function to get a comma separated environment variable as a slice or a default value
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	if value, exists := os.LookupEnv(key); exists {
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return defaultValue
+}
+
 // Helper function to get an environment variable as a duration or a default value
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value, exists := os.LookupEnv(key); exists {
@@ -4,9 +4,17 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 )
 
+var (
+	// ErrAPIClientNotFound is returned when an API client does not exist
+	ErrAPIClientNotFound = errors.New("API client not found")
+	// ErrInvalidAPIClient is returned when the information of an API client is invalid
+	ErrInvalidAPIClient = errors.New("invalid API client")
+)
+
 // APIClient represents an API client in the system
 type APIClient struct {
 	ID          uint       `json:"id"`
@@ -17,12 +25,16 @@ type APIClient struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	// Version is incremented by every update, so that concurrent updates do not overwrite each other
+	Version uint `json:"version"`
+	// PolicySnapshot holds the Casbin rules of a deleted client, re-created when it is restored
+	PolicySnapshot []PolicyRule `json:"policy_snapshot,omitempty"`
 }
 
 // NewAPIClient creates a new API client
 func NewAPIClient(name, description string) (*APIClient, error) {
 	if name == "" {
-		return nil, errors.New("name cannot be empty")
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidAPIClient)
 	}
 
 	apiKey, err := generateAPIKey()
@@ -61,7 +73,7 @@ func (c *APIClient) SetActive(active bool) {
 // UpdateInfo updates the client's information
 func (c *APIClient) UpdateInfo(name, description string) error {
 	if name == "" {
-		return errors.New("name cannot be empty")
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidAPIClient)
 	}
 
 	c.Name = name
@@ -78,3 +90,15 @@ func generateAPIKey() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
+
+// SnapshotPolicies records the Casbin rules of the client before it is deleted
+func (c *APIClient) SnapshotPolicies(rules []PolicyRule) {
+	c.PolicySnapshot = rules
+	c.UpdatedAt = time.Now()
+}
+
+// ClearPolicySnapshot drops the recorded Casbin rules once they are restored
+func (c *APIClient) ClearPolicySnapshot() {
+	c.PolicySnapshot = nil
+	c.UpdatedAt = time.Now()
+}
@@ -0,0 +1,142 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvitationNotFound is returned when an invitation does not exist
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrInvitationUnusable is returned when an invitation was accepted or revoked, or has expired
+	ErrInvitationUnusable = errors.New("invitation is no longer valid")
+	// ErrInvitationNotSent is returned when an invitation could not be emailed
+	ErrInvitationNotSent = errors.New("invitation could not be sent")
+)
+
+// Invitation statuses
+const (
+	// InvitationStatusPending is an invitation waiting to be accepted
+	InvitationStatusPending = "pending"
+	// InvitationStatusAccepted is an invitation used to create an account
+	InvitationStatusAccepted = "accepted"
+	// InvitationStatusRevoked is an invitation withdrawn before it was accepted
+	InvitationStatusRevoked = "revoked"
+	// InvitationStatusExpired is a pending invitation past its expiry time. It is never
+	// stored, but derived from the expiry time.
+	InvitationStatusExpired = "expired"
+)
+
+// Invitation represents an invitation to create an account holding a role in a domain.
+// Only the hash of its token is stored, the token itself being sent to the invitee.
+type Invitation struct {
+	ID         uint       `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	Domain     string     `json:"domain"`
+	TokenHash  string     `json:"-"`
+	Status     string     `json:"status"`
+	InvitedBy  string     `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedBy string     `json:"accepted_by,omitempty"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// NewInvitation creates a new pending invitation valid for a period of time, and returns it
+// along with its token
+func NewInvitation(email, role, domain, invitedBy string, validity time.Duration) (*Invitation, string, error) {
+	if email == "" {
+		return nil, "", errors.New("email cannot be empty")
+	}
+	if role == "" {
+		return nil, "", errors.New("role cannot be empty")
+	}
+	if domain == "" {
+		return nil, "", errors.New("domain cannot be empty")
+	}
+	if invitedBy == "" {
+		return nil, "", errors.New("inviter cannot be empty")
+	}
+	if validity <= 0 {
+		return nil, "", errors.New("validity must be positive")
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	return &Invitation{
+		Email:     email,
+		Role:      role,
+		Domain:    domain,
+		TokenHash: HashInvitationToken(token),
+		Status:    InvitationStatusPending,
+		InvitedBy: invitedBy,
+		ExpiresAt: now.Add(validity),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, token, nil
+}
+
+// generateToken generates a random URL-safe token, such as those of invitations and email changes
+func generateToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// HashInvitationToken returns the hash under which an invitation token is stored
+func HashInvitationToken(token string) string {
+	return hashToken(token)
+}
+
+// hashToken returns the SHA-256 hash of a token, which is random enough not to need a salt
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CurrentStatus returns the status of the invitation at a time, pending invitations
+// past their expiry being expired
+func (i *Invitation) CurrentStatus(now time.Time) string {
+	if i.Status == InvitationStatusPending && !now.Before(i.ExpiresAt) {
+		return InvitationStatusExpired
+	}
+	return i.Status
+}
+
+// Accept records that the invitation was used to create the account of a user
+func (i *Invitation) Accept(username string) error {
+	now := time.Now()
+	if status := i.CurrentStatus(now); status != InvitationStatusPending {
+		return fmt.Errorf("%w: it is %s", ErrInvitationUnusable, status)
+	}
+
+	i.Status = InvitationStatusAccepted
+	i.AcceptedBy = username
+	i.AcceptedAt = &now
+	i.UpdatedAt = now
+	return nil
+}
+
+// Revoke withdraws a pending invitation
+func (i *Invitation) Revoke() error {
+	if status := i.CurrentStatus(time.Now()); status != InvitationStatusPending {
+		return fmt.Errorf("%w: it is %s", ErrInvitationUnusable, status)
+	}
+
+	i.Status = InvitationStatusRevoked
+	i.UpdatedAt = time.Now()
+	return nil
+}
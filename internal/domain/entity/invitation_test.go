@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewInvitation(t *testing.T) {
+	invitation, token, err := NewInvitation("jdoe@example.com", "user", "default", "admin", time.Hour)
+	if err != nil {
+		t.Fatalf("NewInvitation failed: %v", err)
+	}
+	if token == "" || invitation.TokenHash != HashInvitationToken(token) || invitation.TokenHash == token {
+		t.Errorf("token %q stored as %q, want only its hash stored", token, invitation.TokenHash)
+	}
+	if _, _, err := NewInvitation("jdoe@example.com", "user", "default", "admin", 0); err == nil {
+		t.Error("NewInvitation without validity succeeded, want an error")
+	}
+}
+
+func TestInvitationAccept(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		expiresIn time.Duration
+		wantErr   error
+	}{
+		{name: "pending", status: InvitationStatusPending, expiresIn: time.Hour},
+		{name: "expired", status: InvitationStatusPending, expiresIn: -time.Minute, wantErr: ErrInvitationUnusable},
+		{name: "accepted", status: InvitationStatusAccepted, expiresIn: time.Hour, wantErr: ErrInvitationUnusable},
+		{name: "revoked", status: InvitationStatusRevoked, expiresIn: time.Hour, wantErr: ErrInvitationUnusable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invitation := &Invitation{Status: tt.status, ExpiresAt: time.Now().Add(tt.expiresIn)}
+
+			err := invitation.Accept("jdoe")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Accept = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (invitation.Status != InvitationStatusAccepted || invitation.AcceptedBy != "jdoe") {
+				t.Errorf("accepted invitation is %s by %q", invitation.Status, invitation.AcceptedBy)
+			}
+		})
+	}
+}
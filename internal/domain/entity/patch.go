@@ -0,0 +1,20 @@
+package entity
+
+import "errors"
+
+var (
+	// ErrInvalidPatch is returned when a merge patch sets unknown fields, values of the wrong type,
+	// or removes a field that cannot be
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrFieldChangeNotAllowed is returned when a patch changes a field the actor may not change
+	ErrFieldChangeNotAllowed = errors.New("not allowed to change the field")
+)
+
+// FieldChange records the change of a field by a patch, for auditing. Fields of nested objects,
+// such as custom attributes, are named by their path, e.g. attributes.department.
+type FieldChange struct {
+	Field string `json:"field"`
+	// From and To are nil when the field was added or removed
+	From any `json:"from"`
+	To   any `json:"to"`
+}
@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// PolicyRule represents a single Casbin rule, either a policy ("p") or a grouping ("g") rule
+type PolicyRule struct {
+	PType  string   `json:"ptype"`
+	Values []string `json:"values"`
+}
+
+// NewPolicyRule creates a new policy rule
+func NewPolicyRule(ptype string, values ...string) (PolicyRule, error) {
+	if ptype == "" {
+		return PolicyRule{}, errors.New("policy type cannot be empty")
+	}
+	if !strings.HasPrefix(ptype, "p") && !strings.HasPrefix(ptype, "g") {
+		return PolicyRule{}, errors.New("policy type must start with p or g")
+	}
+	if len(values) == 0 {
+		return PolicyRule{}, errors.New("policy rule cannot be empty")
+	}
+
+	return PolicyRule{PType: ptype, Values: values}, nil
+}
+
+// Section returns the Casbin model section the rule belongs to ("p" or "g")
+func (r PolicyRule) Section() string {
+	return r.PType[:1]
+}
+
+// IsGrouping reports whether the rule is a role assignment
+func (r PolicyRule) IsGrouping() bool {
+	return r.Section() == "g"
+}
+
+// String returns the rule in Casbin CSV policy file format, which also uniquely identifies it
+func (r PolicyRule) String() string {
+	fields := make([]string, len(r.Values))
+	for i, value := range r.Values {
+		// Quote values such as conditions that contain the separator
+		if strings.ContainsAny(value, ",\"") {
+			value = `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+		}
+		fields[i] = value
+	}
+	return r.PType + ", " + strings.Join(fields, ", ")
+}
+
+// Policy version actions
+const (
+	PolicyActionBaseline  = "baseline"
+	PolicyActionAdd       = "add"
+	PolicyActionRemove    = "remove"
+	PolicyActionImport    = "import"
+	PolicyActionBootstrap = "bootstrap"
+	PolicyActionRollback  = "rollback"
+	PolicyActionMigrate   = "migrate"
+	PolicyActionRename    = "rename"
+)
+
+// PolicyVersion represents a versioned changeset of policy rules.
+// Replaying every changeset up to a version yields the policy as it was at that version.
+type PolicyVersion struct {
+	Version     uint         `json:"version"`
+	Actor       string       `json:"actor"`
+	Action      string       `json:"action"`
+	Description string       `json:"description"`
+	Added       []PolicyRule `json:"added"`
+	Removed     []PolicyRule `json:"removed"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// NewPolicyVersion creates a new policy changeset
+func NewPolicyVersion(actor, action string, added, removed []PolicyRule) (*PolicyVersion, error) {
+	if actor == "" {
+		return nil, errors.New("actor cannot be empty")
+	}
+	if action == "" {
+		return nil, errors.New("action cannot be empty")
+	}
+	if len(added) == 0 && len(removed) == 0 && action != PolicyActionBaseline {
+		return nil, errors.New("changeset cannot be empty")
+	}
+
+	return &PolicyVersion{
+		Actor:     actor,
+		Action:    action,
+		Added:     added,
+		Removed:   removed,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// ReplayPolicyVersions applies changesets in order and returns the resulting policy rules
+func ReplayPolicyVersions(versions []*PolicyVersion) []PolicyRule {
+	index := make(map[string]int)
+	var rules []PolicyRule
+
+	for _, version := range versions {
+		for _, rule := range version.Removed {
+			key := rule.String()
+			if i, ok := index[key]; ok {
+				rules[i] = PolicyRule{}
+				delete(index, key)
+			}
+		}
+		for _, rule := range version.Added {
+			key := rule.String()
+			if _, ok := index[key]; !ok {
+				index[key] = len(rules)
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	snapshot := make([]PolicyRule, 0, len(index))
+	for _, rule := range rules {
+		if rule.PType != "" {
+			snapshot = append(snapshot, rule)
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,124 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrErasureRequestNotFound is returned when a user has no erasure request
+	ErrErasureRequestNotFound = errors.New("erasure request not found")
+	// ErrErasurePending is returned when a user already has a pending erasure request
+	ErrErasurePending = errors.New("an erasure of this user is already pending")
+	// ErrErasureNotPending is returned when an erasure request was already completed or cancelled
+	ErrErasureNotPending = errors.New("erasure request is not pending")
+	// ErrUserErased is returned when a user was erased and only a pseudonymous stub of them is left
+	ErrUserErased = errors.New("user was erased")
+)
+
+// Erasure request statuses
+const (
+	// ErasureStatusPending is a request waiting for its scheduled time, until which it can be cancelled
+	ErasureStatusPending = "pending"
+	// ErasureStatusCompleted is a request whose user was erased
+	ErasureStatusCompleted = "completed"
+	// ErasureStatusCancelled is a request withdrawn before the user was erased
+	ErasureStatusCancelled = "cancelled"
+)
+
+// erasedPrefix starts the pseudonyms of erased users, which registered users cannot take
+const erasedPrefix = "erased-"
+
+// erasedEmailDomain is the domain of the pseudonymous emails of erased users, reserved by RFC 2606
+const erasedEmailDomain = "erased.invalid"
+
+// ErasedUsername returns the pseudonym replacing the username of an erased user
+func ErasedUsername(id uint) string {
+	return erasedPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+// ErasedEmail returns the pseudonym replacing the email of an erased user
+func ErasedEmail(id uint) string {
+	return ErasedUsername(id) + "@" + erasedEmailDomain
+}
+
+// IsErasedUsername reports whether a username is the pseudonym of an erased user
+func IsErasedUsername(username string) bool {
+	return strings.HasPrefix(username, erasedPrefix)
+}
+
+// DataSubject identifies a user in the records of other aggregates: by username in role grants,
+// policy rules and accepted invitations, by actor in the fields recording who made a change,
+// and by email in the invitations sent to them
+type DataSubject struct {
+	Username string
+	Email    string
+	Actor    string
+}
+
+// ErasureRequest records a request to erase a user, that is to anonymize their account and the
+// references to them in other records, which are kept. The request is carried out at its scheduled
+// time, and outlives the erasure as evidence that it was done.
+type ErasureRequest struct {
+	ID          uint       `json:"id"`
+	UserID      uint       `json:"user_id"`
+	Reason      string     `json:"reason,omitempty"`
+	Status      string     `json:"status"`
+	RequestedBy string     `json:"requested_by"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	CancelledBy string     `json:"cancelled_by,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NewErasureRequest creates a new pending request to erase a user once a delay has passed
+func NewErasureRequest(userID uint, reason, requestedBy string, delay time.Duration) (*ErasureRequest, error) {
+	if userID == 0 {
+		return nil, errors.New("user cannot be empty")
+	}
+	if requestedBy == "" {
+		return nil, errors.New("requester cannot be empty")
+	}
+	if delay < 0 {
+		return nil, errors.New("delay cannot be negative")
+	}
+
+	now := time.Now()
+	return &ErasureRequest{
+		UserID:      userID,
+		Reason:      reason,
+		Status:      ErasureStatusPending,
+		RequestedBy: requestedBy,
+		ScheduledAt: now.Add(delay),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// Cancel withdraws a pending request
+func (r *ErasureRequest) Cancel(cancelledBy string) error {
+	if r.Status != ErasureStatusPending {
+		return fmt.Errorf("%w: it is %s", ErrErasureNotPending, r.Status)
+	}
+
+	r.Status = ErasureStatusCancelled
+	r.CancelledBy = cancelledBy
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// Complete records that the user of a pending request was erased
+func (r *ErasureRequest) Complete(now time.Time) error {
+	if r.Status != ErasureStatusPending {
+		return fmt.Errorf("%w: it is %s", ErrErasureNotPending, r.Status)
+	}
+
+	r.Status = ErasureStatusCompleted
+	r.CompletedAt = &now
+	r.UpdatedAt = now
+	return nil
+}
@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrRegistrationClosed is returned when the registration mode does not let people register themselves
+	ErrRegistrationClosed = errors.New("registration is closed")
+	// ErrEmailDomainNotAllowed is returned when registering with an email outside the allowed domains
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed to register")
+	// ErrRoleNotAssignable is returned when the actor has no permission to assign a role
+	ErrRoleNotAssignable = errors.New("not allowed to assign this role")
+)
+
+// Registration modes
+const (
+	// RegistrationOpen lets anyone register
+	RegistrationOpen = "open"
+	// RegistrationAllowList lets people register with an email of an allowed domain
+	RegistrationAllowList = "allow_list"
+	// RegistrationInviteOnly only lets people in through an invitation
+	RegistrationInviteOnly = "invite_only"
+	// RegistrationDisabled only lets administrators create accounts, invitations included
+	RegistrationDisabled = "disabled"
+)
+
+// RegistrationPolicy decides who may create an account without an administrator
+type RegistrationPolicy struct {
+	Mode string
+	// AllowedDomains are the email domains accepted in allow_list mode
+	AllowedDomains []string
+	// DefaultRole is the role of self-registered users
+	DefaultRole string
+}
+
+// NewRegistrationPolicy creates a new RegistrationPolicy
+func NewRegistrationPolicy(mode string, allowedDomains []string, defaultRole string) (*RegistrationPolicy, error) {
+	switch mode {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationDisabled:
+	case RegistrationAllowList:
+		if len(allowedDomains) == 0 {
+			return nil, errors.New("allow_list registration requires at least one allowed email domain")
+		}
+	default:
+		return nil, fmt.Errorf("unknown registration mode %q, expected %s, %s, %s or %s",
+			mode, RegistrationOpen, RegistrationAllowList, RegistrationInviteOnly, RegistrationDisabled)
+	}
+	if defaultRole == "" {
+		return nil, errors.New("default role cannot be empty")
+	}
+
+	domains := make([]string, len(allowedDomains))
+	for i, domain := range allowedDomains {
+		domains[i] = strings.ToLower(strings.TrimPrefix(domain, "@"))
+	}
+
+	return &RegistrationPolicy{
+		Mode:           mode,
+		AllowedDomains: domains,
+		DefaultRole:    defaultRole,
+	}, nil
+}
+
+// AllowsSelfRegistration checks whether someone may register themselves with an email
+func (p *RegistrationPolicy) AllowsSelfRegistration(email string) error {
+	switch p.Mode {
+	case RegistrationOpen:
+		return nil
+	case RegistrationAllowList:
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return ErrEmailDomainNotAllowed
+		}
+		domain := strings.ToLower(email[at+1:])
+		for _, allowed := range p.AllowedDomains {
+			if domain == allowed {
+				return nil
+			}
+		}
+		return ErrEmailDomainNotAllowed
+	}
+	return ErrRegistrationClosed
+}
+
+// AllowsInvitations checks whether people may be invited to create an account
+func (p *RegistrationPolicy) AllowsInvitations() error {
+	if p.Mode == RegistrationDisabled {
+		return ErrRegistrationClosed
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistrationPolicy(t *testing.T) {
+	tests := []struct {
+		mode    string
+		email   string
+		wantErr error
+	}{
+		{mode: RegistrationOpen, email: "jdoe@anywhere.net"},
+		{mode: RegistrationAllowList, email: "jdoe@EXAMPLE.COM"},
+		{mode: RegistrationAllowList, email: "jdoe@sub.example.com", wantErr: ErrEmailDomainNotAllowed},
+		{mode: RegistrationAllowList, email: "jdoe@example.com.evil.net", wantErr: ErrEmailDomainNotAllowed},
+		{mode: RegistrationInviteOnly, email: "jdoe@example.com", wantErr: ErrRegistrationClosed},
+		{mode: RegistrationDisabled, email: "jdoe@example.com", wantErr: ErrRegistrationClosed},
+	}
+
+	for _, tt := range tests {
+		policy, err := NewRegistrationPolicy(tt.mode, []string{"@Example.com"}, "user")
+		if err != nil {
+			t.Fatalf("NewRegistrationPolicy(%q) failed: %v", tt.mode, err)
+		}
+		if err := policy.AllowsSelfRegistration(tt.email); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: AllowsSelfRegistration(%q) = %v, want %v", tt.mode, tt.email, err, tt.wantErr)
+		}
+	}
+
+	if _, err := NewRegistrationPolicy(RegistrationAllowList, nil, "user"); err == nil {
+		t.Error("NewRegistrationPolicy of an allow list without domains succeeded, want an error")
+	}
+}
@@ -0,0 +1,165 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrRoleGrantNotFound is returned when a role grant does not exist
+	ErrRoleGrantNotFound = errors.New("role grant not found")
+	// ErrRoleGrantTransition is returned when a decision does not apply to the status of a grant
+	ErrRoleGrantTransition = errors.New("invalid role grant transition")
+)
+
+// Role grant statuses
+const (
+	// RoleGrantStatusPending is a grant waiting for approval
+	RoleGrantStatusPending = "pending"
+	// RoleGrantStatusApproved is a grant that will be activated at its start time
+	RoleGrantStatusApproved = "approved"
+	// RoleGrantStatusActive is a grant whose role is currently assigned
+	RoleGrantStatusActive = "active"
+	// RoleGrantStatusExpired is a grant past its expiry time
+	RoleGrantStatusExpired = "expired"
+	// RoleGrantStatusRejected is a grant whose approval was refused
+	RoleGrantStatusRejected = "rejected"
+	// RoleGrantStatusRevoked is a grant withdrawn before it expired
+	RoleGrantStatusRevoked = "revoked"
+)
+
+// RoleGrant represents the temporary assignment of a role to a subject in a domain.
+// The role is assigned from StartsAt until ExpiresAt, once the grant is approved.
+type RoleGrant struct {
+	ID          uint       `json:"id"`
+	Subject     string     `json:"subject"`
+	Role        string     `json:"role"`
+	Domain      string     `json:"domain"`
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"`
+	StartsAt    time.Time  `json:"starts_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RequestedBy string     `json:"requested_by"`
+	DecidedBy   string     `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NewRoleGrant creates a new role grant requested by an actor.
+// Grants that require approval start pending, the others are approved right away.
+func NewRoleGrant(subject, role, domain, reason, requestedBy string, startsAt, expiresAt time.Time, requiresApproval bool) (*RoleGrant, error) {
+	if subject == "" {
+		return nil, errors.New("subject cannot be empty")
+	}
+	if role == "" {
+		return nil, errors.New("role cannot be empty")
+	}
+	if domain == "" {
+		return nil, errors.New("domain cannot be empty")
+	}
+	if requestedBy == "" {
+		return nil, errors.New("requester cannot be empty")
+	}
+
+	now := time.Now()
+	if startsAt.IsZero() {
+		startsAt = now
+	}
+	if !expiresAt.After(startsAt) {
+		return nil, errors.New("expiry must be after the start time")
+	}
+	if !expiresAt.After(now) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	status := RoleGrantStatusApproved
+	if requiresApproval {
+		status = RoleGrantStatusPending
+	}
+
+	return &RoleGrant{
+		Subject:     subject,
+		Role:        role,
+		Domain:      domain,
+		Reason:      reason,
+		Status:      status,
+		StartsAt:    startsAt,
+		ExpiresAt:   expiresAt,
+		RequestedBy: requestedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// Approve approves a pending grant. Requesters cannot approve their own requests.
+func (g *RoleGrant) Approve(approver string) error {
+	if g.Status != RoleGrantStatusPending {
+		return fmt.Errorf("%w: only pending grants can be approved", ErrRoleGrantTransition)
+	}
+	if approver == g.RequestedBy {
+		return fmt.Errorf("%w: grants cannot be approved by their requester", ErrRoleGrantTransition)
+	}
+
+	g.decide(RoleGrantStatusApproved, approver)
+	return nil
+}
+
+// Reject refuses a pending grant
+func (g *RoleGrant) Reject(approver string) error {
+	if g.Status != RoleGrantStatusPending {
+		return fmt.Errorf("%w: only pending grants can be rejected", ErrRoleGrantTransition)
+	}
+
+	g.decide(RoleGrantStatusRejected, approver)
+	return nil
+}
+
+// Revoke withdraws a grant before it expires
+func (g *RoleGrant) Revoke(actor string) error {
+	switch g.Status {
+	case RoleGrantStatusPending, RoleGrantStatusApproved, RoleGrantStatusActive:
+	default:
+		return fmt.Errorf("%w: only pending, approved or active grants can be revoked", ErrRoleGrantTransition)
+	}
+
+	g.decide(RoleGrantStatusRevoked, actor)
+	return nil
+}
+
+// decide records a decision on the grant
+func (g *RoleGrant) decide(status, actor string) {
+	now := time.Now()
+	g.Status = status
+	g.DecidedBy = actor
+	g.DecidedAt = &now
+	g.UpdatedAt = now
+}
+
+// IsDue reports whether an approved grant should be activated
+func (g *RoleGrant) IsDue(now time.Time) bool {
+	return g.Status == RoleGrantStatusApproved && !g.StartsAt.After(now) && g.ExpiresAt.After(now)
+}
+
+// IsExpired reports whether a grant that has not ended yet is past its expiry time
+func (g *RoleGrant) IsExpired(now time.Time) bool {
+	switch g.Status {
+	case RoleGrantStatusPending, RoleGrantStatusApproved, RoleGrantStatusActive:
+		return !g.ExpiresAt.After(now)
+	default:
+		return false
+	}
+}
+
+// Activate marks an approved grant as active once its role is assigned
+func (g *RoleGrant) Activate() {
+	g.Status = RoleGrantStatusActive
+	g.UpdatedAt = time.Now()
+}
+
+// Expire marks a grant as expired once its role is unassigned
+func (g *RoleGrant) Expire() {
+	g.Status = RoleGrantStatusExpired
+	g.UpdatedAt = time.Now()
+}
@@ -2,11 +2,47 @@ package entity
 
 import (
 	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
 	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/pkg/argon2"
 )
 
+var (
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrImpersonationNotAllowed is returned when an actor cannot impersonate a user
+	ErrImpersonationNotAllowed = errors.New("impersonation not allowed")
+	// ErrInvalidProfile is returned when profile fields or custom attributes are invalid
+	ErrInvalidProfile = errors.New("invalid profile")
+	// ErrAvatarNotFound is returned when a user has no avatar
+	ErrAvatarNotFound = errors.New("avatar not found")
+	// ErrUnsupportedAvatarType is returned when an avatar is not a PNG, JPEG or GIF image
+	ErrUnsupportedAvatarType = errors.New("avatar must be a PNG, JPEG or GIF image")
+	// ErrInvalidAvatar is returned when an avatar cannot be decoded or is too large
+	ErrInvalidAvatar = errors.New("invalid avatar")
+	// ErrUsernameTaken is returned when a username is already held by another user, soft deleted or not
+	ErrUsernameTaken = errors.New("username already exists")
+	// ErrEmailTaken is returned when an email is already held by another user, soft deleted or not
+	ErrEmailTaken = errors.New("email already exists")
+	// ErrEmailChangeNotFound is returned when a user has no pending email change, or none matches a token
+	ErrEmailChangeNotFound = errors.New("email change not found")
+	// ErrEmailChangeExpired is returned when confirming an email change past its expiry time
+	ErrEmailChangeExpired = errors.New("email change has expired")
+	// ErrEmailChangeNotSent is returned when the confirmation of an email change could not be emailed
+	ErrEmailChangeNotSent = errors.New("email change confirmation could not be sent")
+)
+
+const (
+	// maxDisplayNameLength is the maximum length of a display name
+	maxDisplayNameLength = 100
+)
+
+// localePattern matches BCP 47 language tags such as en, pt-BR or zh-Hant-TW
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
 // User represents a user in the system
 type User struct {
 	ID        uint       `json:"id"`
@@ -18,15 +54,37 @@ type User struct {
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version is incremented by every update, so that concurrent updates do not overwrite each other
+	Version uint `json:"version"`
+
+	// DisplayName, Locale and Timezone are optional profile fields
+	DisplayName string `json:"display_name"`
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+	// Attributes holds the values of the custom attributes defined by administrators
+	Attributes map[string]any `json:"attributes"`
+	// AvatarKey is the key of the avatar in the blob store, empty without avatar
+	AvatarKey string `json:"-"`
+
+	// PendingEmail is the email the user asked to change to, which replaces Email once confirmed
+	// with the token sent to it before PendingEmailExpiresAt. Only the hash of the token is stored.
+	PendingEmail          string     `json:"pending_email,omitempty"`
+	PendingEmailExpiresAt *time.Time `json:"pending_email_expires_at,omitempty"`
+	EmailChangeTokenHash  string     `json:"-"`
+
+	// TokensRevokedAt rejects the tokens of the user issued at or before it, nil when none are revoked
+	TokensRevokedAt *time.Time `json:"-"`
+	// ErasedAt is when the user was erased, after which only a pseudonymous stub of them is kept
+	ErasedAt *time.Time `json:"erased_at,omitempty"`
 }
 
 // NewUser creates a new user
 func NewUser(username, email, password, role string) (*User, error) {
-	if username == "" {
-		return nil, errors.New("username cannot be empty")
+	if err := validateUsername(username); err != nil {
+		return nil, err
 	}
-	if email == "" {
-		return nil, errors.New("email cannot be empty")
+	if err := validateEmail(email); err != nil {
+		return nil, err
 	}
 	if password == "" {
 		return nil, errors.New("password cannot be empty")
@@ -38,13 +96,14 @@ func NewUser(username, email, password, role string) (*User, error) {
 	}
 
 	return &User{
-		Username:  username,
-		Email:     email,
-		Password:  hashedPassword,
-		Role:      role,
-		Active:    true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Username:   username,
+		Email:      email,
+		Password:   hashedPassword,
+		Role:       role,
+		Active:     true,
+		Attributes: map[string]any{},
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
 	}, nil
 }
 
@@ -70,29 +129,217 @@ func (u *User) ChangePassword(password string) error {
 	return nil
 }
 
-// UpdateProfile updates the user's profile information
-func (u *User) UpdateProfile(username, email string) error {
-	if username == "" {
-		return errors.New("username cannot be empty")
-	}
-	if email == "" {
-		return errors.New("email cannot be empty")
+// UpdateProfile updates the user's username. The email is changed through RequestEmailChange.
+func (u *User) UpdateProfile(username string) error {
+	if err := validateUsername(username); err != nil {
+		return err
 	}
 
 	u.Username = username
-	u.Email = email
 	u.UpdatedAt = time.Now()
 	return nil
 }
 
+// RequestEmailChange records that the user asked to change their email, replacing any change
+// pending, and returns the token confirming it, valid for a period of time
+func (u *User) RequestEmailChange(email string, validity time.Duration) (string, error) {
+	if err := validateEmail(email); err != nil {
+		return "", err
+	}
+	if email == u.Email {
+		return "", fmt.Errorf("%w: email is unchanged", ErrInvalidProfile)
+	}
+	if validity <= 0 {
+		return "", errors.New("validity must be positive")
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(validity)
+	u.PendingEmail = email
+	u.PendingEmailExpiresAt = &expiresAt
+	u.EmailChangeTokenHash = HashEmailChangeToken(token)
+	u.UpdatedAt = now
+	return token, nil
+}
+
+// HasPendingEmailChange reports whether the user has an email change waiting to be confirmed at a time
+func (u *User) HasPendingEmailChange(now time.Time) bool {
+	return u.PendingEmail != "" && u.PendingEmailExpiresAt != nil && now.Before(*u.PendingEmailExpiresAt)
+}
+
+// ConfirmEmailChange replaces the email of the user with the pending one
+func (u *User) ConfirmEmailChange(now time.Time) error {
+	if u.PendingEmail == "" {
+		return ErrEmailChangeNotFound
+	}
+	if !u.HasPendingEmailChange(now) {
+		return ErrEmailChangeExpired
+	}
+
+	u.Email = u.PendingEmail
+	u.clearEmailChange()
+	u.UpdatedAt = now
+	return nil
+}
+
+// CancelEmailChange withdraws the email change of the user, pending or expired
+func (u *User) CancelEmailChange() error {
+	if u.PendingEmail == "" {
+		return ErrEmailChangeNotFound
+	}
+
+	u.clearEmailChange()
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// clearEmailChange forgets the email change of the user
+func (u *User) clearEmailChange() {
+	u.PendingEmail = ""
+	u.PendingEmailExpiresAt = nil
+	u.EmailChangeTokenHash = ""
+}
+
+// HashEmailChangeToken returns the hash under which an email change token is stored
+func HashEmailChangeToken(token string) string {
+	return hashToken(token)
+}
+
+// UpdateDetails updates the optional profile fields of the user. Empty values clear them.
+// The locale is a BCP 47 language tag and the timezone an IANA time zone name.
+func (u *User) UpdateDetails(displayName, locale, timezone string) error {
+	if len([]rune(displayName)) > maxDisplayNameLength {
+		return fmt.Errorf("%w: display name cannot be longer than %d characters", ErrInvalidProfile, maxDisplayNameLength)
+	}
+	if locale != "" && !localePattern.MatchString(locale) {
+		return fmt.Errorf("%w: invalid locale %q", ErrInvalidProfile, locale)
+	}
+	if timezone != "" {
+		// Local would depend on the server
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidProfile, timezone)
+		}
+	}
+
+	u.DisplayName = displayName
+	u.Locale = locale
+	u.Timezone = timezone
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetAttributes replaces the custom attributes of the user, once checked against their definitions
+func (u *User) SetAttributes(attributes map[string]any, definitions []*UserAttribute) error {
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	if err := ValidateUserAttributes(attributes, definitions); err != nil {
+		return err
+	}
+
+	u.Attributes = attributes
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetAvatar sets the key of the user's avatar in the blob store
+func (u *User) SetAvatar(key string) {
+	u.AvatarKey = key
+	u.UpdatedAt = time.Now()
+}
+
 // SetRole sets the user's role
 func (u *User) SetRole(role string) {
 	u.Role = role
 	u.UpdatedAt = time.Now()
 }
 
-// SetActive sets the user's active status
+// SetActive sets the user's active status. Deactivating a user revokes their tokens,
+// which are not given back when they are activated again.
 func (u *User) SetActive(active bool) {
+	now := time.Now()
+	if u.Active && !active {
+		u.RevokeTokens(now)
+	}
 	u.Active = active
-	u.UpdatedAt = time.Now()
+	u.UpdatedAt = now
+}
+
+// RevokeTokens rejects every token issued to the user until now
+func (u *User) RevokeTokens(now time.Time) {
+	u.TokensRevokedAt = &now
+	u.UpdatedAt = now
+}
+
+// IsErased reports whether the user was erased
+func (u *User) IsErased() bool {
+	return u.ErasedAt != nil
+}
+
+// Erase anonymizes the user beyond a soft delete: their username and email are replaced with
+// pseudonyms derived from their ID, every other personal field is cleared, their tokens are revoked
+// and they can no longer log in. The row is kept so that records referring to it stay consistent.
+func (u *User) Erase(now time.Time) {
+	u.Username = ErasedUsername(u.ID)
+	u.Email = ErasedEmail(u.ID)
+	u.Password = ""
+	u.Role = ""
+	u.Active = false
+	u.DisplayName = ""
+	u.Locale = ""
+	u.Timezone = ""
+	u.Attributes = map[string]any{}
+	u.AvatarKey = ""
+	u.clearEmailChange()
+	u.ErasedAt = &now
+	if u.DeletedAt == nil {
+		u.DeletedAt = &now
+	}
+	u.RevokeTokens(now)
+}
+
+// CanBeImpersonatedBy checks that an actor may act as the user: both must be active,
+// and nobody impersonates themselves
+func (u *User) CanBeImpersonatedBy(actor *User) error {
+	if actor.ID == u.ID {
+		return fmt.Errorf("%w: users cannot impersonate themselves", ErrImpersonationNotAllowed)
+	}
+	if !actor.Active {
+		return fmt.Errorf("%w: %s is inactive", ErrImpersonationNotAllowed, actor.Username)
+	}
+	if !u.Active {
+		return fmt.Errorf("%w: %s is inactive", ErrImpersonationNotAllowed, u.Username)
+	}
+	return nil
+}
+
+// validateUsername checks a username, which is also the Casbin subject of the user
+func validateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("%w: username cannot be empty", ErrInvalidProfile)
+	}
+	if IsGroupSubject(username) {
+		return fmt.Errorf("%w: username cannot start with %q", ErrInvalidProfile, GroupSubjectPrefix)
+	}
+	if IsErasedUsername(username) {
+		return fmt.Errorf("%w: username cannot start with %q", ErrInvalidProfile, erasedPrefix)
+	}
+	return nil
+}
+
+// validateEmail checks that an email is a bare address such as jdoe@example.com
+func validateEmail(email string) error {
+	if email == "" {
+		return fmt.Errorf("%w: email cannot be empty", ErrInvalidProfile)
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return fmt.Errorf("%w: invalid email %q", ErrInvalidProfile, email)
+	}
+	return nil
 }
@@ -0,0 +1,147 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+// ErrUserAttributeNotFound is returned when a custom attribute is not defined
+var ErrUserAttributeNotFound = errors.New("user attribute not found")
+
+// Types of custom attributes
+const (
+	UserAttributeTypeString  = "string"
+	UserAttributeTypeNumber  = "number"
+	UserAttributeTypeInteger = "integer"
+	UserAttributeTypeBoolean = "boolean"
+	// UserAttributeTypeEnum is a string among the options of the attribute
+	UserAttributeTypeEnum = "enum"
+)
+
+// maxUserAttributeStringLength is the maximum length of a string attribute value
+const maxUserAttributeStringLength = 1000
+
+// userAttributeNamePattern matches the names of custom attributes, as used for their JSON keys
+var userAttributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// UserAttribute defines a custom attribute users may hold, in addition to their profile fields.
+// Values are checked against the definitions when they are written.
+type UserAttribute struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Required    bool      `json:"required"`
+	Options     []string  `json:"options,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewUserAttribute creates a new custom attribute definition
+func NewUserAttribute(name, attributeType, description string, required bool, options []string) (*UserAttribute, error) {
+	if !userAttributeNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid attribute name %q: use lowercase letters, digits and underscores, starting with a letter", name)
+	}
+
+	attribute := &UserAttribute{
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	if err := attribute.Update(attributeType, description, required, options); err != nil {
+		return nil, err
+	}
+	return attribute, nil
+}
+
+// Update changes the definition of the attribute. Values already held by users are left as they are.
+func (a *UserAttribute) Update(attributeType, description string, required bool, options []string) error {
+	switch attributeType {
+	case UserAttributeTypeString, UserAttributeTypeNumber, UserAttributeTypeInteger, UserAttributeTypeBoolean:
+		if len(options) > 0 {
+			return fmt.Errorf("only %s attributes have options", UserAttributeTypeEnum)
+		}
+	case UserAttributeTypeEnum:
+		if len(options) == 0 {
+			return fmt.Errorf("%s attributes need at least one option", UserAttributeTypeEnum)
+		}
+	default:
+		return fmt.Errorf("unknown attribute type %q, expected %s, %s, %s, %s or %s", attributeType,
+			UserAttributeTypeString, UserAttributeTypeNumber, UserAttributeTypeInteger, UserAttributeTypeBoolean, UserAttributeTypeEnum)
+	}
+
+	a.Type = attributeType
+	a.Description = description
+	a.Required = required
+	a.Options = options
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// ValidateValue checks that a value decoded from JSON suits the type of the attribute
+func (a *UserAttribute) ValidateValue(value any) error {
+	switch a.Type {
+	case UserAttributeTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: attribute %s must be a string", ErrInvalidProfile, a.Name)
+		}
+		if len([]rune(s)) > maxUserAttributeStringLength {
+			return fmt.Errorf("%w: attribute %s cannot be longer than %d characters", ErrInvalidProfile, a.Name, maxUserAttributeStringLength)
+		}
+	case UserAttributeTypeNumber:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%w: attribute %s must be a number", ErrInvalidProfile, a.Name)
+		}
+	case UserAttributeTypeInteger:
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%w: attribute %s must be an integer", ErrInvalidProfile, a.Name)
+		}
+	case UserAttributeTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%w: attribute %s must be a boolean", ErrInvalidProfile, a.Name)
+		}
+	case UserAttributeTypeEnum:
+		if s, ok := value.(string); !ok || !slices.Contains(a.Options, s) {
+			return fmt.Errorf("%w: attribute %s must be one of %v", ErrInvalidProfile, a.Name, a.Options)
+		}
+	}
+	return nil
+}
+
+// ValidateUserAttributes checks the custom attributes of a user: each must be defined and hold
+// a value of its type, null values being rejected, and every required attribute must be set
+func ValidateUserAttributes(attributes map[string]any, definitions []*UserAttribute) error {
+	byName := make(map[string]*UserAttribute, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+
+	// Sorted so that the first invalid attribute reported does not vary
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		definition, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown attribute %q", ErrInvalidProfile, name)
+		}
+		if err := definition.ValidateValue(attributes[name]); err != nil {
+			return err
+		}
+	}
+
+	for _, definition := range definitions {
+		if _, ok := attributes[definition.Name]; definition.Required && !ok {
+			return fmt.Errorf("%w: attribute %s is required", ErrInvalidProfile, definition.Name)
+		}
+	}
+	return nil
+}
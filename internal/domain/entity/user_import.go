@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUserImportNotFound is returned when a user import does not exist
+var ErrUserImportNotFound = errors.New("user import not found")
+
+// User import statuses
+const (
+	// UserImportStatusPending is an import waiting to be processed
+	UserImportStatusPending = "pending"
+	// UserImportStatusRunning is an import being processed
+	UserImportStatusRunning = "running"
+	// UserImportStatusCompleted is an import whose rows were all processed, successfully or not
+	UserImportStatusCompleted = "completed"
+	// UserImportStatusFailed is an import aborted by an error unrelated to its rows
+	UserImportStatusFailed = "failed"
+)
+
+// User file formats
+const (
+	// UserFileFormatCSV is a CSV file with a header row
+	UserFileFormatCSV = "csv"
+	// UserFileFormatJSONL is a file with a JSON object per line
+	UserFileFormatJSONL = "jsonl"
+)
+
+// UserImportError is the reason a row of an import was not imported
+type UserImportError struct {
+	// Row is the number of the row in the file, starting at 1 after any header
+	Row      int    `json:"row"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+// UserImport represents the background import of users from a file.
+// In dry-run mode the rows are only validated and no user is created.
+type UserImport struct {
+	ID     uint   `json:"id"`
+	Format string `json:"format"`
+	DryRun bool   `json:"dry_run"`
+	Status string `json:"status"`
+	// Data is the content of the imported file, which may hold passwords and is cleared once the import is finished
+	Data []byte `json:"-"`
+	// TotalRows is the number of rows in the file
+	TotalRows int `json:"total_rows"`
+	// ProcessedRows is the number of rows processed so far, from the first one
+	ProcessedRows int `json:"processed_rows"`
+	// SucceededRows is the number of rows imported, or that would be in dry-run mode
+	SucceededRows int               `json:"succeeded_rows"`
+	Errors        []UserImportError `json:"errors"`
+	// Error is the reason a failed import was aborted
+	Error       string     `json:"error,omitempty"`
+	RequestedBy string     `json:"requested_by"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NewUserImport creates a new pending import of the rows of a file, requested by an actor
+func NewUserImport(format string, data []byte, totalRows int, dryRun bool, requestedBy string) (*UserImport, error) {
+	if format != UserFileFormatCSV && format != UserFileFormatJSONL {
+		return nil, fmt.Errorf("unsupported format %q, expected %s or %s", format, UserFileFormatCSV, UserFileFormatJSONL)
+	}
+	if requestedBy == "" {
+		return nil, errors.New("requester cannot be empty")
+	}
+
+	now := time.Now()
+	return &UserImport{
+		Format:      format,
+		DryRun:      dryRun,
+		Status:      UserImportStatusPending,
+		Data:        data,
+		TotalRows:   totalRows,
+		RequestedBy: requestedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// Start marks the import as running. Imports interrupted while running are started
+// again, and carry on from the first row not processed.
+func (i *UserImport) Start() {
+	now := time.Now()
+	i.Status = UserImportStatusRunning
+	if i.StartedAt == nil {
+		i.StartedAt = &now
+	}
+	i.UpdatedAt = now
+}
+
+// RecordSuccess records that the next row was imported
+func (i *UserImport) RecordSuccess() {
+	i.ProcessedRows++
+	i.SucceededRows++
+}
+
+// RecordError records that the next row was not imported
+func (i *UserImport) RecordError(username string, err error) {
+	i.ProcessedRows++
+	i.Errors = append(i.Errors, UserImportError{Row: i.ProcessedRows, Username: username, Error: err.Error()})
+}
+
+// Interrupt puts a running import back to pending, to be carried on later
+func (i *UserImport) Interrupt() {
+	i.Status = UserImportStatusPending
+	i.UpdatedAt = time.Now()
+}
+
+// Complete marks the import as completed
+func (i *UserImport) Complete() {
+	i.finish(UserImportStatusCompleted)
+}
+
+// Fail marks the import as aborted by an error
+func (i *UserImport) Fail(err error) {
+	i.Error = err.Error()
+	i.finish(UserImportStatusFailed)
+}
+
+// finish records the end of the import and clears the file, no longer needed
+func (i *UserImport) finish(status string) {
+	now := time.Now()
+	i.Status = status
+	i.Data = nil
+	i.FinishedAt = &now
+	i.UpdatedAt = now
+}
+
+// FailedRows returns the number of rows processed but not imported
+func (i *UserImport) FailedRows() int {
+	return len(i.Errors)
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserEmailChange(t *testing.T) {
+	user := &User{Username: "jdoe", Email: "jdoe@example.com"}
+
+	if _, err := user.RequestEmailChange("jdoe@example.com", time.Hour); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("RequestEmailChange of the current email = %v, want %v", err, ErrInvalidProfile)
+	}
+
+	first, err := user.RequestEmailChange("john@example.org", time.Hour)
+	if err != nil {
+		t.Fatalf("RequestEmailChange failed: %v", err)
+	}
+	second, err := user.RequestEmailChange("johnny@example.org", time.Hour)
+	if err != nil {
+		t.Fatalf("RequestEmailChange failed: %v", err)
+	}
+	if user.EmailChangeTokenHash == HashEmailChangeToken(first) || user.EmailChangeTokenHash != HashEmailChangeToken(second) {
+		t.Error("the token of the replaced change still matches")
+	}
+	if user.Email != "jdoe@example.com" {
+		t.Errorf("email changed to %q before confirmation", user.Email)
+	}
+
+	if err := user.ConfirmEmailChange(time.Now().Add(2 * time.Hour)); !errors.Is(err, ErrEmailChangeExpired) {
+		t.Errorf("ConfirmEmailChange once expired = %v, want %v", err, ErrEmailChangeExpired)
+	}
+	if err := user.ConfirmEmailChange(time.Now()); err != nil {
+		t.Fatalf("ConfirmEmailChange failed: %v", err)
+	}
+	if user.Email != "johnny@example.org" || user.PendingEmail != "" || user.EmailChangeTokenHash != "" {
+		t.Errorf("confirmed change left email %q, pending %q", user.Email, user.PendingEmail)
+	}
+	if err := user.CancelEmailChange(); !errors.Is(err, ErrEmailChangeNotFound) {
+		t.Errorf("CancelEmailChange without a change = %v, want %v", err, ErrEmailChangeNotFound)
+	}
+}
@@ -2,10 +2,29 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 )
 
+// APIClientSortColumns lists the columns API clients can be sorted by
+var APIClientSortColumns = []string{"id", "name", "active", "created_at", "updated_at"}
+
+// APIClientQuery specifies the API clients returned by a listing. Zero fields do not filter.
+type APIClientQuery struct {
+	// Active only lists active or inactive clients
+	Active *bool
+	// CreatedFrom and CreatedTo only list clients created in this range, both inclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Deleted lists soft deleted clients instead of the others
+	Deleted bool
+	// Search only lists clients whose name contains it, ignoring case
+	Search string
+
+	PageQuery
+}
+
 // APIClientRepository defines the interface for API client repository
 type APIClientRepository interface {
 	// Create creates a new API client
@@ -17,20 +36,21 @@ type APIClientRepository interface {
 	// GetByAPIKey retrieves an API client by API key
 	GetByAPIKey(ctx context.Context, apiKey string) (*entity.APIClient, error)
 
-	// Update updates an API client
+	// Update updates an API client at the version it was read at and increments its version, or fails with
+	// ErrVersionConflict when it was updated since
 	Update(ctx context.Context, client *entity.APIClient) error
 
-	// Delete soft deletes an API client
-	Delete(ctx context.Context, id uint) error
+	// Delete soft deletes an API client at the given version, or fails with ErrVersionConflict when it was updated since
+	Delete(ctx context.Context, id uint, version uint) error
 
-	// List retrieves all API clients with pagination
-	List(ctx context.Context, offset, limit int) ([]*entity.APIClient, int64, error)
+	// List retrieves a page of the API clients matching a query
+	List(ctx context.Context, query APIClientQuery) ([]*entity.APIClient, *Page, error)
 
 	// GetDeletedByID retrieves a soft deleted API client by ID
 	GetDeletedByID(ctx context.Context, id uint) (*entity.APIClient, error)
 
-	// ListDeleted retrieves all soft deleted API clients with pagination
-	ListDeleted(ctx context.Context, offset, limit int) ([]*entity.APIClient, int64, error)
+	// ListDeletedBefore retrieves the API clients soft deleted before a time
+	ListDeletedBefore(ctx context.Context, before time.Time) ([]*entity.APIClient, error)
 
 	// Restore restores a soft deleted API client
 	Restore(ctx context.Context, id uint) error
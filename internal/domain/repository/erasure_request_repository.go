@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// ErasureRequestRepository defines the interface for erasure request repository
+type ErasureRequestRepository interface {
+	// Create creates a new erasure request
+	Create(ctx context.Context, request *entity.ErasureRequest) error
+
+	// GetLatestByUser retrieves the latest erasure request of a user
+	GetLatestByUser(ctx context.Context, userID uint) (*entity.ErasureRequest, error)
+
+	// Update updates an erasure request
+	Update(ctx context.Context, request *entity.ErasureRequest) error
+
+	// ListDue retrieves the pending requests scheduled at or before the given time, oldest first
+	ListDue(ctx context.Context, now time.Time) ([]*entity.ErasureRequest, error)
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// GroupRepository defines the interface for group repository
+type GroupRepository interface {
+	// Create creates a new group
+	Create(ctx context.Context, group *entity.Group) error
+
+	// GetByID retrieves a group by ID
+	GetByID(ctx context.Context, id uint) (*entity.Group, error)
+
+	// GetByName retrieves a group of a domain by name
+	GetByName(ctx context.Context, name, domain string) (*entity.Group, error)
+
+	// Update updates a group
+	Update(ctx context.Context, group *entity.Group) error
+
+	// Delete deletes a group
+	Delete(ctx context.Context, id uint) error
+
+	// List retrieves the groups of a domain, or of every domain when empty, with pagination, sorted by name
+	List(ctx context.Context, domain string, offset, limit int) ([]*entity.Group, int64, error)
+}
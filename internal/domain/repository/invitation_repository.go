@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// InvitationFilter restricts the invitations returned by a listing
+type InvitationFilter struct {
+	// Status only lists invitations with this status when set, expired being
+	// the pending invitations past their expiry at Now
+	Status string
+	// Email only lists the invitations sent to this email when set
+	Email string
+	Now   time.Time
+}
+
+// InvitationRepository defines the interface for invitation repository
+type InvitationRepository interface {
+	// Create creates a new invitation
+	Create(ctx context.Context, invitation *entity.Invitation) error
+
+	// GetByID retrieves an invitation by ID
+	GetByID(ctx context.Context, id uint) (*entity.Invitation, error)
+
+	// GetByTokenHash retrieves an invitation by the hash of its token
+	GetByTokenHash(ctx context.Context, tokenHash string) (*entity.Invitation, error)
+
+	// Update updates an invitation
+	Update(ctx context.Context, invitation *entity.Invitation) error
+
+	// Claim saves an invitation marked accepted by Invitation.Accept, provided it is still pending and
+	// unexpired when saved, so that concurrent accepts cannot both succeed. It fails with
+	// entity.ErrInvitationUnusable otherwise.
+	Claim(ctx context.Context, invitation *entity.Invitation) error
+
+	// Release puts an invitation claimed for a user back to pending, when their account could not be created
+	Release(ctx context.Context, invitation *entity.Invitation) error
+
+	// List retrieves invitations matching a filter with pagination, newest first
+	List(ctx context.Context, filter InvitationFilter, offset, limit int) ([]*entity.Invitation, int64, error)
+}
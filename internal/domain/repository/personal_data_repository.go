@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// PersonalRecords holds the records of other aggregates referring to a user
+type PersonalRecords struct {
+	// RoleGrants are the grants to the user, or requested or decided by them
+	RoleGrants []*entity.RoleGrant
+	// Invitations are the invitations sent to the user's email, accepted by them or sent by them
+	Invitations []*entity.Invitation
+	// PolicyVersions are the policy changes made by the user or changing their rules
+	PolicyVersions []*entity.PolicyVersion
+	// UserImports are the user imports requested by the user
+	UserImports []*entity.UserImport
+	// Groups are the groups created by the user
+	Groups []*entity.Group
+	// ErasureRequests are the requests to erase the user
+	ErasureRequests []*entity.ErasureRequest
+}
+
+// PersonalDataRepository finds and erases the personal data of a user held across aggregates,
+// for data subject requests
+type PersonalDataRepository interface {
+	// Find retrieves the records of other aggregates referring to a user, oldest first
+	Find(ctx context.Context, userID uint, subject entity.DataSubject) (*PersonalRecords, error)
+
+	// Erase saves an erased user and the completed request to erase them, and replaces the references
+	// to the user's former identity in other records with their pseudonym, in a single transaction
+	Erase(ctx context.Context, user *entity.User, request *entity.ErasureRequest, former, pseudonym entity.DataSubject) error
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// PolicyVersionRepository defines the interface for policy version repository
+type PolicyVersionRepository interface {
+	// Create records a new policy version and assigns its version number
+	Create(ctx context.Context, version *entity.PolicyVersion) error
+
+	// GetByVersion retrieves a policy version by version number
+	GetByVersion(ctx context.Context, version uint) (*entity.PolicyVersion, error)
+
+	// GetLatestVersion retrieves the latest version number, or 0 if there is none
+	GetLatestVersion(ctx context.Context) (uint, error)
+
+	// List retrieves policy versions with pagination, newest first
+	List(ctx context.Context, offset, limit int) ([]*entity.PolicyVersion, int64, error)
+
+	// ListUpTo retrieves all policy versions up to and including a version, oldest first
+	ListUpTo(ctx context.Context, version uint) ([]*entity.PolicyVersion, error)
+
+	// ApplyChangeset writes the changeset's rules to the policy storage and records
+	// the version in a single transaction
+	ApplyChangeset(ctx context.Context, version *entity.PolicyVersion) error
+}
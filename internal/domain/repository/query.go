@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidSort is returned when a listing is sorted by a column it cannot be sorted by
+	ErrInvalidSort = errors.New("invalid sort")
+	// ErrInvalidCursor is returned when a page cursor is malformed or was issued for another sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	// DefaultPageLimit is the number of items a listing returns when no limit is given
+	DefaultPageLimit = 10
+	// MaxPageLimit is the largest number of items a listing returns at once
+	MaxPageLimit = 100
+)
+
+// PageQuery specifies a page of a listing. Pages are delimited by opaque cursors rather
+// than offsets, so that they stay consistent while rows are added and removed.
+type PageQuery struct {
+	// Sort orders the listing by these columns, then by ID
+	Sort []SortField
+	// After lists the items following the one a cursor points at
+	After string
+	// Before lists the items preceding the one a cursor points at, when After is empty
+	Before string
+	Limit  int
+	// SkipCount leaves the total count out, saving a query
+	SkipCount bool
+}
+
+// Page describes where a page of a listing stands among the others
+type Page struct {
+	// Next is the cursor of the following page, empty on the last one
+	Next string
+	// Prev is the cursor of the preceding page, empty on the first one
+	Prev string
+	// TotalCount is the number of items matching the query over all pages, nil when skipped
+	TotalCount *int64
+}
+
+// SortField is a column a listing is sorted by
+type SortField struct {
+	Column     string
+	Descending bool
+}
+
+// ParseSort parses a comma separated list of columns, each prefixed with - to sort it in
+// descending order, e.g. "-created_at,username". Only the given columns are accepted.
+func ParseSort(value string, columns []string) ([]SortField, error) {
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		field := SortField{Column: strings.TrimPrefix(item, "-"), Descending: strings.HasPrefix(item, "-")}
+		if !containsColumn(columns, field.Column) {
+			return nil, fmt.Errorf("%w: cannot sort by %q, expected one of %s", ErrInvalidSort, field.Column, strings.Join(columns, ", "))
+		}
+		if seen[field.Column] {
+			return nil, fmt.Errorf("%w: %q is given more than once", ErrInvalidSort, field.Column)
+		}
+		seen[field.Column] = true
+
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// containsColumn reports whether a column is in a list
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatSort formats sort fields the way ParseSort parses them
+func FormatSort(sort []SortField) string {
+	items := make([]string, len(sort))
+	for i, field := range sort {
+		items[i] = field.Column
+		if field.Descending {
+			items[i] = "-" + field.Column
+		}
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// RoleGrantFilter restricts the role grants returned by a listing
+type RoleGrantFilter struct {
+	// Status only lists grants with this status when set
+	Status string
+	// Subject only lists grants of this subject when set
+	Subject string
+}
+
+// RoleGrantRepository defines the interface for role grant repository
+type RoleGrantRepository interface {
+	// Create creates a new role grant
+	Create(ctx context.Context, grant *entity.RoleGrant) error
+
+	// GetByID retrieves a role grant by ID
+	GetByID(ctx context.Context, id uint) (*entity.RoleGrant, error)
+
+	// Update updates a role grant
+	Update(ctx context.Context, grant *entity.RoleGrant) error
+
+	// List retrieves role grants matching a filter with pagination, newest first
+	List(ctx context.Context, filter RoleGrantFilter, offset, limit int) ([]*entity.RoleGrant, int64, error)
+
+	// ListDue retrieves the grants to activate or expire at the given time
+	ListDue(ctx context.Context, now time.Time) ([]*entity.RoleGrant, error)
+
+	// RenameSubject moves the grants of a subject, whatever their status, to another subject
+	RenameSubject(ctx context.Context, from, to string) error
+
+	// CountActive counts the active grants of a role to a subject in a domain, excluding a grant
+	CountActive(ctx context.Context, subject, role, domain string, excludeID uint) (int64, error)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserAttributeRepository defines the interface for custom user attribute definition repository
+type UserAttributeRepository interface {
+	// Create creates a new attribute definition
+	Create(ctx context.Context, attribute *entity.UserAttribute) error
+
+	// GetByName retrieves an attribute definition by name, nil if it does not exist
+	GetByName(ctx context.Context, name string) (*entity.UserAttribute, error)
+
+	// Update updates an attribute definition
+	Update(ctx context.Context, attribute *entity.UserAttribute) error
+
+	// Delete deletes an attribute definition
+	Delete(ctx context.Context, id uint) error
+
+	// List retrieves every attribute definition, sorted by name
+	List(ctx context.Context) ([]*entity.UserAttribute, error)
+}
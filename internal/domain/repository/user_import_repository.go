@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserImportRepository defines the interface for user import repository
+type UserImportRepository interface {
+	// Create creates a new user import
+	Create(ctx context.Context, userImport *entity.UserImport) error
+
+	// GetByID retrieves a user import by ID, without the content of its file
+	GetByID(ctx context.Context, id uint) (*entity.UserImport, error)
+
+	// Update updates the status and progress of a user import, clearing its file once finished
+	Update(ctx context.Context, userImport *entity.UserImport) error
+
+	// ClaimNext starts the oldest pending import, or a running one not updated since
+	// staleBefore, and returns it with the content of its file. Imports claimed by
+	// another instance are skipped. It returns nil when there is none.
+	ClaimNext(ctx context.Context, staleBefore time.Time) (*entity.UserImport, error)
+}
@@ -2,10 +2,31 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 )
 
+// UserSortColumns lists the columns users can be sorted by
+var UserSortColumns = []string{"id", "username", "email", "role", "active", "created_at", "updated_at"}
+
+// UserQuery specifies the users returned by a listing. Zero fields do not filter.
+type UserQuery struct {
+	// Role only lists users with this role
+	Role string
+	// Active only lists active or inactive users
+	Active *bool
+	// CreatedFrom and CreatedTo only list users created in this range, both inclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Deleted lists soft deleted users instead of the others
+	Deleted bool
+	// Search only lists users whose username or email contains it, ignoring case
+	Search string
+
+	PageQuery
+}
+
 // UserRepository defines the interface for user repository
 type UserRepository interface {
 	// Create creates a new user
@@ -20,24 +41,39 @@ type UserRepository interface {
 	// GetByEmail retrieves a user by email
 	GetByEmail(ctx context.Context, email string) (*entity.User, error)
 
-	// Update updates a user
+	// GetByEmailChangeTokenHash retrieves the user whose email change has a token of the given hash
+	GetByEmailChangeTokenHash(ctx context.Context, tokenHash string) (*entity.User, error)
+
+	// EmailExists reports whether a user, soft deleted or not, holds an email
+	EmailExists(ctx context.Context, email string) (bool, error)
+
+	// UsernameExists reports whether a user, soft deleted or not, holds a username
+	UsernameExists(ctx context.Context, username string) (bool, error)
+
+	// Update updates a user at the version it was read at and increments its version, or fails with
+	// ErrVersionConflict when it was updated since
 	Update(ctx context.Context, user *entity.User) error
 
-	// Delete soft deletes a user
-	Delete(ctx context.Context, id uint) error
+	// Delete soft deletes a user at the given version, or fails with ErrVersionConflict when it was updated since
+	Delete(ctx context.Context, id uint, version uint) error
 
-	// List retrieves all users with pagination
-	List(ctx context.Context, offset, limit int) ([]*entity.User, int64, error)
+	// List retrieves a page of the users matching a query
+	List(ctx context.Context, query UserQuery) ([]*entity.User, *Page, error)
 
 	// GetDeletedByID retrieves a soft deleted user by ID
 	GetDeletedByID(ctx context.Context, id uint) (*entity.User, error)
 
-	// ListDeleted retrieves all soft deleted users with pagination
-	ListDeleted(ctx context.Context, offset, limit int) ([]*entity.User, int64, error)
-
 	// Restore restores a soft deleted user
 	Restore(ctx context.Context, id uint) error
 
 	// PermanentDelete permanently deletes a user
 	PermanentDelete(ctx context.Context, id uint) error
+
+	// GetTokensRevokedAt retrieves the time at or before which the tokens of a user, deleted or not,
+	// were issued to be rejected, or nil when none are revoked. It returns entity.ErrUserNotFound
+	// once the user is purged.
+	GetTokensRevokedAt(ctx context.Context, id uint) (*time.Time, error)
+
+	// RemoveAttribute removes a custom attribute from every user, deleted ones included
+	RemoveAttribute(ctx context.Context, name string) error
 }
@@ -0,0 +1,7 @@
+package repository
+
+import "errors"
+
+// ErrVersionConflict is returned when updating or deleting a record whose version changed since it was read,
+// that is when another update got there first
+var ErrVersionConflict = errors.New("version conflict: the record was modified since it was read")
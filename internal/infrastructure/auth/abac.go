@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+// ConditionAlways is the condition of rules that apply unconditionally
+const ConditionAlways = "true"
+
+// RequestAttributes holds the attributes of a request that policy conditions are evaluated
+// against. Conditions refer to them as r.env, for example:
+//
+//	r.env.OwnerID == r.env.SubjectID
+//	r.env.Hour >= 9 && r.env.Hour < 18 && r.env.Weekday != 0
+//	ipMatch(r.env.IP, '10.0.0.0/8')
+type RequestAttributes struct {
+	// SubjectID is the ID of the authenticated user or API client
+	SubjectID string
+	// OwnerID is the ID of the user owning the requested resource, empty when unknown
+	OwnerID string
+	// OwnerRole is the highest effective role of the user owning the requested resource, empty when unknown
+	OwnerRole string
+	// IP is the client IP address
+	IP string
+	// Hour is the hour of the request time in UTC, from 0 to 23
+	Hour int
+	// Weekday is the day of the week of the request time in UTC, 0 being Sunday
+	Weekday int
+}
+
+// NewRequestAttributes creates the attributes of a request made at the given time
+func NewRequestAttributes(subjectID, ownerID, ownerRole, ip string, at time.Time) RequestAttributes {
+	at = at.UTC()
+	return RequestAttributes{
+		SubjectID: subjectID,
+		OwnerID:   ownerID,
+		OwnerRole: ownerRole,
+		IP:        ip,
+		Hour:      at.Hour(),
+		Weekday:   int(at.Weekday()),
+	}
+}
+
+// attributeMask tells which request attributes a set of conditions refers to
+type attributeMask struct {
+	subjectID, ownerID, ownerRole, ip, hour, weekday bool
+}
+
+// maskOf returns the mask of the attributes the conditions refer to
+func maskOf(conditions []string) attributeMask {
+	var mask attributeMask
+	for _, cond := range conditions {
+		mask.subjectID = mask.subjectID || strings.Contains(cond, "r.env.SubjectID")
+		mask.ownerID = mask.ownerID || strings.Contains(cond, "r.env.OwnerID")
+		mask.ownerRole = mask.ownerRole || strings.Contains(cond, "r.env.OwnerRole")
+		mask.ip = mask.ip || strings.Contains(cond, "r.env.IP")
+		mask.hour = mask.hour || strings.Contains(cond, "r.env.Hour")
+		mask.weekday = mask.weekday || strings.Contains(cond, "r.env.Weekday")
+	}
+	return mask
+}
+
+// apply returns a copy of attrs keeping only the attributes of the mask, the others zeroed
+func (m attributeMask) apply(attrs RequestAttributes) RequestAttributes {
+	var masked RequestAttributes
+	if m.subjectID {
+		masked.SubjectID = attrs.SubjectID
+	}
+	if m.ownerID {
+		masked.OwnerID = attrs.OwnerID
+	}
+	if m.ownerRole {
+		masked.OwnerRole = attrs.OwnerRole
+	}
+	if m.ip {
+		masked.IP = attrs.IP
+	}
+	if m.hour {
+		masked.Hour = attrs.Hour
+	}
+	if m.weekday {
+		masked.Weekday = attrs.Weekday
+	}
+	return masked
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// ActorSystem is the actor recorded for changes made outside of a request, e.g. by the seeder or the CLI
+const ActorSystem = "system"
+
+// actorContextKey is the context key under which the acting principal is stored
+type actorContextKey struct{}
+
+// impersonatedContextKey is the context key under which the impersonated user is stored
+type impersonatedContextKey struct{}
+
+// WithActor returns a copy of ctx carrying the principal on whose behalf changes are made
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the principal on whose behalf changes are made, or ActorSystem
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+// WithImpersonated returns a copy of ctx recording that the actor is impersonating a user
+func WithImpersonated(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, impersonatedContextKey{}, username)
+}
+
+// IsImpersonating reports whether the actor of ctx is impersonating a user, acting on their behalf
+// with a token issued to them
+func IsImpersonating(ctx context.Context) bool {
+	username, ok := ctx.Value(impersonatedContextKey{}).(string)
+	return ok && username != ""
+}
+
+// authorizedSubject returns the Casbin subject whose permissions apply to the changes made with ctx:
+// the impersonated user while impersonating, as for the route, otherwise the actor
+func authorizedSubject(ctx context.Context) string {
+	if username, ok := ctx.Value(impersonatedContextKey{}).(string); ok && username != "" {
+		return username
+	}
+	return ActorSubject(ActorFromContext(ctx))
+}
+
+// ActorSubject returns the Casbin subject of an actor, its name without the principal type prefix
+func ActorSubject(actor string) string {
+	if _, subject, found := strings.Cut(actor, ":"); found {
+		return subject
+	}
+	return actor
+}
+
+// UserActor returns the actor recorded for changes made by a user
+func UserActor(username string) string {
+	return "user:" + username
+}
@@ -3,11 +3,16 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
 )
 
+// ErrInvalidAPIKey is returned when an API key is missing, unknown or belongs to an inactive API client
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
 // APIKeyService handles API key authentication
 type APIKeyService struct {
 	config     *config.Config
@@ -24,24 +29,36 @@ func NewAPIKeyService(config *config.Config, repository repository.APIClientRepo
 
 // ValidateAPIKey validates an API key
 func (s *APIKeyService) ValidateAPIKey(ctx context.Context, apiKey string) (bool, uint, error) {
+	client, err := s.Authenticate(ctx, apiKey)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return true, client.ID, nil
+}
+
+// Authenticate validates an API key and returns the API client it belongs to. It fails with
+// ErrInvalidAPIKey when the key does not authenticate an active API client, and with the error
+// of the lookup otherwise.
+func (s *APIKeyService) Authenticate(ctx context.Context, apiKey string) (*entity.APIClient, error) {
 	if apiKey == "" {
-		return false, 0, errors.New("API key is required")
+		return nil, fmt.Errorf("%w: API key is required", ErrInvalidAPIKey)
 	}
 
 	client, err := s.repository.GetByAPIKey(ctx, apiKey)
 	if err != nil {
-		return false, 0, err
+		return nil, err
 	}
 
 	if client == nil {
-		return false, 0, errors.New("invalid API key")
+		return nil, ErrInvalidAPIKey
 	}
 
 	if !client.Active {
-		return false, 0, errors.New("API client is inactive")
+		return nil, fmt.Errorf("%w: API client is inactive", ErrInvalidAPIKey)
 	}
 
-	return true, client.ID, nil
+	return client, nil
 }
 
 // GetAPIKeyFromHeader extracts the API key from the request header
@@ -1,64 +1,544 @@
 package auth
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/casbin/casbin/v2/util"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
 	"gorm.io/gorm"
 )
 
+// Casbin domains used by the application
+const (
+	// DomainDefault is the domain users are authorized in
+	DomainDefault = "default"
+	// DomainAPI is the domain API clients are authorized in
+	DomainAPI = "api"
+)
+
+// ActionAssign is the action of the rules letting their subject assign a role, on its RoleObject
+const ActionAssign = "assign"
+
+// RoleObject returns the object of the rules letting their subject assign a role
+func RoleObject(role string) string {
+	return "roles/" + role
+}
+
+// ActionUpdate is the action of the rules letting their subject change a restricted field, on its FieldObject
+const ActionUpdate = "update"
+
+// FieldObject returns the object of the rules letting their subject change a restricted field of a
+// kind of resource, e.g. fields/users/role
+func FieldObject(resource, field string) string {
+	return "fields/" + resource + "/" + field
+}
+
+// APIClientPolicies returns the rules an API client is given on creation: reading the API, except
+// for the other API clients, whose routes are left to the client-admin role
+func APIClientPolicies(client string) []entity.PolicyRule {
+	return []entity.PolicyRule{
+		{PType: "p", Values: []string{client, DomainAPI, "/api/*", "GET", ConditionAlways, EffectAllow}},
+		{PType: "p", Values: []string{client, DomainAPI, "/api/clients", "GET", ConditionAlways, EffectDeny}},
+		{PType: "p", Values: []string{client, DomainAPI, "/api/clients/*", "GET", ConditionAlways, EffectDeny}},
+	}
+}
+
+// MissingAPIClientDenies returns the denies of APIClientPolicies lacking from normalized rules that
+// give an API client its allow, as clients created before the denies existed only got the allow
+func MissingAPIClientDenies(rules []entity.PolicyRule) []entity.PolicyRule {
+	present := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		present[rule.String()] = true
+	}
+
+	var missing []entity.PolicyRule
+	for _, rule := range rules {
+		if rule.PType != "p" || len(rule.Values) == 0 {
+			continue
+		}
+		// The allow comes first, followed by the denies
+		defaults := APIClientPolicies(rule.Values[0])
+		if rule.String() != defaults[0].String() {
+			continue
+		}
+		for _, deny := range defaults[1:] {
+			if !present[deny.String()] {
+				present[deny.String()] = true
+				missing = append(missing, deny)
+			}
+		}
+	}
+	return missing
+}
+
+var (
+	// ErrPolicyExists is returned when adding a rule that already exists
+	ErrPolicyExists = errors.New("policy already exists")
+	// ErrPolicyNotFound is returned when removing a rule that does not exist
+	ErrPolicyNotFound = errors.New("policy not found")
+)
+
 // CasbinService handles authorization using Casbin
 type CasbinService struct {
 	enforcer *casbin.SyncedEnforcer
+	watcher  persist.Watcher
+	history  repository.PolicyVersionRepository
+	cache    *decisionCache // nil when decisions are not cached
+	// changeMutex serializes policy changes, from computing them against the current rules to
+	// writing them, so that concurrent changes do not interleave
+	changeMutex sync.Mutex
 }
 
-// NewCasbinService creates a new CasbinService
-func NewCasbinService(db *gorm.DB, config *config.Config) (*CasbinService, error) {
+// NewCasbinService creates a new CasbinService backed by the casbin_rule table.
+// Every policy change is recorded in history when it is not nil.
+func NewCasbinService(db *gorm.DB, config *config.Config, history repository.PolicyVersionRepository) (*CasbinService, error) {
 	adapter, err := gormadapter.NewAdapterByDB(db)
 	if err != nil {
 		return nil, err
 	}
 
-	enforcer, err := casbin.NewSyncedEnforcer("casbin/model.conf", adapter)
+	service, err := NewCasbinServiceWithAdapter(adapter, config, history)
 	if err != nil {
 		return nil, err
 	}
 
-	// Load policies from the database
-	if err := enforcer.LoadPolicy(); err != nil {
+	// Keep policies in sync with the other instances
+	switch config.Casbin.Watcher {
+	case "postgres":
+		watcher, err := NewPostgresWatcher(db, config.Casbin.WatcherChannel)
+		if err != nil {
+			return nil, err
+		}
+		if err := service.SetWatcher(watcher); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	case "", "none":
+	default:
+		return nil, fmt.Errorf("unknown casbin watcher %q", config.Casbin.Watcher)
+	}
+
+	return service, nil
+}
+
+// NewCasbinServiceWithAdapter creates a new CasbinService loading its policies from an adapter,
+// without synchronization with other instances
+func NewCasbinServiceWithAdapter(adapter persist.Adapter, config *config.Config, history repository.PolicyVersionRepository) (*CasbinService, error) {
+	enforcer, err := casbin.NewSyncedEnforcer(config.Casbin.ModelPath, adapter)
+	if err != nil {
 		return nil, err
 	}
+	enforcer.SetEffector(&priorityEffector{enforcer: enforcer})
 
-	//enforcer.StartAutoLoadPolicy(2 * time.Second)
+	// Load policies from the adapter
+	if err := enforcer.LoadPolicy(); err != nil {
+		return nil, err
+	}
 
-	return &CasbinService{
+	service := &CasbinService{
 		enforcer: enforcer,
-	}, nil
+		history:  history,
+		cache:    newDecisionCache(config.Casbin.CacheTTL, config.Casbin.CacheSize),
+	}
+
+	// Record the current policy as the first version if there is no history yet
+	if err := service.ensureBaseline(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// NewCasbinServiceFromPolicyFile creates a new in-memory CasbinService holding the rules of a
+// CSV or YAML policy file, for offline tooling. Changes are neither persisted nor recorded.
+func NewCasbinServiceFromPolicyFile(config *config.Config, path string) (*CasbinService, error) {
+	format, err := PolicyFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := ParsePolicies(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	// An adapter without a file path starts from an empty policy and ignores writes
+	service, err := NewCasbinServiceWithAdapter(fileadapter.NewAdapter(""), config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := PolicyDiff{Added: service.NormalizePolicies(rules)}
+	if err := service.ApplyPolicyDiff(context.Background(), entity.PolicyActionImport, diff); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// SetWatcher attaches a watcher that propagates policy changes to and from other instances
+func (s *CasbinService) SetWatcher(watcher persist.Watcher) error {
+	if err := s.enforcer.SetWatcher(watcher); err != nil {
+		return err
+	}
+	if err := watcher.SetUpdateCallback(s.applyPolicyUpdate); err != nil {
+		return err
+	}
+
+	s.watcher = watcher
+	return nil
 }
 
-// Enforce checks if a subject can access an object with the given action in the specified domain
-func (s *CasbinService) Enforce(sub, dom, obj, act string) (bool, error) {
-	return s.enforcer.Enforce(sub, dom, obj, act)
+// Close releases the watcher, if any
+func (s *CasbinService) Close() {
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
 }
 
-// AddPolicy adds a policy rule to the enforcer
-func (s *CasbinService) AddPolicy(sub, dom, obj, act string) (bool, error) {
-	return s.enforcer.AddPolicy(sub, dom, obj, act)
+// applyPolicyUpdate applies a change made by another instance to the in-memory policy.
+// Changes that cannot be applied incrementally fall back to a full reload.
+func (s *CasbinService) applyPolicyUpdate(payload string) {
+	defer s.invalidateDecisions()
+
+	var update PolicyUpdate
+	if err := json.Unmarshal([]byte(payload), &update); err == nil {
+		if err := s.applyIncrementalUpdate(update); err == nil {
+			return
+		}
+	}
+
+	if err := s.enforcer.LoadPolicy(); err != nil {
+		log.Printf("Failed to reload policy: %v", err)
+	}
 }
 
-// RemovePolicy removes a policy rule from the enforcer
-func (s *CasbinService) RemovePolicy(sub, dom, obj, act string) (bool, error) {
-	return s.enforcer.RemovePolicy(sub, dom, obj, act)
+// applyIncrementalUpdate applies a single change to the model without persisting it,
+// since the instance that made the change already wrote it to the database
+func (s *CasbinService) applyIncrementalUpdate(update PolicyUpdate) error {
+	lock := s.enforcer.GetLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	m := s.enforcer.GetModel()
+
+	var op model.PolicyOp
+	var affected [][]string
+	var err error
+
+	switch update.Method {
+	case PolicyUpdateAddPolicies:
+		op = model.PolicyAdd
+		affected, err = m.AddPoliciesWithAffected(update.Sec, update.PType, update.Rules)
+	case PolicyUpdateRemovePolicies:
+		op = model.PolicyRemove
+		affected, err = m.RemovePoliciesWithAffected(update.Sec, update.PType, update.Rules)
+	case PolicyUpdateRemoveFilteredPolicy:
+		op = model.PolicyRemove
+		_, affected, err = m.RemoveFilteredPolicy(update.Sec, update.PType, update.FieldIndex, update.FieldValues...)
+	default:
+		return fmt.Errorf("policy update %q requires a full reload", update.Method)
+	}
+	if err != nil {
+		return err
+	}
+
+	if update.Sec == "g" && len(affected) > 0 {
+		return s.enforcer.BuildIncrementalRoleLinks(op, update.PType, affected)
+	}
+	return nil
+}
+
+// Enforce checks if a subject can access an object with the given action in the specified domain.
+// Conditional rules are evaluated against the request attributes.
+// Decisions are served from the cache when it is enabled, keyed by the attributes that the
+// conditions of the rules matching the domain, object and action refer to.
+func (s *CasbinService) Enforce(sub, dom, obj, act string, attrs RequestAttributes) (bool, error) {
+	if s.cache == nil {
+		return s.enforcer.Enforce(sub, dom, obj, act, attrs)
+	}
+
+	// The attributes the conditions of the matching rules refer to are part of the key,
+	// since the decision depends on them
+	scope := requestScope{dom: dom, obj: obj, act: act}
+	mask, found, generation := s.cache.getMask(scope)
+	if !found {
+		var err error
+		if mask, err = s.conditionMask(scope); err != nil {
+			return false, err
+		}
+		s.cache.putMask(scope, mask, generation)
+	}
+
+	key := decisionKey{sub: sub, dom: dom, obj: obj, act: act, attrs: mask.apply(attrs)}
+	now := time.Now()
+	allowed, found, _ := s.cache.get(key, now)
+	if found {
+		return allowed, nil
+	}
+
+	allowed, err := s.enforcer.Enforce(sub, dom, obj, act, attrs)
+	if err != nil {
+		return false, err
+	}
+	// Cached under the generation of the mask, so that it is dropped if the policy changed meanwhile
+	s.cache.put(key, allowed, generation, now)
+	return allowed, nil
+}
+
+// conditionMask returns the mask of the attributes referred to by the conditions of the rules
+// that may match a request in a scope, whatever its subject
+func (s *CasbinService) conditionMask(scope requestScope) (attributeMask, error) {
+	rules, err := s.GetAllPolicies()
+	if err != nil {
+		return attributeMask{}, err
+	}
+
+	var conditions []string
+	for _, rule := range rules {
+		if rule.PType != "p" || len(rule.Values) < 5 || rule.Values[1] != scope.dom {
+			continue
+		}
+		if !util.KeyMatch2(scope.obj, rule.Values[2]) || (rule.Values[3] != scope.act && rule.Values[3] != "*") {
+			continue
+		}
+		conditions = append(conditions, rule.Values[4])
+	}
+	return maskOf(conditions), nil
+}
+
+// DecisionCacheStats returns the statistics of the decision cache
+func (s *CasbinService) DecisionCacheStats() DecisionCacheStats {
+	if s.cache == nil {
+		return DecisionCacheStats{}
+	}
+	return s.cache.stats()
+}
+
+// invalidateDecisions drops the cached decisions after a policy or grouping change.
+// It must run once the change is applied to the enforcer, so that decisions computed
+// concurrently with the change are not cached.
+func (s *CasbinService) invalidateDecisions() {
+	if s.cache != nil {
+		s.cache.invalidate()
+	}
+}
+
+// AddPolicyRule adds a policy or grouping rule, completed with the defaults of the fields it omits
+func (s *CasbinService) AddPolicyRule(ctx context.Context, rule entity.PolicyRule) (bool, error) {
+	rule = entity.PolicyRule{PType: rule.PType, Values: s.normalizeRule(rule.PType, rule.Values)}
+	if err := s.ValidatePolicies([]entity.PolicyRule{rule}); err != nil {
+		return false, err
+	}
+
+	diff, err := s.UpdatePolicies(ctx, entity.PolicyActionAdd, func([]entity.PolicyRule) (PolicyDiff, error) {
+		if has, err := s.hasRule(rule); has || err != nil {
+			return PolicyDiff{}, err
+		}
+		return PolicyDiff{Added: []entity.PolicyRule{rule}}, nil
+	})
+	return !diff.IsEmpty(), err
+}
+
+// RemovePolicyRule removes a policy or grouping rule, completed with the defaults of the fields it omits
+func (s *CasbinService) RemovePolicyRule(ctx context.Context, rule entity.PolicyRule) (bool, error) {
+	rule = entity.PolicyRule{PType: rule.PType, Values: s.normalizeRule(rule.PType, rule.Values)}
+	diff, err := s.UpdatePolicies(ctx, entity.PolicyActionRemove, func([]entity.PolicyRule) (PolicyDiff, error) {
+		if has, err := s.hasRule(rule); !has || err != nil {
+			return PolicyDiff{}, err
+		}
+		return PolicyDiff{Removed: []entity.PolicyRule{rule}}, nil
+	})
+	return !diff.IsEmpty(), err
+}
+
+// hasRule reports whether the enforcer holds a rule
+func (s *CasbinService) hasRule(rule entity.PolicyRule) (bool, error) {
+	if rule.IsGrouping() {
+		return s.enforcer.HasNamedGroupingPolicy(rule.PType, rule.Values)
+	}
+	return s.enforcer.HasNamedPolicy(rule.PType, rule.Values)
+}
+
+// AddPolicy adds an unconditional policy rule to the enforcer
+func (s *CasbinService) AddPolicy(ctx context.Context, sub, dom, obj, act string) (bool, error) {
+	return s.AddPolicyRule(ctx, entity.PolicyRule{PType: "p", Values: []string{sub, dom, obj, act}})
+}
+
+// RemovePolicy removes an unconditional policy rule from the enforcer
+func (s *CasbinService) RemovePolicy(ctx context.Context, sub, dom, obj, act string) (bool, error) {
+	return s.RemovePolicyRule(ctx, entity.PolicyRule{PType: "p", Values: []string{sub, dom, obj, act}})
 }
 
 // AddRoleForUser adds a role for a user in a domain
-func (s *CasbinService) AddRoleForUser(user, role, domain string) (bool, error) {
-	return s.enforcer.AddGroupingPolicy(user, role, domain)
+func (s *CasbinService) AddRoleForUser(ctx context.Context, user, role, domain string) (bool, error) {
+	return s.AddPolicyRule(ctx, entity.PolicyRule{PType: "g", Values: []string{user, role, domain}})
 }
 
 // DeleteRoleForUser removes a role for a user in a domain
-func (s *CasbinService) DeleteRoleForUser(user, role, domain string) (bool, error) {
-	return s.enforcer.RemoveGroupingPolicy(user, role, domain)
+func (s *CasbinService) DeleteRoleForUser(ctx context.Context, user, role, domain string) (bool, error) {
+	return s.RemovePolicyRule(ctx, entity.PolicyRule{PType: "g", Values: []string{user, role, domain}})
+}
+
+// GetSubjectPolicies returns the policy and grouping rules of a subject in a domain
+func (s *CasbinService) GetSubjectPolicies(sub, dom string) ([]entity.PolicyRule, error) {
+	rules, err := s.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+	return subjectPolicies(rules, sub, dom), nil
+}
+
+// subjectPolicies returns the rules of a subject in a domain among rules
+func subjectPolicies(rules []entity.PolicyRule, sub, dom string) []entity.PolicyRule {
+	var subjectRules []entity.PolicyRule
+	for _, rule := range rules {
+		// The domain is the second field of p rules and the third of g rules
+		domIndex := 1
+		if rule.PType[:1] == "g" {
+			domIndex = 2
+		}
+		if len(rule.Values) > domIndex && rule.Values[0] == sub && rule.Values[domIndex] == dom {
+			subjectRules = append(subjectRules, rule)
+		}
+	}
+	return subjectRules
+}
+
+// DeleteSubject removes every policy and grouping rule of a subject in a domain
+// and returns the number of rules removed
+func (s *CasbinService) DeleteSubject(ctx context.Context, sub, dom string) (int, error) {
+	diff, err := s.UpdatePolicies(ctx, entity.PolicyActionRemove, func(current []entity.PolicyRule) (PolicyDiff, error) {
+		return PolicyDiff{Removed: subjectPolicies(current, sub, dom)}, nil
+	})
+	return len(diff.Removed), err
+}
+
+// DeleteSubjectInAllDomains removes every policy and grouping rule of a subject, whatever
+// their domain, and returns the number of rules removed
+func (s *CasbinService) DeleteSubjectInAllDomains(ctx context.Context, sub string) (int, error) {
+	diff, err := s.UpdatePolicies(ctx, entity.PolicyActionRemove, func(current []entity.PolicyRule) (PolicyDiff, error) {
+		var removed []entity.PolicyRule
+		for _, rule := range current {
+			if len(rule.Values) > 0 && rule.Values[0] == sub {
+				removed = append(removed, rule)
+			}
+		}
+		return PolicyDiff{Removed: removed}, nil
+	})
+	return len(diff.Removed), err
+}
+
+// RenameSubject moves every policy and grouping rule of a subject, whatever their domain, to
+// another subject and returns the number of rules moved
+func (s *CasbinService) RenameSubject(ctx context.Context, from, to string) (int, error) {
+	diff, err := s.UpdatePolicies(ctx, entity.PolicyActionRename, func(current []entity.PolicyRule) (PolicyDiff, error) {
+		var diff PolicyDiff
+		for _, rule := range current {
+			if len(rule.Values) == 0 || rule.Values[0] != from {
+				continue
+			}
+			values := slices.Clone(rule.Values)
+			values[0] = to
+			diff.Removed = append(diff.Removed, rule)
+			diff.Added = append(diff.Added, entity.PolicyRule{PType: rule.PType, Values: values})
+		}
+		return diff, nil
+	})
+	return len(diff.Removed), err
+}
+
+// GetRoles returns the roles of a domain: the subjects of its policy rules and the roles
+// assigned by its grouping rules, groups aside
+func (s *CasbinService) GetRoles(dom string) ([]string, error) {
+	rules, err := s.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		role := ""
+		switch {
+		case rule.PType == "p" && len(rule.Values) > 1 && rule.Values[1] == dom:
+			role = rule.Values[0]
+		case rule.PType == "g" && len(rule.Values) > 2 && rule.Values[2] == dom:
+			role = rule.Values[1]
+		}
+		if role != "" && !entity.IsGroupSubject(role) && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// IsReservedSubject reports whether a name is a role or the subject of a policy rule in any domain.
+// Casbin resolves roles by name, so a user named after one would hold its permissions.
+func (s *CasbinService) IsReservedSubject(name string) (bool, error) {
+	rules, err := s.GetAllPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range rules {
+		switch {
+		case rule.PType == "p" && len(rule.Values) > 0 && rule.Values[0] == name:
+			return true, nil
+		case rule.PType[:1] == "g" && len(rule.Values) > 1 && rule.Values[1] == name:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CanAssignRole reports whether the actor of ctx may assign a role in a domain, that is whether a
+// rule grants them the ActionAssign action on the RoleObject of the role, e.g.
+//
+//	p, admin, default, roles/support, assign
+//
+// While impersonating, the impersonated user's permissions apply, not the impersonator's.
+// Changes made by the system, such as seeding, may assign any role.
+func (s *CasbinService) CanAssignRole(ctx context.Context, role, dom string) (bool, error) {
+	if ActorFromContext(ctx) == ActorSystem {
+		return true, nil
+	}
+	return s.Enforce(authorizedSubject(ctx), dom, RoleObject(role), ActionAssign, RequestAttributes{})
+}
+
+// CanUpdateField reports whether the actor of ctx may change a restricted field of a kind of resource
+// in a domain, that is whether a rule grants them the ActionUpdate action on its FieldObject, e.g.
+//
+//	p, admin, default, fields/users/active, update
+//
+// While impersonating, the impersonated user's permissions apply, not the impersonator's.
+// Changes made by the system may change any field.
+func (s *CasbinService) CanUpdateField(ctx context.Context, resource, field, dom string) (bool, error) {
+	if ActorFromContext(ctx) == ActorSystem {
+		return true, nil
+	}
+	return s.Enforce(authorizedSubject(ctx), dom, FieldObject(resource, field), ActionUpdate, RequestAttributes{})
 }
 
 // GetRolesForUser gets roles for a user in a domain
@@ -70,3 +550,178 @@ func (s *CasbinService) GetRolesForUser(user, domain string) ([]string, error) {
 func (s *CasbinService) GetUsersForRole(role, domain string) ([]string, error) {
 	return s.enforcer.GetUsersForRoleInDomain(role, domain), nil
 }
+
+// GetImplicitRolesForUser gets direct and inherited roles for a user in a domain
+func (s *CasbinService) GetImplicitRolesForUser(user, domain string) ([]string, error) {
+	return s.enforcer.GetImplicitRolesForUser(user, domain)
+}
+
+// GetHighestRoleForUser gets the highest of the direct and inherited roles of a user in a domain,
+// groups aside: the one inheriting the most other roles, e.g. superadmin for a member of a group
+// holding it. Roles ranking the same are ordered by name. It returns an empty role when the user
+// holds none.
+func (s *CasbinService) GetHighestRoleForUser(user, domain string) (string, error) {
+	roles, err := s.GetImplicitRolesForUser(user, domain)
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(roles)
+
+	highest, highestRank := "", -1
+	for _, role := range roles {
+		if entity.IsGroupSubject(role) {
+			continue
+		}
+		inherited, err := s.GetImplicitRolesForUser(role, domain)
+		if err != nil {
+			return "", err
+		}
+		if rank := len(inherited); rank > highestRank {
+			highest, highestRank = role, rank
+		}
+	}
+	return highest, nil
+}
+
+// GetImplicitPermissionsForUser gets the permissions granted to a user in a domain,
+// either directly or through any of its direct or inherited roles
+func (s *CasbinService) GetImplicitPermissionsForUser(user, domain string) ([][]string, error) {
+	return s.enforcer.GetImplicitPermissionsForUser(user, domain)
+}
+
+// GetAllPolicies returns every policy and grouping rule currently loaded
+func (s *CasbinService) GetAllPolicies() ([]entity.PolicyRule, error) {
+	lock := s.enforcer.GetLock()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var rules []entity.PolicyRule
+	m := s.enforcer.GetModel()
+	for _, sec := range []string{"p", "g"} {
+		for ptype := range m[sec] {
+			policy, err := m.GetPolicy(sec, ptype)
+			if err != nil {
+				return nil, err
+			}
+			for _, values := range policy {
+				rules = append(rules, entity.PolicyRule{PType: ptype, Values: values})
+			}
+		}
+	}
+
+	return SortPolicies(rules), nil
+}
+
+// IsGranted reports whether any allow rule of a domain covers an object and action, whatever its
+// subject and condition. Objects are matched as paths, so route templates such as /v1/users/:id can be checked.
+func (s *CasbinService) IsGranted(dom, obj, act string) (bool, error) {
+	rules, err := s.GetAllPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range rules {
+		if rule.PType != "p" || len(rule.Values) < 6 || rule.Values[1] != dom {
+			continue
+		}
+		if !util.KeyMatch2(obj, rule.Values[2]) || (rule.Values[3] != act && rule.Values[3] != "*") {
+			continue
+		}
+
+		effect, _, err := ParseEffect(rule.Values[5])
+		if err != nil {
+			return false, err
+		}
+		if effect == EffectAllow {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ApplyPolicyDiff removes and then adds the rules of a diff, recording it under the given action.
+// Diffs computed from the current rules should be applied with UpdatePolicies instead.
+func (s *CasbinService) ApplyPolicyDiff(ctx context.Context, action string, diff PolicyDiff) error {
+	s.changeMutex.Lock()
+	defer s.changeMutex.Unlock()
+
+	return s.applyPolicyDiff(ctx, action, diff)
+}
+
+// UpdatePolicies computes a diff from the current rules and applies it, recording it under the
+// given action. No other change is made in between, so that the diff is still accurate when applied.
+// It returns the diff applied.
+func (s *CasbinService) UpdatePolicies(ctx context.Context, action string, compute func(current []entity.PolicyRule) (PolicyDiff, error)) (PolicyDiff, error) {
+	s.changeMutex.Lock()
+	defer s.changeMutex.Unlock()
+
+	current, err := s.GetAllPolicies()
+	if err != nil {
+		return PolicyDiff{}, err
+	}
+	diff, err := compute(current)
+	if err != nil || diff.IsEmpty() {
+		return diff, err
+	}
+
+	if err := s.applyPolicyDiff(ctx, action, diff); err != nil {
+		return PolicyDiff{}, err
+	}
+	return diff, nil
+}
+
+// applyPolicyDiff applies a diff while changeMutex is held. With a history, the rules and the
+// version recording them are written to the database atomically, as for a rollback; otherwise
+// they are only applied to the enforcer.
+func (s *CasbinService) applyPolicyDiff(ctx context.Context, action string, diff PolicyDiff) error {
+	if err := s.ValidatePolicies(diff.Added); err != nil {
+		return err
+	}
+	if diff.IsEmpty() {
+		return nil
+	}
+
+	if s.history != nil {
+		version, err := entity.NewPolicyVersion(ActorFromContext(ctx), action, diff.Added, diff.Removed)
+		if err != nil {
+			return err
+		}
+		return s.applyChangeset(ctx, version)
+	}
+
+	defer s.invalidateDecisions()
+
+	for ptype, rules := range groupPoliciesByType(diff.Removed) {
+		var err error
+		if ptype[:1] == "g" {
+			_, err = s.enforcer.RemoveNamedGroupingPolicies(ptype, rules)
+		} else {
+			_, err = s.enforcer.RemoveNamedPolicies(ptype, rules)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for ptype, rules := range groupPoliciesByType(diff.Added) {
+		var err error
+		if ptype[:1] == "g" {
+			_, err = s.enforcer.AddNamedGroupingPoliciesEx(ptype, rules)
+		} else {
+			_, err = s.enforcer.AddNamedPoliciesEx(ptype, rules)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupPoliciesByType groups rule values by policy type
+func groupPoliciesByType(rules []entity.PolicyRule) map[string][][]string {
+	grouped := make(map[string][][]string)
+	for _, rule := range rules {
+		grouped[rule.PType] = append(grouped[rule.PType], rule.Values)
+	}
+	return grouped
+}
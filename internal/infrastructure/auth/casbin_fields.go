@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/util"
+	"github.com/casbin/govaluate"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// policyFieldDefaults holds the value given to policy fields that are omitted from a rule.
+// The database adapter drops trailing empty fields, so every default must be non-empty.
+var policyFieldDefaults = map[string]string{
+	"cond": ConditionAlways,
+	"eft":  EffectAllow,
+}
+
+// normalizeRule completes a rule with the defaults of the fields it omits,
+// so that it matches the size the model expects
+func (s *CasbinService) normalizeRule(ptype string, values []string) []string {
+	assertion, ok := s.enforcer.GetModel()[ptype[:1]][ptype]
+	if !ok || len(values) >= len(assertion.Tokens) {
+		return values
+	}
+
+	normalized := make([]string, len(assertion.Tokens))
+	copy(normalized, values)
+	for i := len(values); i < len(assertion.Tokens); i++ {
+		field := strings.TrimPrefix(assertion.Tokens[i], ptype+"_")
+		normalized[i] = policyFieldDefaults[field]
+	}
+	return normalized
+}
+
+// NormalizePolicies completes rules written without optional fields, such as the rules
+// of a policy file, so they can be compared with the loaded policy
+func (s *CasbinService) NormalizePolicies(rules []entity.PolicyRule) []entity.PolicyRule {
+	normalized := make([]entity.PolicyRule, len(rules))
+	for i, rule := range rules {
+		normalized[i] = entity.PolicyRule{PType: rule.PType, Values: s.normalizeRule(rule.PType, rule.Values)}
+	}
+	return normalized
+}
+
+// CompactPolicies drops trailing fields holding their default value, the inverse of NormalizePolicies
+func (s *CasbinService) CompactPolicies(rules []entity.PolicyRule) []entity.PolicyRule {
+	m := s.enforcer.GetModel()
+
+	compacted := make([]entity.PolicyRule, len(rules))
+	for i, rule := range rules {
+		values := rule.Values
+		if assertion, ok := m[rule.PType[:1]][rule.PType]; ok {
+			for len(values) > 1 && len(values) <= len(assertion.Tokens) {
+				field := strings.TrimPrefix(assertion.Tokens[len(values)-1], rule.PType+"_")
+				if def, ok := policyFieldDefaults[field]; !ok || values[len(values)-1] != def {
+					break
+				}
+				values = values[:len(values)-1]
+			}
+		}
+		compacted[i] = entity.PolicyRule{PType: rule.PType, Values: values}
+	}
+	return compacted
+}
+
+// MigratePolicies rewrites rules stored before fields were added to the model, giving the new
+// fields their default value, and gives API clients created before their rules were scoped the
+// denies they lack, see APIClientPolicies. It is run by --migrate rather than on startup, so that
+// stored rules are only rewritten on purpose; rules already migrated are left alone.
+func (s *CasbinService) MigratePolicies(ctx context.Context) error {
+	_, err := s.UpdatePolicies(ctx, entity.PolicyActionMigrate, func(rules []entity.PolicyRule) (PolicyDiff, error) {
+		var diff PolicyDiff
+		for _, rule := range rules {
+			normalized := s.normalizeRule(rule.PType, rule.Values)
+			if len(normalized) == len(rule.Values) {
+				continue
+			}
+			diff.Removed = append(diff.Removed, rule)
+			diff.Added = append(diff.Added, entity.PolicyRule{PType: rule.PType, Values: normalized})
+		}
+		diff.Added = append(diff.Added, MissingAPIClientDenies(s.NormalizePolicies(rules))...)
+		return diff, nil
+	})
+	return err
+}
+
+// ValidatePolicies checks the conditions and effects of policy rules, so that a malformed
+// rule is rejected before it can make every authorization check fail
+func (s *CasbinService) ValidatePolicies(rules []entity.PolicyRule) error {
+	m := s.enforcer.GetModel()
+	functions := model.LoadFunctionMap()
+
+	for _, rule := range rules {
+		assertion, ok := m[rule.Section()][rule.PType]
+		if !ok {
+			return fmt.Errorf("%s: unknown policy type", rule)
+		}
+		if len(rule.Values) != len(assertion.Tokens) {
+			return fmt.Errorf("%s: expected %d fields, got %d", rule, len(assertion.Tokens), len(rule.Values))
+		}
+
+		for i, token := range assertion.Tokens {
+			value := rule.Values[i]
+			switch strings.TrimPrefix(token, rule.PType+"_") {
+			case "cond":
+				if _, err := govaluate.NewEvaluableExpressionWithFunctions(util.EscapeAssertion(value), functions.GetFunctions()); err != nil {
+					return fmt.Errorf("%s: invalid condition: %w", rule, err)
+				}
+			case "eft":
+				if _, _, err := ParseEffect(value); err != nil {
+					return fmt.Errorf("%s: %w", rule, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+func TestAPIClientPolicies(t *testing.T) {
+	var rules []string
+	for _, rule := range APIClientPolicies("billing") {
+		rules = append(rules, rule.String())
+	}
+	service := newCasbinServiceWithRules(t, rules, 0)
+
+	tests := []struct {
+		obj  string
+		want bool
+	}{
+		{obj: "/api/invoices", want: true},
+		{obj: "/api/clients", want: false},
+		{obj: "/api/clients/2", want: false},
+		{obj: "/api/clients/deleted", want: false},
+	}
+
+	for _, tt := range tests {
+		got, err := service.Enforce("billing", DomainAPI, tt.obj, "GET", RequestAttributes{})
+		if err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Enforce(billing, GET %s) = %v, want %v", tt.obj, got, tt.want)
+		}
+	}
+}
+
+func TestMissingAPIClientDenies(t *testing.T) {
+	policies := APIClientPolicies("billing")
+
+	// Clients created before the denies existed only have the allow
+	legacy := []entity.PolicyRule{policies[0], {PType: "g", Values: []string{"billing", "authz-checker", DomainAPI}}}
+	if missing := MissingAPIClientDenies(legacy); len(missing) != 2 || missing[0].String() != policies[1].String() || missing[1].String() != policies[2].String() {
+		t.Errorf("MissingAPIClientDenies(legacy) = %v, want %v", missing, policies[1:])
+	}
+	if missing := MissingAPIClientDenies(policies); len(missing) != 0 {
+		t.Errorf("MissingAPIClientDenies(current) = %v, want none", missing)
+	}
+}
+
+func TestCanAssignRoleWhileImpersonating(t *testing.T) {
+	service := newCasbinServiceWithRules(t, []string{
+		"p, admin, default, roles/support, assign",
+		"g, alice, admin, default",
+	}, 0)
+
+	ctx := WithActor(context.Background(), UserActor("alice"))
+	if allowed, err := service.CanAssignRole(ctx, "support", DomainDefault); err != nil || !allowed {
+		t.Errorf("CanAssignRole as alice = %v, %v, want true", allowed, err)
+	}
+	if allowed, err := service.CanAssignRole(WithImpersonated(ctx, "bob"), "support", DomainDefault); err != nil || allowed {
+		t.Errorf("CanAssignRole as alice impersonating bob = %v, %v, want false", allowed, err)
+	}
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// decisionKey identifies an authorization request
+type decisionKey struct {
+	sub, dom, obj, act string
+	attrs              RequestAttributes
+}
+
+// requestScope identifies the rules that may match an authorization request, whatever its subject
+type requestScope struct {
+	dom, obj, act string
+}
+
+// decisionEntry is a cached authorization decision
+type decisionEntry struct {
+	key       decisionKey
+	allowed   bool
+	expiresAt time.Time
+}
+
+// DecisionCacheStats reports the activity of the decision cache
+type DecisionCacheStats struct {
+	Enabled       bool
+	TTL           time.Duration
+	Size          int
+	Capacity      int
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+}
+
+// HitRatio returns the share of lookups answered from the cache
+func (s DecisionCacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// decisionCache is an LRU cache of authorization decisions with a time to live.
+// Every policy or grouping change invalidates the whole cache, since a single
+// rule can change the outcome of any request through role inheritance.
+type decisionCache struct {
+	ttl      time.Duration
+	capacity int
+
+	mutex      sync.Mutex
+	entries    map[decisionKey]*list.Element
+	order      *list.List // most recently used first
+	generation uint64     // incremented on every invalidation
+	// masks holds the attributes the conditions of the rules of a scope refer to, which are
+	// the only ones keying its decisions, so that e.g. the client IP does not when unused
+	masks map[requestScope]attributeMask
+
+	hits, misses, evictions, invalidations uint64
+}
+
+// newDecisionCache creates a new decisionCache, or returns nil when ttl or capacity is not positive
+func newDecisionCache(ttl time.Duration, capacity int) *decisionCache {
+	if ttl <= 0 || capacity <= 0 {
+		return nil
+	}
+
+	return &decisionCache{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[decisionKey]*list.Element),
+		order:    list.New(),
+		masks:    make(map[requestScope]attributeMask),
+	}
+}
+
+// getMask returns the attribute mask of a scope, along with the current generation
+// to pass to putMask and put once the mask is computed on a miss
+func (c *decisionCache) getMask(scope requestScope) (mask attributeMask, found bool, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	mask, found = c.masks[scope]
+	return mask, found, c.generation
+}
+
+// putMask caches the attribute mask of a scope computed at the given generation. Masks are
+// dropped with the decisions, and all at once when there are more scopes than decisions.
+func (c *decisionCache) putMask(scope requestScope, mask attributeMask, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if len(c.masks) >= c.capacity {
+		c.masks = make(map[requestScope]attributeMask)
+	}
+	c.masks[scope] = mask
+}
+
+// get returns the cached decision for a request, along with the current generation
+// to pass to put once the decision is computed on a miss
+func (c *decisionCache) get(key decisionKey, now time.Time) (allowed, found bool, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*decisionEntry)
+		if now.Before(entry.expiresAt) {
+			c.order.MoveToFront(element)
+			c.hits++
+			return entry.allowed, true, c.generation
+		}
+		c.remove(element)
+	}
+
+	c.misses++
+	return false, false, c.generation
+}
+
+// put caches a decision computed at the given generation. Decisions computed before
+// an invalidation are dropped, as they may be based on the previous policy.
+func (c *decisionCache) put(key decisionKey, allowed bool, generation uint64, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*decisionEntry)
+		entry.allowed = allowed
+		entry.expiresAt = now.Add(c.ttl)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&decisionEntry{key: key, allowed: allowed, expiresAt: now.Add(c.ttl)})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// invalidate drops every cached decision
+func (c *decisionCache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[decisionKey]*list.Element)
+	c.masks = make(map[requestScope]attributeMask)
+	c.order.Init()
+	c.generation++
+	c.invalidations++
+}
+
+// remove drops a cached decision
+func (c *decisionCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*decisionEntry).key)
+}
+
+// stats returns the cache statistics
+func (c *decisionCache) stats() DecisionCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return DecisionCacheStats{
+		Enabled:       true,
+		TTL:           c.ttl,
+		Size:          c.order.Len(),
+		Capacity:      c.capacity,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+	}
+}
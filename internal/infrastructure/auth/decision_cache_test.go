@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+)
+
+// Paths of the bootstrap model and policy, from the directory of the package
+const (
+	testModelFile  = "../../../casbin/model.conf"
+	testPolicyFile = "../../../casbin/policy.csv"
+)
+
+// newTestCasbinService creates an in-memory Casbin service holding the bootstrap policy, with a
+// decision cache unless ttl is 0, and users bench-user-<i> assigned the user role, every tenth admin
+func newTestCasbinService(tb testing.TB, ttl time.Duration, users int) *CasbinService {
+	tb.Helper()
+
+	cfg := &config.Config{Casbin: config.CasbinConfig{ModelPath: testModelFile, CacheTTL: ttl, CacheSize: 10000}}
+	service, err := NewCasbinServiceFromPolicyFile(cfg, testPolicyFile)
+	if err != nil {
+		tb.Fatalf("failed to create Casbin service: %v", err)
+	}
+
+	for i := 0; i < users; i++ {
+		role := "user"
+		if i%10 == 0 {
+			role = "admin"
+		}
+		if _, err := service.AddRoleForUser(context.Background(), benchUserName(i), role, DomainDefault); err != nil {
+			tb.Fatalf("failed to assign %s to %s: %v", role, benchUserName(i), err)
+		}
+	}
+
+	return service
+}
+
+// benchUserName returns the name of the i-th test user
+func benchUserName(i int) string {
+	return fmt.Sprintf("bench-user-%d", i)
+}
+
+func TestDecisionCache(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	key := func(obj string) decisionKey {
+		return decisionKey{sub: "alice", dom: DomainDefault, obj: obj, act: "GET"}
+	}
+
+	tests := []struct {
+		name string
+		// run fills the cache and looks up the key of /v1/users/1 at the returned time
+		run       func(c *decisionCache) time.Time
+		wantFound bool
+		wantStats DecisionCacheStats
+	}{
+		{
+			name:      "miss on an empty cache",
+			run:       func(c *decisionCache) time.Time { return now },
+			wantFound: false,
+			wantStats: DecisionCacheStats{Misses: 1},
+		},
+		{
+			name: "hit within the TTL",
+			run: func(c *decisionCache) time.Time {
+				_, _, generation := c.get(key("/v1/users/1"), now)
+				c.put(key("/v1/users/1"), true, generation, now)
+				return now.Add(time.Second)
+			},
+			wantFound: true,
+			wantStats: DecisionCacheStats{Size: 1, Hits: 1, Misses: 1},
+		},
+		{
+			name: "miss once expired",
+			run: func(c *decisionCache) time.Time {
+				c.put(key("/v1/users/1"), true, 0, now)
+				return now.Add(time.Minute)
+			},
+			wantFound: false,
+			wantStats: DecisionCacheStats{Misses: 1},
+		},
+		{
+			name: "least recently used evicted first",
+			run: func(c *decisionCache) time.Time {
+				c.put(key("/v1/users/1"), true, 0, now)
+				c.put(key("/v1/users/2"), true, 0, now)
+				c.get(key("/v1/users/1"), now)
+				c.put(key("/v1/users/3"), true, 0, now)
+				return now
+			},
+			wantFound: true,
+			wantStats: DecisionCacheStats{Size: 2, Hits: 2, Evictions: 1},
+		},
+		{
+			name: "miss after an invalidation",
+			run: func(c *decisionCache) time.Time {
+				c.put(key("/v1/users/1"), true, 0, now)
+				c.invalidate()
+				return now
+			},
+			wantFound: false,
+			wantStats: DecisionCacheStats{Misses: 1, Invalidations: 1},
+		},
+		{
+			name: "decision computed before an invalidation dropped",
+			run: func(c *decisionCache) time.Time {
+				_, _, generation := c.get(key("/v1/users/1"), now)
+				c.invalidate()
+				c.put(key("/v1/users/1"), true, generation, now)
+				return now
+			},
+			wantFound: false,
+			wantStats: DecisionCacheStats{Misses: 2, Invalidations: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newDecisionCache(30*time.Second, 2)
+			at := tt.run(c)
+
+			allowed, found, _ := c.get(key("/v1/users/1"), at)
+			if found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if found && !allowed {
+				t.Errorf("allowed = false, want the cached true")
+			}
+
+			want := tt.wantStats
+			want.Enabled, want.TTL, want.Capacity = true, 30*time.Second, 2
+			if got := c.stats(); got != want {
+				t.Errorf("stats = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewDecisionCacheDisabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		capacity int
+	}{
+		{name: "no TTL", ttl: 0, capacity: 10},
+		{name: "no capacity", ttl: time.Second, capacity: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c := newDecisionCache(tt.ttl, tt.capacity); c != nil {
+				t.Errorf("newDecisionCache(%v, %d) = %+v, want nil", tt.ttl, tt.capacity, c)
+			}
+		})
+	}
+}
+
+func TestCasbinServiceEnforceCached(t *testing.T) {
+	service := newTestCasbinService(t, time.Minute, 2)
+	ctx := context.Background()
+	user := benchUserName(1)
+
+	enforce := func() bool {
+		t.Helper()
+		allowed, err := service.Enforce(user, DomainDefault, "/v1/reports", "GET", RequestAttributes{})
+		if err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		return allowed
+	}
+
+	if enforce() {
+		t.Fatal("allowed before any rule grants it")
+	}
+	if enforce() {
+		t.Fatal("allowed from the cache before any rule grants it")
+	}
+	if stats := service.DecisionCacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("hits = %d, misses = %d, want 1 and 1", stats.Hits, stats.Misses)
+	}
+
+	// A policy change must not leave the cached deny in place
+	if _, err := service.AddPolicy(ctx, "user", DomainDefault, "/v1/reports", "GET"); err != nil {
+		t.Fatalf("AddPolicy failed: %v", err)
+	}
+	if !enforce() {
+		t.Error("denied from the cache once a rule grants it")
+	}
+
+	// As must a grouping change
+	if _, err := service.DeleteRoleForUser(ctx, user, "user", DomainDefault); err != nil {
+		t.Fatalf("DeleteRoleForUser failed: %v", err)
+	}
+	if enforce() {
+		t.Error("allowed from the cache once the role granting it is removed")
+	}
+
+	if stats := service.DecisionCacheStats(); stats.Invalidations < 2 {
+		t.Errorf("invalidations = %d, want at least 2", stats.Invalidations)
+	}
+}
+
+func TestCasbinServiceEnforceCacheKey(t *testing.T) {
+	service := newTestCasbinService(t, time.Minute, 2)
+	user := benchUserName(1)
+	at := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	requests := []struct {
+		obj     string
+		attrs   RequestAttributes
+		wantHit bool
+	}{
+		{obj: "/v1/users/2", attrs: NewRequestAttributes("2", "2", "user", "10.0.0.1", at)},
+		// Neither the IP nor the time is referred to by the rules of /v1/users/:id
+		{obj: "/v1/users/2", attrs: NewRequestAttributes("2", "2", "user", "10.0.0.2", at.Add(time.Hour)), wantHit: true},
+		{obj: "/v1/users/2", attrs: NewRequestAttributes("2", "3", "user", "10.0.0.1", at)},
+	}
+
+	for i, request := range requests {
+		before := service.DecisionCacheStats().Hits
+		if _, err := service.Enforce(user, DomainDefault, request.obj, "GET", request.attrs); err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+		if hit := service.DecisionCacheStats().Hits > before; hit != request.wantHit {
+			t.Errorf("request %d: hit = %v, want %v", i, hit, request.wantHit)
+		}
+	}
+}
+
+// benchRequest is an authorization request replayed by the benchmarks
+type benchRequest struct {
+	sub, obj, act string
+	attrs         RequestAttributes
+}
+
+// buildBenchRequests builds a mix of requests resembling the API traffic: users reading and
+// updating their own and other accounts, and listing users
+func buildBenchRequests(users int) []benchRequest {
+	at := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	var requests []benchRequest
+	for i := 0; i < users; i++ {
+		subjectID := strconv.Itoa(i + 1)
+		otherID := strconv.Itoa((i+1)%users + 1)
+		own := "/v1/users/" + subjectID
+		other := "/v1/users/" + otherID
+
+		requests = append(requests,
+			benchRequest{benchUserName(i), own, "GET", NewRequestAttributes(subjectID, subjectID, "user", "10.0.0.1", at)},
+			benchRequest{benchUserName(i), own, "PUT", NewRequestAttributes(subjectID, subjectID, "user", "10.0.0.1", at)},
+			benchRequest{benchUserName(i), other, "GET", NewRequestAttributes(subjectID, otherID, "user", "10.0.0.1", at)},
+			benchRequest{benchUserName(i), "/v1/users", "GET", NewRequestAttributes(subjectID, "", "", "10.0.0.1", at)},
+		)
+	}
+	return requests
+}
+
+// benchmarkEnforce replays the requests of 1000 users concurrently, with the cache enabled unless
+// ttl is 0, changing the policy every invalidateEvery unless it is 0
+func benchmarkEnforce(b *testing.B, ttl, invalidateEvery time.Duration) {
+	service := newTestCasbinService(b, ttl, 1000)
+	requests := buildBenchRequests(1000)
+
+	if invalidateEvery > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go invalidatePeriodically(service, invalidateEvery, done)
+	}
+
+	for _, parallelism := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("goroutines-per-cpu-%d", parallelism), func(b *testing.B) {
+			var next atomic.Uint64
+			b.SetParallelism(parallelism)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					request := requests[next.Add(1)%uint64(len(requests))]
+					if _, err := service.Enforce(request.sub, DomainDefault, request.obj, request.act, request.attrs); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			if stats := service.DecisionCacheStats(); stats.Enabled {
+				b.ReportMetric(stats.HitRatio()*100, "%hits")
+			}
+		})
+	}
+}
+
+// invalidatePeriodically adds and removes a policy rule at an interval, invalidating the
+// decision cache, until done is closed
+func invalidatePeriodically(service *CasbinService, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := context.Background()
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = service.AddPolicy(ctx, "bench", DomainDefault, "/v1/bench", "GET")
+			_, _ = service.RemovePolicy(ctx, "bench", DomainDefault, "/v1/bench", "GET")
+		case <-done:
+			return
+		}
+	}
+}
+
+func BenchmarkEnforceUncached(b *testing.B) {
+	benchmarkEnforce(b, 0, 0)
+}
+
+func BenchmarkEnforceCached(b *testing.B) {
+	benchmarkEnforce(b, time.Minute, 0)
+}
+
+func BenchmarkEnforceCachedInvalidated(b *testing.B) {
+	benchmarkEnforce(b, time.Minute, 10*time.Millisecond)
+}
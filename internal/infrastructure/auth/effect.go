@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/effector"
+)
+
+// Policy rule effects
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// ParseEffect parses the effect field of a policy rule: "allow" or "deny",
+// optionally followed by a priority such as "deny:10". The priority defaults to 0.
+func ParseEffect(value string) (string, int, error) {
+	effect, priorityValue, hasPriority := strings.Cut(value, ":")
+	if effect != EffectAllow && effect != EffectDeny {
+		return "", 0, fmt.Errorf("invalid effect %q, expected %s or %s", value, EffectAllow, EffectDeny)
+	}
+	if !hasPriority {
+		return effect, 0, nil
+	}
+
+	priority, err := strconv.Atoi(priorityValue)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid priority in effect %q", value)
+	}
+	return effect, priority, nil
+}
+
+// FormatEffect formats the effect field of a policy rule, leaving out the default priority
+func FormatEffect(effect string, priority int) string {
+	if priority == 0 {
+		return effect
+	}
+	return effect + ":" + strconv.Itoa(priority)
+}
+
+// priorityEffector combines the rules matching a request with deny-overrides semantics:
+// only the matching rules with the highest priority are considered, and among them
+// a deny overrides any allow. A request matching no rule is denied.
+//
+// The effect field holds a priority that Casbin cannot interpret, so the decision
+// is made from the rules themselves once every rule has been matched.
+type priorityEffector struct {
+	enforcer *casbin.SyncedEnforcer
+}
+
+// MergeEffects implements effector.Effector
+func (e *priorityEffector) MergeEffects(expr string, effects []effector.Effect, matches []float64, policyIndex, policyLength int) (effector.Effect, int, error) {
+	if policyIndex < policyLength-1 {
+		return effector.Indeterminate, -1, nil
+	}
+
+	// Called while Casbin holds the enforcer lock
+	assertion, ok := e.enforcer.GetModel()["p"]["p"]
+	if !ok || len(assertion.Policy) != policyLength {
+		return effector.Deny, -1, nil
+	}
+
+	eftIndex := -1
+	for i, token := range assertion.Tokens {
+		if token == "p_eft" {
+			eftIndex = i
+		}
+	}
+
+	decision, explainIndex, best := effector.Deny, -1, math.MinInt
+	for i, rule := range assertion.Policy {
+		if matches[i] == 0 {
+			continue
+		}
+
+		effect, priority := EffectAllow, 0
+		if eftIndex >= 0 {
+			var err error
+			if effect, priority, err = ParseEffect(rule[eftIndex]); err != nil {
+				return effector.Deny, i, err
+			}
+		}
+
+		switch {
+		case priority > best:
+			best = priority
+		case priority < best || decision == effector.Deny:
+			continue
+		}
+
+		explainIndex = i
+		decision = effector.Allow
+		if effect == EffectDeny {
+			decision = effector.Deny
+		}
+	}
+
+	return decision, explainIndex, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import "testing"
+
+func TestParseEffect(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantEffect   string
+		wantPriority int
+		wantErr      bool
+	}{
+		{value: "allow", wantEffect: EffectAllow},
+		{value: "deny:10", wantEffect: EffectDeny, wantPriority: 10},
+		{value: "allow:-5", wantEffect: EffectAllow, wantPriority: -5},
+		{value: "block", wantErr: true},
+		{value: "deny:high", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		effect, priority, err := ParseEffect(tt.value)
+		if (err != nil) != tt.wantErr || effect != tt.wantEffect || priority != tt.wantPriority {
+			t.Errorf("ParseEffect(%q) = %q, %d, %v, want %q, %d", tt.value, effect, priority, err, tt.wantEffect, tt.wantPriority)
+		}
+	}
+}
+
+func TestPriorityEffector(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		hour  int
+		want  bool
+	}{
+		{"deny overrides allow", []string{"p, user, default, /v1/users, GET", "p, user, default, /v1/users, GET, true, deny"}, 12, false},
+		{"deny of an inherited role overrides allow", []string{"p, user, default, /v1/*, GET", "p, member, default, /v1/users, GET, true, deny"}, 12, false},
+		{"higher priority allow overrides deny", []string{"p, user, default, /v1/users, GET, true, allow:10", "p, member, default, /v1/users, GET, true, deny"}, 12, true},
+		{"lower priority deny ignored", []string{"p, user, default, /v1/users, GET, true, deny:-1", "p, user, default, /v1/users, GET"}, 12, true},
+		{"deny whose condition fails ignored", []string{"p, user, default, /v1/users, GET", "p, user, default, /v1/users, GET, r.env.Hour < 9, deny"}, 12, true},
+		{"deny whose condition holds applies", []string{"p, user, default, /v1/users, GET", "p, user, default, /v1/users, GET, r.env.Hour < 9, deny"}, 8, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := append([]string{"g, alice, member, default", "g, member, user, default"}, tt.rules...)
+			service := newCasbinServiceWithRules(t, rules, 0)
+
+			got, err := service.Enforce("alice", DomainDefault, "/v1/users", "GET", RequestAttributes{Hour: tt.hour})
+			if err != nil {
+				t.Fatalf("Enforce failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Enforce = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
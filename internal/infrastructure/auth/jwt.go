@@ -1,23 +1,31 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/hinha/echo-casbin-ddd-app/internal/config"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
 )
 
-// JWTService handles JWT token generation and validation
+// ErrTokenRevoked is returned when a token was issued before the tokens of its user were revoked
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// JWTService handles JWT token generation and validation.
+// Tokens are stateless, except that the tokens of a user can be revoked all at once.
 type JWTService struct {
-	config *config.Config
+	config         *config.Config
+	userRepository repository.UserRepository
 }
 
-// NewJWTService creates a new JWTService
-func NewJWTService(config *config.Config) *JWTService {
+// NewJWTService creates a new JWTService, checking token revocations against the user repository
+func NewJWTService(config *config.Config, userRepository repository.UserRepository) *JWTService {
 	return &JWTService{
-		config: config,
+		config:         config,
+		userRepository: userRepository,
 	}
 }
 
@@ -27,20 +35,53 @@ type Claims struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Role     string `json:"role"`
+	// Act identifies the user actually making the requests when the token impersonates another one
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ActorClaim represents the acting party of a token issued for another user (RFC 8693 "act" claim)
+type ActorClaim struct {
+	Subject string `json:"sub"`
+	UserID  uint   `json:"user_id"`
+}
+
+// IsImpersonated reports whether the token was issued to impersonate its user
+func (c *Claims) IsImpersonated() bool {
+	return c.Act != nil
+}
+
 // GenerateToken generates a JWT token for a user
 func (s *JWTService) GenerateToken(user *entity.User) (string, error) {
+	token, _, err := s.generateToken(user, nil, s.config.JWT.Expiration)
+	return token, err
+}
+
+// GenerateImpersonationToken generates a short-lived JWT token for a user, carrying the actor
+// impersonating them in the act claim. It returns the token and its expiry.
+func (s *JWTService) GenerateImpersonationToken(user, actor *entity.User) (string, time.Time, error) {
+	act := &ActorClaim{
+		Subject: actor.Username,
+		UserID:  actor.ID,
+	}
+	return s.generateToken(user, act, s.config.JWT.ImpersonationExpiration)
+}
+
+// generateToken generates a JWT token for a user valid for the given duration
+func (s *JWTService) generateToken(user *entity.User, act *ActorClaim, expiration time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(expiration)
+
 	claims := &Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Email:    user.Email,
 		Role:     user.Role,
+		Act:      act,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.JWT.Expiration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "echo-casbin-ddd-app",
 			Subject:   user.Username,
 		},
@@ -49,14 +90,15 @@ func (s *JWTService) GenerateToken(user *entity.User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(s.config.JWT.Secret))
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken validates a JWT token and returns the claims. Tokens issued before the tokens
+// of their user, or of the user impersonating them, were revoked are rejected.
+func (s *JWTService) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -68,16 +110,43 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	return nil, errors.New("invalid token")
+	if err := s.checkRevoked(ctx, claims.UserID, claims.IssuedAt); err != nil {
+		return nil, err
+	}
+	if claims.Act != nil {
+		if err := s.checkRevoked(ctx, claims.Act.UserID, claims.IssuedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// checkRevoked rejects a token issued to a user at or before the revocation of their tokens.
+// Issue times are in seconds, so a token issued in the second of the revocation is rejected too.
+// The tokens of a purged user are all rejected, as their revocation time went with them.
+func (s *JWTService) checkRevoked(ctx context.Context, userID uint, issuedAt *jwt.NumericDate) error {
+	revokedAt, err := s.userRepository.GetTokensRevokedAt(ctx, userID)
+	if errors.Is(err, entity.ErrUserNotFound) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if revokedAt != nil && (issuedAt == nil || !issuedAt.After(revokedAt.Truncate(time.Second))) {
+		return ErrTokenRevoked
+	}
+	return nil
 }
 
 // GetUserIDFromToken extracts the user ID from a token
-func (s *JWTService) GetUserIDFromToken(tokenString string) (uint, error) {
-	claims, err := s.ValidateToken(tokenString)
+func (s *JWTService) GetUserIDFromToken(ctx context.Context, tokenString string) (uint, error) {
+	claims, err := s.ValidateToken(ctx, tokenString)
 	if err != nil {
 		return 0, err
 	}
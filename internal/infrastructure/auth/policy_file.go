@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy file formats
+const (
+	PolicyFormatCSV  = "csv"
+	PolicyFormatYAML = "yaml"
+)
+
+// PolicyFormatFromPath infers the policy file format from a file extension
+func PolicyFormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return PolicyFormatCSV, nil
+	case ".yaml", ".yml":
+		return PolicyFormatYAML, nil
+	default:
+		return "", fmt.Errorf("cannot infer policy format from %q", path)
+	}
+}
+
+// ParsePolicies parses policy rules in the given format
+func ParsePolicies(data []byte, format string) ([]entity.PolicyRule, error) {
+	switch format {
+	case PolicyFormatCSV:
+		return parsePolicyCSV(data)
+	case PolicyFormatYAML:
+		return parsePolicyYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported policy format %q", format)
+	}
+}
+
+// FormatPolicies serializes policy rules in the given format
+func FormatPolicies(rules []entity.PolicyRule, format string) ([]byte, error) {
+	switch format {
+	case PolicyFormatCSV:
+		return formatPolicyCSV(rules), nil
+	case PolicyFormatYAML:
+		return formatPolicyYAML(rules)
+	default:
+		return nil, fmt.Errorf("unsupported policy format %q", format)
+	}
+}
+
+// parsePolicyCSV parses a Casbin file adapter compatible CSV policy file
+func parsePolicyCSV(data []byte) ([]entity.PolicyRule, error) {
+	var rules []entity.PolicyRule
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		reader := csv.NewReader(strings.NewReader(line))
+		reader.TrimLeadingSpace = true
+		fields, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		for j := range fields {
+			fields[j] = strings.TrimSpace(fields[j])
+		}
+
+		rule, err := entity.NewPolicyRule(fields[0], fields[1:]...)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// formatPolicyCSV writes rules in Casbin file adapter format, policies before groupings
+func formatPolicyCSV(rules []entity.PolicyRule) []byte {
+	var buf bytes.Buffer
+	for _, rule := range SortPolicies(rules) {
+		buf.WriteString(rule.String())
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// parsePolicyYAML parses a YAML policy file mapping each policy type to its rules, e.g.
+//
+//	p:
+//	  - [admin, default, /v1/users/*, GET]
+//	g:
+//	  - [alice, admin, default]
+func parsePolicyYAML(data []byte) ([]entity.PolicyRule, error) {
+	var doc map[string][][]string
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var rules []entity.PolicyRule
+	for ptype, values := range doc {
+		for _, v := range values {
+			rule, err := entity.NewPolicyRule(ptype, v...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ptype, err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return SortPolicies(rules), nil
+}
+
+// formatPolicyYAML writes rules as a YAML document keyed by policy type, one rule per line
+func formatPolicyYAML(rules []entity.PolicyRule) ([]byte, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+
+	for _, rule := range SortPolicies(rules) {
+		section, ok := sections[rule.PType]
+		if !ok {
+			section = &yaml.Node{Kind: yaml.SequenceNode}
+			sections[rule.PType] = section
+			doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: rule.PType}, section)
+		}
+
+		values := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, value := range rule.Values {
+			values.Content = append(values.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+		}
+		section.Content = append(section.Content, values)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SortPolicies returns a copy of the rules in a stable order: policies before groupings,
+// then by policy type and values
+func SortPolicies(rules []entity.PolicyRule) []entity.PolicyRule {
+	sorted := make([]entity.PolicyRule, len(rules))
+	copy(sorted, rules)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Section() != sorted[j].Section() {
+			return sorted[i].Section() == "p"
+		}
+		return sorted[i].String() < sorted[j].String()
+	})
+
+	return sorted
+}
+
+// PolicyDiff represents the changes needed to go from one policy set to another
+type PolicyDiff struct {
+	Added   []entity.PolicyRule
+	Removed []entity.PolicyRule
+}
+
+// IsEmpty reports whether the diff contains no changes
+func (d PolicyDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// DiffPolicies computes the rules to add to and remove from current to obtain desired.
+// When removeMissing is false, rules absent from desired are kept.
+func DiffPolicies(current, desired []entity.PolicyRule, removeMissing bool) PolicyDiff {
+	currentSet := make(map[string]bool, len(current))
+	for _, rule := range current {
+		currentSet[rule.String()] = true
+	}
+
+	desiredSet := make(map[string]bool, len(desired))
+	var diff PolicyDiff
+	for _, rule := range desired {
+		key := rule.String()
+		if desiredSet[key] {
+			continue
+		}
+		desiredSet[key] = true
+
+		if !currentSet[key] {
+			diff.Added = append(diff.Added, rule)
+		}
+	}
+
+	if removeMissing {
+		for _, rule := range current {
+			if !desiredSet[rule.String()] {
+				diff.Removed = append(diff.Removed, rule)
+			}
+		}
+	}
+
+	diff.Added = SortPolicies(diff.Added)
+	diff.Removed = SortPolicies(diff.Removed)
+	return diff
+}
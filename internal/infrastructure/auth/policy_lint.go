@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/casbin/casbin/v2/util"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// Policy lint checks
+const (
+	// LintDuplicate reports rules present more than once, once omitted fields are given their default
+	LintDuplicate = "duplicate"
+	// LintUnknownRole reports roles assigned by grouping rules that grant no permission, directly or inherited
+	LintUnknownRole = "unknown-role"
+	// LintEmptyDomain reports domains that policy rules refer to but that no grouping rule assigns anyone to
+	LintEmptyDomain = "empty-domain"
+	// LintUnreachable reports rules that can never decide a request, being overridden by another rule
+	LintUnreachable = "unreachable"
+)
+
+// LintChecks lists every policy lint check
+var LintChecks = []string{LintDuplicate, LintUnknownRole, LintEmptyDomain, LintUnreachable}
+
+// LintIssue is a problem found in a policy set
+type LintIssue struct {
+	Check   string
+	Rule    entity.PolicyRule
+	Message string
+}
+
+// String returns the issue as a single line
+func (i LintIssue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", i.Check, i.Rule, i.Message)
+}
+
+// lintPolicy is a p rule with its effect parsed
+type lintPolicy struct {
+	rule                     entity.PolicyRule
+	sub, dom, obj, act, cond string
+	effect                   string
+	priority                 int
+}
+
+// LintPolicies checks a policy set for mistakes that do not make it invalid but are likely
+// not what its author meant. Only the p and g policy types are checked.
+func (s *CasbinService) LintPolicies(rules []entity.PolicyRule) ([]LintIssue, error) {
+	rules = s.NormalizePolicies(rules)
+	if err := s.ValidatePolicies(rules); err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	var policies []lintPolicy
+	var groupings []entity.PolicyRule
+
+	// Rules are compared in full but reported as written, without default fields
+	compacted := s.CompactPolicies(rules)
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		key := rule.String()
+		if seen[key] {
+			issues = append(issues, LintIssue{Check: LintDuplicate, Rule: compacted[i], Message: "rule is defined more than once"})
+			continue
+		}
+		seen[key] = true
+
+		switch rule.PType {
+		case "p":
+			effect, priority, err := ParseEffect(rule.Values[5])
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, lintPolicy{
+				rule:     compacted[i],
+				sub:      rule.Values[0],
+				dom:      rule.Values[1],
+				obj:      rule.Values[2],
+				act:      rule.Values[3],
+				cond:     rule.Values[4],
+				effect:   effect,
+				priority: priority,
+			})
+		case "g":
+			groupings = append(groupings, rule)
+		}
+	}
+
+	issues = append(issues, lintUnknownRoles(policies, groupings)...)
+	issues = append(issues, lintEmptyDomains(policies, groupings)...)
+	issues = append(issues, lintUnreachable(policies, groupings)...)
+
+	return issues, nil
+}
+
+// lintUnknownRoles reports roles that are assigned but grant nothing, either directly or through
+// the roles they inherit, which usually means a misspelled role name
+func lintUnknownRoles(policies []lintPolicy, groupings []entity.PolicyRule) []LintIssue {
+	granting := make(map[[2]string]bool)
+	for _, policy := range policies {
+		granting[[2]string{policy.sub, policy.dom}] = true
+	}
+	parents := roleParents(groupings)
+
+	var grants func(role, dom string, visiting map[string]bool) bool
+	grants = func(role, dom string, visiting map[string]bool) bool {
+		if granting[[2]string{role, dom}] {
+			return true
+		}
+		if visiting[role] {
+			return false
+		}
+		visiting[role] = true
+		for _, parent := range parents[[2]string{role, dom}] {
+			if grants(parent, dom, visiting) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var issues []LintIssue
+	for _, rule := range groupings {
+		role, dom := rule.Values[1], rule.Values[2]
+		if !grants(role, dom, map[string]bool{}) {
+			message := fmt.Sprintf("role %q grants no permission in domain %q", role, dom)
+			issues = append(issues, LintIssue{Check: LintUnknownRole, Rule: rule, Message: message})
+		}
+	}
+	return issues
+}
+
+// lintEmptyDomains reports the rules of domains nobody is assigned a role in
+func lintEmptyDomains(policies []lintPolicy, groupings []entity.PolicyRule) []LintIssue {
+	members := make(map[string]bool)
+	for _, rule := range groupings {
+		members[rule.Values[2]] = true
+	}
+
+	var issues []LintIssue
+	for _, policy := range policies {
+		if !members[policy.dom] {
+			message := fmt.Sprintf("no subject is assigned a role in domain %q", policy.dom)
+			issues = append(issues, LintIssue{Check: LintEmptyDomain, Rule: policy.rule, Message: message})
+		}
+	}
+	return issues
+}
+
+// lintUnreachable reports rules overridden by an unconditional rule of the same subject, or of a role
+// the subject inherits, covering the same object and action: every request matching the rule also
+// matches the other one, which takes precedence through its higher priority, or by being a deny
+// at the same priority as an allow.
+func lintUnreachable(policies []lintPolicy, groupings []entity.PolicyRule) []LintIssue {
+	parents := roleParents(groupings)
+
+	var issues []LintIssue
+	for i, policy := range policies {
+		subjects := inheritedRoles(policy.sub, policy.dom, parents)
+
+		for j, other := range policies {
+			if i == j || other.dom != policy.dom || other.cond != ConditionAlways || !subjects[other.sub] {
+				continue
+			}
+			if other.act != policy.act && other.act != "*" {
+				continue
+			}
+			if !objectCovers(other.obj, policy.obj) {
+				continue
+			}
+			overrides := other.priority > policy.priority ||
+				(other.priority == policy.priority && other.effect == EffectDeny && policy.effect == EffectAllow)
+			if !overrides {
+				continue
+			}
+
+			message := fmt.Sprintf("always overridden by %s", other.rule)
+			issues = append(issues, LintIssue{Check: LintUnreachable, Rule: policy.rule, Message: message})
+			break
+		}
+	}
+	return issues
+}
+
+// roleParents maps each subject and domain to the roles it is directly assigned
+func roleParents(groupings []entity.PolicyRule) map[[2]string][]string {
+	parents := make(map[[2]string][]string)
+	for _, rule := range groupings {
+		key := [2]string{rule.Values[0], rule.Values[2]}
+		parents[key] = append(parents[key], rule.Values[1])
+	}
+	for key := range parents {
+		sort.Strings(parents[key])
+	}
+	return parents
+}
+
+// inheritedRoles returns a subject along with every role it inherits in a domain
+func inheritedRoles(sub, dom string, parents map[[2]string][]string) map[string]bool {
+	roles := map[string]bool{sub: true}
+	queue := []string{sub}
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		for _, parent := range parents[[2]string{role, dom}] {
+			if !roles[parent] {
+				roles[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return roles
+}
+
+// objectCovers reports whether every path matching the object pattern obj also matches pattern.
+// A wildcard in obj can only be covered by a pattern ending with a wildcard.
+func objectCovers(pattern, obj string) bool {
+	if strings.Contains(obj, "*") && !strings.HasSuffix(pattern, "*") {
+		return false
+	}
+	return util.KeyMatch2(obj, pattern)
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// Policy update methods broadcast between instances
+const (
+	PolicyUpdateAddPolicies          = "add_policies"
+	PolicyUpdateRemovePolicies       = "remove_policies"
+	PolicyUpdateRemoveFilteredPolicy = "remove_filtered_policy"
+	PolicyUpdateUpdatePolicies       = "update_policies"
+	PolicyUpdateReload               = "reload"
+)
+
+// PolicyUpdate represents a policy change broadcast to the other instances
+type PolicyUpdate struct {
+	InstanceID  string     `json:"instance_id"`
+	Method      string     `json:"method"`
+	Sec         string     `json:"sec,omitempty"`
+	PType       string     `json:"ptype,omitempty"`
+	Rules       [][]string `json:"rules,omitempty"`
+	OldRules    [][]string `json:"old_rules,omitempty"`
+	FieldIndex  int        `json:"field_index,omitempty"`
+	FieldValues []string   `json:"field_values,omitempty"`
+}
+
+// policyWatcher implements the Casbin watcher interfaces on top of a transport
+// that delivers serialized PolicyUpdate messages to every instance.
+// Updates published by this instance are ignored when they come back.
+type policyWatcher struct {
+	instanceID string
+	publish    func(payload string) error
+
+	mutex    sync.RWMutex
+	callback func(string)
+}
+
+// newPolicyWatcher creates a new policyWatcher with a random instance ID
+func newPolicyWatcher(publish func(payload string) error) (*policyWatcher, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &policyWatcher{
+		instanceID: hex.EncodeToString(id),
+		publish:    publish,
+	}, nil
+}
+
+// SetUpdateCallback sets the function called with the payload of updates from other instances
+func (w *policyWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update notifies the other instances to reload the full policy
+func (w *policyWatcher) Update() error {
+	return w.send(PolicyUpdate{Method: PolicyUpdateReload})
+}
+
+// UpdateForAddPolicy notifies the other instances that a rule was added
+func (w *policyWatcher) UpdateForAddPolicy(sec, ptype string, params ...string) error {
+	return w.send(PolicyUpdate{Method: PolicyUpdateAddPolicies, Sec: sec, PType: ptype, Rules: [][]string{params}})
+}
+
+// UpdateForRemovePolicy notifies the other instances that a rule was removed
+func (w *policyWatcher) UpdateForRemovePolicy(sec, ptype string, params ...string) error {
+	return w.send(PolicyUpdate{Method: PolicyUpdateRemovePolicies, Sec: sec, PType: ptype, Rules: [][]string{params}})
+}
+
+// UpdateForRemoveFilteredPolicy notifies the other instances that rules matching a filter were removed
+func (w *policyWatcher) UpdateForRemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	return w.send(PolicyUpdate{
+		Method:      PolicyUpdateRemoveFilteredPolicy,
+		Sec:         sec,
+		PType:       ptype,
+		FieldIndex:  fieldIndex,
+		FieldValues: fieldValues,
+	})
+}
+
+// UpdateForSavePolicy notifies the other instances to reload the full policy
+func (w *policyWatcher) UpdateForSavePolicy(model.Model) error {
+	return w.Update()
+}
+
+// UpdateForAddPolicies notifies the other instances that rules were added
+func (w *policyWatcher) UpdateForAddPolicies(sec string, ptype string, rules ...[]string) error {
+	return w.send(PolicyUpdate{Method: PolicyUpdateAddPolicies, Sec: sec, PType: ptype, Rules: rules})
+}
+
+// UpdateForRemovePolicies notifies the other instances that rules were removed
+func (w *policyWatcher) UpdateForRemovePolicies(sec string, ptype string, rules ...[]string) error {
+	return w.send(PolicyUpdate{Method: PolicyUpdateRemovePolicies, Sec: sec, PType: ptype, Rules: rules})
+}
+
+// UpdateForUpdatePolicy notifies the other instances that a rule was replaced
+func (w *policyWatcher) UpdateForUpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return w.UpdateForUpdatePolicies(sec, ptype, [][]string{oldRule}, [][]string{newRule})
+}
+
+// UpdateForUpdatePolicies notifies the other instances that rules were replaced
+func (w *policyWatcher) UpdateForUpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return w.send(PolicyUpdate{Method: PolicyUpdateUpdatePolicies, Sec: sec, PType: ptype, OldRules: oldRules, Rules: newRules})
+}
+
+// send serializes and publishes an update
+func (w *policyWatcher) send(update PolicyUpdate) error {
+	update.InstanceID = w.instanceID
+
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	return w.publish(string(payload))
+}
+
+// receive dispatches a payload delivered by the transport to the update callback
+func (w *policyWatcher) receive(payload string) {
+	var update PolicyUpdate
+	if err := json.Unmarshal([]byte(payload), &update); err == nil && update.InstanceID == w.instanceID {
+		return
+	}
+
+	w.mutex.RLock()
+	callback := w.callback
+	w.mutex.RUnlock()
+
+	if callback != nil {
+		callback(payload)
+	}
+}
+
+// LocalWatcherBus connects LocalWatchers living in the same process.
+// It stands in for Postgres LISTEN/NOTIFY in tests and single-binary setups.
+type LocalWatcherBus struct {
+	mutex    sync.RWMutex
+	watchers map[*LocalWatcher]bool
+}
+
+// NewLocalWatcherBus creates a new LocalWatcherBus
+func NewLocalWatcherBus() *LocalWatcherBus {
+	return &LocalWatcherBus{
+		watchers: make(map[*LocalWatcher]bool),
+	}
+}
+
+// broadcast delivers a payload to every watcher on the bus
+func (b *LocalWatcherBus) broadcast(payload string) error {
+	b.mutex.RLock()
+	watchers := make([]*LocalWatcher, 0, len(b.watchers))
+	for watcher := range b.watchers {
+		watchers = append(watchers, watcher)
+	}
+	b.mutex.RUnlock()
+
+	for _, watcher := range watchers {
+		watcher.receive(payload)
+	}
+	return nil
+}
+
+// LocalWatcher is an in-process watcher that delivers updates synchronously through a LocalWatcherBus
+type LocalWatcher struct {
+	*policyWatcher
+	bus *LocalWatcherBus
+}
+
+// NewLocalWatcher creates a new LocalWatcher attached to the bus
+func NewLocalWatcher(bus *LocalWatcherBus) (*LocalWatcher, error) {
+	if bus == nil {
+		return nil, errors.New("watcher bus cannot be nil")
+	}
+
+	base, err := newPolicyWatcher(bus.broadcast)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &LocalWatcher{policyWatcher: base, bus: bus}
+
+	bus.mutex.Lock()
+	bus.watchers[watcher] = true
+	bus.mutex.Unlock()
+
+	return watcher, nil
+}
+
+// Close detaches the watcher from the bus
+func (w *LocalWatcher) Close() {
+	w.bus.mutex.Lock()
+	delete(w.bus.watchers, w)
+	w.bus.mutex.Unlock()
+}
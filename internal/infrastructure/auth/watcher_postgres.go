@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// maxNotifyPayload is the largest payload Postgres accepts for NOTIFY
+const maxNotifyPayload = 8000
+
+// PostgresWatcher broadcasts policy updates between instances using Postgres LISTEN/NOTIFY
+type PostgresWatcher struct {
+	*policyWatcher
+	db      *gorm.DB
+	channel string
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewPostgresWatcher creates a new PostgresWatcher listening on the given channel
+func NewPostgresWatcher(db *gorm.DB, channel string) (*PostgresWatcher, error) {
+	if channel == "" {
+		return nil, errors.New("watcher channel cannot be empty")
+	}
+
+	watcher := &PostgresWatcher{
+		db:      db,
+		channel: channel,
+	}
+
+	base, err := newPolicyWatcher(watcher.notify)
+	if err != nil {
+		return nil, err
+	}
+	watcher.policyWatcher = base
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher.cancel = cancel
+
+	watcher.done.Add(1)
+	go watcher.run(ctx)
+
+	return watcher, nil
+}
+
+// Close stops listening for notifications
+func (w *PostgresWatcher) Close() {
+	w.cancel()
+	w.done.Wait()
+}
+
+// notify publishes a payload on the channel. Updates too large for NOTIFY
+// are replaced by a full reload request.
+func (w *PostgresWatcher) notify(payload string) error {
+	if len(payload) > maxNotifyPayload {
+		return w.Update()
+	}
+
+	return w.db.Exec("SELECT pg_notify(?, ?)", w.channel, payload).Error
+}
+
+// run keeps a dedicated connection listening on the channel, reconnecting on failure
+func (w *PostgresWatcher) run(ctx context.Context) {
+	defer w.done.Done()
+
+	backoff := time.Second
+	for {
+		err := w.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Policy watcher connection lost: %v", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+
+		// Notifications sent while disconnected are lost, so resynchronize completely
+		w.receive(`{"method":"` + PolicyUpdateReload + `"}`)
+	}
+}
+
+// listen holds a connection from the pool and waits for notifications until an error occurs
+func (w *PostgresWatcher) listen(ctx context.Context) error {
+	sqlDB, err := w.db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("policy watcher requires the pgx driver")
+		}
+		pgxConn := stdlibConn.Conn()
+
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+pgx.Identifier{w.channel}.Sanitize()); err != nil {
+			return err
+		}
+		defer func() {
+			// Use a fresh context since ctx may already be cancelled
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, _ = pgxConn.Exec(unlistenCtx, "UNLISTEN "+pgx.Identifier{w.channel}.Sanitize())
+		}()
+
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			w.receive(notification.Payload)
+		}
+	})
+}
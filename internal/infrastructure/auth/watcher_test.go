@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+)
+
+func TestLocalWatcher(t *testing.T) {
+	ctx := context.Background()
+	bus := NewLocalWatcherBus()
+	rules := []string{"p, user, default, /v1/users/:id, GET", "g, alice, user, default"}
+	first := newWatchedCasbinService(t, bus, rules)
+	second := newWatchedCasbinService(t, bus, rules)
+	closed := newWatchedCasbinService(t, bus, rules)
+	closed.Close()
+
+	// The decisions of the second instance are cached before the changes
+	assertEnforce(t, second, "alice", "/v1/reports", false)
+	assertEnforce(t, second, "bob", "/v1/users/1", false)
+
+	if _, err := first.AddPolicy(ctx, "user", DomainDefault, "/v1/reports", "GET"); err != nil {
+		t.Fatalf("AddPolicy failed: %v", err)
+	}
+	if _, err := first.AddRoleForUser(ctx, "bob", "user", DomainDefault); err != nil {
+		t.Fatalf("AddRoleForUser failed: %v", err)
+	}
+
+	assertEnforce(t, second, "alice", "/v1/reports", true)
+	assertEnforce(t, second, "bob", "/v1/users/1", true)
+	assertEnforce(t, closed, "alice", "/v1/reports", false)
+}
+
+func TestNewLocalWatcherWithoutBus(t *testing.T) {
+	if _, err := NewLocalWatcher(nil); err == nil {
+		t.Error("NewLocalWatcher(nil) succeeded, want an error")
+	}
+}
+
+// newWatchedCasbinService creates a Casbin service holding CSV rules, with a decision cache, whose
+// changes are propagated through a LocalWatcher on the bus
+func newWatchedCasbinService(t *testing.T, bus *LocalWatcherBus, rules []string) *CasbinService {
+	t.Helper()
+
+	service := newCasbinServiceWithRules(t, rules, time.Minute)
+	watcher, err := NewLocalWatcher(bus)
+	if err != nil {
+		t.Fatalf("NewLocalWatcher failed: %v", err)
+	}
+	if err := service.SetWatcher(watcher); err != nil {
+		t.Fatalf("SetWatcher failed: %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service
+}
+
+// newCasbinServiceWithRules creates an in-memory Casbin service holding CSV rules, with a decision
+// cache unless ttl is 0
+func newCasbinServiceWithRules(tb testing.TB, rules []string, ttl time.Duration) *CasbinService {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "policy.csv")
+	if err := os.WriteFile(path, []byte(strings.Join(rules, "\n")+"\n"), 0o600); err != nil {
+		tb.Fatalf("failed to write policy: %v", err)
+	}
+
+	cfg := &config.Config{Casbin: config.CasbinConfig{ModelPath: testModelFile, CacheTTL: ttl, CacheSize: 1000}}
+	service, err := NewCasbinServiceFromPolicyFile(cfg, path)
+	if err != nil {
+		tb.Fatalf("failed to create Casbin service: %v", err)
+	}
+	return service
+}
+
+// assertEnforce fails the test unless sub is allowed GET on obj in the default domain as wanted
+func assertEnforce(t *testing.T, service *CasbinService, sub, obj string, want bool) {
+	t.Helper()
+
+	got, err := service.Enforce(sub, DomainDefault, obj, "GET", RequestAttributes{})
+	if err != nil {
+		t.Fatalf("Enforce failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Enforce(%s, GET %s) = %v, want %v", sub, obj, got, want)
+	}
+}
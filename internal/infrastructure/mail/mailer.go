@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+)
+
+// Mail drivers
+const (
+	// DriverLog writes messages to the log instead of sending them, for development
+	DriverLog = "log"
+	// DriverSMTP sends messages through an SMTP server
+	DriverSMTP = "smtp"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	// Send sends a message
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer creates the mailer selected by the configuration
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	switch cfg.Mail.Driver {
+	case DriverLog, "":
+		return &LogMailer{}, nil
+	case DriverSMTP:
+		return NewSMTPMailer(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q, expected %s or %s", cfg.Mail.Driver, DriverLog, DriverSMTP)
+	}
+}
+
+// LogMailer writes messages to the log instead of sending them
+type LogMailer struct{}
+
+// Send logs a message
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP server, authenticating when a username is configured
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a new SMTPMailer
+func NewSMTPMailer(cfg *config.Config) *SMTPMailer {
+	mailer := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort),
+		from: cfg.Mail.From,
+	}
+	if cfg.Mail.SMTPUsername != "" {
+		mailer.auth = smtp.PlainAuth("", cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.SMTPHost)
+	}
+	return mailer
+}
+
+// Send sends a message. The context is not used, as net/smtp does not support cancellation.
+func (m *SMTPMailer) Send(_ context.Context, msg Message) error {
+	// Header values cannot span lines, which would let them inject other headers
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid recipient or subject")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.from)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
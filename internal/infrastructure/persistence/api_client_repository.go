@@ -3,6 +3,7 @@ package persistence
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
@@ -27,6 +28,7 @@ func (r *APIClientRepository) Create(ctx context.Context, client *entity.APIClie
 	model := &models.APIClient{}
 	model.FromEntity(client)
 	model.ID = 0 // Ensure ID is not set for creation
+	model.Version = 1
 
 	result := r.db.WithContext(ctx).Create(model)
 	if result.Error != nil {
@@ -34,6 +36,7 @@ func (r *APIClientRepository) Create(ctx context.Context, client *entity.APIClie
 	}
 
 	client.ID = model.ID
+	client.Version = model.Version
 	return nil
 }
 
@@ -65,33 +68,40 @@ func (r *APIClientRepository) GetByAPIKey(ctx context.Context, apiKey string) (*
 	return model.ToEntity(), nil
 }
 
-// Update updates an API client
+// Update updates an API client at the version it was read at and increments its version
 func (r *APIClientRepository) Update(ctx context.Context, client *entity.APIClient) error {
 	model := &models.APIClient{}
 	model.FromEntity(client)
 	model.ID = client.ID
+	model.Version = client.Version + 1
 
-	result := r.db.WithContext(ctx).Save(model)
-	return result.Error
+	if err := updateVersioned(r.db.WithContext(ctx), model, client.ID, client.Version); err != nil {
+		return err
+	}
+
+	client.Version = model.Version
+	return nil
 }
 
-// Delete soft deletes an API client
-func (r *APIClientRepository) Delete(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).Delete(&models.APIClient{}, id)
-	return result.Error
+// Delete soft deletes an API client at the given version
+func (r *APIClientRepository) Delete(ctx context.Context, id uint, version uint) error {
+	return deleteVersioned(r.db.WithContext(ctx), &models.APIClient{}, id, version)
 }
 
-// List retrieves all API clients with pagination
-func (r *APIClientRepository) List(ctx context.Context, offset, limit int) ([]*entity.APIClient, int64, error) {
-	var count int64
-	if err := r.db.WithContext(ctx).Model(&models.APIClient{}).Count(&count).Error; err != nil {
-		return nil, 0, err
+// List retrieves the API clients matching a query, along with where their page stands
+func (r *APIClientRepository) List(ctx context.Context, query repository.APIClientQuery) ([]*entity.APIClient, *repository.Page, error) {
+	db := scopeDeleted(r.db.WithContext(ctx).Model(&models.APIClient{}), query.Deleted)
+	if query.Active != nil {
+		db = db.Where("active = ?", *query.Active)
+	}
+	db = scopeCreated(db, query.CreatedFrom, query.CreatedTo)
+	if query.Search != "" {
+		db = db.Where("name ILIKE ?", searchPattern(query.Search))
 	}
 
-	var models []models.APIClient
-	result := r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&models)
-	if result.Error != nil {
-		return nil, 0, result.Error
+	models, page, err := paginate(db, query.PageQuery, (*models.APIClient).SortValue)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	clients := make([]*entity.APIClient, len(models))
@@ -99,7 +109,7 @@ func (r *APIClientRepository) List(ctx context.Context, offset, limit int) ([]*e
 		clients[i] = model.ToEntity()
 	}
 
-	return clients, count, nil
+	return clients, page, nil
 }
 
 // GetDeletedByID retrieves a soft deleted API client by ID
@@ -116,17 +126,12 @@ func (r *APIClientRepository) GetDeletedByID(ctx context.Context, id uint) (*ent
 	return model.ToEntity(), nil
 }
 
-// ListDeleted retrieves all soft deleted API clients with pagination
-func (r *APIClientRepository) ListDeleted(ctx context.Context, offset, limit int) ([]*entity.APIClient, int64, error) {
-	var count int64
-	if err := r.db.WithContext(ctx).Unscoped().Model(&models.APIClient{}).Where("deleted_at IS NOT NULL").Count(&count).Error; err != nil {
-		return nil, 0, err
-	}
-
+// ListDeletedBefore retrieves the API clients soft deleted before a time
+func (r *APIClientRepository) ListDeletedBefore(ctx context.Context, before time.Time) ([]*entity.APIClient, error) {
 	var models []models.APIClient
-	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Offset(offset).Limit(limit).Find(&models)
+	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Find(&models)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, result.Error
 	}
 
 	clients := make([]*entity.APIClient, len(models))
@@ -134,7 +139,7 @@ func (r *APIClientRepository) ListDeleted(ctx context.Context, offset, limit int
 		clients[i] = model.ToEntity()
 	}
 
-	return clients, count, nil
+	return clients, nil
 }
 
 // Restore restores a soft deleted API client
@@ -37,12 +37,29 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	return &Database{DB: db}, nil
 }
 
-// AutoMigrate runs database migrations
+// AutoMigrate runs database migrations, then creates the indexes backing searches
 func (d *Database) AutoMigrate() error {
-	return d.DB.AutoMigrate(
+	err := d.DB.AutoMigrate(
 		&models.User{},
 		&models.APIClient{},
+		&models.PolicyVersion{},
+		&models.RoleGrant{},
+		&models.UserImport{},
+		&models.Invitation{},
+		&models.UserAttribute{},
+		&models.Group{},
+		&models.ErasureRequest{},
 	)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range searchIndexes {
+		if err := d.DB.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create search indexes: %w", err)
+		}
+	}
+	return nil
 }
 
 // Close closes the database connection
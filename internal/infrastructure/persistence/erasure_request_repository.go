@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+)
+
+// ErasureRequestRepository is the implementation of repository.ErasureRequestRepository
+type ErasureRequestRepository struct {
+	db *gorm.DB
+}
+
+// NewErasureRequestRepository creates a new ErasureRequestRepository
+func NewErasureRequestRepository(db *gorm.DB) repository.ErasureRequestRepository {
+	return &ErasureRequestRepository{
+		db: db,
+	}
+}
+
+// Create creates a new erasure request
+func (r *ErasureRequestRepository) Create(ctx context.Context, request *entity.ErasureRequest) error {
+	model := &models.ErasureRequest{}
+	model.FromEntity(request)
+
+	result := r.db.WithContext(ctx).Create(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	request.ID = model.ID
+	return nil
+}
+
+// GetLatestByUser retrieves the latest erasure request of a user
+func (r *ErasureRequestRepository) GetLatestByUser(ctx context.Context, userID uint) (*entity.ErasureRequest, error) {
+	var model models.ErasureRequest
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").First(&model)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// Update updates an erasure request
+func (r *ErasureRequestRepository) Update(ctx context.Context, request *entity.ErasureRequest) error {
+	model := &models.ErasureRequest{}
+	model.FromEntity(request)
+	model.ID = request.ID
+
+	result := r.db.WithContext(ctx).Save(model)
+	return result.Error
+}
+
+// ListDue retrieves the pending requests scheduled at or before the given time, oldest first
+func (r *ErasureRequestRepository) ListDue(ctx context.Context, now time.Time) ([]*entity.ErasureRequest, error) {
+	var models []models.ErasureRequest
+	result := r.db.WithContext(ctx).
+		Where("status = ? AND scheduled_at <= ?", entity.ErasureStatusPending, now).
+		Order("id").
+		Find(&models)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	requests := make([]*entity.ErasureRequest, len(models))
+	for i, model := range models {
+		requests[i] = model.ToEntity()
+	}
+
+	return requests, nil
+}
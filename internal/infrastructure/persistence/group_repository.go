@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+)
+
+// GroupRepository is the implementation of repository.GroupRepository
+type GroupRepository struct {
+	db *gorm.DB
+}
+
+// NewGroupRepository creates a new GroupRepository
+func NewGroupRepository(db *gorm.DB) repository.GroupRepository {
+	return &GroupRepository{
+		db: db,
+	}
+}
+
+// Create creates a new group
+func (r *GroupRepository) Create(ctx context.Context, group *entity.Group) error {
+	model := &models.Group{}
+	model.FromEntity(group)
+
+	result := r.db.WithContext(ctx).Create(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	group.ID = model.ID
+	return nil
+}
+
+// GetByID retrieves a group by ID
+func (r *GroupRepository) GetByID(ctx context.Context, id uint) (*entity.Group, error) {
+	return r.get(ctx, "id = ?", id)
+}
+
+// GetByName retrieves a group of a domain by name
+func (r *GroupRepository) GetByName(ctx context.Context, name, domain string) (*entity.Group, error) {
+	return r.get(ctx, "name = ? AND domain = ?", name, domain)
+}
+
+// get retrieves the group matching a condition
+func (r *GroupRepository) get(ctx context.Context, query string, args ...any) (*entity.Group, error) {
+	var model models.Group
+	result := r.db.WithContext(ctx).Where(query, args...).First(&model)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// Update updates a group
+func (r *GroupRepository) Update(ctx context.Context, group *entity.Group) error {
+	model := &models.Group{}
+	model.FromEntity(group)
+	model.ID = group.ID
+
+	result := r.db.WithContext(ctx).Save(model)
+	return result.Error
+}
+
+// Delete deletes a group
+func (r *GroupRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Group{}, id)
+	return result.Error
+}
+
+// List retrieves the groups of a domain, or of every domain when empty, with pagination, sorted by name
+func (r *GroupRepository) List(ctx context.Context, domain string, offset, limit int) ([]*entity.Group, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Group{})
+	if domain != "" {
+		query = query.Where("domain = ?", domain)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []models.Group
+	result := query.Order("name, domain").Offset(offset).Limit(limit).Find(&models)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	groups := make([]*entity.Group, len(models))
+	for i, model := range models {
+		groups[i] = model.ToEntity()
+	}
+
+	return groups, count, nil
+}
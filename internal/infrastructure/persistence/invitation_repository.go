@@ -0,0 +1,145 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+)
+
+// InvitationRepository is the implementation of repository.InvitationRepository
+type InvitationRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new InvitationRepository
+func NewInvitationRepository(db *gorm.DB) repository.InvitationRepository {
+	return &InvitationRepository{
+		db: db,
+	}
+}
+
+// Create creates a new invitation
+func (r *InvitationRepository) Create(ctx context.Context, invitation *entity.Invitation) error {
+	model := &models.Invitation{}
+	model.FromEntity(invitation)
+
+	result := r.db.WithContext(ctx).Create(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	invitation.ID = model.ID
+	return nil
+}
+
+// GetByID retrieves an invitation by ID
+func (r *InvitationRepository) GetByID(ctx context.Context, id uint) (*entity.Invitation, error) {
+	return r.get(ctx, "id = ?", id)
+}
+
+// GetByTokenHash retrieves an invitation by the hash of its token
+func (r *InvitationRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*entity.Invitation, error) {
+	return r.get(ctx, "token_hash = ?", tokenHash)
+}
+
+// get retrieves the invitation matching a condition
+func (r *InvitationRepository) get(ctx context.Context, query string, args ...any) (*entity.Invitation, error) {
+	var model models.Invitation
+	result := r.db.WithContext(ctx).Where(query, args...).First(&model)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// Update updates an invitation
+func (r *InvitationRepository) Update(ctx context.Context, invitation *entity.Invitation) error {
+	model := &models.Invitation{}
+	model.FromEntity(invitation)
+	model.ID = invitation.ID
+
+	result := r.db.WithContext(ctx).Save(model)
+	return result.Error
+}
+
+// Claim saves an accepted invitation with a single conditional update, provided it is still pending
+// and unexpired at the time it was accepted
+func (r *InvitationRepository) Claim(ctx context.Context, invitation *entity.Invitation) error {
+	if invitation.Status != entity.InvitationStatusAccepted || invitation.AcceptedAt == nil {
+		return errors.New("invitation must be accepted before being claimed")
+	}
+
+	result := r.db.WithContext(ctx).Model(&models.Invitation{}).
+		Where("id = ? AND status = ? AND expires_at > ?", invitation.ID, entity.InvitationStatusPending, *invitation.AcceptedAt).
+		Updates(map[string]any{
+			"status":      invitation.Status,
+			"accepted_by": invitation.AcceptedBy,
+			"accepted_at": invitation.AcceptedAt,
+			"updated_at":  invitation.UpdatedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: it was accepted, revoked or expired meanwhile", entity.ErrInvitationUnusable)
+	}
+	return nil
+}
+
+// Release puts a claimed invitation back to pending, provided it is still claimed for the same user
+func (r *InvitationRepository) Release(ctx context.Context, invitation *entity.Invitation) error {
+	result := r.db.WithContext(ctx).Model(&models.Invitation{}).
+		Where("id = ? AND status = ? AND accepted_by = ?", invitation.ID, entity.InvitationStatusAccepted, invitation.AcceptedBy).
+		Updates(map[string]any{
+			"status":      entity.InvitationStatusPending,
+			"accepted_by": "",
+			"accepted_at": nil,
+			"updated_at":  time.Now(),
+		})
+	return result.Error
+}
+
+// List retrieves invitations matching a filter with pagination, newest first
+func (r *InvitationRepository) List(ctx context.Context, filter repository.InvitationFilter, offset, limit int) ([]*entity.Invitation, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Invitation{})
+	switch filter.Status {
+	case "":
+	case entity.InvitationStatusPending:
+		query = query.Where("status = ? AND expires_at > ?", entity.InvitationStatusPending, filter.Now)
+	case entity.InvitationStatusExpired:
+		query = query.Where("status = ? AND expires_at <= ?", entity.InvitationStatusPending, filter.Now)
+	default:
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Email != "" {
+		query = query.Where("email = ?", filter.Email)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []models.Invitation
+	result := query.Order("id DESC").Offset(offset).Limit(limit).Find(&models)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	invitations := make([]*entity.Invitation, len(models))
+	for i, model := range models {
+		invitations[i] = model.ToEntity()
+	}
+
+	return invitations, count, nil
+}
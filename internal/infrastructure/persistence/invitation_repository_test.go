@@ -0,0 +1,163 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestInvitationRepositoryClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		// stored changes the invitation as stored before it is claimed
+		stored    func(invitation *entity.Invitation)
+		wantClaim error
+	}{
+		{name: "pending", stored: func(*entity.Invitation) {}},
+		{
+			name:      "expired",
+			stored:    func(i *entity.Invitation) { i.ExpiresAt = time.Now().Add(-time.Minute) },
+			wantClaim: entity.ErrInvitationUnusable,
+		},
+		{
+			name:      "revoked meanwhile",
+			stored:    func(i *entity.Invitation) { i.Status = entity.InvitationStatusRevoked },
+			wantClaim: entity.ErrInvitationUnusable,
+		},
+		{
+			name: "accepted meanwhile",
+			stored: func(i *entity.Invitation) {
+				now := time.Now()
+				i.Status, i.AcceptedBy, i.AcceptedAt = entity.InvitationStatusAccepted, "someone", &now
+			},
+			wantClaim: entity.ErrInvitationUnusable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newTestInvitationRepository(t)
+
+			invitation, _, err := entity.NewInvitation("jdoe@example.com", "user", "default", "admin", time.Hour)
+			if err != nil {
+				t.Fatalf("NewInvitation failed: %v", err)
+			}
+			stored := *invitation
+			tt.stored(&stored)
+			if err := repo.Create(ctx, &stored); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			// Accepted as read before the stored invitation changed
+			invitation.ID = stored.ID
+			if err := invitation.Accept("jdoe"); err != nil {
+				t.Fatalf("Accept failed: %v", err)
+			}
+
+			err = repo.Claim(ctx, invitation)
+			if !errors.Is(err, tt.wantClaim) {
+				t.Fatalf("Claim = %v, want %v", err, tt.wantClaim)
+			}
+
+			got, err := repo.GetByID(ctx, stored.ID)
+			if err != nil {
+				t.Fatalf("GetByID failed: %v", err)
+			}
+			wantStatus, wantAcceptedBy := stored.Status, stored.AcceptedBy
+			if tt.wantClaim == nil {
+				wantStatus, wantAcceptedBy = entity.InvitationStatusAccepted, "jdoe"
+			}
+			if got.Status != wantStatus || got.AcceptedBy != wantAcceptedBy {
+				t.Errorf("stored invitation is %s by %q, want %s by %q", got.Status, got.AcceptedBy, wantStatus, wantAcceptedBy)
+			}
+		})
+	}
+}
+
+func TestInvitationRepositoryClaimOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestInvitationRepository(t)
+
+	invitation, _, err := entity.NewInvitation("jdoe@example.com", "user", "default", "admin", time.Hour)
+	if err != nil {
+		t.Fatalf("NewInvitation failed: %v", err)
+	}
+	if err := repo.Create(ctx, invitation); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Two accepts read the invitation while pending
+	first, second := *invitation, *invitation
+	if err := first.Accept("jdoe"); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if err := second.Accept("johnny"); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	if err := repo.Claim(ctx, &first); err != nil {
+		t.Fatalf("first Claim failed: %v", err)
+	}
+	if err := repo.Claim(ctx, &second); !errors.Is(err, entity.ErrInvitationUnusable) {
+		t.Fatalf("second Claim = %v, want %v", err, entity.ErrInvitationUnusable)
+	}
+
+	// Releasing for another user leaves the claim in place
+	if err := repo.Release(ctx, &second); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, invitation.ID); got.Status != entity.InvitationStatusAccepted || got.AcceptedBy != "jdoe" {
+		t.Fatalf("invitation released for another user: %s by %q", got.Status, got.AcceptedBy)
+	}
+
+	// Released, it can be claimed again
+	if err := repo.Release(ctx, &first); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	got, err := repo.GetByID(ctx, invitation.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.Status != entity.InvitationStatusPending || got.AcceptedBy != "" || got.AcceptedAt != nil {
+		t.Fatalf("released invitation is %s by %q", got.Status, got.AcceptedBy)
+	}
+	if err := repo.Claim(ctx, &second); err != nil {
+		t.Errorf("Claim after release failed: %v", err)
+	}
+}
+
+// newTestInvitationRepository creates an InvitationRepository on an empty in-memory database
+func newTestInvitationRepository(t *testing.T) *InvitationRepository {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// Tables live in the public schema, as on Postgres, which must stay on the one connection
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get connection pool: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.Exec("ATTACH DATABASE ':memory:' AS public").Error; err != nil {
+		t.Fatalf("failed to attach schema: %v", err)
+	}
+
+	// SQLite cannot create the indexes of a table in an attached schema, which the tests do without
+	_ = db.Migrator().CreateTable(&models.Invitation{})
+	if err := db.Exec("SELECT count(*) FROM public.invitations").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	return &InvitationRepository{db: db}
+}
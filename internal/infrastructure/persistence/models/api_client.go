@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
@@ -17,6 +18,9 @@ type APIClient struct {
 	CreatedAt   time.Time      `gorm:"not null"`
 	UpdatedAt   time.Time      `gorm:"not null"`
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Version     uint           `gorm:"not null;default:1"`
+	// PolicySnapshot is a JSON array of the Casbin rules of a deleted client, null otherwise
+	PolicySnapshot *string `gorm:"type:jsonb"`
 }
 
 // TableName specifies the table name for APIClient
@@ -31,15 +35,22 @@ func (c *APIClient) ToEntity() *entity.APIClient {
 		deletedAt = &c.DeletedAt.Time
 	}
 
+	var snapshot []entity.PolicyRule
+	if c.PolicySnapshot != nil {
+		_ = json.Unmarshal([]byte(*c.PolicySnapshot), &snapshot)
+	}
+
 	return &entity.APIClient{
-		ID:          c.ID,
-		Name:        c.Name,
-		Description: c.Description,
-		APIKey:      c.APIKey,
-		Active:      c.Active,
-		CreatedAt:   c.CreatedAt,
-		UpdatedAt:   c.UpdatedAt,
-		DeletedAt:   deletedAt,
+		ID:             c.ID,
+		Name:           c.Name,
+		Description:    c.Description,
+		APIKey:         c.APIKey,
+		Active:         c.Active,
+		CreatedAt:      c.CreatedAt,
+		UpdatedAt:      c.UpdatedAt,
+		DeletedAt:      deletedAt,
+		Version:        c.Version,
+		PolicySnapshot: snapshot,
 	}
 }
 
@@ -50,10 +61,35 @@ func (c *APIClient) FromEntity(client *entity.APIClient) {
 	c.APIKey = client.APIKey
 	c.Active = client.Active
 	c.UpdatedAt = client.UpdatedAt
+	c.Version = client.Version
 
 	if client.DeletedAt != nil {
 		c.DeletedAt = gorm.DeletedAt{Time: *client.DeletedAt, Valid: true}
 	} else {
 		c.DeletedAt = gorm.DeletedAt{Valid: false}
 	}
+
+	c.PolicySnapshot = nil
+	if client.PolicySnapshot != nil {
+		// Marshaling a list of string slices cannot fail
+		data, _ := json.Marshal(client.PolicySnapshot)
+		snapshot := string(data)
+		c.PolicySnapshot = &snapshot
+	}
+}
+
+// SortValue returns the value of a sortable column, from which page cursors are built
+func (c *APIClient) SortValue(column string) any {
+	switch column {
+	case "name":
+		return c.Name
+	case "active":
+		return c.Active
+	case "created_at":
+		return c.CreatedAt
+	case "updated_at":
+		return c.UpdatedAt
+	default:
+		return c.ID
+	}
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// ErasureRequest is the GORM model for erasure requests
+type ErasureRequest struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null;index"`
+	Reason      string    `gorm:"size:1000"`
+	Status      string    `gorm:"size:20;not null;index"`
+	RequestedBy string    `gorm:"size:255;not null"`
+	ScheduledAt time.Time `gorm:"not null"`
+	CancelledBy string    `gorm:"size:255"`
+	CompletedAt *time.Time
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for ErasureRequest
+func (*ErasureRequest) TableName() string {
+	return "public.erasure_requests"
+}
+
+// ToEntity converts the model to a domain entity
+func (r *ErasureRequest) ToEntity() *entity.ErasureRequest {
+	return &entity.ErasureRequest{
+		ID:          r.ID,
+		UserID:      r.UserID,
+		Reason:      r.Reason,
+		Status:      r.Status,
+		RequestedBy: r.RequestedBy,
+		ScheduledAt: r.ScheduledAt,
+		CancelledBy: r.CancelledBy,
+		CompletedAt: r.CompletedAt,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (r *ErasureRequest) FromEntity(request *entity.ErasureRequest) {
+	r.UserID = request.UserID
+	r.Reason = request.Reason
+	r.Status = request.Status
+	r.RequestedBy = request.RequestedBy
+	r.ScheduledAt = request.ScheduledAt
+	r.CancelledBy = request.CancelledBy
+	r.CompletedAt = request.CompletedAt
+	r.CreatedAt = request.CreatedAt
+	r.UpdatedAt = request.UpdatedAt
+}
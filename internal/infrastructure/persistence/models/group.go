@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// Group is the GORM model for groups
+type Group struct {
+	ID          uint      `gorm:"primaryKey"`
+	Name        string    `gorm:"size:63;not null;uniqueIndex:idx_user_groups_domain_name,priority:2"`
+	Description string    `gorm:"size:1000;not null;default:''"`
+	Domain      string    `gorm:"size:255;not null;uniqueIndex:idx_user_groups_domain_name,priority:1"`
+	CreatedBy   string    `gorm:"size:255;not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for Group
+func (*Group) TableName() string {
+	return "public.user_groups"
+}
+
+// ToEntity converts the model to a domain entity
+func (g *Group) ToEntity() *entity.Group {
+	return &entity.Group{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+		Domain:      g.Domain,
+		CreatedBy:   g.CreatedBy,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (g *Group) FromEntity(group *entity.Group) {
+	g.Name = group.Name
+	g.Description = group.Description
+	g.Domain = group.Domain
+	g.CreatedBy = group.CreatedBy
+	g.CreatedAt = group.CreatedAt
+	g.UpdatedAt = group.UpdatedAt
+}
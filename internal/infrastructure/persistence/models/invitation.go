@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// Invitation is the GORM model for invitations
+type Invitation struct {
+	ID         uint      `gorm:"primaryKey"`
+	Email      string    `gorm:"size:255;not null;index"`
+	Role       string    `gorm:"size:255;not null"`
+	Domain     string    `gorm:"size:255;not null"`
+	TokenHash  string    `gorm:"size:64;not null;uniqueIndex"`
+	Status     string    `gorm:"size:20;not null;index"`
+	InvitedBy  string    `gorm:"size:255;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	AcceptedBy string    `gorm:"size:255"`
+	AcceptedAt *time.Time
+	CreatedAt  time.Time `gorm:"not null"`
+	UpdatedAt  time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for Invitation
+func (*Invitation) TableName() string {
+	return "public.invitations"
+}
+
+// ToEntity converts the model to a domain entity
+func (i *Invitation) ToEntity() *entity.Invitation {
+	return &entity.Invitation{
+		ID:         i.ID,
+		Email:      i.Email,
+		Role:       i.Role,
+		Domain:     i.Domain,
+		TokenHash:  i.TokenHash,
+		Status:     i.Status,
+		InvitedBy:  i.InvitedBy,
+		ExpiresAt:  i.ExpiresAt,
+		AcceptedBy: i.AcceptedBy,
+		AcceptedAt: i.AcceptedAt,
+		CreatedAt:  i.CreatedAt,
+		UpdatedAt:  i.UpdatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (i *Invitation) FromEntity(invitation *entity.Invitation) {
+	i.Email = invitation.Email
+	i.Role = invitation.Role
+	i.Domain = invitation.Domain
+	i.TokenHash = invitation.TokenHash
+	i.Status = invitation.Status
+	i.InvitedBy = invitation.InvitedBy
+	i.ExpiresAt = invitation.ExpiresAt
+	i.AcceptedBy = invitation.AcceptedBy
+	i.AcceptedAt = invitation.AcceptedAt
+	i.CreatedAt = invitation.CreatedAt
+	i.UpdatedAt = invitation.UpdatedAt
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// PolicyVersion is the GORM model for policy versions
+type PolicyVersion struct {
+	Version     uint      `gorm:"primaryKey"`
+	Actor       string    `gorm:"size:255;not null"`
+	Action      string    `gorm:"size:50;not null"`
+	Description string    `gorm:"size:1000"`
+	Added       string    `gorm:"type:jsonb;not null"`
+	Removed     string    `gorm:"type:jsonb;not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for PolicyVersion
+func (*PolicyVersion) TableName() string {
+	return "public.policy_versions"
+}
+
+// ToEntity converts the model to a domain entity
+func (v *PolicyVersion) ToEntity() *entity.PolicyVersion {
+	var added, removed []entity.PolicyRule
+	_ = json.Unmarshal([]byte(v.Added), &added)
+	_ = json.Unmarshal([]byte(v.Removed), &removed)
+
+	return &entity.PolicyVersion{
+		Version:     v.Version,
+		Actor:       v.Actor,
+		Action:      v.Action,
+		Description: v.Description,
+		Added:       added,
+		Removed:     removed,
+		CreatedAt:   v.CreatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (v *PolicyVersion) FromEntity(version *entity.PolicyVersion) error {
+	added, err := json.Marshal(nonNilRules(version.Added))
+	if err != nil {
+		return err
+	}
+	removed, err := json.Marshal(nonNilRules(version.Removed))
+	if err != nil {
+		return err
+	}
+
+	v.Actor = version.Actor
+	v.Action = version.Action
+	v.Description = version.Description
+	v.Added = string(added)
+	v.Removed = string(removed)
+	v.CreatedAt = version.CreatedAt
+	return nil
+}
+
+// nonNilRules makes sure empty rule lists are stored as [] rather than null
+func nonNilRules(rules []entity.PolicyRule) []entity.PolicyRule {
+	if rules == nil {
+		return []entity.PolicyRule{}
+	}
+	return rules
+}
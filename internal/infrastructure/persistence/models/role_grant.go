@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// RoleGrant is the GORM model for role grants
+type RoleGrant struct {
+	ID          uint      `gorm:"primaryKey"`
+	Subject     string    `gorm:"size:255;not null;index"`
+	Role        string    `gorm:"size:255;not null"`
+	Domain      string    `gorm:"size:255;not null"`
+	Reason      string    `gorm:"size:1000"`
+	Status      string    `gorm:"size:20;not null;index"`
+	StartsAt    time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	RequestedBy string    `gorm:"size:255;not null"`
+	DecidedBy   string    `gorm:"size:255"`
+	DecidedAt   *time.Time
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for RoleGrant
+func (*RoleGrant) TableName() string {
+	return "public.role_grants"
+}
+
+// ToEntity converts the model to a domain entity
+func (g *RoleGrant) ToEntity() *entity.RoleGrant {
+	return &entity.RoleGrant{
+		ID:          g.ID,
+		Subject:     g.Subject,
+		Role:        g.Role,
+		Domain:      g.Domain,
+		Reason:      g.Reason,
+		Status:      g.Status,
+		StartsAt:    g.StartsAt,
+		ExpiresAt:   g.ExpiresAt,
+		RequestedBy: g.RequestedBy,
+		DecidedBy:   g.DecidedBy,
+		DecidedAt:   g.DecidedAt,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (g *RoleGrant) FromEntity(grant *entity.RoleGrant) {
+	g.Subject = grant.Subject
+	g.Role = grant.Role
+	g.Domain = grant.Domain
+	g.Reason = grant.Reason
+	g.Status = grant.Status
+	g.StartsAt = grant.StartsAt
+	g.ExpiresAt = grant.ExpiresAt
+	g.RequestedBy = grant.RequestedBy
+	g.DecidedBy = grant.DecidedBy
+	g.DecidedAt = grant.DecidedAt
+	g.CreatedAt = grant.CreatedAt
+	g.UpdatedAt = grant.UpdatedAt
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
@@ -18,6 +19,21 @@ type User struct {
 	CreatedAt time.Time      `gorm:"not null"`
 	UpdatedAt time.Time      `gorm:"not null"`
 	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Version   uint           `gorm:"not null;default:1"`
+
+	DisplayName string `gorm:"size:100;not null;default:''"`
+	Locale      string `gorm:"size:35;not null;default:''"`
+	Timezone    string `gorm:"size:64;not null;default:''"`
+	// Attributes holds the JSON encoded custom attributes
+	Attributes string `gorm:"type:jsonb;not null;default:'{}'"`
+	AvatarKey  string `gorm:"size:255;not null;default:''"`
+
+	PendingEmail          string `gorm:"size:255;not null;default:''"`
+	PendingEmailExpiresAt *time.Time
+	EmailChangeTokenHash  string `gorm:"size:64;not null;default:'';index"`
+
+	TokensRevokedAt *time.Time
+	ErasedAt        *time.Time
 }
 
 // TableName specifies the table name for User
@@ -32,16 +48,32 @@ func (u *User) ToEntity() *entity.User {
 		deletedAt = &u.DeletedAt.Time
 	}
 
+	attributes := map[string]any{}
+	_ = json.Unmarshal([]byte(u.Attributes), &attributes)
+
 	return &entity.User{
-		ID:        u.ID,
-		Username:  u.Username,
-		Email:     u.Email,
-		Password:  u.Password,
-		Role:      u.Role,
-		Active:    u.Active,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
-		DeletedAt: deletedAt,
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Password:    u.Password,
+		Role:        u.Role,
+		Active:      u.Active,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		DeletedAt:   deletedAt,
+		Version:     u.Version,
+		DisplayName: u.DisplayName,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+		Attributes:  attributes,
+		AvatarKey:   u.AvatarKey,
+
+		PendingEmail:          u.PendingEmail,
+		PendingEmailExpiresAt: u.PendingEmailExpiresAt,
+		EmailChangeTokenHash:  u.EmailChangeTokenHash,
+
+		TokensRevokedAt: u.TokensRevokedAt,
+		ErasedAt:        u.ErasedAt,
 	}
 }
 
@@ -53,6 +85,23 @@ func (u *User) FromEntity(user *entity.User) {
 	u.Role = user.Role
 	u.Active = user.Active
 	u.UpdatedAt = user.UpdatedAt
+	u.Version = user.Version
+	u.DisplayName = user.DisplayName
+	u.Locale = user.Locale
+	u.Timezone = user.Timezone
+	u.AvatarKey = user.AvatarKey
+	u.PendingEmail = user.PendingEmail
+	u.PendingEmailExpiresAt = user.PendingEmailExpiresAt
+	u.EmailChangeTokenHash = user.EmailChangeTokenHash
+	u.TokensRevokedAt = user.TokensRevokedAt
+	u.ErasedAt = user.ErasedAt
+
+	attributes := user.Attributes
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	data, _ := json.Marshal(attributes)
+	u.Attributes = string(data)
 
 	if user.DeletedAt != nil {
 		u.DeletedAt = gorm.DeletedAt{Time: *user.DeletedAt, Valid: true}
@@ -60,3 +109,23 @@ func (u *User) FromEntity(user *entity.User) {
 		u.DeletedAt = gorm.DeletedAt{Valid: false}
 	}
 }
+
+// SortValue returns the value of a sortable column, from which page cursors are built
+func (u *User) SortValue(column string) any {
+	switch column {
+	case "username":
+		return u.Username
+	case "email":
+		return u.Email
+	case "role":
+		return u.Role
+	case "active":
+		return u.Active
+	case "created_at":
+		return u.CreatedAt
+	case "updated_at":
+		return u.UpdatedAt
+	default:
+		return u.ID
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserAttribute is the GORM model for custom user attribute definitions
+type UserAttribute struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"size:63;not null;uniqueIndex"`
+	Type        string `gorm:"size:20;not null"`
+	Description string `gorm:"size:1000;not null"`
+	Required    bool   `gorm:"not null"`
+	// Options holds the JSON encoded options of enum attributes
+	Options   string    `gorm:"type:jsonb;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for UserAttribute
+func (*UserAttribute) TableName() string {
+	return "public.user_attributes"
+}
+
+// ToEntity converts the model to a domain entity
+func (a *UserAttribute) ToEntity() *entity.UserAttribute {
+	var options []string
+	_ = json.Unmarshal([]byte(a.Options), &options)
+
+	return &entity.UserAttribute{
+		ID:          a.ID,
+		Name:        a.Name,
+		Type:        a.Type,
+		Description: a.Description,
+		Required:    a.Required,
+		Options:     options,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (a *UserAttribute) FromEntity(attribute *entity.UserAttribute) {
+	a.ID = attribute.ID
+	a.Name = attribute.Name
+	a.Type = attribute.Type
+	a.Description = attribute.Description
+	a.Required = attribute.Required
+	a.CreatedAt = attribute.CreatedAt
+	a.UpdatedAt = attribute.UpdatedAt
+
+	options := attribute.Options
+	if options == nil {
+		options = []string{}
+	}
+	data, _ := json.Marshal(options)
+	a.Options = string(data)
+}
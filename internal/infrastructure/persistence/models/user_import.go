@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+)
+
+// UserImport is the GORM model for user imports
+type UserImport struct {
+	ID            uint   `gorm:"primaryKey"`
+	Format        string `gorm:"size:10;not null"`
+	DryRun        bool   `gorm:"not null"`
+	Status        string `gorm:"size:20;not null;index"`
+	Data          []byte `gorm:"not null"`
+	TotalRows     int    `gorm:"not null"`
+	ProcessedRows int    `gorm:"not null"`
+	SucceededRows int    `gorm:"not null"`
+	// Errors holds the JSON encoded row errors
+	Errors      string `gorm:"type:jsonb;not null"`
+	Error       string `gorm:"size:1000"`
+	RequestedBy string `gorm:"size:255;not null"`
+	StartedAt   *time.Time
+	FinishedAt  *time.Time
+	CreatedAt   time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for UserImport
+func (*UserImport) TableName() string {
+	return "public.user_imports"
+}
+
+// ToEntity converts the model to a domain entity
+func (i *UserImport) ToEntity() *entity.UserImport {
+	var rowErrors []entity.UserImportError
+	_ = json.Unmarshal([]byte(i.Errors), &rowErrors)
+
+	return &entity.UserImport{
+		ID:            i.ID,
+		Format:        i.Format,
+		DryRun:        i.DryRun,
+		Status:        i.Status,
+		Data:          i.Data,
+		TotalRows:     i.TotalRows,
+		ProcessedRows: i.ProcessedRows,
+		SucceededRows: i.SucceededRows,
+		Errors:        rowErrors,
+		Error:         i.Error,
+		RequestedBy:   i.RequestedBy,
+		StartedAt:     i.StartedAt,
+		FinishedAt:    i.FinishedAt,
+		CreatedAt:     i.CreatedAt,
+		UpdatedAt:     i.UpdatedAt,
+	}
+}
+
+// FromEntity updates the model from a domain entity
+func (i *UserImport) FromEntity(userImport *entity.UserImport) {
+	rowErrors := userImport.Errors
+	if rowErrors == nil {
+		rowErrors = []entity.UserImportError{}
+	}
+	data, _ := json.Marshal(rowErrors)
+	file := userImport.Data
+	if file == nil {
+		file = []byte{}
+	}
+
+	i.Format = userImport.Format
+	i.DryRun = userImport.DryRun
+	i.Status = userImport.Status
+	i.Data = file
+	i.TotalRows = userImport.TotalRows
+	i.ProcessedRows = userImport.ProcessedRows
+	i.SucceededRows = userImport.SucceededRows
+	i.Errors = string(data)
+	i.Error = userImport.Error
+	i.RequestedBy = userImport.RequestedBy
+	i.StartedAt = userImport.StartedAt
+	i.FinishedAt = userImport.FinishedAt
+	i.CreatedAt = userImport.CreatedAt
+	i.UpdatedAt = userImport.UpdatedAt
+}
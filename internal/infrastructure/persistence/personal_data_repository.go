@@ -0,0 +1,229 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+)
+
+// PersonalDataRepository is the implementation of repository.PersonalDataRepository
+type PersonalDataRepository struct {
+	db *gorm.DB
+}
+
+// NewPersonalDataRepository creates a new PersonalDataRepository
+func NewPersonalDataRepository(db *gorm.DB) repository.PersonalDataRepository {
+	return &PersonalDataRepository{
+		db: db,
+	}
+}
+
+// Find retrieves the records of other aggregates referring to a user, oldest first
+func (r *PersonalDataRepository) Find(ctx context.Context, userID uint, subject entity.DataSubject) (*repository.PersonalRecords, error) {
+	db := r.db.WithContext(ctx)
+	records := &repository.PersonalRecords{}
+
+	var grants []models.RoleGrant
+	err := db.Where("subject = ? OR requested_by = ? OR decided_by = ?", subject.Username, subject.Actor, subject.Actor).
+		Order("id").Find(&grants).Error
+	if err != nil {
+		return nil, err
+	}
+	records.RoleGrants = make([]*entity.RoleGrant, len(grants))
+	for i, model := range grants {
+		records.RoleGrants[i] = model.ToEntity()
+	}
+
+	var invitations []models.Invitation
+	err = db.Where("email = ? OR invited_by = ? OR accepted_by = ?", subject.Email, subject.Actor, subject.Username).
+		Order("id").Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	records.Invitations = make([]*entity.Invitation, len(invitations))
+	for i, model := range invitations {
+		records.Invitations[i] = model.ToEntity()
+	}
+
+	versions, err := r.findPolicyVersions(db, subject)
+	if err != nil {
+		return nil, err
+	}
+	records.PolicyVersions = make([]*entity.PolicyVersion, len(versions))
+	for i, model := range versions {
+		records.PolicyVersions[i] = model.ToEntity()
+	}
+
+	// The uploaded files hold the data of the imported users, not of the requester
+	var imports []models.UserImport
+	err = db.Omit("data").Where("requested_by = ?", subject.Actor).Order("id").Find(&imports).Error
+	if err != nil {
+		return nil, err
+	}
+	records.UserImports = make([]*entity.UserImport, len(imports))
+	for i, model := range imports {
+		records.UserImports[i] = model.ToEntity()
+	}
+
+	var groups []models.Group
+	if err := db.Where("created_by = ?", subject.Actor).Order("id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	records.Groups = make([]*entity.Group, len(groups))
+	for i, model := range groups {
+		records.Groups[i] = model.ToEntity()
+	}
+
+	var requests []models.ErasureRequest
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	records.ErasureRequests = make([]*entity.ErasureRequest, len(requests))
+	for i, model := range requests {
+		records.ErasureRequests[i] = model.ToEntity()
+	}
+
+	return records, nil
+}
+
+// findPolicyVersions retrieves the policy versions made by a user or changing rules of which
+// they are the subject
+func (r *PersonalDataRepository) findPolicyVersions(db *gorm.DB, subject entity.DataSubject) ([]models.PolicyVersion, error) {
+	// The rules are matched as text first, then decoded to only keep those of the subject
+	pattern := searchPattern(jsonString(subject.Username))
+
+	var candidates []models.PolicyVersion
+	err := db.Where("actor = ? OR CAST(added AS TEXT) LIKE ? OR CAST(removed AS TEXT) LIKE ?", subject.Actor, pattern, pattern).
+		Order("version").Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []models.PolicyVersion
+	for _, model := range candidates {
+		version := model.ToEntity()
+		if version.Actor == subject.Actor || hasSubjectRule(version.Added, subject.Username) || hasSubjectRule(version.Removed, subject.Username) {
+			versions = append(versions, model)
+		}
+	}
+	return versions, nil
+}
+
+// Erase saves an erased user and the completed request to erase them, and replaces the references
+// to the user's former identity in other records with their pseudonym, in a single transaction
+func (r *PersonalDataRepository) Erase(ctx context.Context, user *entity.User, request *entity.ErasureRequest, former, pseudonym entity.DataSubject) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		userModel := &models.User{}
+		userModel.FromEntity(user)
+		userModel.ID = user.ID
+		userModel.CreatedAt = user.CreatedAt
+		userModel.Version = user.Version + 1
+		// The user may have been soft deleted before being erased, and the erasure prevails over
+		// concurrent updates
+		if err := tx.Unscoped().Save(userModel).Error; err != nil {
+			return err
+		}
+
+		requestModel := &models.ErasureRequest{}
+		requestModel.FromEntity(request)
+		requestModel.ID = request.ID
+		if err := tx.Save(requestModel).Error; err != nil {
+			return err
+		}
+
+		replacements := []struct {
+			model    any
+			column   string
+			from, to string
+		}{
+			{&models.RoleGrant{}, "subject", former.Username, pseudonym.Username},
+			{&models.RoleGrant{}, "requested_by", former.Actor, pseudonym.Actor},
+			{&models.RoleGrant{}, "decided_by", former.Actor, pseudonym.Actor},
+			{&models.Invitation{}, "email", former.Email, pseudonym.Email},
+			{&models.Invitation{}, "invited_by", former.Actor, pseudonym.Actor},
+			{&models.Invitation{}, "accepted_by", former.Username, pseudonym.Username},
+			{&models.UserImport{}, "requested_by", former.Actor, pseudonym.Actor},
+			{&models.Group{}, "created_by", former.Actor, pseudonym.Actor},
+			{&models.ErasureRequest{}, "requested_by", former.Actor, pseudonym.Actor},
+			{&models.ErasureRequest{}, "cancelled_by", former.Actor, pseudonym.Actor},
+		}
+		for _, replacement := range replacements {
+			if replacement.from == "" {
+				continue
+			}
+			// Columns are updated as is, so that the records do not look modified
+			result := tx.Model(replacement.model).Where(replacement.column+" = ?", replacement.from).
+				UpdateColumn(replacement.column, replacement.to)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return r.pseudonymizePolicyVersions(tx, former, pseudonym)
+	})
+}
+
+// pseudonymizePolicyVersions replaces a user in the actor and the rules of the policy versions,
+// so that replaying the history still yields consistent policies
+func (r *PersonalDataRepository) pseudonymizePolicyVersions(tx *gorm.DB, former, pseudonym entity.DataSubject) error {
+	versions, err := r.findPolicyVersions(tx, former)
+	if err != nil {
+		return err
+	}
+
+	for _, model := range versions {
+		version := model.ToEntity()
+		if version.Actor == former.Actor {
+			version.Actor = pseudonym.Actor
+		}
+		replaceSubject(version.Added, former.Username, pseudonym.Username)
+		replaceSubject(version.Removed, former.Username, pseudonym.Username)
+
+		if err := model.FromEntity(version); err != nil {
+			return err
+		}
+		result := tx.Model(&models.PolicyVersion{}).Where("version = ?", model.Version).UpdateColumns(map[string]any{
+			"actor":   model.Actor,
+			"added":   model.Added,
+			"removed": model.Removed,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// hasSubjectRule reports whether any of the rules has the given subject
+func hasSubjectRule(rules []entity.PolicyRule, subject string) bool {
+	for _, rule := range rules {
+		if len(rule.Values) > 0 && rule.Values[0] == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceSubject replaces the subject of the rules having the given one
+func replaceSubject(rules []entity.PolicyRule, from, to string) {
+	for _, rule := range rules {
+		if len(rule.Values) > 0 && rule.Values[0] == from {
+			rule.Values[0] = to
+		}
+	}
+}
+
+// jsonString encodes a string as a JSON value, as it appears in JSON columns
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,142 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PolicyVersionRepository is the implementation of repository.PolicyVersionRepository
+type PolicyVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewPolicyVersionRepository creates a new PolicyVersionRepository
+func NewPolicyVersionRepository(db *gorm.DB) repository.PolicyVersionRepository {
+	return &PolicyVersionRepository{
+		db: db,
+	}
+}
+
+// Create records a new policy version and assigns its version number
+func (r *PolicyVersionRepository) Create(ctx context.Context, version *entity.PolicyVersion) error {
+	return r.create(r.db.WithContext(ctx), version)
+}
+
+// create records a policy version using the given connection or transaction
+func (r *PolicyVersionRepository) create(db *gorm.DB, version *entity.PolicyVersion) error {
+	model := &models.PolicyVersion{}
+	if err := model.FromEntity(version); err != nil {
+		return err
+	}
+
+	if err := db.Create(model).Error; err != nil {
+		return err
+	}
+
+	version.Version = model.Version
+	return nil
+}
+
+// GetByVersion retrieves a policy version by version number
+func (r *PolicyVersionRepository) GetByVersion(ctx context.Context, version uint) (*entity.PolicyVersion, error) {
+	var model models.PolicyVersion
+	result := r.db.WithContext(ctx).First(&model, version)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// GetLatestVersion retrieves the latest version number, or 0 if there is none
+func (r *PolicyVersionRepository) GetLatestVersion(ctx context.Context) (uint, error) {
+	var latest uint
+	result := r.db.WithContext(ctx).Model(&models.PolicyVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&latest)
+	return latest, result.Error
+}
+
+// List retrieves policy versions with pagination, newest first
+func (r *PolicyVersionRepository) List(ctx context.Context, offset, limit int) ([]*entity.PolicyVersion, int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.PolicyVersion{}).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []models.PolicyVersion
+	result := r.db.WithContext(ctx).Order("version DESC").Offset(offset).Limit(limit).Find(&models)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	versions := make([]*entity.PolicyVersion, len(models))
+	for i, model := range models {
+		versions[i] = model.ToEntity()
+	}
+
+	return versions, count, nil
+}
+
+// ListUpTo retrieves all policy versions up to and including a version, oldest first
+func (r *PolicyVersionRepository) ListUpTo(ctx context.Context, version uint) ([]*entity.PolicyVersion, error) {
+	var models []models.PolicyVersion
+	result := r.db.WithContext(ctx).Where("version <= ?", version).Order("version ASC").Find(&models)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	versions := make([]*entity.PolicyVersion, len(models))
+	for i, model := range models {
+		versions[i] = model.ToEntity()
+	}
+
+	return versions, nil
+}
+
+// ApplyChangeset writes the changeset's rules to the casbin_rule table and records
+// the version in a single transaction
+func (r *PolicyVersionRepository) ApplyChangeset(ctx context.Context, version *entity.PolicyVersion) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, rule := range version.Removed {
+			line := toCasbinRule(rule)
+			result := tx.Where(&line, "ptype", "v0", "v1", "v2", "v3", "v4", "v5").Delete(&gormadapter.CasbinRule{})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		for _, rule := range version.Added {
+			line := toCasbinRule(rule)
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&line).Error; err != nil {
+				return err
+			}
+		}
+
+		return r.create(tx, version)
+	})
+}
+
+// toCasbinRule converts a policy rule to a row of the Casbin GORM adapter table
+func toCasbinRule(rule entity.PolicyRule) gormadapter.CasbinRule {
+	values := make([]string, 6)
+	copy(values, rule.Values)
+
+	return gormadapter.CasbinRule{
+		Ptype: rule.PType,
+		V0:    values[0],
+		V1:    values[1],
+		V2:    values[2],
+		V3:    values[3],
+		V4:    values[4],
+		V5:    values[5],
+	}
+}
@@ -0,0 +1,215 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// searchIndexes are the trigram indexes backing the case-insensitive search of listings
+var searchIndexes = []string{
+	"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+	"CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON public.users USING gin (username gin_trgm_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON public.users USING gin (email gin_trgm_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_api_clients_name_trgm ON public.api_clients USING gin (name gin_trgm_ops)",
+}
+
+// scopeDeleted restricts a query to soft deleted rows when deleted is set, and to the others otherwise
+func scopeDeleted(db *gorm.DB, deleted bool) *gorm.DB {
+	if deleted {
+		return db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	return db
+}
+
+// scopeCreated restricts a query to rows created in a range, both ends being optional
+func scopeCreated(db *gorm.DB, from, to *time.Time) *gorm.DB {
+	if from != nil {
+		db = db.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		db = db.Where("created_at <= ?", *to)
+	}
+	return db
+}
+
+// searchPattern returns an ILIKE pattern matching the values containing a term,
+// which is matched literally
+func searchPattern(term string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(term) + "%"
+}
+
+// sortKeys returns the columns a listing is sorted by, ending with the ID as a tiebreaker
+// so that every row has a distinct position. Columns following the ID are left out.
+func sortKeys(sort []repository.SortField) []repository.SortField {
+	var keys []repository.SortField
+	for _, field := range sort {
+		keys = append(keys, field)
+		if field.Column == "id" {
+			return keys
+		}
+	}
+	return append(keys, repository.SortField{Column: "id"})
+}
+
+// timeColumns are the sortable columns holding times
+var timeColumns = map[string]bool{"created_at": true, "updated_at": true}
+
+// pageCursor is the content of a cursor: the sort keys of a listing and their values for a row
+type pageCursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+// encodeCursor returns the opaque cursor pointing at a row
+func encodeCursor(keys []repository.SortField, values []any) string {
+	// Marshaling strings, booleans, times and numbers cannot fail
+	data, _ := json.Marshal(pageCursor{Sort: repository.FormatSort(keys), Values: values})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the values of the sort keys a cursor points at, typed as the columns they belong to
+func decodeCursor(value string, keys []repository.SortField) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, repository.ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, repository.ErrInvalidCursor
+	}
+	if cursor.Sort != repository.FormatSort(keys) || len(cursor.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: it was issued for another sort", repository.ErrInvalidCursor)
+	}
+
+	for i, key := range keys {
+		switch v := cursor.Values[i].(type) {
+		case float64:
+			if key.Column != "id" {
+				return nil, repository.ErrInvalidCursor
+			}
+			cursor.Values[i] = uint(v)
+		case string:
+			if timeColumns[key.Column] {
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return nil, repository.ErrInvalidCursor
+				}
+				cursor.Values[i] = t
+			}
+		case bool:
+		default:
+			return nil, repository.ErrInvalidCursor
+		}
+	}
+	return cursor.Values, nil
+}
+
+// keysetCondition returns the condition selecting the rows after the values of the sort keys,
+// or before them when backward is set: (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for
+// descending keys. The columns must have been checked against a whitelist by repository.ParseSort.
+func keysetCondition(keys []repository.SortField, values []any, backward bool) (string, []any) {
+	var disjuncts []string
+	var args []any
+	for i, key := range keys {
+		var conjuncts []string
+		for j := 0; j < i; j++ {
+			conjuncts = append(conjuncts, keys[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+
+		operator := " > ?"
+		if key.Descending != backward {
+			operator = " < ?"
+		}
+		conjuncts = append(conjuncts, key.Column+operator)
+		args = append(args, values[i])
+
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+	return strings.Join(disjuncts, " OR "), args
+}
+
+// paginate retrieves the page of rows of a query given by a page query, value returning the value of
+// a sort column for a row. The rows matching the query are counted first unless the count is skipped.
+func paginate[T any](db *gorm.DB, query repository.PageQuery, value func(row *T, column string) any) ([]T, *repository.Page, error) {
+	page := &repository.Page{}
+	if !query.SkipCount {
+		var count int64
+		if err := db.Count(&count).Error; err != nil {
+			return nil, nil, err
+		}
+		page.TotalCount = &count
+	}
+
+	keys := sortKeys(query.Sort)
+	cursor, backward := query.After, false
+	if cursor == "" && query.Before != "" {
+		cursor, backward = query.Before, true
+	}
+
+	if cursor != "" {
+		values, err := decodeCursor(cursor, keys)
+		if err != nil {
+			return nil, nil, err
+		}
+		condition, args := keysetCondition(keys, values, backward)
+		db = db.Where(condition, args...)
+	}
+
+	// Pages before a cursor are read in reverse order, from the cursor on
+	for _, key := range keys {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: key.Column}, Desc: key.Descending != backward})
+	}
+
+	// One more row than the limit tells whether there is a page beyond this one
+	var rows []T
+	if err := db.Limit(query.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	more := len(rows) > query.Limit
+	if more {
+		rows = rows[:query.Limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, page, nil
+	}
+
+	rowCursor := func(row *T) string {
+		values := make([]any, len(keys))
+		for i, key := range keys {
+			values[i] = value(row, key.Column)
+		}
+		return encodeCursor(keys, values)
+	}
+
+	// There are rows beyond the page when more were found, and behind it when coming from a cursor
+	first, last := &rows[0], &rows[len(rows)-1]
+	if backward {
+		first, last = last, first
+	}
+	if more {
+		page.Next = rowCursor(last)
+	}
+	if cursor != "" {
+		page.Prev = rowCursor(first)
+	}
+	if backward {
+		page.Next, page.Prev = page.Prev, page.Next
+	}
+
+	return rows, page, nil
+}
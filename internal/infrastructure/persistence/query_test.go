@@ -0,0 +1,112 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	keys := []repository.SortField{{Column: "created_at", Descending: true}, {Column: "id"}}
+	created := time.Date(2024, time.March, 1, 10, 30, 0, 123456789, time.UTC)
+
+	values, err := decodeCursor(encodeCursor(keys, []any{created, uint(7)}), keys)
+	if err != nil || !reflect.DeepEqual(values, []any{created, uint(7)}) {
+		t.Errorf("decodeCursor = %#v, %v, want the encoded values", values, err)
+	}
+
+	for _, cursor := range []string{
+		"not a cursor!",
+		encodeCursor([]repository.SortField{{Column: "id"}}, []any{uint(1)}),
+		encodeCursor(keys, []any{created}),
+		encodeCursor(keys, []any{"yesterday", uint(1)}),
+	} {
+		if _, err := decodeCursor(cursor, keys); !errors.Is(err, repository.ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q) error = %v, want %v", cursor, err, repository.ErrInvalidCursor)
+		}
+	}
+}
+
+// pageItem is a row of the listing paginated by TestPaginate
+type pageItem struct {
+	ID    uint `gorm:"primaryKey"`
+	Label string
+}
+
+func TestPaginate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&pageItem{}); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	// Labels repeat so that the ID breaks ties
+	for i := 1; i <= 7; i++ {
+		if err := db.Create(&pageItem{ID: uint(i), Label: fmt.Sprintf("g%d", i%3)}).Error; err != nil {
+			t.Fatalf("failed to insert row: %v", err)
+		}
+	}
+	value := func(item *pageItem, column string) any {
+		if column == "label" {
+			return item.Label
+		}
+		return item.ID
+	}
+
+	tests := []struct {
+		name string
+		sort []repository.SortField
+		want [][]uint
+	}{
+		{name: "by id", want: [][]uint{{1, 2, 3}, {4, 5, 6}, {7}}},
+		{name: "by label then id", sort: []repository.SortField{{Column: "label"}}, want: [][]uint{{3, 6, 1}, {4, 7, 2}, {5}}},
+		{name: "by label descending then id", sort: []repository.SortField{{Column: "label", Descending: true}}, want: [][]uint{{2, 5, 1}, {4, 7, 3}, {6}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := repository.PageQuery{Sort: tt.sort, Limit: 3}
+			var prev string
+
+			// Forward through the pages following the next cursors, then back one page
+			for i, want := range tt.want {
+				rows, page, err := paginate(db.Model(&pageItem{}), query, value)
+				if err != nil {
+					t.Fatalf("page %d: paginate failed: %v", i, err)
+				}
+				if got := pageItemIDs(rows); !reflect.DeepEqual(got, want) {
+					t.Fatalf("page %d = %v, want %v", i, got, want)
+				}
+				if (page.Next == "") != (i == len(tt.want)-1) {
+					t.Errorf("page %d: next = %q", i, page.Next)
+				}
+				query.After, prev = page.Next, page.Prev
+			}
+
+			rows, _, err := paginate(db.Model(&pageItem{}), repository.PageQuery{Sort: tt.sort, Limit: 3, Before: prev}, value)
+			if err != nil {
+				t.Fatalf("paginate backward failed: %v", err)
+			}
+			if got, want := pageItemIDs(rows), tt.want[len(tt.want)-2]; !reflect.DeepEqual(got, want) {
+				t.Errorf("previous page = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// pageItemIDs returns the IDs of rows
+func pageItemIDs(rows []pageItem) []uint {
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids
+}
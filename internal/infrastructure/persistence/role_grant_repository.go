@@ -0,0 +1,131 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+)
+
+// RoleGrantRepository is the implementation of repository.RoleGrantRepository
+type RoleGrantRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleGrantRepository creates a new RoleGrantRepository
+func NewRoleGrantRepository(db *gorm.DB) repository.RoleGrantRepository {
+	return &RoleGrantRepository{
+		db: db,
+	}
+}
+
+// Create creates a new role grant
+func (r *RoleGrantRepository) Create(ctx context.Context, grant *entity.RoleGrant) error {
+	model := &models.RoleGrant{}
+	model.FromEntity(grant)
+
+	result := r.db.WithContext(ctx).Create(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	grant.ID = model.ID
+	return nil
+}
+
+// GetByID retrieves a role grant by ID
+func (r *RoleGrantRepository) GetByID(ctx context.Context, id uint) (*entity.RoleGrant, error) {
+	var model models.RoleGrant
+	result := r.db.WithContext(ctx).First(&model, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// Update updates a role grant
+func (r *RoleGrantRepository) Update(ctx context.Context, grant *entity.RoleGrant) error {
+	model := &models.RoleGrant{}
+	model.FromEntity(grant)
+	model.ID = grant.ID
+
+	result := r.db.WithContext(ctx).Save(model)
+	return result.Error
+}
+
+// RenameSubject moves the grants of a subject, whatever their status, to another subject
+func (r *RoleGrantRepository) RenameSubject(ctx context.Context, from, to string) error {
+	result := r.db.WithContext(ctx).Model(&models.RoleGrant{}).Where("subject = ?", from).Update("subject", to)
+	return result.Error
+}
+
+// List retrieves role grants matching a filter with pagination, newest first
+func (r *RoleGrantRepository) List(ctx context.Context, filter repository.RoleGrantFilter, offset, limit int) ([]*entity.RoleGrant, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.RoleGrant{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Subject != "" {
+		query = query.Where("subject = ?", filter.Subject)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []models.RoleGrant
+	result := query.Order("id DESC").Offset(offset).Limit(limit).Find(&models)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	grants := make([]*entity.RoleGrant, len(models))
+	for i, model := range models {
+		grants[i] = model.ToEntity()
+	}
+
+	return grants, count, nil
+}
+
+// ListDue retrieves the grants to activate or expire at the given time
+func (r *RoleGrantRepository) ListDue(ctx context.Context, now time.Time) ([]*entity.RoleGrant, error) {
+	var models []models.RoleGrant
+	result := r.db.WithContext(ctx).
+		Where("status = ? AND starts_at <= ?", entity.RoleGrantStatusApproved, now).
+		Or("status IN ? AND expires_at <= ?", []string{
+			entity.RoleGrantStatusPending,
+			entity.RoleGrantStatusApproved,
+			entity.RoleGrantStatusActive,
+		}, now).
+		Order("id").
+		Find(&models)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	grants := make([]*entity.RoleGrant, len(models))
+	for i, model := range models {
+		grants[i] = model.ToEntity()
+	}
+
+	return grants, nil
+}
+
+// CountActive counts the active grants of a role to a subject in a domain, excluding a grant
+func (r *RoleGrantRepository) CountActive(ctx context.Context, subject, role, domain string, excludeID uint) (int64, error) {
+	var count int64
+	result := r.db.WithContext(ctx).Model(&models.RoleGrant{}).
+		Where("subject = ? AND role = ? AND domain = ? AND status = ? AND id <> ?",
+			subject, role, domain, entity.RoleGrantStatusActive, excludeID).
+		Count(&count)
+	return count, result.Error
+}
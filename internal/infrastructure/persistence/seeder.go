@@ -5,23 +5,27 @@ import (
 	"errors"
 	"github.com/hinha/echo-casbin-ddd-app/internal/config"
 	"log"
+	"os"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
 	"github.com/hinha/echo-casbin-ddd-app/pkg/argon2"
 )
 
 // Seeder handles database seeding
 type Seeder struct {
-	cfg      *config.Config
-	userRepo repository.UserRepository
+	cfg           *config.Config
+	userRepo      repository.UserRepository
+	casbinService *auth.CasbinService
 }
 
 // NewSeeder creates a new seeder
-func NewSeeder(cfg *config.Config, userRepo repository.UserRepository) *Seeder {
+func NewSeeder(cfg *config.Config, userRepo repository.UserRepository, casbinService *auth.CasbinService) *Seeder {
 	return &Seeder{
-		cfg:      cfg,
-		userRepo: userRepo,
+		cfg:           cfg,
+		userRepo:      userRepo,
+		casbinService: casbinService,
 	}
 }
 
@@ -71,11 +75,63 @@ func (s *Seeder) SeedUsers(ctx context.Context) error {
 		log.Println("Admin user created and verified successfully")
 	}
 
+	// Make sure the admin user holds the superadmin role
+	added, err := s.casbinService.AddRoleForUser(ctx, s.cfg.Server.InitialAdminUsername, "superadmin", auth.DomainDefault)
+	if err != nil {
+		return err
+	}
+	if added {
+		log.Println("Superadmin role granted to admin user")
+	}
+
+	return nil
+}
+
+// SeedPolicies adds the bootstrap policies that are not in the database yet.
+// Existing rules are never removed, so running it again is a no-op.
+func (s *Seeder) SeedPolicies(ctx context.Context) error {
+	path := s.cfg.Casbin.BootstrapPolicyPath
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("Bootstrap policy file %s not found, skipping", path)
+			return nil
+		}
+		return err
+	}
+
+	format, err := auth.PolicyFormatFromPath(path)
+	if err != nil {
+		return err
+	}
+
+	desired, err := auth.ParsePolicies(data, format)
+	if err != nil {
+		return err
+	}
+
+	desired = s.casbinService.NormalizePolicies(desired)
+	diff, err := s.casbinService.UpdatePolicies(ctx, entity.PolicyActionBootstrap, func(current []entity.PolicyRule) (auth.PolicyDiff, error) {
+		return auth.DiffPolicies(current, desired, false), nil
+	})
+	if err != nil || diff.IsEmpty() {
+		return err
+	}
+
+	log.Printf("Added %d bootstrap policy rules", len(diff.Added))
 	return nil
 }
 
 // Seed seeds all initial data
 func (s *Seeder) Seed(ctx context.Context) error {
+	if err := s.SeedPolicies(ctx); err != nil {
+		return err
+	}
+
 	if err := s.SeedUsers(ctx); err != nil {
 		return err
 	}
@@ -0,0 +1,80 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+)
+
+// UserAttributeRepository is the implementation of repository.UserAttributeRepository
+type UserAttributeRepository struct {
+	db *gorm.DB
+}
+
+// NewUserAttributeRepository creates a new UserAttributeRepository
+func NewUserAttributeRepository(db *gorm.DB) repository.UserAttributeRepository {
+	return &UserAttributeRepository{
+		db: db,
+	}
+}
+
+// Create creates a new attribute definition
+func (r *UserAttributeRepository) Create(ctx context.Context, attribute *entity.UserAttribute) error {
+	model := &models.UserAttribute{}
+	model.FromEntity(attribute)
+
+	result := r.db.WithContext(ctx).Create(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	attribute.ID = model.ID
+	return nil
+}
+
+// GetByName retrieves an attribute definition by name
+func (r *UserAttributeRepository) GetByName(ctx context.Context, name string) (*entity.UserAttribute, error) {
+	var model models.UserAttribute
+	result := r.db.WithContext(ctx).Where("name = ?", name).First(&model)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// Update updates an attribute definition
+func (r *UserAttributeRepository) Update(ctx context.Context, attribute *entity.UserAttribute) error {
+	model := &models.UserAttribute{}
+	model.FromEntity(attribute)
+
+	result := r.db.WithContext(ctx).Save(model)
+	return result.Error
+}
+
+// Delete deletes an attribute definition
+func (r *UserAttributeRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.UserAttribute{}, id)
+	return result.Error
+}
+
+// List retrieves every attribute definition, sorted by name
+func (r *UserAttributeRepository) List(ctx context.Context) ([]*entity.UserAttribute, error) {
+	var models []models.UserAttribute
+	if err := r.db.WithContext(ctx).Order("name").Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	attributes := make([]*entity.UserAttribute, len(models))
+	for i, model := range models {
+		attributes[i] = model.ToEntity()
+	}
+	return attributes, nil
+}
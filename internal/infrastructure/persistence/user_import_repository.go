@@ -0,0 +1,98 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/persistence/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserImportRepository is the implementation of repository.UserImportRepository
+type UserImportRepository struct {
+	db *gorm.DB
+}
+
+// NewUserImportRepository creates a new UserImportRepository
+func NewUserImportRepository(db *gorm.DB) repository.UserImportRepository {
+	return &UserImportRepository{
+		db: db,
+	}
+}
+
+// Create creates a new user import
+func (r *UserImportRepository) Create(ctx context.Context, userImport *entity.UserImport) error {
+	model := &models.UserImport{}
+	model.FromEntity(userImport)
+
+	result := r.db.WithContext(ctx).Create(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	userImport.ID = model.ID
+	return nil
+}
+
+// GetByID retrieves a user import by ID, without the content of its file
+func (r *UserImportRepository) GetByID(ctx context.Context, id uint) (*entity.UserImport, error) {
+	var model models.UserImport
+	result := r.db.WithContext(ctx).Omit("data").First(&model, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// Update updates the status and progress of a user import, leaving its file untouched until the import
+// is finished, when the file is cleared
+func (r *UserImportRepository) Update(ctx context.Context, userImport *entity.UserImport) error {
+	model := &models.UserImport{}
+	model.FromEntity(userImport)
+	model.ID = userImport.ID
+
+	// The file is written once created, and only cleared once the import is finished
+	db := r.db.WithContext(ctx)
+	if userImport.FinishedAt == nil {
+		db = db.Omit("data")
+	}
+
+	result := db.Save(model)
+	return result.Error
+}
+
+// ClaimNext starts the oldest pending import, or a running one not updated since staleBefore
+func (r *UserImportRepository) ClaimNext(ctx context.Context, staleBefore time.Time) (*entity.UserImport, error) {
+	var claimed *entity.UserImport
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var model models.UserImport
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)", entity.UserImportStatusPending, entity.UserImportStatusRunning, staleBefore).
+			Order("id").
+			First(&model)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return result.Error
+		}
+
+		claimed = model.ToEntity()
+		claimed.Start()
+		model.FromEntity(claimed)
+		return tx.Omit("data").Save(&model).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
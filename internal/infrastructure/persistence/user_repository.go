@@ -3,6 +3,7 @@ package persistence
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
@@ -27,13 +28,22 @@ func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
 	model := &models.User{}
 	model.FromEntity(user)
 	model.ID = 0 // Ensure ID is not set for creation
+	model.Version = 1
 
 	result := r.db.WithContext(ctx).Create(model)
 	if result.Error != nil {
 		return result.Error
 	}
 
+	// The column default replaces false on insert, making inactive users active
+	if !user.Active {
+		if err := r.db.WithContext(ctx).Model(model).Update("active", false).Error; err != nil {
+			return err
+		}
+	}
+
 	user.ID = model.ID
+	user.Version = model.Version
 	return nil
 }
 
@@ -79,33 +89,80 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*entity.
 	return model.ToEntity(), nil
 }
 
-// Update updates a user
+// GetByEmailChangeTokenHash retrieves the user whose email change has a token of the given hash
+func (r *UserRepository) GetByEmailChangeTokenHash(ctx context.Context, tokenHash string) (*entity.User, error) {
+	var model models.User
+	result := r.db.WithContext(ctx).Where("email_change_token_hash = ?", tokenHash).First(&model)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return model.ToEntity(), nil
+}
+
+// EmailExists reports whether a user, soft deleted or not, holds an email. Soft deleted users
+// keep their email, which the unique index does not let anyone else take.
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	var count int64
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+// UsernameExists reports whether a user, soft deleted or not, holds a username. Soft deleted users
+// keep their username, which the unique index does not let anyone else take.
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	var count int64
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+// Update updates a user at the version it was read at and increments its version
 func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
 	model := &models.User{}
 	model.FromEntity(user)
 	model.ID = user.ID
+	model.Version = user.Version + 1
 
-	result := r.db.WithContext(ctx).Save(model)
-	return result.Error
+	if err := updateVersioned(r.db.WithContext(ctx), model, user.ID, user.Version); err != nil {
+		return err
+	}
+
+	user.Version = model.Version
+	return nil
 }
 
-// Delete soft deletes a user
-func (r *UserRepository) Delete(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).Delete(&models.User{}, id)
-	return result.Error
+// Delete soft deletes a user at the given version
+func (r *UserRepository) Delete(ctx context.Context, id uint, version uint) error {
+	return deleteVersioned(r.db.WithContext(ctx), &models.User{}, id, version)
 }
 
-// List retrieves all users with pagination
-func (r *UserRepository) List(ctx context.Context, offset, limit int) ([]*entity.User, int64, error) {
-	var count int64
-	if err := r.db.WithContext(ctx).Model(&models.User{}).Count(&count).Error; err != nil {
-		return nil, 0, err
+// List retrieves the users matching a query, along with where their page stands
+func (r *UserRepository) List(ctx context.Context, query repository.UserQuery) ([]*entity.User, *repository.Page, error) {
+	db := scopeDeleted(r.db.WithContext(ctx).Model(&models.User{}), query.Deleted)
+	if query.Role != "" {
+		db = db.Where("role = ?", query.Role)
+	}
+	if query.Active != nil {
+		db = db.Where("active = ?", *query.Active)
+	}
+	db = scopeCreated(db, query.CreatedFrom, query.CreatedTo)
+	if query.Search != "" {
+		pattern := searchPattern(query.Search)
+		db = db.Where("username ILIKE ? OR email ILIKE ?", pattern, pattern)
 	}
 
-	var models []models.User
-	result := r.db.WithContext(ctx).Offset(offset).Limit(limit).Find(&models)
-	if result.Error != nil {
-		return nil, 0, result.Error
+	models, page, err := paginate(db, query.PageQuery, (*models.User).SortValue)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	users := make([]*entity.User, len(models))
@@ -113,7 +170,7 @@ func (r *UserRepository) List(ctx context.Context, offset, limit int) ([]*entity
 		users[i] = model.ToEntity()
 	}
 
-	return users, count, nil
+	return users, page, nil
 }
 
 // GetDeletedByID retrieves a soft deleted user by ID
@@ -130,27 +187,6 @@ func (r *UserRepository) GetDeletedByID(ctx context.Context, id uint) (*entity.U
 	return model.ToEntity(), nil
 }
 
-// ListDeleted retrieves all soft deleted users with pagination
-func (r *UserRepository) ListDeleted(ctx context.Context, offset, limit int) ([]*entity.User, int64, error) {
-	var count int64
-	if err := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL").Count(&count).Error; err != nil {
-		return nil, 0, err
-	}
-
-	var models []models.User
-	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Offset(offset).Limit(limit).Find(&models)
-	if result.Error != nil {
-		return nil, 0, result.Error
-	}
-
-	users := make([]*entity.User, len(models))
-	for i, model := range models {
-		users[i] = model.ToEntity()
-	}
-
-	return users, count, nil
-}
-
 // Restore restores a soft deleted user
 func (r *UserRepository) Restore(ctx context.Context, id uint) error {
 	result := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("id = ?", id).Update("deleted_at", nil)
@@ -168,3 +204,26 @@ func (r *UserRepository) PermanentDelete(ctx context.Context, id uint) error {
 	result := r.db.WithContext(ctx).Unscoped().Delete(&models.User{}, id)
 	return result.Error
 }
+
+// GetTokensRevokedAt retrieves the time at or before which the tokens of a user, deleted or not,
+// were issued to be rejected, or nil when none are revoked. It returns entity.ErrUserNotFound
+// once the user is purged.
+func (r *UserRepository) GetTokensRevokedAt(ctx context.Context, id uint) (*time.Time, error) {
+	var model models.User
+	result := r.db.WithContext(ctx).Unscoped().Select("tokens_revoked_at").Where("id = ?", id).Limit(1).Find(&model)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, entity.ErrUserNotFound
+	}
+	return model.TokensRevokedAt, nil
+}
+
+// RemoveAttribute removes a custom attribute from every user, deleted ones included
+func (r *UserRepository) RemoveAttribute(ctx context.Context, name string) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("jsonb_exists(attributes, ?)", name).
+		UpdateColumn("attributes", gorm.Expr("attributes - ?", name))
+	return result.Error
+}
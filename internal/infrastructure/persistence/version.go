@@ -0,0 +1,31 @@
+package persistence
+
+import (
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+// updateVersioned saves every column of a model but its creation time, provided the record is
+// still at the version it was read at. The model is expected to carry the next version already.
+func updateVersioned(db *gorm.DB, model any, id, version uint) error {
+	result := db.Model(model).Where("id = ? AND version = ?", id, version).Select("*").Omit("created_at").Updates(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrVersionConflict
+	}
+	return nil
+}
+
+// deleteVersioned soft deletes a record, provided it is still at the given version
+func deleteVersioned(db *gorm.DB, model any, id, version uint) error {
+	result := db.Where("version = ?", version).Delete(model, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+)
+
+// ErrBlobNotFound is returned when no blob is stored under a key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Blob store drivers
+const (
+	// DriverLocal stores blobs as files in a directory
+	DriverLocal = "local"
+)
+
+// Blob is a stored file
+type Blob struct {
+	Data        []byte
+	ContentType string
+}
+
+// BlobStore stores files under keys such as avatars/1/3f2a.png.
+// Keys are slash separated paths, relative to the root of the store.
+type BlobStore interface {
+	// Put stores a blob, replacing any blob stored under the same key
+	Put(ctx context.Context, key string, blob Blob) error
+	// Get retrieves a blob, or ErrBlobNotFound
+	Get(ctx context.Context, key string) (*Blob, error)
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore creates the blob store selected by the configuration
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.Storage.Driver {
+	case DriverLocal, "":
+		return NewLocalBlobStore(cfg.Storage.LocalPath)
+	default:
+		return nil, fmt.Errorf("unknown blob store driver %q, expected %s", cfg.Storage.Driver, DriverLocal)
+	}
+}
+
+// LocalBlobStore stores blobs as files in a directory. The content type of a blob
+// is derived from the extension of its key.
+type LocalBlobStore struct {
+	root string
+}
+
+// NewLocalBlobStore creates a new LocalBlobStore, creating its directory if needed
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &LocalBlobStore{root: root}, nil
+}
+
+// path returns the file of a key, rejecting keys escaping the root
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean != "/"+key || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// Put stores a blob in a file, written to a temporary file first so that it is never read half written
+func (s *LocalBlobStore) Put(_ context.Context, key string, blob Blob) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(blob.Data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Get reads a blob from its file
+func (s *LocalBlobStore) Get(_ context.Context, key string) (*Blob, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &Blob{Data: data, ContentType: contentType}, nil
+}
+
+// Delete removes the file of a blob
+func (s *LocalBlobStore) Delete(_ context.Context, key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
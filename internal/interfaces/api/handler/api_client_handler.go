@@ -1,12 +1,17 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
 	"github.com/labstack/echo/v4"
 )
 
@@ -32,23 +37,58 @@ type APIClientResponse struct {
 	ID          uint   `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	APIKey      string `json:"api_key"`
-	Active      bool   `json:"active"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	// APIKey is only given when the client is created and when its key is regenerated
+	APIKey    string `json:"api_key,omitempty"`
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+	// Version is incremented by every update, it is also given as the ETag of a single client
+	Version uint `json:"version"`
+	// Policies are the Casbin rules a deleted client gets back when restored
+	Policies []string `json:"policies,omitempty"`
 }
 
-// toAPIClientResponse converts an API client entity to an API client response
+// toAPIClientResponse converts an API client entity to an API client response, without its API key
 func toAPIClientResponse(client *entity.APIClient) *APIClientResponse {
-	return &APIClientResponse{
+	resp := &APIClientResponse{
 		ID:          client.ID,
 		Name:        client.Name,
 		Description: client.Description,
-		APIKey:      client.APIKey,
 		Active:      client.Active,
 		CreatedAt:   client.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:   client.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Version:     client.Version,
 	}
+	if client.DeletedAt != nil {
+		resp.DeletedAt = client.DeletedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	for _, rule := range client.PolicySnapshot {
+		resp.Policies = append(resp.Policies, rule.String())
+	}
+	return resp
+}
+
+// toAPIClientKeyResponse converts an API client entity to an API client response holding its API key
+func toAPIClientKeyResponse(client *entity.APIClient) *APIClientResponse {
+	resp := toAPIClientResponse(client)
+	resp.APIKey = client.APIKey
+	return resp
+}
+
+// apiClientErrorResponse maps an API client error to a response
+func apiClientErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrAPIClientNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrInvalidAPIClient), errors.Is(err, entity.ErrInvalidPatch):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrFieldChangeNotAllowed):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 }
 
 // CreateRequest represents the request for creating an API client
@@ -59,12 +99,12 @@ type CreateAPIClientRequest struct {
 
 // Create handles creating an API client
 // @Summary Create a new API client
-// @Description Create a new API client with the provided details
+// @Description Create a new API client with the provided details. The name cannot be a role or the subject of a policy rule.
 // @Tags api-clients
 // @Accept json
 // @Produce json
 // @Param request body CreateAPIClientRequest true "API Client creation request"
-// @Success 201 {object} APIClientResponse "Created API client"
+// @Success 201 {object} APIClientResponse "Created API client with its API key"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router / [post]
@@ -85,20 +125,22 @@ func (h *APIClientHandler) Create(c echo.Context) error {
 
 	output, err := h.apiClientUseCase.Create(c.Request().Context(), input)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apiClientErrorResponse(c, err)
 	}
 
-	return c.JSON(http.StatusCreated, toAPIClientResponse(output.APIClient))
+	setVersionETag(c, output.APIClient.Version)
+	return c.JSON(http.StatusCreated, toAPIClientKeyResponse(output.APIClient))
 }
 
 // GetByID handles getting an API client by ID
 // @Summary Get an API client by ID
-// @Description Retrieve an API client by its ID
+// @Description Retrieve an API client by its ID. The ETag is to be sent back in If-Match to update or delete the client.
 // @Tags api-clients
 // @Accept json
 // @Produce json
 // @Param id path int true "API Client ID"
 // @Success 200 {object} APIClientResponse "API client details"
+// @Header 200 {string} ETag "Version of the API client"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 404 {object} map[string]string "API client not found"
 // @Failure 500 {object} map[string]string "Internal server error"
@@ -122,6 +164,7 @@ func (h *APIClientHandler) GetByID(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "API client not found"})
 	}
 
+	setVersionETag(c, client.Version)
 	return c.JSON(http.StatusOK, toAPIClientResponse(client))
 }
 
@@ -133,14 +176,19 @@ type UpdateAPIClientRequest struct {
 
 // Update handles updating an API client
 // @Summary Update an API client
-// @Description Update an existing API client with the provided details
+// @Description Update an existing API client with the provided details. The update only applies to the version of the client given in If-Match.
 // @Tags api-clients
 // @Accept json
 // @Produce json
 // @Param id path int true "API Client ID"
+// @Param If-Match header string true "ETag of the API client as fetched, or * for whichever version is current"
 // @Param request body UpdateAPIClientRequest true "API Client update request"
 // @Success 200 {object} APIClientResponse "Updated API client"
+// @Header 200 {string} ETag "New version of the API client"
 // @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "API client not found"
+// @Failure 412 {object} map[string]string "API client updated since the version in If-Match"
+// @Failure 428 {object} map[string]string "If-Match header missing"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /{id} [put]
 func (h *APIClientHandler) Update(c echo.Context) error {
@@ -158,20 +206,89 @@ func (h *APIClientHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return ifMatchErrorResponse(c, err)
+	}
+
 	input := dto.UpdateAPIClientInput{
 		ID:          uint(id),
 		Name:        req.Name,
 		Description: req.Description,
+		Version:     version,
 	}
 
 	client, err := h.apiClientUseCase.Update(c.Request().Context(), input)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return conditionalErrorResponse(c, err, apiClientErrorResponse)
 	}
 
+	setVersionETag(c, client.Version)
 	return c.JSON(http.StatusOK, toAPIClientResponse(client))
 }
 
+// PatchAPIClientResponse represents the response for patching an API client
+type PatchAPIClientResponse struct {
+	APIClient *APIClientResponse `json:"api_client"`
+	// Changes are the fields the patch changed, for auditing
+	Changes []*FieldChangeResponse `json:"changes"`
+}
+
+// Patch handles partially updating an API client
+// @Summary Patch an API client
+// @Description Partially update an API client with a JSON merge patch (RFC 7396) of its name, description and active: members replace the fields and null clears the description. Changing active needs a rule granting update on fields/api_clients/active in the api domain. The patch only applies to the version of the client given in If-Match.
+// @Tags api-clients
+// @Accept json
+// @Produce json
+// @Param id path int true "API Client ID"
+// @Param If-Match header string true "ETag of the API client as fetched, or * for whichever version is current"
+// @Param request body object true "JSON merge patch, sent as application/merge-patch+json"
+// @Success 200 {object} PatchAPIClientResponse "Patched API client and the fields changed"
+// @Header 200 {string} ETag "New version of the API client"
+// @Failure 400 {object} map[string]string "Bad request, or a patch setting unknown fields or invalid values"
+// @Failure 403 {object} map[string]string "Not allowed to change a field"
+// @Failure 404 {object} map[string]string "API client not found"
+// @Failure 412 {object} map[string]string "API client updated since the version in If-Match"
+// @Failure 415 {object} map[string]string "Not a JSON merge patch"
+// @Failure 428 {object} map[string]string "If-Match header missing"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id} [patch]
+func (h *APIClientHandler) Patch(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid API client ID"})
+	}
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		return mergePatchErrorResponse(c, err)
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return ifMatchErrorResponse(c, err)
+	}
+
+	input := dto.PatchAPIClientInput{
+		ID:      uint(id),
+		Patch:   patch,
+		Version: version,
+	}
+
+	output, err := h.apiClientUseCase.Patch(c.Request().Context(), input)
+	if err != nil {
+		return conditionalErrorResponse(c, err, apiClientErrorResponse)
+	}
+
+	resp := PatchAPIClientResponse{
+		APIClient: toAPIClientResponse(output.APIClient),
+		Changes:   toFieldChangesResponse(output.Changes),
+	}
+
+	setVersionETag(c, output.APIClient.Version)
+	return c.JSON(http.StatusOK, resp)
+}
+
 // RegenerateAPIKey handles regenerating an API key for an API client
 // @Summary Regenerate API key
 // @Description Regenerate the API key for an existing API client
@@ -181,6 +298,8 @@ func (h *APIClientHandler) Update(c echo.Context) error {
 // @Param id path int true "API Client ID"
 // @Success 200 {object} APIClientResponse "API client with new API key"
 // @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "API client not found"
+// @Failure 409 {object} map[string]string "API client updated concurrently"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /{id}/regenerate-key [post]
 func (h *APIClientHandler) RegenerateAPIKey(c echo.Context) error {
@@ -195,10 +314,11 @@ func (h *APIClientHandler) RegenerateAPIKey(c echo.Context) error {
 
 	client, err := h.apiClientUseCase.RegenerateAPIKey(c.Request().Context(), input)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apiClientErrorResponse(c, err)
 	}
 
-	return c.JSON(http.StatusOK, toAPIClientResponse(client))
+	setVersionETag(c, client.Version)
+	return c.JSON(http.StatusOK, toAPIClientKeyResponse(client))
 }
 
 // SetActiveRequest represents the request for setting an API client's active status
@@ -216,6 +336,8 @@ type SetAPIClientActiveRequest struct {
 // @Param request body SetAPIClientActiveRequest true "Set active status request"
 // @Success 200 {object} APIClientResponse "Updated API client"
 // @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "API client not found"
+// @Failure 409 {object} map[string]string "API client updated concurrently"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /{id}/set-active [post]
 func (h *APIClientHandler) SetActive(c echo.Context) error {
@@ -236,21 +358,26 @@ func (h *APIClientHandler) SetActive(c echo.Context) error {
 
 	client, err := h.apiClientUseCase.SetActive(c.Request().Context(), input)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apiClientErrorResponse(c, err)
 	}
 
+	setVersionETag(c, client.Version)
 	return c.JSON(http.StatusOK, toAPIClientResponse(client))
 }
 
 // Delete handles deleting an API client
 // @Summary Delete an API client
-// @Description Delete an existing API client
+// @Description Delete an existing API client, provided it is still at the version given in If-Match
 // @Tags api-clients
 // @Accept json
 // @Produce json
 // @Param id path int true "API Client ID"
+// @Param If-Match header string true "ETag of the API client as fetched, or * for whichever version is current"
 // @Success 200 {object} map[string]string "Success message"
 // @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "API client not found"
+// @Failure 412 {object} map[string]string "API client updated since the version in If-Match"
+// @Failure 428 {object} map[string]string "If-Match header missing"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /{id} [delete]
 func (h *APIClientHandler) Delete(c echo.Context) error {
@@ -259,12 +386,18 @@ func (h *APIClientHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid API client ID"})
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return ifMatchErrorResponse(c, err)
+	}
+
 	input := dto.DeleteAPIClientInput{
-		ID: uint(id),
+		ID:      uint(id),
+		Version: version,
 	}
 
 	if err := h.apiClientUseCase.Delete(c.Request().Context(), input); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return conditionalErrorResponse(c, err, apiClientErrorResponse)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "API client deleted successfully"})
@@ -273,38 +406,99 @@ func (h *APIClientHandler) Delete(c echo.Context) error {
 // ListAPIClientsResponse represents the response for listing API clients
 type ListAPIClientsResponse struct {
 	APIClients []*APIClientResponse `json:"api_clients"`
-	TotalCount int64                `json:"total_count"`
+	// Next and Prev link to the following and preceding pages, when there are
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	TotalCount *int64 `json:"total_count,omitempty"`
 }
 
 // List handles listing API clients
 // @Summary List API clients
-// @Description Get a paginated list of API clients
+// @Description Get a paginated list of API clients, optionally filtered, searched and sorted
 // @Tags api-clients
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number (default: 1)"
-// @Param limit query int false "Items per page (default: 10)"
+// @Param after query string false "Cursor from the next link of a previous page, to list the API clients following it"
+// @Param before query string false "Cursor from the prev link of a previous page, to list the API clients preceding it"
+// @Param limit query int false "Items per page (default: 10, at most 100)"
+// @Param count query bool false "Set to false to leave the total count out (default: true)"
+// @Param active query bool false "Only list active or inactive API clients"
+// @Param created_from query string false "Only list API clients created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only list API clients created at or before this time (RFC 3339)"
+// @Param q query string false "Only list API clients whose name contains this text, ignoring case"
+// @Param sort query string false "Comma separated columns to sort by, prefixed with - for descending order: id, name, active, created_at, updated_at (default: id)"
 // @Success 200 {object} ListAPIClientsResponse "List of API clients"
+// @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router / [get]
 func (h *APIClientHandler) List(c echo.Context) error {
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	if page <= 0 {
-		page = 1
-	}
+	return h.list(c, h.apiClientUseCase.List)
+}
+
+// ListDeleted handles listing deleted API clients
+// @Summary List deleted API clients
+// @Description Get a paginated list of deleted API clients, with the policies each gets back when restored and the same filters and sorting as the list of API clients
+// @Tags api-clients
+// @Accept json
+// @Produce json
+// @Param after query string false "Cursor from the next link of a previous page, to list the API clients following it"
+// @Param before query string false "Cursor from the prev link of a previous page, to list the API clients preceding it"
+// @Param limit query int false "Items per page (default: 10, at most 100)"
+// @Param count query bool false "Set to false to leave the total count out (default: true)"
+// @Param active query bool false "Only list active or inactive API clients"
+// @Param created_from query string false "Only list API clients created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only list API clients created at or before this time (RFC 3339)"
+// @Param q query string false "Only list API clients whose name contains this text, ignoring case"
+// @Param sort query string false "Comma separated columns to sort by, prefixed with - for descending order: id, name, active, created_at, updated_at (default: id)"
+// @Success 200 {object} ListAPIClientsResponse "List of deleted API clients"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /deleted [get]
+func (h *APIClientHandler) ListDeleted(c echo.Context) error {
+	return h.list(c, h.apiClientUseCase.ListDeleted)
+}
 
+// list parses the page, filters and sorting of an API client listing and responds with the clients listed
+func (h *APIClientHandler) list(c echo.Context, list func(context.Context, dto.ListAPIClientsInput) (*dto.ListAPIClientsOutput, error)) error {
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 	if limit <= 0 {
 		limit = 10
 	}
 
+	count, err := queryBool(c, "count")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	active, err := queryBool(c, "active")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	createdFrom, err := queryTime(c, "created_from")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	createdTo, err := queryTime(c, "created_to")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
 	input := dto.ListAPIClientsInput{
-		Page:  page,
-		Limit: limit,
+		After:       c.QueryParam("after"),
+		Before:      c.QueryParam("before"),
+		Limit:       limit,
+		SkipCount:   count != nil && !*count,
+		Active:      active,
+		CreatedFrom: createdFrom,
+		CreatedTo:   createdTo,
+		Search:      c.QueryParam("q"),
+		Sort:        c.QueryParam("sort"),
 	}
 
-	output, err := h.apiClientUseCase.List(c.Request().Context(), input)
+	output, err := list(c.Request().Context(), input)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidSort) || errors.Is(err, repository.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -315,21 +509,93 @@ func (h *APIClientHandler) List(c echo.Context) error {
 
 	resp := ListAPIClientsResponse{
 		APIClients: clients,
+		Next:       pageLink(c, "after", output.Next),
+		Prev:       pageLink(c, "before", output.Prev),
 		TotalCount: output.TotalCount,
 	}
 
 	return c.JSON(http.StatusOK, resp)
 }
 
-// RegisterRoutes registers the API client routes
-func (h *APIClientHandler) RegisterRoutes(e *echo.Echo, middlewares ...echo.MiddlewareFunc) {
-	g := e.Group("/api/clients", middlewares...)
-
-	g.POST("", h.Create)
-	g.GET("/:id", h.GetByID)
-	g.PUT("/:id", h.Update)
-	g.POST("/:id/regenerate-key", h.RegenerateAPIKey)
-	g.POST("/:id/set-active", h.SetActive)
-	g.DELETE("/:id", h.Delete)
-	g.GET("", h.List)
+// Restore handles restoring a deleted API client
+// @Summary Restore a deleted API client
+// @Description Restore a deleted API client along with the policies it had when deleted
+// @Tags api-clients
+// @Accept json
+// @Produce json
+// @Param id path int true "API Client ID"
+// @Success 200 {object} APIClientResponse "Restored API client"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Deleted API client not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /deleted/{id}/restore [post]
+func (h *APIClientHandler) Restore(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid API client ID"})
+	}
+
+	input := dto.RestoreAPIClientInput{
+		ID: uint(id),
+	}
+
+	client, err := h.apiClientUseCase.Restore(c.Request().Context(), input)
+	if err != nil {
+		if errors.Is(err, entity.ErrAPIClientNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	setVersionETag(c, client.Version)
+	return c.JSON(http.StatusOK, toAPIClientResponse(client))
+}
+
+// Purge handles permanently deleting a deleted API client
+// @Summary Purge a deleted API client
+// @Description Permanently delete a deleted API client, which can no longer be restored
+// @Tags api-clients
+// @Accept json
+// @Produce json
+// @Param id path int true "API Client ID"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Deleted API client not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /deleted/{id} [delete]
+func (h *APIClientHandler) Purge(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid API client ID"})
+	}
+
+	input := dto.PurgeAPIClientInput{
+		ID: uint(id),
+	}
+
+	if err := h.apiClientUseCase.Purge(c.Request().Context(), input); err != nil {
+		if errors.Is(err, entity.ErrAPIClientNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "API client purged successfully"})
+}
+
+// RegisterRoutes registers the API client routes and records them in the catalog
+func (h *APIClientHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/api/clients", middlewares...), auth.DomainAPI)
+
+	g.Add(http.MethodPost, "", h.Create, "api_clients.create", "Create an API client")
+	g.Add(http.MethodGet, "/:id", h.GetByID, "api_clients.read", "Get an API client")
+	g.Add(http.MethodPut, "/:id", h.Update, "api_clients.update", "Update an API client")
+	g.Add(http.MethodPatch, "/:id", h.Patch, "api_clients.patch", "Partially update an API client with a JSON merge patch")
+	g.Add(http.MethodPost, "/:id/regenerate-key", h.RegenerateAPIKey, "api_clients.regenerate_key", "Regenerate the API key of an API client")
+	g.Add(http.MethodPost, "/:id/set-active", h.SetActive, "api_clients.set_active", "Activate or deactivate an API client")
+	g.Add(http.MethodDelete, "/:id", h.Delete, "api_clients.delete", "Delete an API client")
+	g.Add(http.MethodGet, "", h.List, "api_clients.list", "List API clients")
+	g.Add(http.MethodGet, "/deleted", h.ListDeleted, "api_clients.list_deleted", "List deleted API clients")
+	g.Add(http.MethodPost, "/deleted/:id/restore", h.Restore, "api_clients.restore", "Restore a deleted API client and its policies")
+	g.Add(http.MethodDelete, "/deleted/:id", h.Purge, "api_clients.purge", "Permanently delete a deleted API client")
 }
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title Authorization API
+// @version 1.0
+// @description API for inspecting authorization decisions
+// @BasePath /v1
+
+// AuthzHandler handles HTTP requests for authorization introspection
+type AuthzHandler struct {
+	authzUseCase interfaces.AuthzUseCase
+	catalog      *middleware.RouteCatalog
+}
+
+// NewAuthzHandler creates a new AuthzHandler listing the routes of catalog
+func NewAuthzHandler(authzUseCase interfaces.AuthzUseCase, catalog *middleware.RouteCatalog) *AuthzHandler {
+	return &AuthzHandler{
+		authzUseCase: authzUseCase,
+		catalog:      catalog,
+	}
+}
+
+// PermissionResponse represents a single effective permission in the response
+type PermissionResponse struct {
+	Object string `json:"object"`
+	Action string `json:"action"`
+	// Condition is the expression the request must satisfy, omitted when unconditional
+	Condition string `json:"condition,omitempty"`
+	// Effect is either allow or deny; among the matching rules with the highest priority, deny wins
+	Effect   string `json:"effect"`
+	Priority int    `json:"priority"`
+	// GrantedTo is the subject or role the permission is granted to
+	GrantedTo string `json:"granted_to"`
+}
+
+// MyPermissionsResponse represents the response for the current principal's permissions
+type MyPermissionsResponse struct {
+	Subject     string                `json:"subject"`
+	Type        string                `json:"type"`
+	Domain      string                `json:"domain"`
+	Roles       []string              `json:"roles"`
+	Groups      []string              `json:"groups"`
+	Permissions []*PermissionResponse `json:"permissions"`
+	// ImpersonatedBy is the username of the user impersonating the principal, if any
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+}
+
+// GetMyPermissions handles resolving the current principal's effective permissions
+// @Summary Get my permissions
+// @Description Get the effective roles and permissions of the authenticated user or API client, resolved through role inheritance and group membership
+// @Tags authz
+// @Accept json
+// @Produce json
+// @Param prefix query string false "Only return permissions whose object starts with this prefix"
+// @Success 200 {object} MyPermissionsResponse "Effective roles and permissions"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /me/permissions [get]
+func (h *AuthzHandler) GetMyPermissions(c echo.Context) error {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	input := dto.GetPermissionsInput{
+		Subject:      principal.Subject,
+		Domain:       principal.Domain,
+		ObjectPrefix: c.QueryParam("prefix"),
+	}
+
+	output, err := h.authzUseCase.GetPermissions(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	permissions := make([]*PermissionResponse, len(output.Permissions))
+	for i, permission := range output.Permissions {
+		permissions[i] = &PermissionResponse{
+			Object:    permission.Object,
+			Action:    permission.Action,
+			Condition: permission.Condition,
+			Effect:    permission.Effect,
+			Priority:  permission.Priority,
+			GrantedTo: permission.Subject,
+		}
+	}
+
+	roles := output.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	groups := output.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+
+	resp := MyPermissionsResponse{
+		Subject:        output.Subject,
+		Type:           principal.Type,
+		Domain:         output.Domain,
+		Roles:          roles,
+		Groups:         groups,
+		Permissions:    permissions,
+		ImpersonatedBy: principal.Impersonator,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// CheckAttributesRequest represents the attributes conditional rules are evaluated against
+type CheckAttributesRequest struct {
+	SubjectID string `json:"subject_id"`
+	OwnerID   string `json:"owner_id"`
+	OwnerRole string `json:"owner_role"`
+	IP        string `json:"ip"`
+}
+
+// CheckRequest represents a single authorization question
+type CheckRequest struct {
+	Subject    string                 `json:"subject"`
+	Domain     string                 `json:"domain"`
+	Object     string                 `json:"object"`
+	Action     string                 `json:"action"`
+	Attributes CheckAttributesRequest `json:"attributes"`
+}
+
+// BatchCheckRequest represents the request for evaluating authorization questions in one call
+type BatchCheckRequest struct {
+	Checks []CheckRequest `json:"checks"`
+}
+
+// DecisionResponse represents the answer to an authorization question
+type DecisionResponse struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchCheckResponse represents the decisions of a batch, in the order of its checks
+type BatchCheckResponse struct {
+	Decisions []*DecisionResponse `json:"decisions"`
+	// ElapsedMicros is the time spent evaluating the batch in microseconds
+	ElapsedMicros int64 `json:"elapsed_us"`
+}
+
+// BatchCheck handles evaluating many authorization questions in one call
+// @Summary Batch authorization check
+// @Description Evaluate many (subject, domain, object, action) tuples and return a decision for each, in order. Checks that are invalid, fail or are not evaluated within the latency budget are denied with an error.
+// @Tags authz
+// @Accept json
+// @Produce json
+// @Param request body BatchCheckRequest true "Authorization questions"
+// @Success 200 {object} BatchCheckResponse "Decisions"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Router /authz/batch-check [post]
+func (h *AuthzHandler) BatchCheck(c echo.Context) error {
+	var req BatchCheckRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	checks := make([]dto.Check, len(req.Checks))
+	for i, check := range req.Checks {
+		checks[i] = dto.Check{
+			Subject: check.Subject,
+			Domain:  check.Domain,
+			Object:  check.Object,
+			Action:  check.Action,
+			Attributes: dto.CheckAttributes{
+				SubjectID: check.Attributes.SubjectID,
+				OwnerID:   check.Attributes.OwnerID,
+				OwnerRole: check.Attributes.OwnerRole,
+				IP:        check.Attributes.IP,
+			},
+		}
+	}
+
+	output, err := h.authzUseCase.BatchCheck(c.Request().Context(), dto.BatchCheckInput{Checks: checks})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	decisions := make([]*DecisionResponse, len(output.Decisions))
+	for i, decision := range output.Decisions {
+		decisions[i] = &DecisionResponse{
+			Allowed: decision.Allowed,
+			Error:   decision.Error,
+		}
+	}
+
+	resp := BatchCheckResponse{
+		Decisions:     decisions,
+		ElapsedMicros: output.Elapsed.Microseconds(),
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RoutePermissionResponse represents a route and the permission it requires in the response
+type RoutePermissionResponse struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Permission  string `json:"permission"`
+	Description string `json:"description"`
+	// Authorized tells whether Casbin authorizes the route, otherwise authentication is enough or it is public
+	Authorized bool `json:"authorized"`
+	// Domain, Object and Action are the values a policy rule must grant to allow the route
+	Domain string `json:"domain,omitempty"`
+	Object string `json:"object,omitempty"`
+	Action string `json:"action,omitempty"`
+}
+
+// ListPermissionsResponse represents the response for listing the route catalog
+type ListPermissionsResponse struct {
+	Permissions []*RoutePermissionResponse `json:"permissions"`
+}
+
+// ListPermissions handles listing every route and the permission it requires
+// @Summary List permissions
+// @Description List every route of the API with its permission name, and the domain, object and action a policy rule must grant to allow it
+// @Tags authz
+// @Accept json
+// @Produce json
+// @Param domain query string false "Only return the routes authorized in this domain"
+// @Success 200 {object} ListPermissionsResponse "Route catalog"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Router /authz/permissions [get]
+func (h *AuthzHandler) ListPermissions(c echo.Context) error {
+	domain := c.QueryParam("domain")
+
+	permissions := []*RoutePermissionResponse{}
+	for _, route := range h.catalog.Routes() {
+		if domain != "" && route.Domain != domain {
+			continue
+		}
+
+		permission := &RoutePermissionResponse{
+			Method:      route.Method,
+			Path:        route.Path,
+			Permission:  route.Permission,
+			Description: route.Description,
+			Authorized:  route.Authorized(),
+		}
+		if route.Authorized() {
+			permission.Domain = route.Domain
+			permission.Object = route.Path
+			permission.Action = route.Method
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return c.JSON(http.StatusOK, ListPermissionsResponse{Permissions: permissions})
+}
+
+// RegisterRoutes registers the authorization introspection routes and records them in the catalog
+func (h *AuthzHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/me", middlewares...), "")
+
+	g.Add(http.MethodGet, "/permissions", h.GetMyPermissions, "me.permissions", "Get my effective roles and permissions")
+}
+
+// RegisterCheckRoutes registers the authorization decision routes for API clients and records them in the catalog
+func (h *AuthzHandler) RegisterCheckRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/authz", middlewares...), auth.DomainAPI)
+
+	g.Add(http.MethodPost, "/batch-check", h.BatchCheck, "authz.batch_check", "Check many authorization decisions at once")
+}
+
+// RegisterCatalogRoutes registers the route catalog routes for users and records them in the catalog
+func (h *AuthzHandler) RegisterCatalogRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/authz", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodGet, "/permissions", h.ListPermissions, "authz.permissions.list", "List every route and the permission it requires")
+}
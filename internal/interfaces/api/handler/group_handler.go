@@ -0,0 +1,459 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title Group API
+// @version 1.0
+// @description API for managing groups of users and the roles they hold together
+// @BasePath /v1/groups
+
+// GroupHandler handles HTTP requests for groups
+type GroupHandler struct {
+	groupUseCase interfaces.GroupUseCase
+}
+
+// NewGroupHandler creates a new GroupHandler
+func NewGroupHandler(groupUseCase interfaces.GroupUseCase) *GroupHandler {
+	return &GroupHandler{
+		groupUseCase: groupUseCase,
+	}
+}
+
+// GroupResponse represents a group in the response
+type GroupResponse struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Domain      string   `json:"domain"`
+	Roles       []string `json:"roles"`
+	CreatedBy   string   `json:"created_by"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// toGroupResponse converts a group output to a group response
+func toGroupResponse(output *dto.GroupOutput) *GroupResponse {
+	group := output.Group
+	return &GroupResponse{
+		ID:          group.ID,
+		Name:        group.Name,
+		Description: group.Description,
+		Domain:      group.Domain,
+		Roles:       output.Roles,
+		CreatedBy:   group.CreatedBy,
+		CreatedAt:   group.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   group.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// groupErrorResponse maps a group error to a response
+func groupErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrGroupNotFound), errors.Is(err, entity.ErrUserNotFound),
+		errors.Is(err, entity.ErrNotGroupMember), errors.Is(err, entity.ErrGroupRoleNotHeld):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrGroupExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrRoleNotAssignable):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+// CreateGroupRequest represents the request for creating a group
+type CreateGroupRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Domain      string `json:"domain"`
+}
+
+// Create handles creating a group
+// @Summary Create a group
+// @Description Create a group of users in a domain (default: default). The group holds no role until one is assigned to it.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param request body CreateGroupRequest true "Group creation request"
+// @Success 201 {object} GroupResponse "Created group"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 409 {object} map[string]string "Group name already taken in the domain"
+// @Router / [post]
+func (h *GroupHandler) Create(c echo.Context) error {
+	var req CreateGroupRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.CreateGroupInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Domain:      req.Domain,
+	}
+
+	output, err := h.groupUseCase.Create(c.Request().Context(), input)
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, toGroupResponse(output))
+}
+
+// Get handles getting a group by ID
+// @Summary Get a group
+// @Description Retrieve a group by its ID, with the roles it holds
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Success 200 {object} GroupResponse "Group details"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Group not found"
+// @Router /{id} [get]
+func (h *GroupHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	output, err := h.groupUseCase.GetByID(c.Request().Context(), uint(id))
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toGroupResponse(output))
+}
+
+// ListGroupsResponse represents the response for listing groups
+type ListGroupsResponse struct {
+	Groups     []*GroupResponse `json:"groups"`
+	TotalCount int64            `json:"total_count"`
+}
+
+// List handles listing groups
+// @Summary List groups
+// @Description Get a paginated list of groups, sorted by name
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param domain query string false "Only list the groups of this domain"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10)"
+// @Success 200 {object} ListGroupsResponse "List of groups"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router / [get]
+func (h *GroupHandler) List(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	input := dto.ListGroupsInput{
+		Domain: c.QueryParam("domain"),
+		Page:   page,
+		Limit:  limit,
+	}
+
+	output, err := h.groupUseCase.List(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	groups := make([]*GroupResponse, len(output.Groups))
+	for i, group := range output.Groups {
+		groups[i] = toGroupResponse(group)
+	}
+
+	resp := ListGroupsResponse{
+		Groups:     groups,
+		TotalCount: output.TotalCount,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// UpdateGroupRequest represents the request for updating a group
+type UpdateGroupRequest struct {
+	Description string `json:"description"`
+}
+
+// Update handles updating a group
+// @Summary Update a group
+// @Description Change the description of a group. Its name and domain cannot change.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Param request body UpdateGroupRequest true "Group update request"
+// @Success 200 {object} GroupResponse "Updated group"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Group not found"
+// @Router /{id} [put]
+func (h *GroupHandler) Update(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	var req UpdateGroupRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	input := dto.UpdateGroupInput{
+		ID:          uint(id),
+		Description: req.Description,
+	}
+
+	output, err := h.groupUseCase.Update(c.Request().Context(), input)
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toGroupResponse(output))
+}
+
+// Delete handles deleting a group
+// @Summary Delete a group
+// @Description Delete a group, removing its members, who lose the roles of the group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Success 204 "No content"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Not allowed to assign the roles of the group"
+// @Failure 404 {object} map[string]string "Group not found"
+// @Router /{id} [delete]
+func (h *GroupHandler) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	if err := h.groupUseCase.Delete(c.Request().Context(), uint(id)); err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GroupMemberResponse represents a member of a group in the response
+type GroupMemberResponse struct {
+	Username string `json:"username"`
+	// UserID and Email are omitted when no account goes by the username
+	UserID uint   `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+// ListGroupMembersResponse represents the response for listing the members of a group
+type ListGroupMembersResponse struct {
+	Members []*GroupMemberResponse `json:"members"`
+}
+
+// ListMembers handles listing the members of a group
+// @Summary List group members
+// @Description List the members of a group, sorted by username
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Success 200 {object} ListGroupMembersResponse "Members of the group"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Group not found"
+// @Router /{id}/members [get]
+func (h *GroupHandler) ListMembers(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	members, err := h.groupUseCase.ListMembers(c.Request().Context(), uint(id))
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	resp := ListGroupMembersResponse{
+		Members: make([]*GroupMemberResponse, len(members)),
+	}
+	for i, member := range members {
+		resp.Members[i] = &GroupMemberResponse{Username: member.Username}
+		if member.User != nil {
+			resp.Members[i].UserID = member.User.ID
+			resp.Members[i].Email = member.User.Email
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// AddMember handles adding a user to a group
+// @Summary Add a group member
+// @Description Add a user to a group, giving them the roles of the group. Adding a member twice has no effect.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Param user_id path int true "User ID"
+// @Success 204 "No content"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Not allowed to assign the roles of the group"
+// @Failure 404 {object} map[string]string "Group or user not found"
+// @Router /{id}/members/{user_id} [put]
+func (h *GroupHandler) AddMember(c echo.Context) error {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	input := dto.GroupMemberInput{
+		GroupID: uint(groupID),
+		UserID:  uint(userID),
+	}
+
+	if err := h.groupUseCase.AddMember(c.Request().Context(), input); err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveMember handles removing a user from a group
+// @Summary Remove a group member
+// @Description Remove a user from a group, taking the roles of the group away from them
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Param user_id path int true "User ID"
+// @Success 204 "No content"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Not allowed to assign the roles of the group"
+// @Failure 404 {object} map[string]string "Group or user not found, or the user is not a member"
+// @Router /{id}/members/{user_id} [delete]
+func (h *GroupHandler) RemoveMember(c echo.Context) error {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	input := dto.GroupMemberInput{
+		GroupID: uint(groupID),
+		UserID:  uint(userID),
+	}
+
+	if err := h.groupUseCase.RemoveMember(c.Request().Context(), input); err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddRole handles assigning a role to a group
+// @Summary Assign a role to a group
+// @Description Assign a role of the domain of a group to the group, and thereby to its members. The caller must be allowed to assign the role.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Param role path string true "Role"
+// @Success 200 {object} GroupResponse "Group with its roles"
+// @Failure 400 {object} map[string]string "Bad request, or unknown role"
+// @Failure 403 {object} map[string]string "Not allowed to assign the role"
+// @Failure 404 {object} map[string]string "Group not found"
+// @Router /{id}/roles/{role} [put]
+func (h *GroupHandler) AddRole(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	input := dto.GroupRoleInput{
+		GroupID: uint(id),
+		Role:    c.Param("role"),
+	}
+
+	output, err := h.groupUseCase.AddRole(c.Request().Context(), input)
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toGroupResponse(output))
+}
+
+// RemoveRole handles unassigning a role from a group
+// @Summary Unassign a role from a group
+// @Description Unassign a role from a group, taking it away from its members unless they hold it otherwise. The caller must be allowed to assign the role.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Param role path string true "Role"
+// @Success 200 {object} GroupResponse "Group with its roles"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Not allowed to assign the role"
+// @Failure 404 {object} map[string]string "Group not found, or the group does not hold the role"
+// @Router /{id}/roles/{role} [delete]
+func (h *GroupHandler) RemoveRole(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group ID"})
+	}
+
+	input := dto.GroupRoleInput{
+		GroupID: uint(id),
+		Role:    c.Param("role"),
+	}
+
+	output, err := h.groupUseCase.RemoveRole(c.Request().Context(), input)
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toGroupResponse(output))
+}
+
+// RegisterRoutes registers the group routes and records them in the catalog
+func (h *GroupHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/groups", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodPost, "", h.Create, "groups.create", "Create a group")
+	g.Add(http.MethodGet, "", h.List, "groups.list", "List groups")
+	g.Add(http.MethodGet, "/:id", h.Get, "groups.read", "Get a group")
+	g.Add(http.MethodPut, "/:id", h.Update, "groups.update", "Update a group")
+	g.Add(http.MethodDelete, "/:id", h.Delete, "groups.delete", "Delete a group")
+	g.Add(http.MethodGet, "/:id/members", h.ListMembers, "groups.members.list", "List the members of a group")
+	g.Add(http.MethodPut, "/:id/members/:user_id", h.AddMember, "groups.members.add", "Add a user to a group", middleware.ForbidImpersonation())
+	g.Add(http.MethodDelete, "/:id/members/:user_id", h.RemoveMember, "groups.members.remove", "Remove a user from a group")
+	g.Add(http.MethodPut, "/:id/roles/:role", h.AddRole, "groups.roles.add", "Assign a role to a group", middleware.ForbidImpersonation())
+	g.Add(http.MethodDelete, "/:id/roles/:role", h.RemoveRole, "groups.roles.remove", "Unassign a role from a group")
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title Invitation API
+// @version 1.0
+// @description API for inviting people to create an account
+// @BasePath /v1/invitations
+
+// InvitationHandler handles HTTP requests for invitations
+type InvitationHandler struct {
+	invitationUseCase interfaces.InvitationUseCase
+}
+
+// NewInvitationHandler creates a new InvitationHandler
+func NewInvitationHandler(invitationUseCase interfaces.InvitationUseCase) *InvitationHandler {
+	return &InvitationHandler{
+		invitationUseCase: invitationUseCase,
+	}
+}
+
+// InvitationResponse represents an invitation in the response
+type InvitationResponse struct {
+	ID         uint   `json:"id"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	Domain     string `json:"domain"`
+	Status     string `json:"status"`
+	InvitedBy  string `json:"invited_by"`
+	ExpiresAt  string `json:"expires_at"`
+	AcceptedBy string `json:"accepted_by,omitempty"`
+	AcceptedAt string `json:"accepted_at,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// toInvitationResponse converts an invitation entity to an invitation response
+func toInvitationResponse(invitation *entity.Invitation) *InvitationResponse {
+	resp := &InvitationResponse{
+		ID:         invitation.ID,
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		Domain:     invitation.Domain,
+		Status:     invitation.CurrentStatus(time.Now()),
+		InvitedBy:  invitation.InvitedBy,
+		ExpiresAt:  invitation.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		AcceptedBy: invitation.AcceptedBy,
+		CreatedAt:  invitation.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:  invitation.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if invitation.AcceptedAt != nil {
+		resp.AcceptedAt = invitation.AcceptedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// invitationErrorResponse maps an invitation error to a response
+func invitationErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrInvitationNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrInvitationUnusable):
+		return c.JSON(http.StatusGone, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrInvitationNotSent):
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrRoleNotAssignable), errors.Is(err, entity.ErrRegistrationClosed):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrEmailTaken), errors.Is(err, entity.ErrUsernameTaken):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+// CreateInvitationRequest represents the request for inviting someone
+type CreateInvitationRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Role   string `json:"role" validate:"required"`
+	Domain string `json:"domain"`
+}
+
+// Create handles inviting someone to create an account
+// @Summary Invite someone
+// @Description Email an invitation to create an account holding a role in a domain (default: default). The invitation link expires after INVITATION_EXPIRATION.
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param request body CreateInvitationRequest true "Invitation request"
+// @Success 201 {object} InvitationResponse "Sent invitation"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Not allowed to assign the role, or registration is disabled"
+// @Failure 409 {object} map[string]string "A user already has the email"
+// @Failure 502 {object} map[string]string "The invitation could not be sent"
+// @Router / [post]
+func (h *InvitationHandler) Create(c echo.Context) error {
+	var req CreateInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.CreateInvitationInput{
+		Email:  req.Email,
+		Role:   req.Role,
+		Domain: req.Domain,
+	}
+
+	invitation, err := h.invitationUseCase.Create(c.Request().Context(), input)
+	if err != nil {
+		return invitationErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, toInvitationResponse(invitation))
+}
+
+// Get handles getting an invitation by ID
+// @Summary Get an invitation
+// @Description Retrieve an invitation by its ID
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} InvitationResponse "Invitation details"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Invitation not found"
+// @Router /{id} [get]
+func (h *InvitationHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid invitation ID"})
+	}
+
+	invitation, err := h.invitationUseCase.GetByID(c.Request().Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, entity.ErrInvitationNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, toInvitationResponse(invitation))
+}
+
+// ListInvitationsResponse represents the response for listing invitations
+type ListInvitationsResponse struct {
+	Invitations []*InvitationResponse `json:"invitations"`
+	TotalCount  int64                 `json:"total_count"`
+}
+
+// List handles listing invitations
+// @Summary List invitations
+// @Description Get a paginated list of invitations, newest first
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param status query string false "pending, accepted, revoked or expired"
+// @Param email query string false "Only list the invitations sent to this email"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10)"
+// @Success 200 {object} ListInvitationsResponse "List of invitations"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router / [get]
+func (h *InvitationHandler) List(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	input := dto.ListInvitationsInput{
+		Status: c.QueryParam("status"),
+		Email:  c.QueryParam("email"),
+		Page:   page,
+		Limit:  limit,
+	}
+
+	output, err := h.invitationUseCase.List(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	invitations := make([]*InvitationResponse, len(output.Invitations))
+	for i, invitation := range output.Invitations {
+		invitations[i] = toInvitationResponse(invitation)
+	}
+
+	resp := ListInvitationsResponse{
+		Invitations: invitations,
+		TotalCount:  output.TotalCount,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Revoke handles revoking a pending invitation
+// @Summary Revoke an invitation
+// @Description Withdraw a pending invitation, so that its link can no longer be used
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} InvitationResponse "Revoked invitation"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Invitation not found"
+// @Failure 410 {object} map[string]string "Invitation already accepted, revoked or expired"
+// @Router /{id}/revoke [post]
+func (h *InvitationHandler) Revoke(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid invitation ID"})
+	}
+
+	invitation, err := h.invitationUseCase.Revoke(c.Request().Context(), uint(id))
+	if err != nil {
+		return invitationErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toInvitationResponse(invitation))
+}
+
+// AcceptInvitationRequest represents the request for accepting an invitation
+type AcceptInvitationRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+// Accept handles accepting an invitation
+// @Summary Accept an invitation
+// @Description Create an account from the token of an invitation, choosing its username and password. The account gets the email and role of the invitation.
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param token path string true "Invitation token"
+// @Param request body AcceptInvitationRequest true "Account request"
+// @Success 201 {object} RegisterResponse "Created user, with a token"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Registration is disabled"
+// @Failure 404 {object} map[string]string "Invitation not found"
+// @Failure 409 {object} map[string]string "Username taken, or a user registered with the email since the invitation was sent"
+// @Failure 410 {object} map[string]string "Invitation already accepted, revoked or expired"
+// @Router /{token}/accept [post]
+func (h *InvitationHandler) Accept(c echo.Context) error {
+	var req AcceptInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.AcceptInvitationInput{
+		Token:    c.Param("token"),
+		Username: req.Username,
+		Password: req.Password,
+	}
+
+	output, err := h.invitationUseCase.Accept(c.Request().Context(), input)
+	if err != nil {
+		return invitationErrorResponse(c, err)
+	}
+
+	resp := RegisterResponse{
+		User:  toUserResponse(output.User),
+		Token: output.Token,
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// RegisterRoutes registers the invitation routes and records them in the catalog
+func (h *InvitationHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	public := catalog.Group(e.Group("/v1/invitations"), "")
+	public.Add(http.MethodPost, "/:token/accept", h.Accept, "invitations.accept", "Create an account from an invitation")
+
+	g := catalog.Group(e.Group("/v1/invitations", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodPost, "", h.Create, "invitations.create", "Invite someone to create an account", middleware.ForbidImpersonation())
+	g.Add(http.MethodGet, "", h.List, "invitations.list", "List invitations")
+	g.Add(http.MethodGet, "/:id", h.Get, "invitations.read", "Get an invitation")
+	g.Add(http.MethodPost, "/:id/revoke", h.Revoke, "invitations.revoke", "Revoke a pending invitation")
+}
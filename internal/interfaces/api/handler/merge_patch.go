@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// mergePatchContentType is the media type of JSON merge patches (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+var (
+	// errUnsupportedPatchType is returned when a patch is neither a JSON merge patch nor plain JSON
+	errUnsupportedPatchType = errors.New("patches must be sent as " + mergePatchContentType)
+	// errInvalidMergePatch is returned when a merge patch is not a JSON object
+	errInvalidMergePatch = errors.New("invalid patch: expected a JSON object")
+)
+
+// FieldChangeResponse represents the change of a field by a patch in the response
+type FieldChangeResponse struct {
+	// Field is named by its path for members of nested objects, e.g. attributes.department
+	Field string `json:"field"`
+	// From and To are null when the field was added or removed
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// toFieldChangesResponse converts field changes to their response, an empty list without changes
+func toFieldChangesResponse(changes []entity.FieldChange) []*FieldChangeResponse {
+	resp := make([]*FieldChangeResponse, len(changes))
+	for i, change := range changes {
+		resp[i] = &FieldChangeResponse{
+			Field: change.Field,
+			From:  change.From,
+			To:    change.To,
+		}
+	}
+	return resp
+}
+
+// bindMergePatch decodes the JSON merge patch carried by a request, sent as application/merge-patch+json
+// or as application/json. As patched resources are objects, so must be the patch.
+func bindMergePatch(c echo.Context) (map[string]any, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != mergePatchContentType && mediaType != echo.MIMEApplicationJSON {
+		return nil, errUnsupportedPatchType
+	}
+
+	var patch map[string]any
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil || patch == nil {
+		return nil, errInvalidMergePatch
+	}
+	return patch, nil
+}
+
+// mergePatchErrorResponse maps an error decoding a merge patch to a response
+func mergePatchErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, errUnsupportedPatchType) {
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
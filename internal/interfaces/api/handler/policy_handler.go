@@ -0,0 +1,546 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title Policy API
+// @version 1.0
+// @description API for managing Casbin policies
+// @BasePath /v1/admin/policies
+
+// maxPolicyFileSize is the largest policy file accepted for import
+const maxPolicyFileSize = 10 << 20
+
+// PolicyHandler handles HTTP requests for policy management
+type PolicyHandler struct {
+	policyUseCase interfaces.PolicyUseCase
+}
+
+// NewPolicyHandler creates a new PolicyHandler
+func NewPolicyHandler(policyUseCase interfaces.PolicyUseCase) *PolicyHandler {
+	return &PolicyHandler{
+		policyUseCase: policyUseCase,
+	}
+}
+
+// PolicyRuleResponse represents a policy rule in the response
+type PolicyRuleResponse struct {
+	PType  string   `json:"ptype"`
+	Values []string `json:"values"`
+}
+
+// toPolicyRuleResponses converts policy rules to policy rule responses
+func toPolicyRuleResponses(rules []entity.PolicyRule) []*PolicyRuleResponse {
+	resp := make([]*PolicyRuleResponse, len(rules))
+	for i, rule := range rules {
+		resp[i] = &PolicyRuleResponse{
+			PType:  rule.PType,
+			Values: rule.Values,
+		}
+	}
+	return resp
+}
+
+// policyFormatParam reads the policy format query parameter, defaulting to CSV
+func policyFormatParam(c echo.Context) string {
+	if format := c.QueryParam("format"); format != "" {
+		return format
+	}
+	return auth.PolicyFormatCSV
+}
+
+// PolicyRequest represents a policy rule in requests
+type PolicyRequest struct {
+	Subject   string `json:"subject" validate:"required"`
+	Domain    string `json:"domain" validate:"required"`
+	Object    string `json:"object" validate:"required"`
+	Action    string `json:"action" validate:"required"`
+	Condition string `json:"condition"`
+	Effect    string `json:"effect" validate:"omitempty,oneof=allow deny"`
+	Priority  int    `json:"priority"`
+}
+
+// toPolicyInput converts a policy request to a policy
+func (r *PolicyRequest) toPolicyInput() dto.Policy {
+	return dto.Policy{
+		Subject:   r.Subject,
+		Domain:    r.Domain,
+		Object:    r.Object,
+		Action:    r.Action,
+		Condition: r.Condition,
+		Effect:    r.Effect,
+		Priority:  r.Priority,
+	}
+}
+
+// PolicyResponse represents a policy rule in the response
+type PolicyResponse struct {
+	Subject   string `json:"subject"`
+	Domain    string `json:"domain"`
+	Object    string `json:"object"`
+	Action    string `json:"action"`
+	Condition string `json:"condition,omitempty"`
+	Effect    string `json:"effect"`
+	Priority  int    `json:"priority"`
+}
+
+// toPolicyResponse converts a policy to a policy response
+func toPolicyResponse(policy dto.Policy) *PolicyResponse {
+	return &PolicyResponse{
+		Subject:   policy.Subject,
+		Domain:    policy.Domain,
+		Object:    policy.Object,
+		Action:    policy.Action,
+		Condition: policy.Condition,
+		Effect:    policy.Effect,
+		Priority:  policy.Priority,
+	}
+}
+
+// ListPoliciesResponse represents the response for listing policy rules
+type ListPoliciesResponse struct {
+	Policies []*PolicyResponse `json:"policies"`
+}
+
+// List handles listing policy rules
+// @Summary List policies
+// @Description Get the policy rules with their condition, effect and priority
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param subject query string false "Only list the rules of this subject or role"
+// @Param domain query string false "Only list the rules of this domain"
+// @Success 200 {object} ListPoliciesResponse "List of policy rules"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router / [get]
+func (h *PolicyHandler) List(c echo.Context) error {
+	input := dto.ListPoliciesInput{
+		Subject: c.QueryParam("subject"),
+		Domain:  c.QueryParam("domain"),
+	}
+
+	output, err := h.policyUseCase.ListPolicies(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	policies := make([]*PolicyResponse, len(output.Policies))
+	for i, policy := range output.Policies {
+		policies[i] = toPolicyResponse(policy)
+	}
+
+	return c.JSON(http.StatusOK, ListPoliciesResponse{Policies: policies})
+}
+
+// Add handles adding a policy rule
+// @Summary Add a policy
+// @Description Add a rule allowing or denying an action. Among the rules matching a request, only those with the highest priority are considered and a deny overrides any allow.
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param request body PolicyRequest true "Policy rule"
+// @Success 201 {object} PolicyResponse "Added policy rule"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 409 {object} map[string]string "Policy already exists"
+// @Router / [post]
+func (h *PolicyHandler) Add(c echo.Context) error {
+	var req PolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := req.toPolicyInput()
+	if err := h.policyUseCase.AddPolicy(c.Request().Context(), input); err != nil {
+		if errors.Is(err, auth.ErrPolicyExists) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if input.Effect == "" {
+		input.Effect = auth.EffectAllow
+	}
+	return c.JSON(http.StatusCreated, toPolicyResponse(input))
+}
+
+// Remove handles removing a policy rule
+// @Summary Remove a policy
+// @Description Remove a rule; the condition, effect and priority must match the rule to remove
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param request body PolicyRequest true "Policy rule"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Policy not found"
+// @Router / [delete]
+func (h *PolicyHandler) Remove(c echo.Context) error {
+	var req PolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := h.policyUseCase.RemovePolicy(c.Request().Context(), req.toPolicyInput()); err != nil {
+		if errors.Is(err, auth.ErrPolicyNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Policy removed successfully"})
+}
+
+// Export handles exporting the full policy set
+// @Summary Export policies
+// @Description Export all policy and grouping rules as a Casbin CSV or YAML file
+// @Tags policies
+// @Produce plain
+// @Param format query string false "csv (default) or yaml"
+// @Success 200 {string} string "Policy file"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /export [get]
+func (h *PolicyHandler) Export(c echo.Context) error {
+	format := policyFormatParam(c)
+	if format != auth.PolicyFormatCSV && format != auth.PolicyFormatYAML {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid format"})
+	}
+
+	output, err := h.policyUseCase.Export(c.Request().Context(), dto.ExportPoliciesInput{Format: format})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	contentType := "text/csv"
+	if format == auth.PolicyFormatYAML {
+		contentType = "application/yaml"
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=policy."+format)
+	return c.Blob(http.StatusOK, contentType, output.Data)
+}
+
+// ImportPoliciesResponse represents the response for importing policies
+type ImportPoliciesResponse struct {
+	Added   []*PolicyRuleResponse `json:"added"`
+	Removed []*PolicyRuleResponse `json:"removed"`
+	Applied bool                  `json:"applied"`
+}
+
+// Import handles importing a policy set
+// @Summary Import policies
+// @Description Import policy and grouping rules from a Casbin CSV or YAML file sent as the request body
+// @Tags policies
+// @Accept plain
+// @Produce json
+// @Param format query string false "csv (default) or yaml"
+// @Param replace query bool false "Remove existing rules missing from the file"
+// @Param dry_run query bool false "Only report what would be added and removed"
+// @Success 200 {object} ImportPoliciesResponse "Changes made, or that would be made in dry-run mode"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /import [post]
+func (h *PolicyHandler) Import(c echo.Context) error {
+	replace, _ := strconv.ParseBool(c.QueryParam("replace"))
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxPolicyFileSize))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	input := dto.ImportPoliciesInput{
+		Data:    data,
+		Format:  policyFormatParam(c),
+		Replace: replace,
+		DryRun:  dryRun,
+	}
+
+	output, err := h.policyUseCase.Import(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	resp := ImportPoliciesResponse{
+		Added:   toPolicyRuleResponses(output.Added),
+		Removed: toPolicyRuleResponses(output.Removed),
+		Applied: output.Applied,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// PolicyVersionResponse represents a policy version in the response
+type PolicyVersionResponse struct {
+	Version     uint                  `json:"version"`
+	Actor       string                `json:"actor"`
+	Action      string                `json:"action"`
+	Description string                `json:"description"`
+	Added       []*PolicyRuleResponse `json:"added"`
+	Removed     []*PolicyRuleResponse `json:"removed"`
+	CreatedAt   string                `json:"created_at"`
+}
+
+// toPolicyVersionResponse converts a policy version entity to a policy version response
+func toPolicyVersionResponse(version *entity.PolicyVersion) *PolicyVersionResponse {
+	return &PolicyVersionResponse{
+		Version:     version.Version,
+		Actor:       version.Actor,
+		Action:      version.Action,
+		Description: version.Description,
+		Added:       toPolicyRuleResponses(version.Added),
+		Removed:     toPolicyRuleResponses(version.Removed),
+		CreatedAt:   version.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// ListPolicyVersionsResponse represents the response for listing policy versions
+type ListPolicyVersionsResponse struct {
+	Versions   []*PolicyVersionResponse `json:"versions"`
+	TotalCount int64                    `json:"total_count"`
+}
+
+// ListVersions handles listing policy versions
+// @Summary List policy versions
+// @Description Get a paginated list of policy changesets, newest first
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10)"
+// @Success 200 {object} ListPolicyVersionsResponse "List of policy versions"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /versions [get]
+func (h *PolicyHandler) ListVersions(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	input := dto.ListPolicyVersionsInput{
+		Page:  page,
+		Limit: limit,
+	}
+
+	output, err := h.policyUseCase.ListVersions(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	versions := make([]*PolicyVersionResponse, len(output.Versions))
+	for i, version := range output.Versions {
+		versions[i] = toPolicyVersionResponse(version)
+	}
+
+	resp := ListPolicyVersionsResponse{
+		Versions:   versions,
+		TotalCount: output.TotalCount,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetVersion handles getting a policy version
+// @Summary Get a policy version
+// @Description Retrieve the changeset recorded for a policy version
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param version path int true "Policy version"
+// @Success 200 {object} PolicyVersionResponse "Policy version details"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Policy version not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /versions/{version} [get]
+func (h *PolicyHandler) GetVersion(c echo.Context) error {
+	version, err := strconv.ParseUint(c.Param("version"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid policy version"})
+	}
+
+	policyVersion, err := h.policyUseCase.GetVersion(c.Request().Context(), uint(version))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if policyVersion == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Policy version not found"})
+	}
+
+	return c.JSON(http.StatusOK, toPolicyVersionResponse(policyVersion))
+}
+
+// DiffPolicyVersionsResponse represents the response for comparing two policy versions
+type DiffPolicyVersionsResponse struct {
+	From    uint                  `json:"from"`
+	To      uint                  `json:"to"`
+	Added   []*PolicyRuleResponse `json:"added"`
+	Removed []*PolicyRuleResponse `json:"removed"`
+}
+
+// DiffVersions handles comparing the policy at two versions
+// @Summary Diff two policy versions
+// @Description Get the rules added and removed between the policy at one version and at another
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param from query int true "Base version"
+// @Param to query int true "Target version"
+// @Success 200 {object} DiffPolicyVersionsResponse "Rules added and removed"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Policy version not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /versions/diff [get]
+func (h *PolicyHandler) DiffVersions(c echo.Context) error {
+	from, err := strconv.ParseUint(c.QueryParam("from"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid from version"})
+	}
+
+	to, err := strconv.ParseUint(c.QueryParam("to"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid to version"})
+	}
+
+	input := dto.DiffPolicyVersionsInput{
+		From: uint(from),
+		To:   uint(to),
+	}
+
+	output, err := h.policyUseCase.DiffVersions(c.Request().Context(), input)
+	if err != nil {
+		if errors.Is(err, auth.ErrPolicyVersionNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	resp := DiffPolicyVersionsResponse{
+		From:    output.From,
+		To:      output.To,
+		Added:   toPolicyRuleResponses(output.Added),
+		Removed: toPolicyRuleResponses(output.Removed),
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Rollback handles restoring the policy as it was at a version
+// @Summary Roll back policies
+// @Description Atomically restore the policy as it was right after a version; the rollback is recorded as a new version
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param version path int true "Policy version to restore"
+// @Success 200 {object} PolicyVersionResponse "Version recording the rollback"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Policy version not found"
+// @Failure 409 {object} map[string]string "Policy already identical to this version"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /versions/{version}/rollback [post]
+func (h *PolicyHandler) Rollback(c echo.Context) error {
+	version, err := strconv.ParseUint(c.Param("version"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid policy version"})
+	}
+
+	input := dto.RollbackPolicyInput{
+		Version: uint(version),
+	}
+
+	policyVersion, err := h.policyUseCase.Rollback(c.Request().Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrPolicyVersionNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, auth.ErrPolicyUnchanged):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, toPolicyVersionResponse(policyVersion))
+}
+
+// DecisionCacheStatsResponse represents the activity of the authorization decision cache in the response
+type DecisionCacheStatsResponse struct {
+	Enabled       bool    `json:"enabled"`
+	TTL           string  `json:"ttl"`
+	Size          int     `json:"size"`
+	Capacity      int     `json:"capacity"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Evictions     uint64  `json:"evictions"`
+	Invalidations uint64  `json:"invalidations"`
+}
+
+// CacheStats handles reporting the decision cache statistics
+// @Summary Get decision cache statistics
+// @Description Get the hits, misses, evictions and invalidations of the authorization decision cache since startup
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Success 200 {object} DecisionCacheStatsResponse "Decision cache statistics"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /cache [get]
+func (h *PolicyHandler) CacheStats(c echo.Context) error {
+	output, err := h.policyUseCase.DecisionCacheStats(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	resp := DecisionCacheStatsResponse{
+		Enabled:       output.Enabled,
+		TTL:           output.TTL.String(),
+		Size:          output.Size,
+		Capacity:      output.Capacity,
+		Hits:          output.Hits,
+		Misses:        output.Misses,
+		HitRatio:      output.HitRatio,
+		Evictions:     output.Evictions,
+		Invalidations: output.Invalidations,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RegisterRoutes registers the policy routes and records them in the catalog
+func (h *PolicyHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/admin/policies", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodGet, "", h.List, "policies.list", "List policy rules")
+	g.Add(http.MethodPost, "", h.Add, "policies.add", "Add a policy rule")
+	g.Add(http.MethodDelete, "", h.Remove, "policies.remove", "Remove a policy rule")
+	g.Add(http.MethodGet, "/export", h.Export, "policies.export", "Export all policy and grouping rules")
+	g.Add(http.MethodPost, "/import", h.Import, "policies.import", "Import policy and grouping rules")
+	g.Add(http.MethodGet, "/versions", h.ListVersions, "policies.versions.list", "List policy changesets")
+	g.Add(http.MethodGet, "/versions/diff", h.DiffVersions, "policies.versions.diff", "Compare the policy at two versions")
+	g.Add(http.MethodGet, "/versions/:version", h.GetVersion, "policies.versions.read", "Get a policy changeset")
+	g.Add(http.MethodPost, "/versions/:version/rollback", h.Rollback, "policies.versions.rollback", "Restore the policy as it was at a version")
+	g.Add(http.MethodGet, "/cache", h.CacheStats, "policies.cache.read", "Get decision cache statistics")
+}
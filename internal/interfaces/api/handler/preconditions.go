@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// errIfMatchRequired is returned when a request changing a versioned resource has no If-Match header
+	errIfMatchRequired = errors.New("If-Match header is required, set it to the ETag the resource was fetched with")
+	// errInvalidIfMatch is returned when an If-Match header is not an ETag set by setVersionETag
+	errInvalidIfMatch = errors.New("invalid If-Match header: expected an ETag such as \"1\" or *")
+)
+
+// setVersionETag sets the ETag of a response to the version of the resource it represents
+func setVersionETag(c echo.Context, version uint) {
+	c.Response().Header().Set("ETag", `"`+strconv.FormatUint(uint64(version), 10)+`"`)
+}
+
+// ifMatchVersion parses the version of the resource a request is based on from its If-Match header,
+// which is required. * matches whichever version is current and gives 0.
+func ifMatchVersion(c echo.Context) (uint, error) {
+	value := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if value == "" {
+		return 0, errIfMatchRequired
+	}
+	if value == "*" {
+		return 0, nil
+	}
+
+	unquoted, ok := strings.CutPrefix(value, `"`)
+	if !ok {
+		return 0, errInvalidIfMatch
+	}
+	unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	if !ok {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.ParseUint(unquoted, 10, 32)
+	if err != nil || version == 0 {
+		return 0, errInvalidIfMatch
+	}
+	return uint(version), nil
+}
+
+// ifMatchErrorResponse maps an error parsing the If-Match header to a response
+func ifMatchErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, errIfMatchRequired) {
+		return c.JSON(http.StatusPreconditionRequired, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+// conditionalErrorResponse maps the error of a request conditioned by If-Match to a response.
+// The resource having changed since fails the precondition, other errors are mapped by fallback.
+func conditionalErrorResponse(c echo.Context, err error, fallback func(echo.Context, error) error) error {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return c.JSON(http.StatusPreconditionFailed, map[string]string{"error": err.Error()})
+	}
+	return fallback(c, err)
+}
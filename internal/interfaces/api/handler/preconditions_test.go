@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantVersion uint
+		// wantStatus is the status of the response to the error, 0 when the header is valid
+		wantStatus int
+	}{
+		{header: "", wantStatus: http.StatusPreconditionRequired},
+		{header: "*", wantVersion: 0},
+		{header: `"3"`, wantVersion: 3},
+		{header: "3", wantStatus: http.StatusBadRequest},
+		{header: `W/"3"`, wantStatus: http.StatusBadRequest},
+		{header: `"0"`, wantStatus: http.StatusBadRequest},
+		{header: `"1", "2"`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/v1/users/1", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			if err := ifMatchErrorResponse(c, err); err != nil {
+				t.Fatalf("ifMatchErrorResponse failed: %v", err)
+			}
+		}
+		if tt.wantStatus != 0 && rec.Code != tt.wantStatus || tt.wantStatus == 0 && (err != nil || version != tt.wantVersion) {
+			t.Errorf("ifMatchVersion(%q) = %d, %v with status %d, want %d with status %d", tt.header, version, err, rec.Code, tt.wantVersion, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title Privacy API
+// @version 1.0
+// @description API for data subject requests: exporting and erasing the data of users
+// @BasePath /v1/users
+
+// Formats of user data exports
+const (
+	userDataFormatJSON = "json"
+	userDataFormatZIP  = "zip"
+)
+
+// PrivacyHandler handles HTTP requests for data subject requests
+type PrivacyHandler struct {
+	privacyUseCase interfaces.PrivacyUseCase
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler
+func NewPrivacyHandler(privacyUseCase interfaces.PrivacyUseCase) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyUseCase: privacyUseCase,
+	}
+}
+
+// SessionsResponse describes the sessions of a user. Tokens are stateless JWTs, so no session is
+// kept on the server: only the time before which their tokens are rejected is.
+type SessionsResponse struct {
+	TokensRevokedAt string `json:"tokens_revoked_at,omitempty"`
+}
+
+// CreatedGroupResponse represents a group created by a user
+type CreatedGroupResponse struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Domain    string `json:"domain"`
+	CreatedAt string `json:"created_at"`
+}
+
+// UserDataExportResponse represents everything kept about a user
+type UserDataExportResponse struct {
+	ExportedAt string             `json:"exported_at"`
+	User       *UserResponse      `json:"user"`
+	Roles      *UserRolesResponse `json:"roles"`
+	Sessions   *SessionsResponse  `json:"sessions"`
+	// RoleGrants are the grants to the user, or requested or decided by them
+	RoleGrants []*RoleGrantResponse `json:"role_grants"`
+	// Invitations are the invitations sent to the user's email, accepted by them or sent by them
+	Invitations []*InvitationResponse `json:"invitations"`
+	// PolicyChanges are the policy versions made by the user or changing their rules
+	PolicyChanges   []*PolicyVersionResponse  `json:"policy_changes"`
+	UserImports     []*UserImportResponse     `json:"user_imports"`
+	GroupsCreated   []*CreatedGroupResponse   `json:"groups_created"`
+	ErasureRequests []*ErasureRequestResponse `json:"erasure_requests"`
+}
+
+// toUserDataExportResponse converts a user data export to a response
+func toUserDataExportResponse(output *dto.UserDataExport) *UserDataExportResponse {
+	resp := &UserDataExportResponse{
+		ExportedAt:      output.ExportedAt.Format("2006-01-02T15:04:05Z07:00"),
+		User:            toUserResponse(output.User),
+		Roles:           toUserRolesResponse(output.Roles),
+		Sessions:        &SessionsResponse{},
+		RoleGrants:      make([]*RoleGrantResponse, len(output.RoleGrants)),
+		Invitations:     make([]*InvitationResponse, len(output.Invitations)),
+		PolicyChanges:   make([]*PolicyVersionResponse, len(output.PolicyVersions)),
+		UserImports:     make([]*UserImportResponse, len(output.UserImports)),
+		GroupsCreated:   make([]*CreatedGroupResponse, len(output.Groups)),
+		ErasureRequests: make([]*ErasureRequestResponse, len(output.ErasureRequests)),
+	}
+	if output.User.TokensRevokedAt != nil {
+		resp.Sessions.TokensRevokedAt = output.User.TokensRevokedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	for i, grant := range output.RoleGrants {
+		resp.RoleGrants[i] = toRoleGrantResponse(grant)
+	}
+	for i, invitation := range output.Invitations {
+		resp.Invitations[i] = toInvitationResponse(invitation)
+	}
+	for i, version := range output.PolicyVersions {
+		resp.PolicyChanges[i] = toPolicyVersionResponse(version)
+	}
+	for i, userImport := range output.UserImports {
+		resp.UserImports[i] = toUserImportResponse(userImport)
+	}
+	for i, group := range output.Groups {
+		resp.GroupsCreated[i] = &CreatedGroupResponse{
+			ID:        group.ID,
+			Name:      group.Name,
+			Domain:    group.Domain,
+			CreatedAt: group.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+	}
+	for i, request := range output.ErasureRequests {
+		resp.ErasureRequests[i] = toErasureRequestResponse(request)
+	}
+	return resp
+}
+
+// ErasureRequestRequest represents the request for erasing a user
+type ErasureRequestRequest struct {
+	Reason string `json:"reason" validate:"max=1000"`
+}
+
+// ErasureRequestResponse represents an erasure request in the response
+type ErasureRequestResponse struct {
+	ID          uint   `json:"id"`
+	UserID      uint   `json:"user_id"`
+	Reason      string `json:"reason"`
+	Status      string `json:"status"`
+	RequestedBy string `json:"requested_by"`
+	ScheduledAt string `json:"scheduled_at"`
+	CancelledBy string `json:"cancelled_by,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// toErasureRequestResponse converts an erasure request entity to a response
+func toErasureRequestResponse(request *entity.ErasureRequest) *ErasureRequestResponse {
+	resp := &ErasureRequestResponse{
+		ID:          request.ID,
+		UserID:      request.UserID,
+		Reason:      request.Reason,
+		Status:      request.Status,
+		RequestedBy: request.RequestedBy,
+		ScheduledAt: request.ScheduledAt.Format("2006-01-02T15:04:05Z07:00"),
+		CancelledBy: request.CancelledBy,
+		CreatedAt:   request.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   request.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if request.CompletedAt != nil {
+		resp.CompletedAt = request.CompletedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// privacyErrorResponse maps a data subject request error to a response
+func privacyErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrUserNotFound), errors.Is(err, entity.ErrErasureRequestNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrErasurePending), errors.Is(err, entity.ErrErasureNotPending):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrUserErased):
+		return c.JSON(http.StatusGone, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// Export handles exporting everything kept about a user
+// @Summary Export the data of a user
+// @Description Export everything kept about a user, soft deleted or not: their account, roles, sessions and the records referring to them. The zip format bundles the JSON document as user.json with the avatar of the user.
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Produce application/zip
+// @Param id path int true "User ID"
+// @Param format query string false "json (default) or zip"
+// @Success 200 {object} UserDataExportResponse "Data of the user"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/export [get]
+func (h *PrivacyHandler) Export(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = userDataFormatJSON
+	}
+	if format != userDataFormatJSON && format != userDataFormatZIP {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported format %q, expected json or zip", format)})
+	}
+
+	output, err := h.privacyUseCase.Export(c.Request().Context(), uint(id))
+	if err != nil {
+		return privacyErrorResponse(c, err)
+	}
+
+	resp := toUserDataExportResponse(output)
+	filename := fmt.Sprintf("user-%d.%s", output.User.ID, format)
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+filename)
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	if format == userDataFormatJSON {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	data, err := userDataArchive(resp, output.Avatar)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.Blob(http.StatusOK, "application/zip", data)
+}
+
+// userDataArchive bundles a user data export and the avatar of the user, if any, in a ZIP archive
+func userDataArchive(resp *UserDataExportResponse, avatar *dto.AvatarOutput) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	file, err := archive.Create("user.json")
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(resp); err != nil {
+		return nil, err
+	}
+
+	if avatar != nil {
+		// Avatars are always stored as PNG
+		file, err := archive.Create("avatar.png")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(avatar.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RequestErasure handles requesting the erasure of a user
+// @Summary Request the erasure of a user
+// @Description Schedule the erasure of a user after USER_ERASURE_DELAY, until when it can be cancelled. Erasing a user anonymizes their account and the records referring to them, removes their Casbin rules and revokes their tokens.
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body ErasureRequestRequest false "Erasure request"
+// @Success 202 {object} ErasureRequestResponse "Scheduled erasure request"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 409 {object} map[string]string "An erasure is already pending"
+// @Failure 410 {object} map[string]string "User was erased"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/erasure [post]
+func (h *PrivacyHandler) RequestErasure(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	var req ErasureRequestRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	request, err := h.privacyUseCase.RequestErasure(c.Request().Context(), dto.RequestErasureInput{
+		UserID: uint(id),
+		Reason: req.Reason,
+	})
+	if err != nil {
+		return privacyErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, toErasureRequestResponse(request))
+}
+
+// GetErasure handles getting the latest erasure request of a user
+// @Summary Get the erasure request of a user
+// @Description Get the latest erasure request of a user, to follow it up
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} ErasureRequestResponse "Erasure request"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User or erasure request not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/erasure [get]
+func (h *PrivacyHandler) GetErasure(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	request, err := h.privacyUseCase.GetErasure(c.Request().Context(), uint(id))
+	if err != nil {
+		return privacyErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toErasureRequestResponse(request))
+}
+
+// CancelErasure handles cancelling the pending erasure of a user
+// @Summary Cancel the erasure of a user
+// @Description Cancel the pending erasure request of a user before it is carried out
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} ErasureRequestResponse "Cancelled erasure request"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User or erasure request not found"
+// @Failure 409 {object} map[string]string "Erasure request is not pending"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/erasure [delete]
+func (h *PrivacyHandler) CancelErasure(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	request, err := h.privacyUseCase.CancelErasure(c.Request().Context(), uint(id))
+	if err != nil {
+		return privacyErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toErasureRequestResponse(request))
+}
+
+// loadDataSubject loads the user targeted by a request, soft deleted or not, who is the owner of their data
+func (h *PrivacyHandler) loadDataSubject(c echo.Context) (*middleware.Resource, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		// Left for the handler to reject
+		return &middleware.Resource{}, nil
+	}
+
+	user, err := h.privacyUseCase.GetDataSubject(c.Request().Context(), uint(id))
+	if errors.Is(err, entity.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := h.privacyUseCase.GetOwnerRole(c.Request().Context(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middleware.Resource{OwnerID: user.ID, OwnerRole: role}, nil
+}
+
+// RegisterResourceLoaders declares how the resources targeted by the privacy routes are loaded
+func (h *PrivacyHandler) RegisterResourceLoaders(loaders middleware.ResourceLoaders) {
+	loaders.Register(http.MethodGet, "/v1/users/:id/export", h.loadDataSubject)
+	loaders.Register(http.MethodPost, "/v1/users/:id/erasure", h.loadDataSubject)
+	loaders.Register(http.MethodGet, "/v1/users/:id/erasure", h.loadDataSubject)
+	loaders.Register(http.MethodDelete, "/v1/users/:id/erasure", h.loadDataSubject)
+}
+
+// RegisterRoutes registers the privacy routes and records them in the catalog
+func (h *PrivacyHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/users", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodGet, "/:id/export", h.Export, "users.personal_data.export", "Export everything kept about a user")
+	g.Add(http.MethodPost, "/:id/erasure", h.RequestErasure, "users.erasure.request", "Schedule the erasure of a user", middleware.ForbidImpersonation())
+	g.Add(http.MethodGet, "/:id/erasure", h.GetErasure, "users.erasure.read", "Get the erasure request of a user")
+	g.Add(http.MethodDelete, "/:id/erasure", h.CancelErasure, "users.erasure.cancel", "Cancel the pending erasure of a user", middleware.ForbidImpersonation())
+}
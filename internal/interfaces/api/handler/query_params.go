@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// queryBool parses an optional boolean query parameter
+func queryBool(c echo.Context, name string) (*bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected true or false", name)
+	}
+	return &b, nil
+}
+
+// queryTime parses an optional RFC 3339 time query parameter
+func queryTime(c echo.Context, name string) (*time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected a time such as 2006-01-02T15:04:05Z", name)
+	}
+	return &t, nil
+}
+
+// pageLink returns the URL of the request with its page cursor replaced, as given under name
+// (after or before), or an empty string when there is no cursor
+func pageLink(c echo.Context, name, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	query := c.QueryParams()
+	link := url.Values{}
+	for key, values := range query {
+		if key != "after" && key != "before" {
+			link[key] = values
+		}
+	}
+	link.Set(name, cursor)
+	return c.Request().URL.Path + "?" + link.Encode()
+}
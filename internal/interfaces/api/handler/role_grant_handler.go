@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title Role Grant API
+// @version 1.0
+// @description API for managing time-bound role assignments
+// @BasePath /v1/role-grants
+
+// RoleGrantHandler handles HTTP requests for role grants
+type RoleGrantHandler struct {
+	roleGrantUseCase interfaces.RoleGrantUseCase
+}
+
+// NewRoleGrantHandler creates a new RoleGrantHandler
+func NewRoleGrantHandler(roleGrantUseCase interfaces.RoleGrantUseCase) *RoleGrantHandler {
+	return &RoleGrantHandler{
+		roleGrantUseCase: roleGrantUseCase,
+	}
+}
+
+// RoleGrantResponse represents a role grant in the response
+type RoleGrantResponse struct {
+	ID          uint   `json:"id"`
+	Subject     string `json:"subject"`
+	Role        string `json:"role"`
+	Domain      string `json:"domain"`
+	Reason      string `json:"reason"`
+	Status      string `json:"status"`
+	StartsAt    string `json:"starts_at"`
+	ExpiresAt   string `json:"expires_at"`
+	RequestedBy string `json:"requested_by"`
+	DecidedBy   string `json:"decided_by,omitempty"`
+	DecidedAt   string `json:"decided_at,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// toRoleGrantResponse converts a role grant entity to a role grant response
+func toRoleGrantResponse(grant *entity.RoleGrant) *RoleGrantResponse {
+	resp := &RoleGrantResponse{
+		ID:          grant.ID,
+		Subject:     grant.Subject,
+		Role:        grant.Role,
+		Domain:      grant.Domain,
+		Reason:      grant.Reason,
+		Status:      grant.Status,
+		StartsAt:    grant.StartsAt.Format("2006-01-02T15:04:05Z07:00"),
+		ExpiresAt:   grant.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		RequestedBy: grant.RequestedBy,
+		DecidedBy:   grant.DecidedBy,
+		CreatedAt:   grant.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   grant.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if grant.DecidedAt != nil {
+		resp.DecidedAt = grant.DecidedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// roleGrantErrorResponse maps a role grant error to a response
+func roleGrantErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrRoleNotAssignable):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrRoleGrantNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrRoleGrantTransition):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// CreateRoleGrantRequest represents the request for granting a role temporarily
+type CreateRoleGrantRequest struct {
+	Subject   string    `json:"subject" validate:"required"`
+	Role      string    `json:"role" validate:"required"`
+	Domain    string    `json:"domain"`
+	Reason    string    `json:"reason"`
+	StartsAt  time.Time `json:"starts_at"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+// Create handles granting a role temporarily
+// @Summary Grant a role temporarily
+// @Description Grant a role to a user from starts_at (default now) until expires_at. Grants of privileged roles stay pending until approved.
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param request body CreateRoleGrantRequest true "Role grant request"
+// @Success 201 {object} RoleGrantResponse "Created role grant"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Not allowed to assign the role"
+// @Router / [post]
+func (h *RoleGrantHandler) Create(c echo.Context) error {
+	var req CreateRoleGrantRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.CreateRoleGrantInput{
+		Subject:   req.Subject,
+		Role:      req.Role,
+		Domain:    req.Domain,
+		Reason:    req.Reason,
+		StartsAt:  req.StartsAt,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	grant, err := h.roleGrantUseCase.Create(c.Request().Context(), input)
+	if errors.Is(err, entity.ErrRoleNotAssignable) {
+		return roleGrantErrorResponse(c, err)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, toRoleGrantResponse(grant))
+}
+
+// RequestElevationRequest represents the request for requesting a role for oneself
+type RequestElevationRequest struct {
+	Role      string    `json:"role" validate:"required"`
+	Reason    string    `json:"reason" validate:"required"`
+	StartsAt  time.Time `json:"starts_at"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+// RequestElevation handles requesting a role for the authenticated user
+// @Summary Request a role elevation
+// @Description Request a role for the authenticated user from starts_at (default now) until expires_at. The request stays pending until approved by someone else.
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param request body RequestElevationRequest true "Elevation request"
+// @Success 201 {object} RoleGrantResponse "Pending role grant"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /request [post]
+func (h *RoleGrantHandler) RequestElevation(c echo.Context) error {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok || principal.Type != middleware.PrincipalTypeUser {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	var req RequestElevationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.CreateRoleGrantInput{
+		Subject:   principal.Subject,
+		Role:      req.Role,
+		Domain:    principal.Domain,
+		Reason:    req.Reason,
+		StartsAt:  req.StartsAt,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	grant, err := h.roleGrantUseCase.RequestElevation(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, toRoleGrantResponse(grant))
+}
+
+// Get handles getting a role grant by ID
+// @Summary Get a role grant
+// @Description Retrieve a role grant by its ID
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param id path int true "Role grant ID"
+// @Success 200 {object} RoleGrantResponse "Role grant details"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Role grant not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id} [get]
+func (h *RoleGrantHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid role grant ID"})
+	}
+
+	grant, err := h.roleGrantUseCase.GetByID(c.Request().Context(), uint(id))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if grant == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Role grant not found"})
+	}
+
+	return c.JSON(http.StatusOK, toRoleGrantResponse(grant))
+}
+
+// ListRoleGrantsResponse represents the response for listing role grants
+type ListRoleGrantsResponse struct {
+	Grants     []*RoleGrantResponse `json:"grants"`
+	TotalCount int64                `json:"total_count"`
+}
+
+// List handles listing role grants
+// @Summary List role grants
+// @Description Get a paginated list of role grants, newest first
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param status query string false "pending, approved, active, expired, rejected or revoked"
+// @Param subject query string false "Only list the grants of this user"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10)"
+// @Success 200 {object} ListRoleGrantsResponse "List of role grants"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router / [get]
+func (h *RoleGrantHandler) List(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	input := dto.ListRoleGrantsInput{
+		Status:  c.QueryParam("status"),
+		Subject: c.QueryParam("subject"),
+		Page:    page,
+		Limit:   limit,
+	}
+
+	output, err := h.roleGrantUseCase.List(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	grants := make([]*RoleGrantResponse, len(output.Grants))
+	for i, grant := range output.Grants {
+		grants[i] = toRoleGrantResponse(grant)
+	}
+
+	resp := ListRoleGrantsResponse{
+		Grants:     grants,
+		TotalCount: output.TotalCount,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Approve handles approving a pending role grant
+// @Summary Approve a role grant
+// @Description Approve a pending role grant; the role is assigned at the grant's start time
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param id path int true "Role grant ID"
+// @Success 200 {object} RoleGrantResponse "Approved role grant"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Role grant not found"
+// @Failure 409 {object} map[string]string "Role grant is not pending, or was requested by the approver"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/approve [post]
+func (h *RoleGrantHandler) Approve(c echo.Context) error {
+	return h.decide(c, h.roleGrantUseCase.Approve)
+}
+
+// Reject handles rejecting a pending role grant
+// @Summary Reject a role grant
+// @Description Reject a pending role grant
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param id path int true "Role grant ID"
+// @Success 200 {object} RoleGrantResponse "Rejected role grant"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Role grant not found"
+// @Failure 409 {object} map[string]string "Role grant is not pending"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/reject [post]
+func (h *RoleGrantHandler) Reject(c echo.Context) error {
+	return h.decide(c, h.roleGrantUseCase.Reject)
+}
+
+// Revoke handles withdrawing a role grant
+// @Summary Revoke a role grant
+// @Description Withdraw a pending, approved or active role grant; an active role is unassigned immediately
+// @Tags role-grants
+// @Accept json
+// @Produce json
+// @Param id path int true "Role grant ID"
+// @Success 200 {object} RoleGrantResponse "Revoked role grant"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Role grant not found"
+// @Failure 409 {object} map[string]string "Role grant has already ended"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/revoke [post]
+func (h *RoleGrantHandler) Revoke(c echo.Context) error {
+	return h.decide(c, h.roleGrantUseCase.Revoke)
+}
+
+// decide applies a decision to the role grant in the route
+func (h *RoleGrantHandler) decide(c echo.Context, decision func(ctx context.Context, id uint) (*entity.RoleGrant, error)) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid role grant ID"})
+	}
+
+	grant, err := decision(c.Request().Context(), uint(id))
+	if err != nil {
+		return roleGrantErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toRoleGrantResponse(grant))
+}
+
+// RegisterRoutes registers the role grant routes and records them in the catalog
+func (h *RoleGrantHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/role-grants", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodPost, "", h.Create, "role_grants.create", "Grant a role temporarily")
+	g.Add(http.MethodPost, "/request", h.RequestElevation, "role_grants.request", "Request a role for yourself", middleware.ForbidImpersonation())
+	g.Add(http.MethodGet, "", h.List, "role_grants.list", "List role grants")
+	g.Add(http.MethodGet, "/:id", h.Get, "role_grants.read", "Get a role grant")
+	g.Add(http.MethodPost, "/:id/approve", h.Approve, "role_grants.approve", "Approve a pending role grant", middleware.ForbidImpersonation())
+	g.Add(http.MethodPost, "/:id/reject", h.Reject, "role_grants.reject", "Reject a pending role grant")
+	g.Add(http.MethodPost, "/:id/revoke", h.Revoke, "role_grants.revoke", "Revoke a role grant")
+}
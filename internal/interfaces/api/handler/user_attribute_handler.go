@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title User Attribute API
+// @version 1.0
+// @description API for defining the custom attributes of users
+// @BasePath /v1/user-attributes
+
+// UserAttributeHandler handles HTTP requests for custom user attribute definitions
+type UserAttributeHandler struct {
+	userAttributeUseCase interfaces.UserAttributeUseCase
+}
+
+// NewUserAttributeHandler creates a new UserAttributeHandler
+func NewUserAttributeHandler(userAttributeUseCase interfaces.UserAttributeUseCase) *UserAttributeHandler {
+	return &UserAttributeHandler{
+		userAttributeUseCase: userAttributeUseCase,
+	}
+}
+
+// UserAttributeResponse represents a custom attribute definition in the response
+type UserAttributeResponse struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Required    bool     `json:"required"`
+	Options     []string `json:"options,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// toUserAttributeResponse converts an attribute definition to an attribute response
+func toUserAttributeResponse(attribute *entity.UserAttribute) *UserAttributeResponse {
+	return &UserAttributeResponse{
+		Name:        attribute.Name,
+		Type:        attribute.Type,
+		Description: attribute.Description,
+		Required:    attribute.Required,
+		Options:     attribute.Options,
+		CreatedAt:   attribute.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   attribute.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// ListUserAttributesResponse represents the response for listing attribute definitions
+type ListUserAttributesResponse struct {
+	Attributes []*UserAttributeResponse `json:"attributes"`
+}
+
+// List handles listing attribute definitions
+// @Summary List custom attributes
+// @Description List the custom attributes users may hold, sorted by name
+// @Tags user-attributes
+// @Accept json
+// @Produce json
+// @Success 200 {object} ListUserAttributesResponse "Attribute definitions"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router / [get]
+func (h *UserAttributeHandler) List(c echo.Context) error {
+	attributes, err := h.userAttributeUseCase.List(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	resp := ListUserAttributesResponse{
+		Attributes: make([]*UserAttributeResponse, len(attributes)),
+	}
+	for i, attribute := range attributes {
+		resp.Attributes[i] = toUserAttributeResponse(attribute)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// PutUserAttributeRequest represents the request for defining a custom attribute
+type PutUserAttributeRequest struct {
+	Type        string `json:"type" validate:"required,oneof=string number integer boolean enum"`
+	Description string `json:"description"`
+	// Required attributes must be set whenever a user is updated
+	Required bool `json:"required"`
+	// Options are the values allowed for enum attributes
+	Options []string `json:"options"`
+}
+
+// Put handles defining a custom attribute, or changing its definition
+// @Summary Define a custom attribute
+// @Description Define a custom attribute users may hold, or change its definition. Values already held by users are not checked again until the user is updated.
+// @Tags user-attributes
+// @Accept json
+// @Produce json
+// @Param name path string true "Attribute name: lowercase letters, digits and underscores, starting with a letter"
+// @Param request body PutUserAttributeRequest true "Attribute definition"
+// @Success 200 {object} UserAttributeResponse "Changed definition"
+// @Success 201 {object} UserAttributeResponse "New definition"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{name} [put]
+func (h *UserAttributeHandler) Put(c echo.Context) error {
+	var req PutUserAttributeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.PutUserAttributeInput{
+		Name:        c.Param("name"),
+		Type:        req.Type,
+		Description: req.Description,
+		Required:    req.Required,
+		Options:     req.Options,
+	}
+
+	output, err := h.userAttributeUseCase.Put(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	status := http.StatusOK
+	if output.Created {
+		status = http.StatusCreated
+	}
+	return c.JSON(status, toUserAttributeResponse(output.Attribute))
+}
+
+// Delete handles deleting a custom attribute
+// @Summary Delete a custom attribute
+// @Description Delete a custom attribute definition and remove its value from every user
+// @Tags user-attributes
+// @Accept json
+// @Produce json
+// @Param name path string true "Attribute name"
+// @Success 204 "No content"
+// @Failure 404 {object} map[string]string "Attribute not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{name} [delete]
+func (h *UserAttributeHandler) Delete(c echo.Context) error {
+	if err := h.userAttributeUseCase.Delete(c.Request().Context(), c.Param("name")); err != nil {
+		if errors.Is(err, entity.ErrUserAttributeNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RegisterRoutes registers the user attribute routes and records them in the catalog
+func (h *UserAttributeHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/user-attributes", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodGet, "", h.List, "user_attributes.list", "List custom user attributes")
+	g.Add(http.MethodPut, "/:name", h.Put, "user_attributes.put", "Define a custom user attribute")
+	g.Add(http.MethodDelete, "/:name", h.Delete, "user_attributes.delete", "Delete a custom user attribute")
+}
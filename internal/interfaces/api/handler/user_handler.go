@@ -1,12 +1,20 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
 	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
 	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
 	"github.com/labstack/echo/v4"
 )
 
@@ -18,21 +26,33 @@ import (
 // UserHandler handles HTTP requests for users
 type UserHandler struct {
 	userUseCase interfaces.UserUseCase
+	// avatarMaxSize is the largest avatar upload accepted, in bytes
+	avatarMaxSize int
 }
 
 // NewUserHandler creates a new UserHandler
-func NewUserHandler(userUseCase interfaces.UserUseCase) *UserHandler {
+func NewUserHandler(userUseCase interfaces.UserUseCase, avatarMaxSize int) *UserHandler {
 	return &UserHandler{
-		userUseCase: userUseCase,
+		userUseCase:   userUseCase,
+		avatarMaxSize: avatarMaxSize,
 	}
 }
 
-// RegisterRequest represents the request for user registration
+// RegisterRequest represents the request for user registration.
+// Self-registered users get the default role, see CreateUserRequest to choose one.
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
-	Role     string `json:"role" validate:"required"`
+}
+
+// CreateUserRequest represents the request for creating a user on their behalf
+type CreateUserRequest struct {
+	Username string `json:"username" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=6"`
+	// Role defaults to the role of self-registered users
+	Role string `json:"role"`
 }
 
 // RegisterResponse represents the response for user registration
@@ -50,11 +70,26 @@ type UserResponse struct {
 	Active    bool   `json:"active"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+	// Version is incremented by every update, it is also given as the ETag of a single user
+	Version uint `json:"version"`
+
+	DisplayName string         `json:"display_name"`
+	Locale      string         `json:"locale"`
+	Timezone    string         `json:"timezone"`
+	Attributes  map[string]any `json:"attributes"`
+	// AvatarURL is empty when the user has no avatar
+	AvatarURL string `json:"avatar_url,omitempty"`
+	// PendingEmail replaces Email once confirmed before PendingEmailExpiresAt
+	PendingEmail          string `json:"pending_email,omitempty"`
+	PendingEmailExpiresAt string `json:"pending_email_expires_at,omitempty"`
+	// ErasedAt is set once the user was erased, leaving a pseudonymous stub
+	ErasedAt string `json:"erased_at,omitempty"`
 }
 
 // toUserResponse converts a user entity to a user response
 func toUserResponse(user *entity.User) *UserResponse {
-	return &UserResponse{
+	resp := &UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
 		Email:     user.Email,
@@ -62,18 +97,68 @@ func toUserResponse(user *entity.User) *UserResponse {
 		Active:    user.Active,
 		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt: user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Version:   user.Version,
+
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Attributes:  user.Attributes,
+	}
+	if user.DeletedAt != nil {
+		resp.DeletedAt = user.DeletedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if resp.Attributes == nil {
+		resp.Attributes = map[string]any{}
+	}
+	if user.AvatarKey != "" {
+		resp.AvatarURL = fmt.Sprintf("/v1/users/%d/avatar", user.ID)
+	}
+	if user.PendingEmail != "" && user.PendingEmailExpiresAt != nil {
+		resp.PendingEmail = user.PendingEmail
+		resp.PendingEmailExpiresAt = user.PendingEmailExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if user.ErasedAt != nil {
+		resp.ErasedAt = user.ErasedAt.Format("2006-01-02T15:04:05Z07:00")
 	}
+	return resp
+}
+
+// userErrorResponse maps a user error to a response
+func userErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrUserNotFound), errors.Is(err, entity.ErrAvatarNotFound),
+		errors.Is(err, entity.ErrEmailChangeNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrInvalidProfile), errors.Is(err, entity.ErrInvalidAvatar), errors.Is(err, entity.ErrInvalidPatch):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrUnsupportedAvatarType):
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrImpersonationNotAllowed), errors.Is(err, entity.ErrRoleNotAssignable),
+		errors.Is(err, entity.ErrRegistrationClosed), errors.Is(err, entity.ErrEmailDomainNotAllowed),
+		errors.Is(err, entity.ErrFieldChangeNotAllowed):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrUserErased), errors.Is(err, entity.ErrEmailChangeExpired):
+		return c.JSON(http.StatusGone, map[string]string{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict), errors.Is(err, entity.ErrEmailTaken),
+		errors.Is(err, entity.ErrUsernameTaken):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, entity.ErrEmailChangeNotSent):
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 }
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Register a new user with the provided details
+// @Description Register a new user with the default role, as allowed by REGISTRATION_MODE
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param request body RegisterRequest true "User registration request"
 // @Success 201 {object} RegisterResponse "Registered user with token"
-// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 400 {object} map[string]string "Bad request, or the username is the name of a role"
+// @Failure 403 {object} map[string]string "Registration is closed, or the email domain is not allowed"
+// @Failure 409 {object} map[string]string "Username or email already taken"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /register [post]
 func (h *UserHandler) Register(c echo.Context) error {
@@ -90,12 +175,11 @@ func (h *UserHandler) Register(c echo.Context) error {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: req.Password,
-		Role:     req.Role,
 	}
 
 	output, err := h.userUseCase.Register(c.Request().Context(), input)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return userErrorResponse(c, err)
 	}
 
 	resp := RegisterResponse{
@@ -106,6 +190,45 @@ func (h *UserHandler) Register(c echo.Context) error {
 	return c.JSON(http.StatusCreated, resp)
 }
 
+// CreateUser handles creating a user on their behalf
+// @Summary Create a user
+// @Description Create a user holding a role (default: the role of self-registered users). The caller must be allowed to assign the role, by a rule granting assign on roles/<role>.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body CreateUserRequest true "User creation request"
+// @Success 201 {object} UserResponse "Created user"
+// @Failure 400 {object} map[string]string "Bad request, or the username is the name of a role"
+// @Failure 403 {object} map[string]string "Not allowed to assign the role"
+// @Failure 409 {object} map[string]string "Username or email already taken"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router / [post]
+func (h *UserHandler) CreateUser(c echo.Context) error {
+	var req CreateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.CreateUserInput{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+		Role:     req.Role,
+	}
+
+	user, err := h.userUseCase.CreateUser(c.Request().Context(), input)
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	setVersionETag(c, user.Version)
+	return c.JSON(http.StatusCreated, toUserResponse(user))
+}
+
 // LoginRequest represents the request for user login
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
@@ -159,12 +282,13 @@ func (h *UserHandler) Login(c echo.Context) error {
 
 // GetUser handles getting a user by ID
 // @Summary Get a user by ID
-// @Description Retrieve a user by their ID
+// @Description Retrieve a user by their ID. The ETag is to be sent back in If-Match to update or delete the user.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
 // @Success 200 {object} UserResponse "User details"
+// @Header 200 {string} ETag "Version of the user"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Internal server error"
@@ -184,26 +308,44 @@ func (h *UserHandler) GetUser(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
 
+	setVersionETag(c, user.Version)
 	return c.JSON(http.StatusOK, toUserResponse(user))
 }
 
-// UpdateUserRequest represents the request for updating a user
+// UpdateUserRequest represents the request for updating a user.
+// Omitted profile fields and attributes are cleared.
 type UpdateUserRequest struct {
 	Username string `json:"username" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
+	// Email only replaces the current one once confirmed, see RequestEmailChange
+	Email       string `json:"email" validate:"required,email"`
+	DisplayName string `json:"display_name"`
+	// Locale is a BCP 47 language tag, e.g. en-US
+	Locale string `json:"locale"`
+	// Timezone is an IANA time zone name, e.g. Europe/Paris
+	Timezone string `json:"timezone"`
+	// Attributes holds values of the custom attributes defined at /v1/user-attributes
+	Attributes map[string]any `json:"attributes"`
 }
 
 // UpdateUser handles updating a user
 // @Summary Update a user
-// @Description Update an existing user with the provided details
+// @Description Update an existing user with the provided details, replacing their profile fields and custom attributes. A new username must not be taken nor name a role; renaming moves the Casbin rules of the user and revokes their tokens. A new email is not set right away but sent a confirmation, see the email-change endpoint, and shown as pending_email. The update only applies to the version of the user given in If-Match.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user as fetched, or * for whichever version is current"
 // @Param request body UpdateUserRequest true "User update request"
 // @Success 200 {object} UserResponse "Updated user"
-// @Failure 400 {object} map[string]string "Bad request"
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} map[string]string "Bad request, or invalid profile fields or attributes"
+// @Failure 403 {object} map[string]string "Changing the email while impersonating the user"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 409 {object} map[string]string "Another user has the new username or email"
+// @Failure 412 {object} map[string]string "User updated since the version in If-Match"
+// @Failure 428 {object} map[string]string "If-Match header missing"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 502 {object} map[string]string "The confirmation of the new email could not be sent"
 // @Router /{id} [put]
 func (h *UserHandler) UpdateUser(c echo.Context) error {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -220,20 +362,337 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return ifMatchErrorResponse(c, err)
+	}
+
 	input := dto.UpdateUserInput{
-		ID:       uint(id),
-		Username: req.Username,
-		Email:    req.Email,
+		ID:          uint(id),
+		Username:    req.Username,
+		Email:       req.Email,
+		DisplayName: req.DisplayName,
+		Locale:      req.Locale,
+		Timezone:    req.Timezone,
+		Attributes:  req.Attributes,
+		Version:     version,
 	}
 
 	user, err := h.userUseCase.UpdateUser(c.Request().Context(), input)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return conditionalErrorResponse(c, err, userErrorResponse)
 	}
 
+	setVersionETag(c, user.Version)
 	return c.JSON(http.StatusOK, toUserResponse(user))
 }
 
+// PatchUserResponse represents the response for patching a user
+type PatchUserResponse struct {
+	User *UserResponse `json:"user"`
+	// Changes are the fields the patch changed, for auditing
+	Changes []*FieldChangeResponse `json:"changes"`
+}
+
+// PatchUser handles partially updating a user
+// @Summary Patch a user
+// @Description Partially update a user with a JSON merge patch (RFC 7396) of its username, email, display_name, locale, timezone, attributes, active and role: members replace the fields, nested in attributes, and null clears optional fields. Changing active or role needs a rule granting update on fields/users/<field>, and changing role assigning both roles. A new username must not be taken nor name a role; renaming moves the Casbin rules of the user and revokes their tokens. A new email is not set right away but sent a confirmation, see the email-change endpoint, and reported as a change of pending_email. The patch only applies to the version of the user given in If-Match.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user as fetched, or * for whichever version is current"
+// @Param request body object true "JSON merge patch, sent as application/merge-patch+json"
+// @Success 200 {object} PatchUserResponse "Patched user and the fields changed"
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} map[string]string "Bad request, or a patch setting unknown fields or invalid values"
+// @Failure 403 {object} map[string]string "Not allowed to change a field or assign a role, or changing the email while impersonating the user"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 409 {object} map[string]string "Another user has the new username or email"
+// @Failure 412 {object} map[string]string "User updated since the version in If-Match"
+// @Failure 415 {object} map[string]string "Not a JSON merge patch"
+// @Failure 428 {object} map[string]string "If-Match header missing"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 502 {object} map[string]string "The confirmation of the new email could not be sent"
+// @Router /{id} [patch]
+func (h *UserHandler) PatchUser(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		return mergePatchErrorResponse(c, err)
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return ifMatchErrorResponse(c, err)
+	}
+
+	input := dto.PatchUserInput{
+		ID:      uint(id),
+		Patch:   patch,
+		Version: version,
+	}
+
+	output, err := h.userUseCase.PatchUser(c.Request().Context(), input)
+	if err != nil {
+		return conditionalErrorResponse(c, err, userErrorResponse)
+	}
+
+	resp := PatchUserResponse{
+		User:    toUserResponse(output.User),
+		Changes: toFieldChangesResponse(output.Changes),
+	}
+
+	setVersionETag(c, output.User.Version)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RequestEmailChangeRequest represents the request for changing the email of a user
+type RequestEmailChangeRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// RequestEmailChange handles requesting to change the email of a user
+// @Summary Change the email of a user
+// @Description Send a link confirming a new email to it, and notify the current email. The email is replaced once confirmed before EMAIL_CHANGE_EXPIRATION, and shown as pending_email until then. A new request replaces the pending one.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body RequestEmailChangeRequest true "Email change request"
+// @Success 202 {object} UserResponse "User with their pending email"
+// @Header 202 {string} ETag "New version of the user"
+// @Failure 400 {object} map[string]string "Bad request, or the email is invalid or unchanged"
+// @Failure 403 {object} map[string]string "Not allowed while impersonating a user"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 409 {object} map[string]string "Another user has the email"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 502 {object} map[string]string "The confirmation could not be sent"
+// @Router /{id}/email-change [post]
+func (h *UserHandler) RequestEmailChange(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	var req RequestEmailChangeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	input := dto.RequestEmailChangeInput{
+		ID:    uint(id),
+		Email: req.Email,
+	}
+
+	user, err := h.userUseCase.RequestEmailChange(c.Request().Context(), input)
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	setVersionETag(c, user.Version)
+	return c.JSON(http.StatusAccepted, toUserResponse(user))
+}
+
+// CancelEmailChange handles withdrawing the email change of a user
+// @Summary Cancel an email change
+// @Description Withdraw the pending email change of a user, keeping their current email
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "User without pending email"
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User or email change not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/email-change [delete]
+func (h *UserHandler) CancelEmailChange(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	user, err := h.userUseCase.CancelEmailChange(c.Request().Context(), uint(id))
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	setVersionETag(c, user.Version)
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// ConfirmEmailChange handles confirming the new email of a user
+// @Summary Confirm an email change
+// @Description Replace the email of a user with the one the token of the confirmation link was sent to
+// @Tags users
+// @Produce json
+// @Param token path string true "Email change token"
+// @Success 200 {object} UserResponse "User with their new email"
+// @Failure 404 {object} map[string]string "Email change not found"
+// @Failure 409 {object} map[string]string "Another user took the email since the change was requested"
+// @Failure 410 {object} map[string]string "Email change expired"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /email-changes/{token}/confirm [post]
+func (h *UserHandler) ConfirmEmailChange(c echo.Context) error {
+	user, err := h.userUseCase.ConfirmEmailChange(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// UploadAvatar handles uploading the avatar of a user
+// @Summary Upload an avatar
+// @Description Set the avatar of a user from a PNG, JPEG or GIF image, sent as the request body or as the avatar field of a multipart form. The image type is detected from its content. The image is cropped to a square and resized to AVATAR_SIZE pixels.
+// @Tags users
+// @Accept png,jpeg,gif,mpfd
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "User with their new avatar"
+// @Failure 400 {object} map[string]string "Bad request, or the image cannot be decoded"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 413 {object} map[string]string "Image too large"
+// @Failure 415 {object} map[string]string "Not a PNG, JPEG or GIF image"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/avatar [put]
+func (h *UserHandler) UploadAvatar(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	body := c.Request().Body
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "The avatar field is missing"})
+		}
+		src, err := file.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		}
+		defer src.Close()
+		body = src
+	}
+
+	// Read one byte past the limit to tell an image of the maximum size from a larger one
+	data, err := io.ReadAll(io.LimitReader(body, int64(h.avatarMaxSize)+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if len(data) > h.avatarMaxSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("The image cannot be larger than %d bytes", h.avatarMaxSize)})
+	}
+
+	user, err := h.userUseCase.UploadAvatar(c.Request().Context(), dto.UploadAvatarInput{ID: uint(id), Data: data})
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	setVersionETag(c, user.Version)
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// GetAvatar handles getting the avatar of a user
+// @Summary Get an avatar
+// @Description Get the avatar of a user. The ETag changes with every upload, send it back in If-None-Match to skip an unchanged avatar.
+// @Tags users
+// @Produce png
+// @Param id path int true "User ID"
+// @Success 200 {file} binary "Avatar image"
+// @Success 304 "Avatar unchanged"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User or avatar not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/avatar [get]
+func (h *UserHandler) GetAvatar(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	avatar, err := h.userUseCase.GetAvatar(c.Request().Context(), uint(id))
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	etag := `"` + avatar.Version + `"`
+	c.Response().Header().Set("ETag", etag)
+	c.Response().Header().Set("Cache-Control", "private, no-cache")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Blob(http.StatusOK, avatar.ContentType, avatar.Data)
+}
+
+// GroupRolesResponse represents the roles a user inherits from a group
+type GroupRolesResponse struct {
+	Group string   `json:"group"`
+	Roles []string `json:"roles"`
+}
+
+// UserRolesResponse represents the response for the roles of a user
+type UserRolesResponse struct {
+	Domain string `json:"domain"`
+	// Direct are the roles assigned to the user
+	Direct []string `json:"direct"`
+	// Groups are the groups of the user, with the roles each of them holds
+	Groups []*GroupRolesResponse `json:"groups"`
+	// Effective are the roles the user holds, directly, through groups or by inheritance
+	Effective []string `json:"effective"`
+}
+
+// GetRoles handles resolving the roles of a user
+// @Summary Get the roles of a user
+// @Description Get the roles assigned to a user, those of their groups and the effective roles these add up to through role inheritance
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param domain query string false "Domain of the roles (default: default)"
+// @Success 200 {object} UserRolesResponse "Roles of the user"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/roles [get]
+func (h *UserHandler) GetRoles(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	output, err := h.userUseCase.GetRoles(c.Request().Context(), uint(id), c.QueryParam("domain"))
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, toUserRolesResponse(output))
+}
+
+// toUserRolesResponse converts the roles of a user to a response
+func toUserRolesResponse(output *dto.UserRolesOutput) *UserRolesResponse {
+	resp := &UserRolesResponse{
+		Domain:    output.Domain,
+		Direct:    output.Direct,
+		Groups:    make([]*GroupRolesResponse, len(output.Groups)),
+		Effective: output.Effective,
+	}
+	for i, group := range output.Groups {
+		resp.Groups[i] = &GroupRolesResponse{Group: group.Group, Roles: group.Roles}
+	}
+	return resp
+}
+
 // ChangePasswordRequest represents the request for changing a user's password
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" validate:"required"`
@@ -282,39 +741,212 @@ func (h *UserHandler) ChangePassword(c echo.Context) error {
 
 // ListUsersResponse represents the response for listing users
 type ListUsersResponse struct {
-	Users      []*UserResponse `json:"users"`
-	TotalCount int64           `json:"total_count"`
+	Users []*UserResponse `json:"users"`
+	// Next and Prev link to the following and preceding pages, when there are
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	TotalCount *int64 `json:"total_count,omitempty"`
 }
 
 // ListUsers handles listing users
 // @Summary List users
-// @Description Get a paginated list of users
+// @Description Get a paginated list of users, optionally filtered, searched and sorted
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number (default: 1)"
-// @Param limit query int false "Items per page (default: 10)"
+// @Param after query string false "Cursor from the next link of a previous page, to list the users following it"
+// @Param before query string false "Cursor from the prev link of a previous page, to list the users preceding it"
+// @Param limit query int false "Items per page (default: 10, at most 100)"
+// @Param count query bool false "Set to false to leave the total count out (default: true)"
+// @Param role query string false "Only list users with this role"
+// @Param active query bool false "Only list active or inactive users"
+// @Param created_from query string false "Only list users created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only list users created at or before this time (RFC 3339)"
+// @Param q query string false "Only list users whose username or email contains this text, ignoring case"
+// @Param sort query string false "Comma separated columns to sort by, prefixed with - for descending order: id, username, email, role, active, created_at, updated_at (default: id)"
 // @Success 200 {object} ListUsersResponse "List of users"
+// @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router / [get]
 func (h *UserHandler) ListUsers(c echo.Context) error {
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	if page <= 0 {
-		page = 1
+	return h.listUsers(c, h.userUseCase.ListUsers)
+}
+
+// ImpersonateResponse represents the response for impersonating a user
+type ImpersonateResponse struct {
+	User *UserResponse `json:"user"`
+	// ImpersonatedBy is the username of the user acting as the impersonated one
+	ImpersonatedBy string `json:"impersonated_by"`
+	Token          string `json:"token"`
+	ExpiresAt      string `json:"expires_at"`
+}
+
+// Impersonate handles issuing a token to act as a user
+// @Summary Impersonate a user
+// @Description Get a short-lived token to act as a user, for support staff troubleshooting their account. Everything done with the token is attributed to the impersonating user.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} ImpersonateResponse "Impersonation token"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/impersonate [post]
+func (h *UserHandler) Impersonate(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
 	}
 
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit <= 0 {
-		limit = 10
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok || principal.Type != middleware.PrincipalTypeUser {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only users can impersonate users"})
+	}
+
+	input := dto.ImpersonateInput{
+		UserID:  uint(id),
+		ActorID: principal.ID,
+	}
+
+	output, err := h.userUseCase.Impersonate(c.Request().Context(), input)
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	resp := ImpersonateResponse{
+		User:           toUserResponse(output.User),
+		ImpersonatedBy: output.Actor.Username,
+		Token:          output.Token,
+		ExpiresAt:      output.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ActivateUser handles activating a user
+// @Summary Activate a user
+// @Description Allow a deactivated user to log in again
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "Activated user"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/activate [post]
+func (h *UserHandler) ActivateUser(c echo.Context) error {
+	return h.setUserActive(c, true)
+}
+
+// DeactivateUser handles deactivating a user
+// @Summary Deactivate a user
+// @Description Prevent a user from logging in, without deleting their account. The tokens they were issued are revoked.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "Deactivated user"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id}/deactivate [post]
+func (h *UserHandler) DeactivateUser(c echo.Context) error {
+	return h.setUserActive(c, false)
+}
+
+// setUserActive activates or deactivates the user targeted by a request
+func (h *UserHandler) setUserActive(c echo.Context, active bool) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	input := dto.SetUserActiveInput{
+		ID:     uint(id),
+		Active: active,
+	}
+
+	user, err := h.userUseCase.SetUserActive(c.Request().Context(), input)
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	setVersionETag(c, user.Version)
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// DeleteUser handles soft deleting a user
+// @Summary Delete a user
+// @Description Soft delete a user, who can be restored until purged, provided they are still at the version given in If-Match. The tokens they were issued are revoked.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user as fetched, or * for whichever version is current"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 412 {object} map[string]string "User updated since the version in If-Match"
+// @Failure 428 {object} map[string]string "If-Match header missing"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /{id} [delete]
+func (h *UserHandler) DeleteUser(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return ifMatchErrorResponse(c, err)
 	}
 
-	input := dto.ListUsersInput{
-		Page:  page,
-		Limit: limit,
+	if err := h.userUseCase.DeleteUser(c.Request().Context(), uint(id), version); err != nil {
+		return conditionalErrorResponse(c, err, userErrorResponse)
 	}
 
-	output, err := h.userUseCase.ListUsers(c.Request().Context(), input)
+	return c.JSON(http.StatusOK, map[string]string{"message": "User deleted successfully"})
+}
+
+// ListDeletedUsers handles listing soft deleted users
+// @Summary List deleted users
+// @Description Get a paginated list of soft deleted users, with the same filters and sorting as the list of users
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param after query string false "Cursor from the next link of a previous page, to list the users following it"
+// @Param before query string false "Cursor from the prev link of a previous page, to list the users preceding it"
+// @Param limit query int false "Items per page (default: 10, at most 100)"
+// @Param count query bool false "Set to false to leave the total count out (default: true)"
+// @Param role query string false "Only list users with this role"
+// @Param active query bool false "Only list active or inactive users"
+// @Param created_from query string false "Only list users created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only list users created at or before this time (RFC 3339)"
+// @Param q query string false "Only list users whose username or email contains this text, ignoring case"
+// @Param sort query string false "Comma separated columns to sort by, prefixed with - for descending order: id, username, email, role, active, created_at, updated_at (default: id)"
+// @Success 200 {object} ListUsersResponse "List of deleted users"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /deleted [get]
+func (h *UserHandler) ListDeletedUsers(c echo.Context) error {
+	return h.listUsers(c, h.userUseCase.ListDeletedUsers)
+}
+
+// listUsers parses the page, filters and sorting of a user listing and responds with the users listed
+func (h *UserHandler) listUsers(c echo.Context, list func(context.Context, dto.ListUsersInput) (*dto.ListUsersOutput, error)) error {
+	input, err := listUsersInput(c)
 	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	output, err := list(c.Request().Context(), input)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidSort) || errors.Is(err, repository.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -325,20 +957,193 @@ func (h *UserHandler) ListUsers(c echo.Context) error {
 
 	resp := ListUsersResponse{
 		Users:      users,
+		Next:       pageLink(c, "after", output.Next),
+		Prev:       pageLink(c, "before", output.Prev),
 		TotalCount: output.TotalCount,
 	}
 
 	return c.JSON(http.StatusOK, resp)
 }
 
-// RegisterRoutes registers the user routes
-func (h *UserHandler) RegisterRoutes(e *echo.Echo, middlewares ...echo.MiddlewareFunc) {
-	g := e.Group("/v1/users", middlewares...)
+// listUsersInput parses the page, filters and sorting of a user listing
+func listUsersInput(c echo.Context) (dto.ListUsersInput, error) {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	count, err := queryBool(c, "count")
+	if err != nil {
+		return dto.ListUsersInput{}, err
+	}
+	active, err := queryBool(c, "active")
+	if err != nil {
+		return dto.ListUsersInput{}, err
+	}
+	createdFrom, err := queryTime(c, "created_from")
+	if err != nil {
+		return dto.ListUsersInput{}, err
+	}
+	createdTo, err := queryTime(c, "created_to")
+	if err != nil {
+		return dto.ListUsersInput{}, err
+	}
+
+	return dto.ListUsersInput{
+		After:       c.QueryParam("after"),
+		Before:      c.QueryParam("before"),
+		Limit:       limit,
+		SkipCount:   count != nil && !*count,
+		Role:        c.QueryParam("role"),
+		Active:      active,
+		CreatedFrom: createdFrom,
+		CreatedTo:   createdTo,
+		Search:      c.QueryParam("q"),
+		Sort:        c.QueryParam("sort"),
+	}, nil
+}
+
+// RestoreUser handles restoring a soft deleted user
+// @Summary Restore a deleted user
+// @Description Restore a soft deleted user, along with the roles they held
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "Restored user"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Deleted user not found"
+// @Failure 410 {object} map[string]string "User was erased"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /deleted/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	user, err := h.userUseCase.RestoreUser(c.Request().Context(), uint(id))
+	if err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	setVersionETag(c, user.Version)
+	return c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// PurgeUser handles permanently deleting a soft deleted user
+// @Summary Purge a deleted user
+// @Description Permanently delete a soft deleted user and remove their Casbin rules
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Deleted user not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /deleted/{id} [delete]
+func (h *UserHandler) PurgeUser(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	if err := h.userUseCase.PurgeUser(c.Request().Context(), uint(id)); err != nil {
+		return userErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "User purged successfully"})
+}
+
+// loadUser loads the user targeted by a request, who is the owner of its own account
+func (h *UserHandler) loadUser(c echo.Context) (*middleware.Resource, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		// Left for the handler to reject
+		return &middleware.Resource{}, nil
+	}
+
+	user, err := h.userUseCase.GetUserByID(c.Request().Context(), uint(id))
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	role, err := h.userUseCase.GetOwnerRole(c.Request().Context(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middleware.Resource{OwnerID: user.ID, OwnerRole: role}, nil
+}
+
+// loadDeletedUser loads the soft deleted user targeted by a request
+func (h *UserHandler) loadDeletedUser(c echo.Context) (*middleware.Resource, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		// Left for the handler to reject
+		return &middleware.Resource{}, nil
+	}
+
+	user, err := h.userUseCase.GetDeletedUserByID(c.Request().Context(), uint(id))
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	role, err := h.userUseCase.GetOwnerRole(c.Request().Context(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middleware.Resource{OwnerID: user.ID, OwnerRole: role}, nil
+}
+
+// RegisterResourceLoaders declares how the resources targeted by the user routes are loaded
+func (h *UserHandler) RegisterResourceLoaders(loaders middleware.ResourceLoaders) {
+	loaders.Register(http.MethodGet, "/v1/users/:id", h.loadUser)
+	loaders.Register(http.MethodPut, "/v1/users/:id", h.loadUser)
+	loaders.Register(http.MethodPatch, "/v1/users/:id", h.loadUser)
+	loaders.Register(http.MethodGet, "/v1/users/:id/avatar", h.loadUser)
+	loaders.Register(http.MethodPut, "/v1/users/:id/avatar", h.loadUser)
+	loaders.Register(http.MethodGet, "/v1/users/:id/roles", h.loadUser)
+	loaders.Register(http.MethodPost, "/v1/users/:id/change-password", h.loadUser)
+	loaders.Register(http.MethodPost, "/v1/users/:id/email-change", h.loadUser)
+	loaders.Register(http.MethodDelete, "/v1/users/:id/email-change", h.loadUser)
+	loaders.Register(http.MethodPost, "/v1/users/:id/impersonate", h.loadUser)
+	loaders.Register(http.MethodPost, "/v1/users/:id/activate", h.loadUser)
+	loaders.Register(http.MethodPost, "/v1/users/:id/deactivate", h.loadUser)
+	loaders.Register(http.MethodDelete, "/v1/users/:id", h.loadUser)
+	loaders.Register(http.MethodPost, "/v1/users/deleted/:id/restore", h.loadDeletedUser)
+	loaders.Register(http.MethodDelete, "/v1/users/deleted/:id", h.loadDeletedUser)
+}
+
+// RegisterRoutes registers the user routes and records them in the catalog.
+// Registration and login are public, the other routes are protected by the middlewares.
+// Changing a password and impersonating are not allowed while impersonating a user.
+func (h *UserHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	public := catalog.Group(e.Group("/v1/users"), "")
+	public.Add(http.MethodPost, "/register", h.Register, "users.register", "Register a new user")
+	public.Add(http.MethodPost, "/login", h.Login, "users.login", "Log in and get a token")
+	public.Add(http.MethodPost, "/email-changes/:token/confirm", h.ConfirmEmailChange, "users.email_change.confirm", "Confirm the new email of a user")
+
+	g := catalog.Group(e.Group("/v1/users", middlewares...), auth.DomainDefault)
 
-	g.POST("/register", h.Register)
-	g.POST("/login", h.Login)
-	g.GET("/:id", h.GetUser)
-	g.PUT("/:id", h.UpdateUser)
-	g.POST("/:id/change-password", h.ChangePassword)
-	g.GET("", h.ListUsers)
+	g.Add(http.MethodGet, "/:id", h.GetUser, "users.read", "Get a user")
+	g.Add(http.MethodPut, "/:id", h.UpdateUser, "users.update", "Update a user")
+	g.Add(http.MethodPatch, "/:id", h.PatchUser, "users.patch", "Partially update a user with a JSON merge patch")
+	g.Add(http.MethodGet, "/:id/avatar", h.GetAvatar, "users.avatar.read", "Get the avatar of a user")
+	g.Add(http.MethodGet, "/:id/roles", h.GetRoles, "users.roles.read", "Get the roles of a user, including those of their groups")
+	g.Add(http.MethodPut, "/:id/avatar", h.UploadAvatar, "users.avatar.update", "Upload the avatar of a user")
+	g.Add(http.MethodPost, "/:id/change-password", h.ChangePassword, "users.change_password", "Change the password of a user", middleware.ForbidImpersonation())
+	g.Add(http.MethodPost, "/:id/email-change", h.RequestEmailChange, "users.email_change.request", "Send a confirmation to a new email of a user", middleware.ForbidImpersonation())
+	g.Add(http.MethodDelete, "/:id/email-change", h.CancelEmailChange, "users.email_change.cancel", "Cancel the pending email change of a user")
+	g.Add(http.MethodPost, "/:id/impersonate", h.Impersonate, "users.impersonate", "Get a token to act as a user", middleware.ForbidImpersonation())
+	g.Add(http.MethodGet, "", h.ListUsers, "users.list", "List users")
+	g.Add(http.MethodPost, "", h.CreateUser, "users.create", "Create a user holding a role the caller may assign", middleware.ForbidImpersonation())
+	g.Add(http.MethodPost, "/:id/activate", h.ActivateUser, "users.activate", "Allow a user to log in again")
+	g.Add(http.MethodPost, "/:id/deactivate", h.DeactivateUser, "users.deactivate", "Prevent a user from logging in")
+	g.Add(http.MethodDelete, "/:id", h.DeleteUser, "users.delete", "Soft delete a user")
+	g.Add(http.MethodGet, "/deleted", h.ListDeletedUsers, "users.list_deleted", "List deleted users")
+	g.Add(http.MethodPost, "/deleted/:id/restore", h.RestoreUser, "users.restore", "Restore a deleted user")
+	g.Add(http.MethodDelete, "/deleted/:id", h.PurgeUser, "users.purge", "Permanently delete a deleted user and their Casbin rules")
 }
@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/dto"
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/repository"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/hinha/echo-casbin-ddd-app/internal/interfaces/api/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// @title User Import API
+// @version 1.0
+// @description API for importing and exporting users in bulk
+// @BasePath /v1/admin/users
+
+// maxUserFileSize is the largest user file accepted for import
+const maxUserFileSize = 10 << 20
+
+// userExportColumns are the columns of a CSV user export
+var userExportColumns = []string{"id", "username", "email", "role", "active", "created_at", "updated_at"}
+
+// UserImportHandler handles HTTP requests for bulk user imports and exports
+type UserImportHandler struct {
+	userImportUseCase interfaces.UserImportUseCase
+	userUseCase       interfaces.UserUseCase
+}
+
+// NewUserImportHandler creates a new UserImportHandler
+func NewUserImportHandler(userImportUseCase interfaces.UserImportUseCase, userUseCase interfaces.UserUseCase) *UserImportHandler {
+	return &UserImportHandler{
+		userImportUseCase: userImportUseCase,
+		userUseCase:       userUseCase,
+	}
+}
+
+// UserImportResponse represents a user import in the response
+type UserImportResponse struct {
+	ID            uint                     `json:"id"`
+	Format        string                   `json:"format"`
+	DryRun        bool                     `json:"dry_run"`
+	Status        string                   `json:"status"`
+	TotalRows     int                      `json:"total_rows"`
+	ProcessedRows int                      `json:"processed_rows"`
+	SucceededRows int                      `json:"succeeded_rows"`
+	FailedRows    int                      `json:"failed_rows"`
+	Errors        []entity.UserImportError `json:"errors"`
+	Error         string                   `json:"error,omitempty"`
+	RequestedBy   string                   `json:"requested_by"`
+	StartedAt     string                   `json:"started_at,omitempty"`
+	FinishedAt    string                   `json:"finished_at,omitempty"`
+	CreatedAt     string                   `json:"created_at"`
+}
+
+// toUserImportResponse converts a user import entity to a user import response
+func toUserImportResponse(userImport *entity.UserImport) *UserImportResponse {
+	resp := &UserImportResponse{
+		ID:            userImport.ID,
+		Format:        userImport.Format,
+		DryRun:        userImport.DryRun,
+		Status:        userImport.Status,
+		TotalRows:     userImport.TotalRows,
+		ProcessedRows: userImport.ProcessedRows,
+		SucceededRows: userImport.SucceededRows,
+		FailedRows:    userImport.FailedRows(),
+		Errors:        userImport.Errors,
+		Error:         userImport.Error,
+		RequestedBy:   userImport.RequestedBy,
+		CreatedAt:     userImport.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if resp.Errors == nil {
+		resp.Errors = []entity.UserImportError{}
+	}
+	if userImport.StartedAt != nil {
+		resp.StartedAt = userImport.StartedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if userImport.FinishedAt != nil {
+		resp.FinishedAt = userImport.FinishedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// userFileFormatParam returns the user file format requested, CSV by default
+func userFileFormatParam(c echo.Context) string {
+	if format := c.QueryParam("format"); format != "" {
+		return format
+	}
+	return entity.UserFileFormatCSV
+}
+
+// Import handles queuing a bulk user import
+// @Summary Import users
+// @Description Queue the import of users from a CSV or JSONL file sent as the request body. CSV files start with a header naming the columns username, email, password, role and optionally active; JSONL files hold an object with these fields per line. Each user is assigned their role in Casbin. The import runs in the background: poll the returned import for its progress and the errors of the rows not imported.
+// @Tags user-imports
+// @Accept plain
+// @Produce json
+// @Param format query string false "csv (default) or jsonl"
+// @Param dry_run query bool false "Only validate the rows, without creating any user"
+// @Success 202 {object} UserImportResponse "Queued import"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 413 {object} map[string]string "File too large"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /import [post]
+func (h *UserImportHandler) Import(c echo.Context) error {
+	dryRun, err := queryBool(c, "dry_run")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// Read one byte past the limit to tell a file of the maximum size from a larger one
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxUserFileSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if len(data) > maxUserFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("The file cannot be larger than %d MiB", maxUserFileSize>>20)})
+	}
+
+	input := dto.ImportUsersInput{
+		Data:   data,
+		Format: userFileFormatParam(c),
+		DryRun: dryRun != nil && *dryRun,
+	}
+
+	userImport, err := h.userImportUseCase.Import(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/admin/users/imports/%d", userImport.ID))
+	return c.JSON(http.StatusAccepted, toUserImportResponse(userImport))
+}
+
+// GetImport handles getting a user import
+// @Summary Get a user import
+// @Description Get the status and progress of a user import, with the errors of the rows not imported
+// @Tags user-imports
+// @Accept json
+// @Produce json
+// @Param id path int true "Import ID"
+// @Success 200 {object} UserImportResponse "User import"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "User import not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /imports/{id} [get]
+func (h *UserImportHandler) GetImport(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid import ID"})
+	}
+
+	userImport, err := h.userImportUseCase.GetByID(c.Request().Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, entity.ErrUserImportNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, toUserImportResponse(userImport))
+}
+
+// Export handles exporting users
+// @Summary Export users
+// @Description Stream every user matching the filters of the list of users as CSV or JSONL, without their password
+// @Tags user-imports
+// @Accept json
+// @Produce plain
+// @Param format query string false "csv (default) or jsonl"
+// @Param role query string false "Only export users with this role"
+// @Param active query bool false "Only export active or inactive users"
+// @Param created_from query string false "Only export users created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only export users created at or before this time (RFC 3339)"
+// @Param q query string false "Only export users whose username or email contains this text, ignoring case"
+// @Param sort query string false "Comma separated columns to sort by, prefixed with - for descending order: id, username, email, role, active, created_at, updated_at (default: id)"
+// @Success 200 {string} string "User file"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /export [get]
+func (h *UserImportHandler) Export(c echo.Context) error {
+	format := userFileFormatParam(c)
+	var contentType string
+	switch format {
+	case entity.UserFileFormatCSV:
+		contentType = "text/csv"
+	case entity.UserFileFormatJSONL:
+		contentType = "application/jsonl"
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported format %q, expected csv or jsonl", format)})
+	}
+
+	input, err := listUsersInput(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	resp := c.Response()
+	csvWriter := csv.NewWriter(resp)
+	encoder := json.NewEncoder(resp)
+
+	// The response starts with the first page, so that errors found before are still reported as such
+	started := false
+	write := func(users []*entity.User) error {
+		if !started {
+			started = true
+			resp.Header().Set(echo.HeaderContentType, contentType)
+			resp.Header().Set(echo.HeaderContentDisposition, "attachment; filename=users."+format)
+			resp.WriteHeader(http.StatusOK)
+			if format == entity.UserFileFormatCSV {
+				if err := csvWriter.Write(userExportColumns); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, user := range users {
+			if format == entity.UserFileFormatJSONL {
+				if err := encoder.Encode(toUserResponse(user)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			row := toUserResponse(user)
+			record := []string{strconv.FormatUint(uint64(row.ID), 10), row.Username, row.Email, row.Role, strconv.FormatBool(row.Active), row.CreatedAt, row.UpdatedAt}
+			if err := csvWriter.Write(record); err != nil {
+				return err
+			}
+		}
+
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		resp.Flush()
+		return nil
+	}
+
+	err = h.userUseCase.ExportUsers(c.Request().Context(), input, write)
+	if err != nil && !started {
+		if errors.Is(err, repository.ErrInvalidSort) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		// The status was sent already, the client sees a truncated file
+		log.Printf("User export aborted: %v", err)
+		return nil
+	}
+
+	// Nothing matched: an empty file, with its CSV header
+	if !started {
+		return write(nil)
+	}
+	return nil
+}
+
+// RegisterRoutes registers the user import and export routes and records them in the catalog
+func (h *UserImportHandler) RegisterRoutes(e *echo.Echo, catalog *middleware.RouteCatalog, middlewares ...echo.MiddlewareFunc) {
+	g := catalog.Group(e.Group("/v1/admin/users", middlewares...), auth.DomainDefault)
+
+	g.Add(http.MethodPost, "/import", h.Import, "users.import", "Import users from a CSV or JSONL file")
+	g.Add(http.MethodGet, "/imports/:id", h.GetImport, "users.imports.read", "Get the progress of a user import")
+	g.Add(http.MethodGet, "/export", h.Export, "users.export", "Export users as CSV or JSONL")
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestValidator validates request bodies against their validate struct tags
+type RequestValidator struct {
+	validate *validator.Validate
+}
+
+// NewRequestValidator creates a new RequestValidator, to be set as the validator of Echo.
+// Fields are reported under their JSON name.
+func NewRequestValidator() echo.Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+
+	return &RequestValidator{
+		validate: validate,
+	}
+}
+
+// Validate checks a request body, reporting the first invalid field
+func (v *RequestValidator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) && len(fieldErrs) > 0 {
+		return fieldError(fieldErrs[0])
+	}
+	return err
+}
+
+// fieldError describes why a field failed validation
+func fieldError(err validator.FieldError) error {
+	switch err.Tag() {
+	case "required":
+		return fmt.Errorf("%s is required", err.Field())
+	case "email":
+		return fmt.Errorf("%s must be a valid email", err.Field())
+	case "min":
+		return fmt.Errorf("%s must be at least %s characters long", err.Field(), err.Param())
+	case "oneof":
+		return fmt.Errorf("%s must be one of: %s", err.Field(), err.Param())
+	}
+	return fmt.Errorf("%s is invalid", err.Field())
+}
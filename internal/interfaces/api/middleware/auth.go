@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hinha/echo-casbin-ddd-app/internal/config"
 	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
@@ -10,11 +14,17 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// JWTMiddleware creates a JWT middleware
-func JWTMiddleware(config *config.Config) echo.MiddlewareFunc {
+// JWTMiddleware creates a JWT middleware, rejecting revoked tokens
+func JWTMiddleware(jwtService *auth.JWTService) echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
-		SigningKey:  []byte(config.JWT.Secret),
-		TokenLookup: "header:Authorization",
+		TokenLookup: "header:Authorization:Bearer ",
+		ParseTokenFunc: func(c echo.Context, token string) (interface{}, error) {
+			// Exposes the typed claims instead of the raw token
+			return jwtService.ValidateToken(c.Request().Context(), token)
+		},
+		SuccessHandler: func(c echo.Context) {
+			setActor(c)
+		},
 	})
 }
 
@@ -27,44 +37,70 @@ func APIKeyMiddleware(config *config.Config, apiKeyService *auth.APIKeyService)
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "API key is required"})
 			}
 
-			valid, _, err := apiKeyService.ValidateAPIKey(c.Request().Context(), apiKey)
+			client, err := apiKeyService.Authenticate(c.Request().Context(), apiKey)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-			}
-
-			if !valid {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid API key"})
+				return apiKeyErrorResponse(c, err)
 			}
 
+			c.Set(contextKeyAPIClient, client)
+			setActor(c)
 			return next(c)
 		}
 	}
 }
 
-// CasbinMiddleware creates a Casbin middleware
-func CasbinMiddleware(casbinService *auth.CasbinService) echo.MiddlewareFunc {
+// apiKeyErrorResponse maps an error authenticating an API key to a response. Keys that do not
+// authenticate an active API client are rejected alike, without telling why; other errors
+// are logged rather than returned.
+func apiKeyErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, auth.ErrInvalidAPIKey) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid API key"})
+	}
+	log.Printf("Failed to authenticate API key: %v", err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+}
+
+// CasbinMiddleware creates a Casbin middleware.
+// Conditional rules are evaluated against the principal, the client IP, the request time
+// and the owner of the resource fetched by the loader declared for the route, if any.
+// A missing resource is answered with 404 only to principals allowed whoever its owner.
+func CasbinMiddleware(casbinService *auth.CasbinService, loaders ResourceLoaders) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// Get user from JWT token
-			user := c.Get("user")
-			if user == nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-			}
-
-			// Extract username from JWT claims
-			claims, ok := user.(*auth.Claims)
+			// Get the authenticated user or API client
+			principal, ok := GetPrincipal(c)
 			if !ok {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 			}
 
 			// Get request path and method
 			path := c.Request().URL.Path
 			method := c.Request().Method
 
-			// Check if user has permission
-			allowed, err := casbinService.Enforce(claims.Username, "default", path, method)
+			// Load the targeted resource to know its owner
+			var ownerID, ownerRole string
+			resource, loaded, err := loaders.Load(c)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				log.Printf("Failed to load the resource of %s %s: %v", method, path, err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			}
+			if loaded {
+				if resource == nil {
+					return notFoundResponse(c, casbinService, principal, path, method)
+				}
+				if resource.OwnerID != 0 {
+					ownerID = strconv.FormatUint(uint64(resource.OwnerID), 10)
+				}
+				ownerRole = resource.OwnerRole
+			}
+
+			attrs := auth.NewRequestAttributes(principal.SubjectID(), ownerID, ownerRole, c.RealIP(), time.Now())
+
+			// Check if principal has permission
+			allowed, err := casbinService.Enforce(principal.Subject, principal.Domain, path, method, attrs)
+			if err != nil {
+				log.Printf("Failed to authorize %s for %s %s: %v", principal.Subject, method, path, err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 			}
 
 			if !allowed {
@@ -76,6 +112,23 @@ func CasbinMiddleware(casbinService *auth.CasbinService) echo.MiddlewareFunc {
 	}
 }
 
+// notFoundResponse answers a request for a missing resource, with 404 only when the principal
+// would be allowed regardless of the owner. Others are refused with 403 as for a resource they do
+// not own, so that they cannot tell which resources exist.
+func notFoundResponse(c echo.Context, casbinService *auth.CasbinService, principal *Principal, path, method string) error {
+	attrs := auth.NewRequestAttributes(principal.SubjectID(), "", "", c.RealIP(), time.Now())
+	allowed, err := casbinService.Enforce(principal.Subject, principal.Domain, path, method, attrs)
+	if err != nil {
+		log.Printf("Failed to authorize %s for %s %s: %v", principal.Subject, method, path, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	if !allowed {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Forbidden"})
+	}
+	return c.JSON(http.StatusNotFound, map[string]string{"error": "Resource not found"})
+}
+
 // ExtractTokenFromHeader extracts the token from the Authorization header
 func ExtractTokenFromHeader(header string) string {
 	parts := strings.Split(header, " ")
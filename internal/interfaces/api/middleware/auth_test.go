@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAPIKeyErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "unknown key", err: auth.ErrInvalidAPIKey, wantStatus: http.StatusUnauthorized, wantBody: "Invalid API key"},
+		{
+			name:       "inactive client",
+			err:        fmt.Errorf("%w: API client is inactive", auth.ErrInvalidAPIKey),
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "Invalid API key",
+		},
+		{
+			name:       "lookup failure",
+			err:        errors.New("dial tcp 10.0.0.5:5432: connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/v1/authz/batch-check", nil), rec)
+
+			if err := apiKeyErrorResponse(c, tt.err); err != nil {
+				t.Fatalf("apiKeyErrorResponse failed: %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tt.wantBody) || strings.Contains(body, tt.err.Error()) {
+				t.Errorf("body = %s, want %q without the error", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCasbinMiddlewareAPIClients(t *testing.T) {
+	cfg := &config.Config{Casbin: config.CasbinConfig{ModelPath: "../../../../casbin/model.conf"}}
+	service, err := auth.NewCasbinServiceFromPolicyFile(cfg, "../../../../casbin/policy.csv")
+	if err != nil {
+		t.Fatalf("failed to create Casbin service: %v", err)
+	}
+	rules := append(auth.APIClientPolicies("billing-service"), auth.APIClientPolicies("ops-console")...)
+	rules = append(rules, entity.PolicyRule{PType: "g", Values: []string{"ops-console", "client-admin", auth.DomainAPI}})
+	if err := service.ApplyPolicyDiff(context.Background(), entity.PolicyActionAdd, auth.PolicyDiff{Added: rules}); err != nil {
+		t.Fatalf("failed to add the client rules: %v", err)
+	}
+
+	tests := []struct {
+		client     string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{client: "billing-service", method: http.MethodGet, path: "/api/clients", wantStatus: http.StatusForbidden},
+		{client: "billing-service", method: http.MethodGet, path: "/api/clients/2", wantStatus: http.StatusForbidden},
+		{client: "billing-service", method: http.MethodPost, path: "/api/clients/2/regenerate-key", wantStatus: http.StatusForbidden},
+		{client: "ops-console", method: http.MethodGet, path: "/api/clients", wantStatus: http.StatusOK},
+		{client: "ops-console", method: http.MethodPost, path: "/api/clients/2/regenerate-key", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+		g := e.Group("/api/clients", func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.Set(contextKeyAPIClient, &entity.APIClient{ID: 1, Name: tt.client})
+				return next(c)
+			}
+		}, CasbinMiddleware(service, ResourceLoaders{}))
+		g.GET("", ok)
+		g.GET("/:id", ok)
+		g.POST("/:id/regenerate-key", ok)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s by %s: status = %d, want %d", tt.method, tt.path, tt.client, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestCasbinMiddlewareMissingResource(t *testing.T) {
+	cfg := &config.Config{Casbin: config.CasbinConfig{ModelPath: "../../../../casbin/model.conf"}}
+	service, err := auth.NewCasbinServiceFromPolicyFile(cfg, "../../../../casbin/policy.csv")
+	if err != nil {
+		t.Fatalf("failed to create Casbin service: %v", err)
+	}
+	rules := []entity.PolicyRule{
+		{PType: "g", Values: []string{"alice", "user", auth.DomainDefault}},
+		{PType: "g", Values: []string{"adam", "admin", auth.DomainDefault}},
+	}
+	if err := service.ApplyPolicyDiff(context.Background(), entity.PolicyActionAdd, auth.PolicyDiff{Added: rules}); err != nil {
+		t.Fatalf("failed to add the user rules: %v", err)
+	}
+
+	loaders := ResourceLoaders{}
+	loaders.Register(http.MethodGet, "/v1/users/:id", func(c echo.Context) (*Resource, error) { return nil, nil })
+
+	tests := []struct {
+		username   string
+		wantStatus int
+	}{
+		// Users only read their own account, so a missing one looks like someone else's
+		{username: "alice", wantStatus: http.StatusForbidden},
+		{username: "adam", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		e.GET("/v1/users/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+			func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					c.Set(contextKeyUser, &auth.Claims{UserID: 1, Username: tt.username})
+					return next(c)
+				}
+			}, CasbinMiddleware(service, loaders))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/999", nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET /v1/users/999 by %s: status = %d, want %d", tt.username, rec.Code, tt.wantStatus)
+		}
+	}
+}
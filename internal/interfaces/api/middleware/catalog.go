@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// CatalogRoute describes a route and the permission it requires
+type CatalogRoute struct {
+	Method string
+	// Path is the route template, which is the object Casbin rules grant, e.g. /v1/users/:id
+	Path string
+	// Permission is a stable name for the permission, e.g. users.update
+	Permission  string
+	Description string
+	// Domain is the Casbin domain the route is authorized in, empty when Casbin does not authorize it
+	Domain string
+}
+
+// Authorized reports whether the route is authorized by Casbin
+func (r CatalogRoute) Authorized() bool {
+	return r.Domain != ""
+}
+
+// RouteCatalog records every route of the API along with the permission it requires,
+// so that administrators know which objects and actions policies can grant
+type RouteCatalog struct {
+	mutex  sync.RWMutex
+	routes []CatalogRoute
+}
+
+// NewRouteCatalog creates a new RouteCatalog
+func NewRouteCatalog() *RouteCatalog {
+	return &RouteCatalog{}
+}
+
+// Group returns a route group recording its routes in the catalog. Routes are authorized in
+// domain by the Casbin middleware of the group, or not authorized by Casbin when domain is empty.
+func (c *RouteCatalog) Group(g *echo.Group, domain string) *CatalogGroup {
+	return &CatalogGroup{
+		group:   g,
+		catalog: c,
+		domain:  domain,
+	}
+}
+
+// Routes returns the routes of the catalog, sorted by path and method
+func (c *RouteCatalog) Routes() []CatalogRoute {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	routes := make([]CatalogRoute, len(c.routes))
+	copy(routes, c.routes)
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Ungranted returns the routes authorized by Casbin that no policy rule allows anyone to use
+func (c *RouteCatalog) Ungranted(casbinService *auth.CasbinService) ([]CatalogRoute, error) {
+	var ungranted []CatalogRoute
+	for _, route := range c.Routes() {
+		if !route.Authorized() {
+			continue
+		}
+
+		granted, err := casbinService.IsGranted(route.Domain, route.Path, route.Method)
+		if err != nil {
+			return nil, err
+		}
+		if !granted {
+			ungranted = append(ungranted, route)
+		}
+	}
+	return ungranted, nil
+}
+
+// add records a route
+func (c *RouteCatalog) add(route CatalogRoute) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.routes = append(c.routes, route)
+}
+
+// CatalogGroup registers routes on an Echo group and records them in a catalog
+type CatalogGroup struct {
+	group   *echo.Group
+	catalog *RouteCatalog
+	domain  string
+}
+
+// Add registers a route with its own middlewares, if any, and records it in the catalog under a permission name
+func (g *CatalogGroup) Add(method, path string, handler echo.HandlerFunc, permission, description string, middlewares ...echo.MiddlewareFunc) *echo.Route {
+	route := g.group.Add(method, path, handler, middlewares...)
+
+	g.catalog.add(CatalogRoute{
+		Method:      method,
+		Path:        route.Path,
+		Permission:  permission,
+		Description: description,
+		Domain:      g.domain,
+	})
+
+	return route
+}
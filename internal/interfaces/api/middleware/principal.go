@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/config"
+	"github.com/hinha/echo-casbin-ddd-app/internal/domain/entity"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// Context keys under which the authentication middlewares store the caller
+const (
+	contextKeyUser      = "user"
+	contextKeyAPIClient = "api_client"
+)
+
+// HeaderImpersonatedBy is the response header naming the user impersonating the authenticated one
+const HeaderImpersonatedBy = "X-Impersonated-By"
+
+// Principal types
+const (
+	PrincipalTypeUser      = "user"
+	PrincipalTypeAPIClient = "api_client"
+)
+
+// Principal represents the authenticated caller of a request
+type Principal struct {
+	Type    string
+	ID      uint
+	Subject string // Casbin subject
+	Domain  string // Casbin domain
+	// Impersonator is the username of the user acting as this one, empty unless impersonated
+	Impersonator   string
+	ImpersonatorID uint
+}
+
+// GetPrincipal returns the user or API client authenticated for the request
+func GetPrincipal(c echo.Context) (*Principal, bool) {
+	if claims, ok := c.Get(contextKeyUser).(*auth.Claims); ok {
+		principal := &Principal{
+			Type:    PrincipalTypeUser,
+			ID:      claims.UserID,
+			Subject: claims.Username,
+			Domain:  auth.DomainDefault,
+		}
+		if claims.IsImpersonated() {
+			principal.Impersonator = claims.Act.Subject
+			principal.ImpersonatorID = claims.Act.UserID
+		}
+		return principal, true
+	}
+
+	if client, ok := c.Get(contextKeyAPIClient).(*entity.APIClient); ok {
+		return &Principal{
+			Type:    PrincipalTypeAPIClient,
+			ID:      client.ID,
+			Subject: client.Name,
+			Domain:  auth.DomainAPI,
+		}, true
+	}
+
+	return nil, false
+}
+
+// SubjectID returns the ID of the principal as compared with resource owners in policy conditions.
+// API clients are never resource owners, so their IDs are prefixed to keep them apart from user IDs.
+func (p *Principal) SubjectID() string {
+	id := strconv.FormatUint(uint64(p.ID), 10)
+	if p.Type == PrincipalTypeUser {
+		return id
+	}
+	return p.Type + ":" + id
+}
+
+// IsImpersonated reports whether another user is acting as the principal
+func (p *Principal) IsImpersonated() bool {
+	return p.Impersonator != ""
+}
+
+// Actor returns the identifier recorded for changes made by the principal.
+// Changes made while impersonating a user are recorded against the real actor.
+func (p *Principal) Actor() string {
+	if p.IsImpersonated() {
+		return PrincipalTypeUser + ":" + p.Impersonator
+	}
+	return p.Type + ":" + p.Subject
+}
+
+// setActor records the authenticated principal as the actor in the request context.
+// Requests of impersonated sessions are logged and marked in the response.
+func setActor(c echo.Context) {
+	principal, ok := GetPrincipal(c)
+	if !ok {
+		return
+	}
+
+	ctx := auth.WithActor(c.Request().Context(), principal.Actor())
+	if principal.IsImpersonated() {
+		ctx = auth.WithImpersonated(ctx, principal.Subject)
+	}
+	c.SetRequest(c.Request().WithContext(ctx))
+
+	if principal.IsImpersonated() {
+		c.Response().Header().Set(HeaderImpersonatedBy, principal.Impersonator)
+		log.Printf("Impersonation: %s acting as %s: %s %s", principal.Impersonator, principal.Subject, c.Request().Method, c.Request().URL.Path)
+	}
+}
+
+// ForbidImpersonation rejects requests made while impersonating a user, for sensitive actions
+// only the account holder may take, such as changing their password
+func ForbidImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if principal, ok := GetPrincipal(c); ok && principal.IsImpersonated() {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "Not allowed while impersonating a user"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// AuthenticateMiddleware creates a middleware that accepts either a JWT bearer token
+// or an API key, so the same route can serve users and API clients
+func AuthenticateMiddleware(config *config.Config, jwtService *auth.JWTService, apiKeyService *auth.APIKeyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token := ExtractTokenFromHeader(c.Request().Header.Get("Authorization")); token != "" {
+				claims, err := jwtService.ValidateToken(c.Request().Context(), token)
+				if err != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+				}
+
+				c.Set(contextKeyUser, claims)
+				setActor(c)
+				return next(c)
+			}
+
+			if apiKey := c.Request().Header.Get(config.APIKey.HeaderName); apiKey != "" {
+				client, err := apiKeyService.Authenticate(c.Request().Context(), apiKey)
+				if err != nil {
+					return apiKeyErrorResponse(c, err)
+				}
+
+				c.Set(contextKeyAPIClient, client)
+				setActor(c)
+				return next(c)
+			}
+
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		}
+	}
+}
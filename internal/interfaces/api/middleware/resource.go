@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Resource holds the attributes of the resource targeted by a request
+type Resource struct {
+	// OwnerID is the ID of the user owning the resource, 0 when it has no owner
+	OwnerID uint
+	// OwnerRole is the highest effective role of the user owning the resource, if known
+	OwnerRole string
+}
+
+// ResourceLoader fetches the resource targeted by a request before it is authorized.
+// It returns a nil resource when the resource does not exist.
+type ResourceLoader func(c echo.Context) (*Resource, error)
+
+// ResourceLoaders maps routes to the loader of the resource they target.
+// Handlers declare their loaders so that ownership can be checked before the handler runs.
+type ResourceLoaders map[string]ResourceLoader
+
+// Register declares the loader of the resource targeted by a route, e.g. PUT /v1/users/:id
+func (l ResourceLoaders) Register(method, path string, loader ResourceLoader) {
+	l[method+" "+path] = loader
+}
+
+// Load runs the loader declared for the matched route of the request, if any
+func (l ResourceLoaders) Load(c echo.Context) (*Resource, bool, error) {
+	loader, ok := l[c.Request().Method+" "+c.Path()]
+	if !ok {
+		return nil, false, nil
+	}
+
+	resource, err := loader(c)
+	if err != nil {
+		return nil, true, err
+	}
+	return resource, true, nil
+}
+
+// OwnerFromParam creates a loader for resources owned by the user whose ID is in a route parameter.
+// An invalid ID leaves the owner unknown and is left for the handler to reject.
+func OwnerFromParam(name string) ResourceLoader {
+	return func(c echo.Context) (*Resource, error) {
+		id, err := strconv.ParseUint(c.Param(name), 10, 32)
+		if err != nil {
+			return &Resource{}, nil
+		}
+		return &Resource{OwnerID: uint(id)}, nil
+	}
+}
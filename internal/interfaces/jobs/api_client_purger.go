@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// APIClientPurger periodically purges the deleted API clients past their trash retention
+type APIClientPurger struct {
+	apiClientUseCase interfaces.APIClientUseCase
+	interval         time.Duration
+
+	shutdown chan struct{}
+	done     sync.WaitGroup
+}
+
+// NewAPIClientPurger creates a new APIClientPurger running at the given interval
+func NewAPIClientPurger(apiClientUseCase interfaces.APIClientUseCase, interval time.Duration) *APIClientPurger {
+	return &APIClientPurger{
+		apiClientUseCase: apiClientUseCase,
+		interval:         interval,
+		shutdown:         make(chan struct{}),
+	}
+}
+
+// Start purges expired clients right away, then at every interval
+func (p *APIClientPurger) Start() {
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.run()
+
+			select {
+			case <-ticker.C:
+			case <-p.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// run purges the clients past their retention
+func (p *APIClientPurger) run() {
+	ctx := auth.WithActor(context.Background(), auth.ActorSystem)
+
+	output, err := p.apiClientUseCase.PurgeExpired(ctx)
+	if err != nil {
+		log.Printf("Failed to purge deleted API clients: %v", err)
+	}
+	if output != nil && output.Purged > 0 {
+		log.Printf("Deleted API clients purged: %d", output.Purged)
+	}
+}
+
+// Stop stops the purger and waits for the current run to finish
+func (p *APIClientPurger) Stop() {
+	close(p.shutdown)
+	p.done.Wait()
+}
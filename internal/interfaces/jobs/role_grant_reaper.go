@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// RoleGrantReaper periodically activates role grants whose start time has come
+// and removes the roles of grants past their expiry
+type RoleGrantReaper struct {
+	roleGrantUseCase interfaces.RoleGrantUseCase
+	interval         time.Duration
+
+	shutdown chan struct{}
+	done     sync.WaitGroup
+}
+
+// NewRoleGrantReaper creates a new RoleGrantReaper running at the given interval
+func NewRoleGrantReaper(roleGrantUseCase interfaces.RoleGrantUseCase, interval time.Duration) *RoleGrantReaper {
+	return &RoleGrantReaper{
+		roleGrantUseCase: roleGrantUseCase,
+		interval:         interval,
+		shutdown:         make(chan struct{}),
+	}
+}
+
+// Start processes due grants right away, then at every interval
+func (r *RoleGrantReaper) Start() {
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.run()
+
+			select {
+			case <-ticker.C:
+			case <-r.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// run processes the grants that are due
+func (r *RoleGrantReaper) run() {
+	ctx := auth.WithActor(context.Background(), auth.ActorSystem)
+
+	output, err := r.roleGrantUseCase.ProcessDue(ctx)
+	if err != nil {
+		log.Printf("Failed to process role grants: %v", err)
+	}
+	if output != nil && (output.Activated > 0 || output.Expired > 0) {
+		log.Printf("Role grants processed: %d activated, %d expired", output.Activated, output.Expired)
+	}
+}
+
+// Stop stops the reaper and waits for the current run to finish
+func (r *RoleGrantReaper) Stop() {
+	close(r.shutdown)
+	r.done.Wait()
+}
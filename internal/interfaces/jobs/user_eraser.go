@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+	"github.com/hinha/echo-casbin-ddd-app/internal/infrastructure/auth"
+)
+
+// UserEraser periodically erases the users whose erasure requests are due
+type UserEraser struct {
+	privacyUseCase interfaces.PrivacyUseCase
+	interval       time.Duration
+
+	shutdown chan struct{}
+	done     sync.WaitGroup
+}
+
+// NewUserEraser creates a new UserEraser running at the given interval
+func NewUserEraser(privacyUseCase interfaces.PrivacyUseCase, interval time.Duration) *UserEraser {
+	return &UserEraser{
+		privacyUseCase: privacyUseCase,
+		interval:       interval,
+		shutdown:       make(chan struct{}),
+	}
+}
+
+// Start processes due erasure requests right away, then at every interval
+func (e *UserEraser) Start() {
+	e.done.Add(1)
+	go func() {
+		defer e.done.Done()
+
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			e.run()
+
+			select {
+			case <-ticker.C:
+			case <-e.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// run erases the users whose requests are due
+func (e *UserEraser) run() {
+	ctx := auth.WithActor(context.Background(), auth.ActorSystem)
+
+	output, err := e.privacyUseCase.ProcessDue(ctx)
+	if err != nil {
+		log.Printf("Failed to process erasure requests: %v", err)
+	}
+	if output != nil && output.Erased > 0 {
+		log.Printf("Erasure requests processed: %d users erased", output.Erased)
+	}
+}
+
+// Stop stops the eraser and waits for the current run to finish
+func (e *UserEraser) Stop() {
+	close(e.shutdown)
+	e.done.Wait()
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hinha/echo-casbin-ddd-app/internal/application/interfaces"
+)
+
+// UserImporter periodically processes the queued user imports, one at a time
+type UserImporter struct {
+	userImportUseCase interfaces.UserImportUseCase
+	interval          time.Duration
+
+	// ctx is canceled on shutdown, interrupting the import being processed
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewUserImporter creates a new UserImporter checking for queued imports at the given interval
+func NewUserImporter(userImportUseCase interfaces.UserImportUseCase, interval time.Duration) *UserImporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &UserImporter{
+		userImportUseCase: userImportUseCase,
+		interval:          interval,
+		ctx:               ctx,
+		cancel:            cancel,
+	}
+}
+
+// Start processes queued imports right away, then at every interval
+func (i *UserImporter) Start() {
+	i.done.Add(1)
+	go func() {
+		defer i.done.Done()
+
+		ticker := time.NewTicker(i.interval)
+		defer ticker.Stop()
+
+		for {
+			i.run()
+
+			select {
+			case <-ticker.C:
+			case <-i.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// run processes the queued imports until there is none left
+func (i *UserImporter) run() {
+	for i.ctx.Err() == nil {
+		userImport, err := i.userImportUseCase.ProcessNext(i.ctx)
+		if err != nil {
+			if i.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to process user import: %v", err)
+			return
+		}
+		if userImport == nil {
+			return
+		}
+	}
+}
+
+// Stop stops the importer, putting the import being processed back in the queue
+func (i *UserImporter) Stop() {
+	i.cancel()
+	i.done.Wait()
+}
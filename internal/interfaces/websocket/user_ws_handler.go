@@ -132,8 +132,8 @@ func (h *UserWSHandler) run() {
 // sendUserList sends the user list to a client
 func (h *UserWSHandler) sendUserList(client *websocket.Conn) {
 	input := dto.ListUsersInput{
-		Page:  1,
-		Limit: 100,
+		Limit:     100,
+		SkipCount: true,
 	}
 
 	output, err := h.userUseCase.ListUsers(context.Background(), input)
@@ -173,8 +173,8 @@ func (h *UserWSHandler) sendUserList(client *websocket.Conn) {
 // BroadcastUserUpdate broadcasts a user update to all clients
 func (h *UserWSHandler) BroadcastUserUpdate() {
 	input := dto.ListUsersInput{
-		Page:  1,
-		Limit: 100,
+		Limit:     100,
+		SkipCount: true,
 	}
 
 	output, err := h.userUseCase.ListUsers(context.Background(), input)
@@ -0,0 +1,67 @@
+// Package imaging resizes images without dependencies beyond the standard library
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Thumbnail crops the largest centered square of an image and scales it to size x size pixels.
+// Each pixel of the thumbnail averages the pixels it covers in the source, so that downscaling
+// does not alias; upscaling repeats source pixels.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	// Averaging is done on premultiplied colors, so that transparent pixels do not darken edges
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), src, crop.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if side == 0 {
+		return dst
+	}
+
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, side, size)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, side, size)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := square.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					pix := square.Pix[offset : offset+4 : offset+4]
+					r += uint64(pix[0])
+					g += uint64(pix[1])
+					b += uint64(pix[2])
+					a += uint64(pix[3])
+					n++
+					offset += 4
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span returns the range of source pixels covered by a destination pixel, along one axis
+// of a source of n pixels scaled to size pixels. The range holds at least one pixel.
+func span(i, n, size int) (int, int) {
+	start := i * n / size
+	end := (i + 1) * n / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
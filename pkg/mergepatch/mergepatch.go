@@ -0,0 +1,30 @@
+// Package mergepatch applies JSON merge patches (RFC 7396) to decoded JSON documents
+package mergepatch
+
+// Apply returns the document resulting from applying a merge patch to a target, both decoded
+// JSON objects. Members of the patch replace those of the target, objects being merged recursively,
+// and null members remove them. Neither the target nor the patch is modified.
+func Apply(target, patch map[string]any) map[string]any {
+	result := make(map[string]any, len(target)+len(patch))
+	for name, value := range target {
+		result[name] = value
+	}
+
+	for name, value := range patch {
+		if value == nil {
+			delete(result, name)
+			continue
+		}
+
+		patchObject, ok := value.(map[string]any)
+		if !ok {
+			result[name] = value
+			continue
+		}
+		// A member that is not an object is replaced as a whole
+		targetObject, _ := result[name].(map[string]any)
+		result[name] = Apply(targetObject, patchObject)
+	}
+
+	return result
+}
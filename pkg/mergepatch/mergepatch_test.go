@@ -0,0 +1,34 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// The examples of RFC 7396 appendix A whose target and patch are objects
+	tests := []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	decode := func(document string) map[string]any {
+		var decoded map[string]any
+		if err := json.Unmarshal([]byte(document), &decoded); err != nil {
+			t.Fatalf("invalid JSON %s: %v", document, err)
+		}
+		return decoded
+	}
+
+	for _, tt := range tests {
+		if got := Apply(decode(tt.target), decode(tt.patch)); !reflect.DeepEqual(got, decode(tt.want)) {
+			t.Errorf("Apply(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}